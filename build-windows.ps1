@@ -2,7 +2,11 @@
 $env:CGO_ENABLED = "1"
 $env:GOOS = "windows"
 
-go build -o whatsapp-scheduler.exe .
+# Version for self-update is taken from the git tag (v1.2.3)
+$version = git describe --tags --always 2>$null
+if (-not $version) { $version = "dev" }
+
+go build -ldflags "-X whatsapp-scheduler/internal/update.Version=$version" -o whatsapp-scheduler.exe .
 
 if ($LASTEXITCODE -ne 0) {
     Write-Error "Build failed with exit code $LASTEXITCODE"
@@ -1,6 +1,9 @@
 #!/bin/bash
 
-go build -o whatsapp-scheduler
+# Версия для самообновления берется из git тега (v1.2.3)
+VERSION=$(git describe --tags --always 2>/dev/null || echo dev)
+
+go build -ldflags "-X whatsapp-scheduler/internal/update.Version=$VERSION" -o whatsapp-scheduler
 
 if [ $? -ne 0 ]; then
     echo "Сборка не удалась"
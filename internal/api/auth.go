@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+const (
+	// apiKeysEnv - ключи пользователей API через запятую в виде имя:ключ (alice:s3cret,bob:t0ken).
+	// Если они заданы, запросы к API принимаются только с ключом пользователя или администратора
+	apiKeysEnv = "WHATSAPP_SCHEDULER_API_KEYS"
+	// userContextKey - пользователь запроса в контексте gin
+	userContextKey = "api_user"
+)
+
+// publicPaths - веб-интерфейс и проверки Kubernetes доступны без ключа
+var publicPaths = map[string]bool{"/": true, "/healthz": true, "/readyz": true}
+
+// APIAuth - ключи доступа к API: ключ администратора и ключи пользователей
+type APIAuth struct {
+	adminKey string
+	// users - имя пользователя по ключу
+	users map[string]string
+}
+
+// LoadAPIAuth читает ключ администратора (WHATSAPP_SCHEDULER_ADMIN_KEY) и ключи пользователей
+// (WHATSAPP_SCHEDULER_API_KEYS). Без ключей пользователей API доступно без ключа, как раньше
+func LoadAPIAuth() (*APIAuth, error) {
+	auth := &APIAuth{adminKey: os.Getenv(adminKeyEnv), users: map[string]string{}}
+	names := map[string]bool{}
+	for _, entry := range strings.Split(os.Getenv(apiKeysEnv), ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, key, ok := strings.Cut(entry, ":")
+		name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+		if !ok || name == "" || key == "" {
+			return nil, fmt.Errorf("неверная запись %s '%s', пример: alice:s3cret", apiKeysEnv, entry)
+		}
+		if name == scheduler.AdminUser {
+			return nil, fmt.Errorf("имя '%s' в %s зарезервировано для ключа администратора %s", scheduler.AdminUser, apiKeysEnv, adminKeyEnv)
+		}
+		if names[name] || auth.users[key] != "" || key == auth.adminKey {
+			return nil, fmt.Errorf("повторяющееся имя или ключ пользователя '%s' в %s", name, apiKeysEnv)
+		}
+		names[name] = true
+		auth.users[key] = name
+	}
+	if len(auth.users) > 0 && auth.adminKey == "" {
+		return nil, fmt.Errorf("с ключами пользователей %s нужен ключ администратора %s", apiKeysEnv, adminKeyEnv)
+	}
+	return auth, nil
+}
+
+// enabled проверяет, что заданы ключи пользователей и API доступно только с ключом
+func (a *APIAuth) enabled() bool {
+	return a != nil && len(a.users) > 0
+}
+
+// identify возвращает пользователя ключа key: scheduler.AdminUser для ключа администратора
+func (a *APIAuth) identify(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	if subtle.ConstantTimeCompare([]byte(key), []byte(a.adminKey)) == 1 {
+		return scheduler.AdminUser, true
+	}
+	user := ""
+	for candidate, name := range a.users {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 {
+			user = name
+		}
+	}
+	return user, user != ""
+}
+
+// requestKey возвращает ключ из заголовка X-API-Key или Authorization: Bearer
+func requestKey(c *gin.Context) string {
+	if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return bearer
+	}
+	return c.GetHeader("X-API-Key")
+}
+
+// authenticate пропускает запросы с ключом пользователя или администратора и запоминает пользователя
+// запроса. Настройки и обслуживание (/admin/...) доступны только администратору.
+// Без ключей пользователей пропускает все запросы
+func authenticate(auth *APIAuth) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.enabled() || publicPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+		user, ok := auth.identify(requestKey(c))
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, errorBody(http.StatusUnauthorized, errInvalidAPIKey))
+			return
+		}
+		if user != scheduler.AdminUser && strings.HasPrefix(c.Request.URL.Path, "/admin/") {
+			c.AbortWithStatusJSON(http.StatusForbidden, errorBody(http.StatusForbidden, errAdminOnly))
+			return
+		}
+		c.Set(userContextKey, user)
+		c.Next()
+	}
+}
+
+// requestUser возвращает пользователя запроса, пусто - ключи пользователей не заданы
+func requestUser(c *gin.Context) string {
+	return c.GetString(userContextKey)
+}
+
+// setQuotaHeaders добавляет к ответу суточную квоту отправок пользователя запроса, если она задана:
+// X-Quota-Limit, X-Quota-Remaining и X-Quota-Reset (Unix-время восстановления квоты)
+func setQuotaHeaders(c *gin.Context, s *scheduler.Scheduler) scheduler.QuotaStatus {
+	quota := s.Quota(requestUser(c))
+	if quota.Limit > 0 {
+		c.Header("X-Quota-Limit", strconv.Itoa(quota.Limit))
+		c.Header("X-Quota-Remaining", strconv.Itoa(quota.Remaining()))
+		c.Header("X-Quota-Reset", strconv.FormatInt(quota.Reset.Unix(), 10))
+	}
+	return quota
+}
+
+// retryAfterQuota добавляет к ответу 429 заголовок Retry-After до восстановления квоты
+func retryAfterQuota(c *gin.Context, s *scheduler.Scheduler, quota scheduler.QuotaStatus) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(quota.Reset.Sub(s.Now()).Seconds()))))
+}
+
+// taskScope возвращает пользователя, задачами которого ограничен запрос: при isolate_tasks
+// пользователь без ключа администратора видит только свои задачи. Пусто - все задачи
+func taskScope(c *gin.Context, s *scheduler.Scheduler) string {
+	user := requestUser(c)
+	if user == "" || user == scheduler.AdminUser || !s.Settings().IsolateTasks {
+		return ""
+	}
+	return user
+}
+
+// requireTaskAccess отвечает 404 на запросы к задаче :id другого пользователя, см. taskScope
+func requireTaskAccess(s *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scope := taskScope(c, s); scope != "" && !s.TaskOwnedBy(c.Param("id"), scope) {
+			err := fmt.Errorf("%w: '%s'", scheduler.ErrTaskNotFound, c.Param("id"))
+			c.AbortWithStatusJSON(http.StatusNotFound, errorBody(http.StatusNotFound, err))
+			return
+		}
+		c.Next()
+	}
+}
+
+// grpcInterceptors возвращают перехватчики gRPC, пропускающие при заданных ключах пользователей
+// только вызовы с ключом администратора в метаданных x-api-key или authorization: Bearer.
+// Задачи и отправки gRPC не разделяются по пользователям, поэтому пользователям он недоступен
+func grpcInterceptors(auth *APIAuth) []grpc.ServerOption {
+	authorize := func(ctx context.Context) error {
+		if !auth.enabled() {
+			return nil
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		key := ""
+		if values := md.Get("x-api-key"); len(values) > 0 {
+			key = values[0]
+		}
+		if values := md.Get("authorization"); len(values) > 0 {
+			if bearer, ok := strings.CutPrefix(values[0], "Bearer "); ok {
+				key = bearer
+			}
+		}
+		user, ok := auth.identify(key)
+		if !ok {
+			return status.Error(codes.Unauthenticated, errInvalidAPIKey.Error())
+		}
+		if user != scheduler.AdminUser {
+			return status.Error(codes.PermissionDenied, errAdminOnly.Error())
+		}
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler) (interface{}, error) {
+			if err := authorize(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo,
+			handler grpc.StreamHandler) error {
+			if err := authorize(stream.Context()); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+const (
+	// digestChatEnv - чат WhatsApp оператора (например, собственный номер) для ежедневного дайджеста
+	digestChatEnv = "WHATSAPP_SCHEDULER_DIGEST_CHAT"
+	// digestEmailEnv - адрес электронной почты для ежедневного дайджеста
+	digestEmailEnv = "WHATSAPP_SCHEDULER_DIGEST_EMAIL"
+	// smtpAddrEnv - SMTP сервер host:port для отправки дайджеста на почту
+	smtpAddrEnv     = "WHATSAPP_SCHEDULER_SMTP_ADDR"
+	smtpUsernameEnv = "WHATSAPP_SCHEDULER_SMTP_USERNAME"
+	smtpPasswordEnv = "WHATSAPP_SCHEDULER_SMTP_PASSWORD"
+	// smtpFromEnv - адрес отправителя, по умолчанию имя пользователя SMTP
+	smtpFromEnv = "WHATSAPP_SCHEDULER_SMTP_FROM"
+)
+
+// digestRecipients - получатели ежедневного дайджеста
+type digestRecipients struct {
+	chatName     string
+	email        string
+	smtpAddr     string
+	smtpUsername string
+	smtpPassword string
+	smtpFrom     string
+}
+
+// digestRecipientsFromEnv читает получателей дайджеста из переменных окружения
+func digestRecipientsFromEnv() (digestRecipients, error) {
+	d := digestRecipients{
+		chatName:     strings.TrimSpace(os.Getenv(digestChatEnv)),
+		email:        strings.TrimSpace(os.Getenv(digestEmailEnv)),
+		smtpAddr:     os.Getenv(smtpAddrEnv),
+		smtpUsername: os.Getenv(smtpUsernameEnv),
+		smtpPassword: os.Getenv(smtpPasswordEnv),
+		smtpFrom:     os.Getenv(smtpFromEnv),
+	}
+	if d.smtpFrom == "" {
+		d.smtpFrom = d.smtpUsername
+	}
+	if d.email != "" {
+		if _, _, err := net.SplitHostPort(d.smtpAddr); err != nil {
+			return d, fmt.Errorf("для отправки дайджеста на почту требуется %s в формате host:port", smtpAddrEnv)
+		}
+		if d.smtpFrom == "" {
+			return d, fmt.Errorf("для отправки дайджеста на почту требуется %s или %s", smtpFromEnv, smtpUsernameEnv)
+		}
+	}
+	return d, nil
+}
+
+// send отправляет дайджест в чат WhatsApp оператора и на почту, если они настроены
+func (d digestRecipients) send(s *scheduler.Scheduler, text string) {
+	if d.chatName != "" {
+		if err := s.Deliver(context.Background(), scheduler.OutgoingMessage{ChatName: d.chatName, Message: text}); err != nil {
+			logger.Errorf("❌ Ошибка отправки дайджеста в чат '%s': %v", d.chatName, err)
+		}
+	}
+	if d.email != "" {
+		if err := d.sendEmail(text); err != nil {
+			logger.Errorf("❌ Ошибка отправки дайджеста на %s: %v", d.email, err)
+		}
+	}
+}
+
+// sendEmail отправляет дайджест письмом через SMTP
+func (d digestRecipients) sendEmail(text string) error {
+	host, _, _ := net.SplitHostPort(d.smtpAddr)
+	var auth smtp.Auth
+	if d.smtpUsername != "" {
+		auth = smtp.PlainAuth("", d.smtpUsername, d.smtpPassword, host)
+	}
+
+	subject := "WhatsApp Scheduler: сводка за " + time.Now().Format("02.01.2006")
+	message := strings.Join([]string{
+		"From: " + d.smtpFrom,
+		"To: " + d.email,
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		strings.ReplaceAll(text, "\n", "\r\n"),
+	}, "\r\n")
+	return smtp.SendMail(d.smtpAddr, auth, d.smtpFrom, []string{d.email}, []byte(message))
+}
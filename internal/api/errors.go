@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"whatsapp-scheduler/pkg/scheduler"
+	"whatsapp-scheduler/pkg/whatsapp"
+)
+
+// errInvalidJSON - тело запроса не разобрано
+var errInvalidJSON = errors.New("Ошибка парсинга JSON")
+
+// errEmptyTag - в фильтре или групповой операции указана пустая метка
+var errEmptyTag = errors.New("пустая метка задачи")
+
+// adminKeyEnv - ключ администратора для опасных операций (POST /admin/update)
+const adminKeyEnv = "WHATSAPP_SCHEDULER_ADMIN_KEY"
+
+// errAdminKeyNotSet - операция требует ключа администратора, а он не задан
+var errAdminKeyNotSet = errors.New("операция отключена без ключа администратора")
+
+// errInvalidAdminKey - ключ администратора не передан или неверен
+var errInvalidAdminKey = errors.New("неверный ключ администратора")
+
+// errInvalidAPIKey - ключ пользователя API не передан или неверен
+var errInvalidAPIKey = errors.New("неверный ключ API")
+
+// errAdminOnly - операция доступна только с ключом администратора
+var errAdminOnly = errors.New("операция доступна только администратору")
+
+// errTaskOfOtherUser - активная задача принадлежит другому пользователю и не может быть заменена
+var errTaskOfOtherUser = errors.New("активная задача принадлежит другому пользователю")
+
+// errWhatsAppNotStarted - клиент WhatsApp не запущен (режим --mock)
+var errWhatsAppNotStarted = errors.New("клиент WhatsApp не запущен")
+
+// statusErrorCodes - коды ошибок, не распознанных по типу, по HTTP статусу ответа
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:            scheduler.ErrorCodeInvalidRequest,
+	http.StatusUnauthorized:          scheduler.ErrorCodeNotAuthorized,
+	http.StatusForbidden:             scheduler.ErrorCodeNotAuthorized,
+	http.StatusNotFound:              scheduler.ErrorCodeNotFound,
+	http.StatusConflict:              scheduler.ErrorCodeConflict,
+	http.StatusRequestEntityTooLarge: scheduler.ErrorCodeInvalidRequest,
+	http.StatusUnsupportedMediaType:  scheduler.ErrorCodeInvalidRequest,
+	http.StatusUnprocessableEntity:   scheduler.ErrorCodeInvalidRequest,
+	http.StatusTooManyRequests:       scheduler.ErrorCodeRateLimited,
+	http.StatusServiceUnavailable:    scheduler.ErrorCodeUnavailable,
+}
+
+// errorCode возвращает стабильный код ошибки err для ответа со статусом status
+func errorCode(status int, err error) string {
+	switch {
+	case errors.Is(err, errInvalidJSON):
+		return scheduler.ErrorCodeInvalidJSON
+	case errors.Is(err, whatsapp.ErrNotAuthorized):
+		return scheduler.ErrorCodeNotAuthorized
+	}
+	if code := scheduler.ErrorCode(err); code != "" {
+		return code
+	}
+	if code, ok := statusErrorCodes[status]; ok {
+		return code
+	}
+	return scheduler.ErrorCodeInternal
+}
+
+// errorBody возвращает тело ответа с ошибкой: код для программ, текст для людей и признак того,
+// что запрос можно повторить позже
+func errorBody(status int, err error) gin.H {
+	return gin.H{"error": err.Error(), "code": errorCode(status, err), "retryable": scheduler.IsRetryable(err)}
+}
+
+// respondError отвечает ошибкой err со статусом status
+func respondError(c *gin.Context, status int, err error) {
+	c.JSON(status, errorBody(status, err))
+}
@@ -0,0 +1,253 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"whatsapp-scheduler/pkg/scheduler"
+	"whatsapp-scheduler/proto/schedulerpb"
+)
+
+//go:generate protoc -I ../../proto --go_out=../../proto/schedulerpb --go_opt=paths=source_relative --go-grpc_out=../../proto/schedulerpb --go-grpc_opt=paths=source_relative scheduler.proto
+
+// Статусы SendResult в дополнение к статусам истории
+const (
+	sendResultScheduled = "scheduled"
+	sendResultInvalid   = "invalid"
+)
+
+// grpcServer реализует gRPC API планировщика поверх тех же методов, что и HTTP API
+type grpcServer struct {
+	schedulerpb.UnimplementedSchedulerServer
+	scheduler *scheduler.Scheduler
+}
+
+// StartGRPCServer запускает gRPC сервер на указанном порту. При заданных ключах пользователей
+// вызовы принимаются только с ключом администратора, см. LoadAPIAuth
+func StartGRPCServer(s *scheduler.Scheduler, port int, auth *APIAuth) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("ошибка запуска gRPC сервера: %v", err)
+	}
+
+	server := grpc.NewServer(grpcInterceptors(auth)...)
+	schedulerpb.RegisterSchedulerServer(server, &grpcServer{scheduler: s})
+
+	go func() {
+		logger.Infof("gRPC сервер запущен на порту %d", port)
+		if err := server.Serve(listener); err != nil {
+			logger.Errorf("Ошибка gRPC сервера: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (g *grpcServer) ListTasks(ctx context.Context, req *schedulerpb.ListTasksRequest) (*schedulerpb.ListTasksResponse, error) {
+	tasks := g.scheduler.ListTasks()
+	response := &schedulerpb.ListTasksResponse{Tasks: make([]*schedulerpb.Task, 0, len(tasks))}
+	for _, task := range tasks {
+		response.Tasks = append(response.Tasks, taskToProto(task))
+	}
+	return response, nil
+}
+
+func (g *grpcServer) ScheduleTask(ctx context.Context, req *schedulerpb.Task) (*schedulerpb.Task, error) {
+	task := scheduler.NewTaskFromRequest(taskFromProto(req))
+	if _, err := g.scheduler.AddTask(task); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "ошибка при добавлении задачи: %v", err)
+	}
+	return taskToProto(task), nil
+}
+
+func (g *grpcServer) StopTask(ctx context.Context, req *schedulerpb.StopTaskRequest) (*schedulerpb.StopTaskResponse, error) {
+	if !g.scheduler.StopTask(req.GetId()) {
+		return nil, status.Error(codes.NotFound, "задача не найдена")
+	}
+	return &schedulerpb.StopTaskResponse{}, nil
+}
+
+func (g *grpcServer) Send(ctx context.Context, req *schedulerpb.SendRequest) (*schedulerpb.SendResult, error) {
+	result := g.send(ctx, req)
+	switch result.Status {
+	case sendResultInvalid:
+		return nil, status.Error(codes.InvalidArgument, result.Error)
+	case scheduler.HistoryStatusRateLimited:
+		return nil, status.Error(codes.ResourceExhausted, result.Error)
+	case scheduler.HistoryStatusFailed:
+		return nil, status.Error(codes.Unavailable, result.Error)
+	}
+	return result, nil
+}
+
+// SendStream обрабатывает запросы по очереди, ошибка отдельной отправки не прерывает поток
+func (g *grpcServer) SendStream(stream schedulerpb.Scheduler_SendStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(g.send(stream.Context(), req)); err != nil {
+			return err
+		}
+	}
+}
+
+func (g *grpcServer) SubscribeEvents(req *schedulerpb.SubscribeEventsRequest, stream schedulerpb.Scheduler_SubscribeEventsServer) error {
+	events, unsubscribe := g.scheduler.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case evt := <-events:
+			if len(req.GetKinds()) > 0 && !slices.Contains(req.GetKinds(), evt.Kind) {
+				continue
+			}
+			if err := stream.Send(eventToProto(evt)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// send выполняет (или откладывает) разовую отправку и возвращает ее результат
+func (g *grpcServer) send(ctx context.Context, req *schedulerpb.SendRequest) *schedulerpb.SendResult {
+	result := &schedulerpb.SendResult{RequestId: req.GetRequestId(), ChatName: req.GetChatName()}
+
+	sendReq := sendRequestFromProto(req)
+	if sendReq.Timezone == "" {
+		sendReq.Timezone = g.scheduler.Settings().DefaultTimezone
+	}
+	msg, scheduledAt, err := scheduler.PrepareSend(sendReq, g.scheduler.Now())
+	if err != nil {
+		result.Status = sendResultInvalid
+		result.Error = err.Error()
+		return result
+	}
+	result.ChatName = msg.ChatName
+
+	if scheduledAt != nil {
+		if _, err := g.scheduler.ScheduleOneOff(msg, *scheduledAt); err != nil {
+			result.Status = scheduler.SendStatus(err)
+			result.Error = err.Error()
+			return result
+		}
+		result.Status = sendResultScheduled
+		result.ScheduledAt = timestamppb.New(*scheduledAt)
+		return result
+	}
+
+	err = g.scheduler.Deliver(ctx, msg)
+	result.Status = scheduler.SendStatus(err)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// sendRequestFromProto преобразует gRPC запрос в запрос POST /send
+func sendRequestFromProto(req *schedulerpb.SendRequest) scheduler.SendRequest {
+	send := scheduler.SendRequest{
+		ChatName:           req.GetChatName(),
+		Message:            req.GetMessage(),
+		When:               req.GetWhen(),
+		Timezone:           req.GetTimezone(),
+		DisableLinkPreview: req.GetDisableLinkPreview(),
+		Format:             req.GetFormat(),
+	}
+	if media := req.GetMedia(); media != nil {
+		send.Media = &scheduler.MediaAttachment{
+			MimeType: media.GetMimeType(),
+			FileName: media.GetFileName(),
+			MediaID:  media.GetMediaId(),
+			URL:      media.GetUrl(),
+		}
+		if len(media.GetData()) > 0 {
+			send.Media.Data = base64.StdEncoding.EncodeToString(media.GetData())
+		}
+	}
+	if req.GetScheduledAt() != nil {
+		at := req.GetScheduledAt().AsTime()
+		send.ScheduledAt = &at
+	}
+	return send
+}
+
+// taskFromProto преобразует gRPC задачу в задачу планировщика
+func taskFromProto(task *schedulerpb.Task) *scheduler.ScheduledTask {
+	result := &scheduler.ScheduledTask{
+		ChatName:           task.GetChatName(),
+		Message:            task.GetMessage(),
+		Interval:           int(task.GetInterval()),
+		RandomDelay:        int(task.GetRandomDelay()),
+		MessageCommand:     task.GetMessageCommand(),
+		ExcludedDates:      task.GetExcludedDates(),
+		HolidayCalendars:   task.GetHolidayCalendars(),
+		JitterSeconds:      int(task.GetJitterSeconds()),
+		DisableLinkPreview: task.GetDisableLinkPreview(),
+		SplitLongMessages:  task.GetSplitLongMessages(),
+		SplitDelaySeconds:  int(task.GetSplitDelaySeconds()),
+		Format:             task.GetFormat(),
+		MediaID:            task.GetMediaId(),
+		MediaURL:           task.GetMediaUrl(),
+		DisappearingTimer:  task.GetDisappearingTimer(),
+	}
+	if task.GetStartTime() != nil {
+		result.StartTime = task.GetStartTime().AsTime().In(time.Local)
+	}
+	if task.GetEndTime() != nil {
+		result.EndTime = task.GetEndTime().AsTime().In(time.Local)
+	}
+	return result
+}
+
+// taskToProto преобразует задачу планировщика в gRPC задачу
+func taskToProto(task *scheduler.ScheduledTask) *schedulerpb.Task {
+	return &schedulerpb.Task{
+		Id:                 task.ID,
+		ChatName:           task.ChatName,
+		Message:            task.Message,
+		Interval:           int32(task.Interval),
+		RandomDelay:        int32(task.RandomDelay),
+		StartTime:          timestamppb.New(task.StartTime),
+		EndTime:            timestamppb.New(task.EndTime),
+		MessageCommand:     task.MessageCommand,
+		ExcludedDates:      task.ExcludedDates,
+		HolidayCalendars:   task.HolidayCalendars,
+		JitterSeconds:      int32(task.JitterSeconds),
+		DisableLinkPreview: task.DisableLinkPreview,
+		SplitLongMessages:  task.SplitLongMessages,
+		SplitDelaySeconds:  int32(task.SplitDelaySeconds),
+		Format:             task.Format,
+		MediaId:            task.MediaID,
+		MediaUrl:           task.MediaURL,
+		DisappearingTimer:  task.DisappearingTimer,
+	}
+}
+
+// eventToProto преобразует событие планировщика в gRPC событие
+func eventToProto(evt scheduler.Event) *schedulerpb.Event {
+	return &schedulerpb.Event{
+		Kind:      evt.Kind,
+		TaskId:    evt.TaskID,
+		ChatName:  evt.ChatName,
+		Message:   evt.Message,
+		Status:    evt.Status,
+		Error:     evt.Error,
+		CreatedAt: timestamppb.New(evt.CreatedAt),
+	}
+}
@@ -0,0 +1,1704 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"rsc.io/qr"
+
+	"whatsapp-scheduler/internal/update"
+	"whatsapp-scheduler/pkg/scheduler"
+	"whatsapp-scheduler/pkg/whatsapp"
+)
+
+const (
+	// defaultStatsPeriod - период статистики задачи по умолчанию
+	defaultStatsPeriod = "7d"
+	// maxStatsPeriod - максимальный период статистики задачи
+	maxStatsPeriod = 90 * 24 * time.Hour
+)
+
+// adminSettings - тело GET/PUT /admin/settings: настройки планировщика и вебхуки уведомлений
+type adminSettings struct {
+	scheduler.Settings
+	NotificationSinks []NotificationSink `json:"notification_sinks"`
+}
+
+// NewRouter создает HTTP сервер с веб-интерфейсом и API планировщика.
+// bridge может быть nil, если MQTT не настроен. После установки обновления (POST /admin/update)
+// в restart отправляется запрос перезапуска; nil - перезапуск выполняет пользователь.
+// auth задает ключи доступа к API, см. LoadAPIAuth
+func NewRouter(s *scheduler.Scheduler, wa *whatsapp.Client, bridge *MQTTBridge, notifier *Notifier, restart chan<- struct{},
+	auth *APIAuth) *gin.Engine {
+	// Настройка Gin
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		// Логируем только ошибки и важные запросы, исключаем /tasks и частые проверки Kubernetes
+		if param.Path == "/healthz" || param.Path == "/readyz" {
+			return ""
+		}
+		if param.StatusCode >= 400 || (param.Path != "/tasks" && param.Method != "GET") {
+			return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %s\n",
+				param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+				param.StatusCode,
+				param.Latency,
+				param.ClientIP,
+				param.Method,
+				param.Path,
+			)
+		}
+		return ""
+	}), gin.Recovery(), authenticate(auth))
+
+	// Загрузка HTML шаблонов
+	r.LoadHTMLGlob("ui/*")
+	//r.Static("/static", "./static")
+
+	// Маршруты
+	r.GET("/", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "index.html", gin.H{
+			"title": "WhatsApp Scheduler",
+		})
+	})
+
+	r.GET("/qr", func(c *gin.Context) {
+		if wa == nil {
+			c.JSON(http.StatusOK, gin.H{"qr": "Клиент не инициализирован", "authorized": false})
+			return
+		}
+		if !wa.IsAuthorized() {
+			response := gin.H{"qr": "Ожидание QR кода", "authorized": false}
+			if event, ok := wa.CurrentQR(); ok {
+				for key, value := range qrPayload(event) {
+					response[key] = value
+				}
+			}
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		// Проверяем статус подключения
+		connected := wa.IsConnected()
+		if connected {
+			c.JSON(http.StatusOK, gin.H{"qr": "QR код уже отсканирован", "authorized": true, "connected": true})
+		} else {
+			c.JSON(http.StatusOK, gin.H{"qr": "QR код отсканирован, но соединение потеряно", "authorized": true, "connected": false})
+		}
+	})
+
+	// Поток событий привязки (SSE): новые QR коды по мере их смены и результат сканирования
+	r.GET("/qr/stream", requireWhatsApp(wa), func(c *gin.Context) {
+		if wa.IsAuthorized() {
+			c.SSEvent("qr", gin.H{"event": whatsapp.QREventSuccess})
+			return
+		}
+		events, unsubscribe := wa.SubscribeQR()
+		defer unsubscribe()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case event := <-events:
+				c.SSEvent("qr", qrPayload(event))
+				return event.Event != whatsapp.QREventSuccess
+			case <-c.Request.Context().Done():
+				return false
+			}
+		})
+	})
+
+	r.POST("/qr/restart", requireWhatsApp(wa), func(c *gin.Context) {
+		if err := wa.StartPairing(); err != nil {
+			status := http.StatusInternalServerError
+			if err == whatsapp.ErrAlreadyPaired {
+				status = http.StatusConflict
+			}
+			respondError(c, status, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Ожидание сканирования QR кода, коды передаются в GET /qr/stream"})
+	})
+
+	statusPayload := func() interface{} {
+		if wa == nil {
+			return gin.H{
+				"initialized": false,
+				"authorized":  false,
+				"connected":   false,
+				"read_only":   s.IsReadOnly(),
+				"message":     "Клиент не инициализирован",
+			}
+		}
+
+		authorized := wa.IsAuthorized()
+		connected := wa.IsConnected()
+
+		status := gin.H{
+			"initialized": true,
+			"authorized":  authorized,
+			"connected":   connected,
+			"read_only":   s.IsReadOnly(),
+			"flagged":     s.AccountHealth().Flagged,
+		}
+		if lastKeepalive := wa.LastKeepalive(); !lastKeepalive.IsZero() {
+			status["last_keepalive"] = lastKeepalive
+		}
+
+		if s.IsLoggedOut() {
+			status["reauthorization_required"] = true
+			status["message"] = "Устройство отвязано от телефона, требуется повторная авторизация через QR код"
+		} else if !authorized {
+			status["message"] = "Требуется авторизация через QR код"
+		} else if !connected {
+			status["message"] = "Соединение потеряно, требуется переподключение"
+		} else {
+			status["message"] = "Готов к работе"
+		}
+		return status
+	}
+	r.GET("/status", func(c *gin.Context) {
+		respondWatched(c, s, statusPayload)
+	})
+
+	// Проверки Kubernetes: /healthz - процесс работает, /readyz - сессия WhatsApp авторизована
+	// и подключена, а процесс не останавливается
+	r.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	r.GET("/readyz", func(c *gin.Context) {
+		switch {
+		case s.IsShuttingDown():
+			c.JSON(http.StatusServiceUnavailable, gin.H{"ready": false, "message": "Приложение останавливается"})
+		case wa != nil && !wa.IsAuthorized():
+			c.JSON(http.StatusServiceUnavailable, gin.H{"ready": false, "message": "Требуется авторизация через QR код"})
+		case wa != nil && !wa.IsConnected():
+			c.JSON(http.StatusServiceUnavailable, gin.H{"ready": false, "message": "Нет подключения к WhatsApp"})
+		default:
+			c.JSON(http.StatusOK, gin.H{"ready": true})
+		}
+	})
+
+	r.POST("/schedule", func(c *gin.Context) {
+		var task scheduler.ScheduledTask
+		if err := c.ShouldBindJSON(&task); err != nil {
+			logger.Errorf("Ошибка разбора задачи в POST /schedule: %v", err)
+			respondError(c, 400, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+
+		// Проверяем, есть ли уже активная задача. Задачу другого пользователя при isolate_tasks
+		// не показываем и заменить не предлагаем
+		existingTask := s.GetCurrentTask()
+		if existingTask != nil && !existingTask.OwnedBy(taskScope(c, s)) {
+			respondError(c, http.StatusConflict, errTaskOfOtherUser)
+			return
+		}
+		if existingTask != nil {
+			c.JSON(409, gin.H{
+				"error":         "Уже есть активная задача",
+				"code":          scheduler.ErrorCodeConflict,
+				"existing_task": existingTask,
+				"message":       "Хотите заменить существующую задачу?",
+			})
+			return
+		}
+
+		added := scheduler.NewTaskFromRequest(&task)
+		added.Owner = requestUser(c)
+		_, err := s.AddTask(added)
+		if err == nil {
+			respondTaskAdded(c, added, "Задача добавлена")
+		} else {
+			respondError(c, 400, fmt.Errorf("Ошибка при добавлении задачи: %w", err))
+		}
+	})
+
+	r.GET("/tasks", func(c *gin.Context) {
+		tag, filtered := c.GetQuery("tag")
+		if !filtered {
+			respondWatched(c, s, func() interface{} { return s.TasksOwnedBy(taskScope(c, s)) })
+			return
+		}
+		if strings.TrimSpace(tag) == "" {
+			respondError(c, http.StatusBadRequest, errEmptyTag)
+			return
+		}
+		respondWatched(c, s, func() interface{} { return s.TasksWithTag(tag, taskScope(c, s)) })
+	})
+
+	// Групповые операции над задачами с меткой, при isolate_tasks - только над своими
+	tagged := func(operation func(tag, owner string) []string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			if strings.TrimSpace(c.Param("tag")) == "" {
+				respondError(c, http.StatusBadRequest, errEmptyTag)
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"task_ids": operation(c.Param("tag"), taskScope(c, s))})
+		}
+	}
+	r.POST("/tags/:tag/pause", tagged(func(tag, owner string) []string { return s.PauseTagged(tag, owner, true) }))
+	r.POST("/tags/:tag/resume", tagged(func(tag, owner string) []string { return s.PauseTagged(tag, owner, false) }))
+	r.POST("/tags/:tag/stop", tagged(s.StopTagged))
+
+	r.GET("/tasks/:id/stats", requireTaskAccess(s), func(c *gin.Context) {
+		period, err := parsePeriod(c.DefaultQuery("period", defaultStatsPeriod))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+
+		stats, err := s.Storage().TaskStats(c.Param("id"), time.Now().Add(-period))
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		engagement, err := s.Storage().TaskEngagement(c.Param("id"), time.Now().Add(-period))
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		total := scheduler.TaskStat{}
+		var latencyMs float64
+		for _, stat := range stats {
+			total.Sent += stat.Sent
+			total.Failed += stat.Failed
+			latencyMs += stat.AvgLatencyMs * float64(stat.Sent+stat.Failed)
+		}
+		if attempts := total.Sent + total.Failed; attempts > 0 {
+			total.AvgLatencyMs = latencyMs / float64(attempts)
+		}
+		reactions, replies := 0, 0
+		for _, item := range engagement {
+			reactions += item.Reactions
+			replies += item.Replies
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"task_id":     c.Param("id"),
+			"hours":       stats,
+			"occurrences": engagement,
+			"total": gin.H{"sent": total.Sent, "failed": total.Failed, "avg_latency_ms": total.AvgLatencyMs,
+				"reactions": reactions, "replies": replies},
+		})
+	})
+
+	r.GET("/tasks/:id/variants", requireTaskAccess(s), func(c *gin.Context) {
+		reports, err := s.VariantReports(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"task_id": c.Param("id"), "variants": reports})
+	})
+
+	r.GET("/tasks/:id/occurrences", requireTaskAccess(s), func(c *gin.Context) {
+		from := time.Now()
+		if value := c.Query("from"); value != "" {
+			parsed, err := parseDateTime(value)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, fmt.Errorf("параметр from: %w", err))
+				return
+			}
+			from = parsed
+		}
+		to := from.Add(scheduler.OccurrencesDefaultRange)
+		if value := c.Query("to"); value != "" {
+			parsed, err := parseDateTime(value)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, fmt.Errorf("параметр to: %w", err))
+				return
+			}
+			to = parsed
+		}
+		if to.Before(from) || to.Sub(from) > scheduler.OccurrencesMaxRange {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("to должен быть после from и не дальше %d дней",
+				int(scheduler.OccurrencesMaxRange.Hours()/24)))
+			return
+		}
+
+		preview, exists := s.TaskOccurrences(c.Param("id"), from, to)
+		if !exists {
+			respondError(c, http.StatusNotFound, scheduler.ErrTaskNotFound)
+			return
+		}
+		c.JSON(http.StatusOK, preview)
+	})
+
+	r.GET("/tasks/:id/occurrences/:n/report", requireTaskAccess(s), func(c *gin.Context) {
+		occurrence, err := strconv.Atoi(c.Param("n"))
+		if err != nil || occurrence <= 0 {
+			respondError(c, http.StatusBadRequest, errors.New("номер рассылки должен быть положительным числом"))
+			return
+		}
+		report, err := s.Storage().OccurrenceReport(c.Param("id"), occurrence)
+		if errors.Is(err, scheduler.ErrReportNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+
+		if c.Query("format") != "csv" {
+			c.JSON(http.StatusOK, report)
+			return
+		}
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"chat_name", "status", "error", "updated_at"})
+		for _, recipient := range report.Recipients {
+			w.Write([]string{recipient.ChatName, recipient.Status, recipient.Error, recipient.UpdatedAt.Format(time.RFC3339)})
+		}
+		w.Flush()
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%d.csv"`, report.TaskID, report.Occurrence))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	})
+
+	r.GET("/calendar.ics", func(c *gin.Context) {
+		weeks, err := strconv.Atoi(c.DefaultQuery("weeks", strconv.Itoa(scheduler.CalendarDefaultWeeks)))
+		if err != nil || weeks <= 0 || weeks > scheduler.CalendarMaxWeeks {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("неверный параметр weeks (1-%d)", scheduler.CalendarMaxWeeks))
+			return
+		}
+
+		now := time.Now()
+		calendar := scheduler.BuildCalendar(s.TasksOwnedBy(taskScope(c, s)), now, now.AddDate(0, 0, 7*weeks))
+		c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(calendar))
+	})
+
+	r.GET("/exclusions", func(c *gin.Context) {
+		c.JSON(http.StatusOK, scheduler.GlobalExclusions())
+	})
+
+	r.PUT("/exclusions", func(c *gin.Context) {
+		var exclusions scheduler.Exclusions
+		if err := c.ShouldBindJSON(&exclusions); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		if err := exclusions.Validate(); err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.Storage().SaveGlobalExclusions(exclusions); err != nil {
+			respondError(c, http.StatusInternalServerError, fmt.Errorf("Ошибка сохранения исключений: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Исключения сохранены"})
+	})
+
+	r.GET("/resolve", requireWhatsApp(wa), func(c *gin.Context) {
+		name := strings.TrimSpace(c.Query("name"))
+		if name == "" {
+			respondError(c, http.StatusBadRequest, errors.New("укажите параметр name"))
+			return
+		}
+		candidates, err := wa.ResolveChat(name)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"name": name, "ambiguous": len(candidates) > 1, "candidates": candidates})
+	})
+
+	r.POST("/chats/disappearing", requireWhatsApp(wa), refuseReadOnly(s), func(c *gin.Context) {
+		var req struct {
+			ChatName string `json:"chat_name"`
+			Timer    string `json:"timer"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		timer, err := scheduler.ParseDisappearingTimer(req.Timer)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := wa.SetDisappearingTimer(strings.TrimSpace(req.ChatName), timer); err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Таймер исчезающих сообщений установлен"})
+	})
+
+	r.POST("/chats/mute", requireWhatsApp(wa), func(c *gin.Context) {
+		var req struct {
+			ChatName string `json:"chat_name"`
+			Duration string `json:"duration"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		var duration time.Duration
+		if req.Duration != "" {
+			var err error
+			if duration, err = time.ParseDuration(req.Duration); err != nil || duration < 0 {
+				respondError(c, http.StatusBadRequest, fmt.Errorf("неверная длительность '%s', пример: 8h", req.Duration))
+				return
+			}
+		}
+
+		if err := wa.SetMuted(strings.TrimSpace(req.ChatName), true, duration); err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Уведомления чата отключены"})
+	})
+
+	r.POST("/chats/unmute", requireWhatsApp(wa), func(c *gin.Context) {
+		updateChatState(c, "Уведомления чата включены", func(chatName string) error {
+			return wa.SetMuted(chatName, false, 0)
+		})
+	})
+
+	r.POST("/chats/archive", requireWhatsApp(wa), func(c *gin.Context) {
+		updateChatState(c, "Чат перемещен в архив", func(chatName string) error {
+			return wa.SetArchived(chatName, true)
+		})
+	})
+
+	r.POST("/chats/unarchive", requireWhatsApp(wa), func(c *gin.Context) {
+		updateChatState(c, "Чат извлечен из архива", func(chatName string) error {
+			return wa.SetArchived(chatName, false)
+		})
+	})
+
+	r.POST("/contacts/:jid/block", requireWhatsApp(wa), refuseReadOnly(s), func(c *gin.Context) {
+		setContactBlocked(c, wa, true)
+	})
+
+	r.POST("/contacts/:jid/unblock", requireWhatsApp(wa), refuseReadOnly(s), func(c *gin.Context) {
+		setContactBlocked(c, wa, false)
+	})
+
+	r.GET("/suppressions", func(c *gin.Context) {
+		suppressions, err := s.Storage().ListSuppressions()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, suppressions)
+	})
+	r.POST("/suppressions", func(c *gin.Context) {
+		var req struct {
+			JID    string `json:"jid"`
+			Reason string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		jid, err := whatsapp.ParseUserJID(req.JID)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.Storage().AddSuppression(jid.String(), strings.TrimSpace(req.Reason)); err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Получатель добавлен в список исключений", "jid": jid.String()})
+	})
+	r.DELETE("/suppressions/:jid", func(c *gin.Context) {
+		jid, err := whatsapp.ParseUserJID(c.Param("jid"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		deleted, err := s.Storage().RemoveSuppression(jid.String())
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		if !deleted {
+			respondError(c, http.StatusNotFound, errors.New("Получателя нет в списке исключений"))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Получатель удален из списка исключений"})
+	})
+
+	r.GET("/welcome", func(c *gin.Context) {
+		welcomes, err := s.Storage().ListGroupWelcomes()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, welcomes)
+	})
+	r.PUT("/welcome/:jid", func(c *gin.Context) {
+		jid, err := whatsapp.ParseGroupJID(c.Param("jid"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		var welcome scheduler.GroupWelcome
+		if err := c.ShouldBindJSON(&welcome); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		welcome.GroupJID = jid.String()
+		if err := s.SetGroupWelcome(welcome); err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Приветствие группы сохранено", "group_jid": welcome.GroupJID})
+	})
+	r.DELETE("/welcome/:jid", func(c *gin.Context) {
+		jid, err := whatsapp.ParseGroupJID(c.Param("jid"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		deleted, err := s.Storage().DeleteGroupWelcome(jid.String())
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		if !deleted {
+			respondError(c, http.StatusNotFound, errors.New("Приветствие для группы не настроено"))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Приветствие группы отключено"})
+	})
+
+	r.GET("/groups/:jid/membership-log", func(c *gin.Context) {
+		jid, err := whatsapp.ParseGroupJID(c.Param("jid"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(scheduler.MembershipLogDefaultLimit)))
+		if err != nil || limit <= 0 || limit > scheduler.MembershipLogMaxLimit {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("параметр limit должен быть в диапазоне 1..%d", scheduler.MembershipLogMaxLimit))
+			return
+		}
+		var from, to time.Time
+		if value := c.Query("from"); value != "" {
+			if from, err = parseDateTime(value); err != nil {
+				respondError(c, http.StatusBadRequest, fmt.Errorf("параметр from: %w", err))
+				return
+			}
+		}
+		if value := c.Query("to"); value != "" {
+			if to, err = parseDateTime(value); err != nil {
+				respondError(c, http.StatusBadRequest, fmt.Errorf("параметр to: %w", err))
+				return
+			}
+		}
+
+		events, err := s.Storage().GroupMembershipLog(jid.String(), from, to, limit)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, fmt.Errorf("Ошибка чтения журнала участников: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, events)
+	})
+
+	r.GET("/campaigns", func(c *gin.Context) {
+		campaigns, err := s.Storage().ListCampaigns()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, campaigns)
+	})
+	r.POST("/campaigns", func(c *gin.Context) {
+		var campaign scheduler.Campaign
+		if err := c.ShouldBindJSON(&campaign); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		id, err := s.AddCampaign(&campaign)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("Ошибка при добавлении кампании: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Кампания добавлена", "campaign_id": id})
+	})
+	r.GET("/campaigns/:id", func(c *gin.Context) {
+		campaign, err := s.Storage().GetCampaign(c.Param("id"))
+		if errors.Is(err, scheduler.ErrCampaignNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		enrollments, err := s.Storage().CampaignEnrollments(campaign.ID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"campaign": campaign, "enrollments": enrollments})
+	})
+	r.DELETE("/campaigns/:id", func(c *gin.Context) {
+		deleted, err := s.Storage().DeleteCampaign(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		if !deleted {
+			respondError(c, http.StatusNotFound, scheduler.ErrCampaignNotFound)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Кампания удалена"})
+	})
+	r.POST("/campaigns/:id/enroll", func(c *gin.Context) {
+		var req struct {
+			Recipients []string `json:"recipients"`
+			// ListID - подписать также всех получателей сохраненного списка
+			ListID string `json:"list_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		if req.ListID != "" {
+			list, err := s.Storage().GetRecipientList(req.ListID)
+			if errors.Is(err, scheduler.ErrRecipientListNotFound) {
+				respondError(c, http.StatusNotFound, err)
+				return
+			}
+			if err != nil {
+				respondError(c, http.StatusInternalServerError, err)
+				return
+			}
+			req.Recipients = append(req.Recipients, list.Entries...)
+		}
+		enrolled, err := s.EnrollInCampaign(c.Param("id"), req.Recipients)
+		switch {
+		case errors.Is(err, scheduler.ErrCampaignNotFound):
+			respondError(c, http.StatusNotFound, err)
+		case errors.Is(err, scheduler.ErrAmbiguousChat):
+			body := errorBody(http.StatusBadRequest, err)
+			body["enrolled"] = enrolled
+			c.JSON(http.StatusBadRequest, body)
+		case err != nil:
+			body := errorBody(http.StatusInternalServerError, err)
+			body["enrolled"] = enrolled
+			c.JSON(http.StatusInternalServerError, body)
+		default:
+			c.JSON(http.StatusOK, gin.H{"enrolled": enrolled})
+		}
+	})
+	r.POST("/campaigns/:id/unenroll", func(c *gin.Context) {
+		var req struct {
+			Recipients []string `json:"recipients"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		exited, err := s.UnenrollFromCampaign(c.Param("id"), req.Recipients)
+		if errors.Is(err, scheduler.ErrCampaignNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"exited": exited})
+	})
+
+	// Списки получателей для подписки на кампании
+	r.GET("/recipients/lists", func(c *gin.Context) {
+		lists, err := s.Storage().ListRecipientLists()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, lists)
+	})
+	r.POST("/recipients/lists", func(c *gin.Context) {
+		var list scheduler.RecipientList
+		if err := c.ShouldBindJSON(&list); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		id, err := s.AddRecipientList(&list)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Список получателей добавлен", "list_id": id})
+	})
+	r.GET("/recipients/lists/:id", func(c *gin.Context) {
+		list, err := s.Storage().GetRecipientList(c.Param("id"))
+		if errors.Is(err, scheduler.ErrRecipientListNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	})
+	r.PUT("/recipients/lists/:id", func(c *gin.Context) {
+		var list scheduler.RecipientList
+		if err := c.ShouldBindJSON(&list); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		err := s.UpdateRecipientList(c.Param("id"), &list)
+		switch {
+		case errors.Is(err, scheduler.ErrRecipientListNotFound):
+			respondError(c, http.StatusNotFound, err)
+		case err != nil:
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			c.JSON(http.StatusOK, list)
+		}
+	})
+	r.DELETE("/recipients/lists/:id", func(c *gin.Context) {
+		deleted, err := s.Storage().DeleteRecipientList(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		if !deleted {
+			respondError(c, http.StatusNotFound, scheduler.ErrRecipientListNotFound)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Список получателей удален"})
+	})
+	// Проверка получателей списка перед рассылкой, ?remove_invalid=true удаляет неверные записи
+	r.POST("/recipients/lists/:id/validate", func(c *gin.Context) {
+		report, err := s.ValidateRecipientList(c.Param("id"), c.Query("remove_invalid") == "true")
+		if errors.Is(err, scheduler.ErrRecipientListNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	})
+
+	r.GET("/contacts/block-rules", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"keywords": s.BlockRules().List()})
+	})
+
+	r.PUT("/contacts/block-rules", func(c *gin.Context) {
+		var req struct {
+			Keywords []string `json:"keywords"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		if err := s.Storage().SaveBlockRules(s.BlockRules(), req.Keywords); err != nil {
+			respondError(c, http.StatusInternalServerError, fmt.Errorf("Ошибка сохранения правил: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"keywords": s.BlockRules().List()})
+	})
+
+	r.GET("/mqtt/routes", func(c *gin.Context) {
+		routes, err := loadMQTTRoutes(s.Storage())
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"enabled": bridge != nil, "routes": routes})
+	})
+
+	r.PUT("/mqtt/routes", func(c *gin.Context) {
+		var req struct {
+			Routes []MQTTRoute `json:"routes"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		if err := compileMQTTRoutes(req.Routes); err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		if err := saveMQTTRoutes(s.Storage(), req.Routes); err != nil {
+			respondError(c, http.StatusInternalServerError, fmt.Errorf("Ошибка сохранения маршрутов: %w", err))
+			return
+		}
+		if bridge != nil {
+			if err := bridge.SetRoutes(req.Routes); err != nil {
+				respondError(c, http.StatusBadRequest, err)
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"enabled": bridge != nil, "routes": req.Routes})
+	})
+
+	r.GET("/notifications/sinks", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"sinks": notifier.Sinks()})
+	})
+
+	r.PUT("/notifications/sinks", func(c *gin.Context) {
+		var req struct {
+			Sinks []NotificationSink `json:"sinks"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		if req.Sinks == nil {
+			req.Sinks = []NotificationSink{}
+		}
+		if err := notifier.SetSinks(req.Sinks); err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"sinks": req.Sinks})
+	})
+
+	r.POST("/notifications/test", func(c *gin.Context) {
+		var failures []string
+		for _, sink := range notifier.Sinks() {
+			if err := notifier.post(sink, "✅ WhatsApp Scheduler: тестовое уведомление"); err != nil {
+				failures = append(failures, sink.URL+": "+err.Error())
+			}
+		}
+		if len(failures) > 0 {
+			respondError(c, http.StatusBadGateway, errors.New(strings.Join(failures, "; ")))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Тестовое уведомление отправлено"})
+	})
+
+	r.GET("/digest", func(c *gin.Context) {
+		now := time.Now()
+		text, err := s.Digest(now.Add(-24*time.Hour), now)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.String(http.StatusOK, text)
+	})
+
+	r.GET("/admin/settings", func(c *gin.Context) {
+		c.JSON(http.StatusOK, adminSettings{Settings: s.Settings(), NotificationSinks: notifier.Sinks()})
+	})
+
+	r.PUT("/admin/settings", func(c *gin.Context) {
+		// Поля, которых нет в запросе, сохраняют текущие значения
+		req := adminSettings{Settings: s.Settings()}
+		decoder := json.NewDecoder(c.Request.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		if req.NotificationSinks != nil {
+			if err := validateSinks(req.NotificationSinks); err != nil {
+				respondError(c, http.StatusBadRequest, err)
+				return
+			}
+		}
+		if err := s.SetSettings(req.Settings); err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		if req.NotificationSinks != nil {
+			if err := notifier.SetSinks(req.NotificationSinks); err != nil {
+				respondError(c, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		c.JSON(http.StatusOK, adminSettings{Settings: s.Settings(), NotificationSinks: notifier.Sinks()})
+	})
+
+	// Самопроверка: хранилище, сессия, подключение, шаблоны задач и (send_to_self=true) отправка себе
+	r.POST("/admin/self-test", func(c *gin.Context) {
+		result := s.SelfTest(c.Request.Context(), c.Query("send_to_self") == "true")
+		status := http.StatusOK
+		if !result.Passed {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, result)
+	})
+
+	// Режим только для чтения: отправки отключены, API, история и статус доступны
+	r.GET("/admin/read-only", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"enabled": s.IsReadOnly()})
+	})
+
+	r.PUT("/admin/read-only", func(c *gin.Context) {
+		var req struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		if req.Enabled == nil {
+			respondError(c, http.StatusBadRequest, errors.New("не указано поле enabled"))
+			return
+		}
+		s.SetReadOnly(*req.Enabled)
+		c.JSON(http.StatusOK, gin.H{"enabled": s.IsReadOnly()})
+	})
+
+	// Обнаружение ограничения аккаунта по ошибкам отправки
+	r.GET("/admin/account-health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.AccountHealth())
+	})
+
+	r.POST("/admin/account-health/reset", func(c *gin.Context) {
+		resumed := s.ResetAccountHealth(c.Query("resume") != "false")
+		c.JSON(http.StatusOK, gin.H{"resumed": resumed, "read_only": s.IsReadOnly()})
+	})
+
+	// Размер базы данных и медиатеки, количество строк по таблицам и последняя очистка
+	r.GET("/admin/storage", func(c *gin.Context) {
+		stats, err := s.StorageStats()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, stats)
+	})
+
+	// Очистка по срокам хранения из настроек сразу, не дожидаясь фоновой очистки
+	r.POST("/admin/purge", func(c *gin.Context) {
+		result, err := s.PurgeExpired()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+
+	// Предпросмотр отправок, которые задача выполнила бы за days дней, без отправки сообщений
+	r.POST("/admin/simulate", func(c *gin.Context) {
+		days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(scheduler.SimulationDefaultDays)))
+		if err != nil || days <= 0 || days > scheduler.SimulationMaxDays {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("неверный параметр days (1-%d)", scheduler.SimulationMaxDays))
+			return
+		}
+		var task scheduler.ScheduledTask
+		if err := c.ShouldBindJSON(&task); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+
+		simulation, err := s.Simulate(c.Request.Context(), scheduler.NewTaskFromRequest(&task), days)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("Ошибка симуляции задачи: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, simulation)
+	})
+
+	var updating atomic.Bool
+	r.GET("/admin/update", func(c *gin.Context) {
+		release, err := update.Check(c.Request.Context())
+		if err != nil {
+			respondError(c, http.StatusBadGateway, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"current_version": update.Version, "latest": release})
+	})
+
+	// Замена исполняемого файла доступна только с ключом администратора
+	r.POST("/admin/update", requireAdminKey(auth.adminKey), func(c *gin.Context) {
+		if err := update.Supported(); err != nil {
+			respondError(c, http.StatusForbidden, err)
+			return
+		}
+		if !updating.CompareAndSwap(false, true) {
+			respondError(c, http.StatusConflict, errors.New("обновление уже выполняется"))
+			return
+		}
+		defer updating.Store(false)
+
+		release, err := update.Check(c.Request.Context())
+		if err != nil {
+			respondError(c, http.StatusBadGateway, err)
+			return
+		}
+		if !release.Available {
+			c.JSON(http.StatusOK, gin.H{"message": "Установлена последняя версия", "current_version": update.Version})
+			return
+		}
+		if err := update.Apply(c.Request.Context(), release); err != nil {
+			logger.Errorf("Ошибка обновления до %s: %v", release.Version, err)
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		logger.Infof("⬆️ Установлена версия %s", release.Version)
+
+		if restart == nil {
+			c.JSON(http.StatusOK, gin.H{"message": "Обновление до " + release.Version + " установлено, перезапустите приложение"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Обновление до " + release.Version + " установлено, приложение перезапускается"})
+		select {
+		case restart <- struct{}{}:
+		default:
+		}
+	})
+
+	r.GET("/breakers", func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.Breakers().List())
+	})
+
+	r.POST("/breakers/reset", func(c *gin.Context) {
+		var req struct {
+			ChatName string `json:"chat_name"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+
+		if s.Breakers().Reset(req.ChatName) {
+			logger.Infof("▶️ Отправки в чат '%s' возобновлены | UI: http://localhost:8080", req.ChatName)
+			c.JSON(http.StatusOK, gin.H{"message": "Отправки в чат возобновлены"})
+		} else {
+			respondError(c, http.StatusNotFound, errors.New("Чат не приостановлен"))
+		}
+	})
+
+	r.GET("/alerts", func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.Alerts())
+	})
+
+	r.POST("/stop/:id", requireTaskAccess(s), func(c *gin.Context) {
+		id := c.Param("id")
+		if s.StopTask(id) {
+			c.JSON(http.StatusOK, gin.H{"message": "Задача остановлена"})
+		} else {
+			respondError(c, http.StatusNotFound, scheduler.ErrTaskNotFound)
+		}
+	})
+
+	r.POST("/tasks/:id/confirm", requireTaskAccess(s), func(c *gin.Context) {
+		err := s.ConfirmTask(c.Param("id"))
+		switch {
+		case errors.Is(err, scheduler.ErrTaskNotFound):
+			respondError(c, http.StatusNotFound, err)
+		case err != nil:
+			respondError(c, http.StatusConflict, err)
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Рассылка подтверждена", "task_id": c.Param("id")})
+		}
+	})
+
+	r.POST("/tasks/:id/retry-failed", requireTaskAccess(s), func(c *gin.Context) {
+		results, exists, err := s.RetryFailed(c.Param("id"))
+		if !exists {
+			respondError(c, http.StatusNotFound, scheduler.ErrTaskNotFound)
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"retried": results})
+	})
+
+	send := func(c *gin.Context) {
+		var req scheduler.SendRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		if req.Timezone == "" {
+			req.Timezone = s.Settings().DefaultTimezone
+		}
+
+		msg, scheduledAt, err := scheduler.PrepareSend(req, s.Now())
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		msg = s.WithMessageID(msg)
+		msg.Owner = requestUser(c)
+
+		if scheduledAt != nil {
+			sendID, err := s.ScheduleOneOff(msg, *scheduledAt)
+			if err != nil {
+				status := http.StatusInternalServerError
+				if errors.Is(err, scheduler.ErrShuttingDown) {
+					status = http.StatusServiceUnavailable
+				}
+				respondError(c, status, err)
+				return
+			}
+			setQuotaHeaders(c, s)
+			c.JSON(http.StatusAccepted, gin.H{
+				"success":      true,
+				"message":      "Сообщение запланировано",
+				"chat":         msg.ChatName,
+				"message_id":   msg.ID,
+				"send_id":      sendID,
+				"scheduled_at": scheduledAt,
+			})
+			return
+		}
+
+		result, err := s.DeliverResult(c.Request.Context(), msg)
+		quota := setQuotaHeaders(c, s)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, scheduler.ErrQuotaExceeded):
+				status = http.StatusTooManyRequests
+				retryAfterQuota(c, s, quota)
+			case errors.Is(err, scheduler.ErrRateLimited):
+				status = http.StatusTooManyRequests
+			case errors.Is(err, scheduler.ErrStandby), errors.Is(err, scheduler.ErrShuttingDown), errors.Is(err, scheduler.ErrLoggedOut),
+				errors.Is(err, scheduler.ErrReadOnly),
+				errors.Is(err, scheduler.ErrDisconnected), errors.Is(err, scheduler.ErrServerUnavailable):
+				status = http.StatusServiceUnavailable
+			case errors.Is(err, scheduler.ErrSendTimeout):
+				status = http.StatusGatewayTimeout
+			case errors.Is(err, scheduler.ErrNotAuthorized):
+				status = http.StatusForbidden
+			case errors.Is(err, scheduler.ErrAmbiguousChat):
+				status = http.StatusConflict
+			case errors.Is(err, scheduler.ErrModerationRejected), errors.Is(err, scheduler.ErrTemplateRender):
+				status = http.StatusUnprocessableEntity
+			case errors.Is(err, scheduler.ErrStoredMessageNotFound), errors.Is(err, scheduler.ErrChatNotFound):
+				status = http.StatusNotFound
+			}
+			body := errorBody(status, err)
+			body["success"] = false
+			body["message"] = "Ошибка отправки сообщения"
+			c.JSON(status, body)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":    true,
+			"message":    "Сообщение отправлено",
+			"chat":       msg.ChatName,
+			"chat_jid":   result.ChatJID,
+			"message_id": result.MessageID,
+			"sent_at":    result.Timestamp,
+		})
+	}
+	r.POST("/send", send)
+	r.POST("/send/batch", func(c *gin.Context) {
+		var req struct {
+			Messages []scheduler.SendRequest `json:"messages"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		// Пакет с исчерпанной квотой не принимается, иначе сообщения сверх квоты не отправляются
+		if quota := setQuotaHeaders(c, s); quota.Limit > 0 && quota.Remaining() == 0 {
+			retryAfterQuota(c, s, quota)
+			respondError(c, http.StatusTooManyRequests, scheduler.ErrQuotaExceeded)
+			return
+		}
+		batchID, err := s.SendBatch(req.Messages, requestUser(c))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"batch_id": batchID, "total": len(req.Messages)})
+	})
+	r.GET("/send/batch/:id", func(c *gin.Context) {
+		batch, err := s.Storage().GetBatch(c.Param("id"))
+		if errors.Is(err, scheduler.ErrBatchNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, batch)
+	})
+	// Отложенные разовые отправки: ожидают в хранилище и переживают перезапуск
+	r.GET("/send/scheduled", func(c *gin.Context) {
+		sends, err := s.ScheduledSends()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"sends": sends, "count": len(sends)})
+	})
+	r.DELETE("/send/scheduled/:id", func(c *gin.Context) {
+		err := s.CancelScheduledSend(c.Param("id"))
+		if errors.Is(err, scheduler.ErrScheduledSendNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Отложенная отправка отменена"})
+	})
+	// /test - прежний адрес отправки только текста, оставлен для совместимости
+	r.POST("/test", send)
+
+	// Предпросмотр текста, который будет отправлен после выполнения команды и форматирования
+	r.POST("/preview", func(c *gin.Context) {
+		var task scheduler.ScheduledTask
+		if err := c.ShouldBindJSON(&task); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+		if err := scheduler.ValidateFormat(task.Format); err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+
+		preview := scheduler.NewTaskFromRequest(&task)
+		msg, err := s.PreviewMessage(c.Request.Context(), preview, c.Query("language"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		message := msg.Message
+
+		response := gin.H{"text": message, "length": utf8.RuneCountInString(message)}
+		if msg.Variant != "" {
+			response["variant"] = msg.Variant
+		}
+		if err := scheduler.ValidateMessageLength(message); err != nil {
+			response["warning"] = err.Error()
+			response["parts"] = len(scheduler.SplitMessage(message, scheduler.MaxMessageLength))
+		}
+		c.JSON(http.StatusOK, response)
+	})
+
+	r.POST("/media", func(c *gin.Context) {
+		header, err := c.FormFile("file")
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("Ожидается файл в поле 'file': %w", err))
+			return
+		}
+		if header.Size > scheduler.MaxMediaSize {
+			respondError(c, http.StatusRequestEntityTooLarge, fmt.Errorf("файл больше %d МБ", scheduler.MaxMediaSize>>20))
+			return
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("Ошибка чтения файла: %w", err))
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("Ошибка чтения файла: %w", err))
+			return
+		}
+
+		mimeType := header.Header.Get("Content-Type")
+		if mimeType == "" || mimeType == "application/octet-stream" {
+			mimeType = http.DetectContentType(data)
+		}
+		// Изображения сохраняются уже уменьшенными и без EXIF: координаты не хранятся и в медиатеке
+		data, mimeType = s.PrepareImage(data, mimeType)
+
+		asset, duplicate, err := s.Storage().AddMedia(data, header.Filename, mimeType)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, fmt.Errorf("Ошибка сохранения файла: %w", err))
+			return
+		}
+		response := gin.H{"media": asset, "duplicate": duplicate}
+		// Видео перекодируется в фоне, ход - в GET /media/:id/transcode
+		if strings.HasPrefix(asset.MimeType, "video/") && scheduler.TranscodingEnabled() && c.Query("transcode") != "false" {
+			job, err := s.StartTranscode(asset)
+			if err != nil {
+				respondError(c, http.StatusInternalServerError, err)
+				return
+			}
+			response["transcode"] = job
+		}
+		c.JSON(http.StatusOK, response)
+	})
+
+	r.GET("/media", func(c *gin.Context) {
+		assets, err := s.Storage().ListMedia()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, fmt.Errorf("Ошибка чтения медиатеки: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, assets)
+	})
+
+	// Уменьшенная копия изображения медиатеки для предпросмотра в панели, полный файл не отдается
+	r.GET("/media/:id/thumbnail", func(c *gin.Context) {
+		size, err := strconv.Atoi(c.DefaultQuery("size", strconv.Itoa(scheduler.DefaultThumbnailSize)))
+		if err != nil || size <= 0 || size > scheduler.MaxThumbnailSize {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("параметр size должен быть в диапазоне 1..%d", scheduler.MaxThumbnailSize))
+			return
+		}
+		thumbnail, err := s.Storage().MediaThumbnail(c.Param("id"), size)
+		switch {
+		case errors.Is(err, scheduler.ErrMediaNotFound):
+			respondError(c, http.StatusNotFound, err)
+		case errors.Is(err, scheduler.ErrNoThumbnail):
+			respondError(c, http.StatusUnsupportedMediaType, err)
+		case err != nil:
+			respondError(c, http.StatusInternalServerError, err)
+		default:
+			// Содержимое файла медиатеки не меняется: ID - префикс его SHA-256
+			c.Header("Cache-Control", "private, max-age=86400")
+			c.Data(http.StatusOK, "image/jpeg", thumbnail)
+		}
+	})
+
+	r.POST("/media/:id/transcode", func(c *gin.Context) {
+		asset, err := s.Storage().GetMedia(c.Param("id"))
+		if err != nil {
+			if errors.Is(err, scheduler.ErrMediaNotFound) {
+				respondError(c, http.StatusNotFound, err)
+			} else {
+				respondError(c, http.StatusInternalServerError, err)
+			}
+			return
+		}
+		job, err := s.StartTranscode(asset)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, job)
+	})
+
+	r.GET("/media/:id/transcode", func(c *gin.Context) {
+		job, err := s.TranscodeStatus(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	})
+
+	r.GET("/messages", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit <= 0 {
+			respondError(c, http.StatusBadRequest, errors.New("неверный параметр limit"))
+			return
+		}
+
+		messages, err := s.Storage().ListStoredMessages(strings.TrimSpace(c.Query("chat_jid")), limit)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, fmt.Errorf("Ошибка чтения сохраненных сообщений: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, messages)
+	})
+
+	// Вложение входящего сообщения, сохраненное в медиатеку (настройка download_incoming_media)
+	incomingMedia := func(c *gin.Context) {
+		asset, err := s.Storage().IncomingMedia(c.Param("id"))
+		switch {
+		case errors.Is(err, scheduler.ErrIncomingMediaNotFound), errors.Is(err, scheduler.ErrMediaNotFound):
+			respondError(c, http.StatusNotFound, err)
+			return
+		case err != nil:
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.Header("Content-Type", asset.MimeType)
+		c.Header("X-Media-Id", asset.ID)
+		if asset.FileName != "" {
+			c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": asset.FileName}))
+		}
+		c.File(asset.Path())
+	}
+	r.GET("/messages/:id/media", incomingMedia)
+	// /inbox/:id/media - тот же адрес в терминах входящих сообщений
+	r.GET("/inbox/:id/media", incomingMedia)
+
+	r.GET("/polls", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit <= 0 {
+			respondError(c, http.StatusBadRequest, errors.New("неверный параметр limit"))
+			return
+		}
+
+		polls, err := s.Storage().ListPolls(strings.TrimSpace(c.Query("task_id")), limit)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, fmt.Errorf("Ошибка чтения опросов: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, polls)
+	})
+
+	r.GET("/polls/:message_id/results", func(c *gin.Context) {
+		results, err := s.Storage().PollResults(c.Param("message_id"))
+		if errors.Is(err, scheduler.ErrPollNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, fmt.Errorf("Ошибка подсчета голосов: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, results)
+	})
+
+	r.GET("/history", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit <= 0 {
+			respondError(c, http.StatusBadRequest, errors.New("неверный параметр limit"))
+			return
+		}
+
+		entries, err := s.Storage().ListHistory(limit)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, fmt.Errorf("Ошибка чтения истории: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, entries)
+	})
+
+	r.DELETE("/history", func(c *gin.Context) {
+		before, err := parseDateTime(c.Query("before"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("параметр before: %w", err))
+			return
+		}
+		deleted, err := s.Storage().DeleteHistoryBefore(before)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, fmt.Errorf("Ошибка удаления истории: %w", err))
+			return
+		}
+		logger.Infof("🧹 Удалено %d записей истории до %s", deleted, before.Format(time.RFC3339))
+		c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+	})
+
+	r.DELETE("/messages", func(c *gin.Context) {
+		before, err := parseDateTime(c.Query("before"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("параметр before: %w", err))
+			return
+		}
+		deleted, err := s.Storage().DeleteStoredMessagesBefore(before)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, fmt.Errorf("Ошибка удаления сохраненных сообщений: %w", err))
+			return
+		}
+		logger.Infof("🧹 Удалено %d сохраненных сообщений до %s", deleted, before.Format(time.RFC3339))
+		c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+	})
+
+	// Файлы медиатеки, используемые задачами, не удаляются
+	r.DELETE("/media", func(c *gin.Context) {
+		before, err := parseDateTime(c.Query("before"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("параметр before: %w", err))
+			return
+		}
+		deleted, err := s.DeleteMediaBefore(before)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, fmt.Errorf("Ошибка удаления файлов медиатеки: %w", err))
+			return
+		}
+		logger.Infof("🧹 Удалено %d файлов медиатеки до %s", deleted, before.Format(time.RFC3339))
+		c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+	})
+
+	r.DELETE("/contacts-data/:jid", func(c *gin.Context) {
+		jid, err := whatsapp.ParseUserJID(c.Param("jid"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+
+		names := []string{jid.User}
+		if wa != nil {
+			if names, err = wa.ForgetContact(c.Request.Context(), jid); err != nil {
+				respondError(c, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		deleted, err := s.ForgetRecipient(jid.String(), names)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, fmt.Errorf("Ошибка удаления истории: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"jid": jid.String(), "history_deleted": deleted})
+	})
+
+	// Endpoint для замены существующей задачи
+	r.POST("/replace-task", func(c *gin.Context) {
+		var task scheduler.ScheduledTask
+		if err := c.ShouldBindJSON(&task); err != nil {
+			respondError(c, 400, fmt.Errorf("%w: %v", errInvalidJSON, err))
+			return
+		}
+
+		task.StartTime = task.StartTime.In(time.Local)
+		task.EndTime = task.EndTime.In(time.Local)
+
+		if existing := s.GetCurrentTask(); existing != nil && !existing.OwnedBy(taskScope(c, s)) {
+			respondError(c, http.StatusConflict, errTaskOfOtherUser)
+			return
+		}
+		added := scheduler.NewTaskFromRequest(&task)
+		added.Owner = requestUser(c)
+		_, err := s.AddTask(added)
+		if err == nil {
+			respondTaskAdded(c, added, "Задача заменена")
+		} else {
+			respondError(c, 400, fmt.Errorf("Ошибка при замене задачи: %w", err))
+		}
+	})
+
+	return r
+}
+
+// parsePeriod разбирает период статистики: 7d, 12h или 30m (не больше maxStatsPeriod)
+func parsePeriod(value string) (time.Duration, error) {
+	var period time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		period = time.Duration(n) * 24 * time.Hour
+	} else {
+		period, err = time.ParseDuration(value)
+	}
+	if err != nil || period <= 0 || period > maxStatsPeriod {
+		return 0, fmt.Errorf("неверный период '%s', пример: 7d или 12h (не больше %d дней)", value, maxStatsPeriod/(24*time.Hour))
+	}
+	return period, nil
+}
+
+// parseDateTime разбирает время в формате RFC3339 или дату ГГГГ-ММ-ДД (начало суток по местному времени)
+func parseDateTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("неверное время '%s', пример: 2025-01-02 или 2025-01-02T09:00:00Z", value)
+}
+
+// qrPayload возвращает событие привязки для ответа API с QR кодом в виде PNG в base64
+func qrPayload(event whatsapp.QREvent) gin.H {
+	payload := gin.H{"event": event.Event}
+	if event.Error != "" {
+		payload["error"] = event.Error
+	}
+	if event.Code == "" {
+		return payload
+	}
+	payload["code"] = event.Code
+	payload["expires_at"] = event.ExpiresAt
+	if code, err := qr.Encode(event.Code, qr.L); err == nil {
+		payload["qr_code"] = base64.StdEncoding.EncodeToString(code.PNG())
+	}
+	return payload
+}
+
+// requireAdminKey пропускает только запросы с ключом администратора key в заголовке X-API-Key
+// или Authorization: Bearer. Без заданного ключа запросы отклоняются все
+func requireAdminKey(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key == "" {
+			err := fmt.Errorf("%w: задайте %s", errAdminKeyNotSet, adminKeyEnv)
+			c.AbortWithStatusJSON(http.StatusForbidden, errorBody(http.StatusForbidden, err))
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(requestKey(c)), []byte(key)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, errorBody(http.StatusUnauthorized, errInvalidAdminKey))
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireWhatsApp отклоняет запросы управления чатами, если клиент WhatsApp не запущен (режим --mock)
+func requireWhatsApp(wa *whatsapp.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if wa == nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, errorBody(http.StatusServiceUnavailable, errWhatsAppNotStarted))
+			return
+		}
+		c.Next()
+	}
+}
+
+// respondTaskAdded отвечает на добавление задачи. Рассылка, ожидающая подтверждения,
+// возвращается со статусом 202 и предпросмотром
+func respondTaskAdded(c *gin.Context, task *scheduler.ScheduledTask, message string) {
+	if task.PendingConfirmation {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Рассылка ждет подтверждения: POST /tasks/" + task.ID + "/confirm",
+			"task_id": task.ID,
+			"status":  "pending_confirmation",
+			"preview": task.Preview,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "task_id": task.ID})
+}
+
+// refuseReadOnly отклоняет запросы, отправляющие что-либо в WhatsApp, в режиме только для чтения
+func refuseReadOnly(s *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.IsReadOnly() {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, errorBody(http.StatusServiceUnavailable, scheduler.ErrReadOnly))
+			return
+		}
+		c.Next()
+	}
+}
+
+// updateChatState обрабатывает запросы изменения состояния чата с телом {"chat_name": "..."}
+func updateChatState(c *gin.Context, successMessage string, update func(chatName string) error) {
+	var req struct {
+		ChatName string `json:"chat_name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, fmt.Errorf("%w: %v", errInvalidJSON, err))
+		return
+	}
+	if err := update(strings.TrimSpace(req.ChatName)); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": successMessage})
+}
+
+// setContactBlocked обрабатывает блокировку и разблокировку контакта
+func setContactBlocked(c *gin.Context, wa *whatsapp.Client, blocked bool) {
+	jid, err := whatsapp.ParseUserJID(c.Param("jid"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if err := wa.SetBlocked(jid, blocked); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	message := "Контакт разблокирован"
+	if blocked {
+		message = "Контакт заблокирован"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "jid": jid.String()})
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+const (
+	// redisURLEnv - адрес Redis для выбора ведущего экземпляра (redis://host:6379/0), без него резервирование отключено
+	redisURLEnv = "WHATSAPP_SCHEDULER_REDIS_URL"
+	// leaderKeyEnv - ключ блокировки; экземпляры с одинаковым ключом резервируют друг друга
+	leaderKeyEnv = "WHATSAPP_SCHEDULER_LEADER_KEY"
+	// defaultLeaderKey - ключ блокировки по умолчанию
+	defaultLeaderKey = "whatsapp-scheduler:leader"
+)
+
+// Продление и освобождение выполняются атомарно и только владельцем блокировки
+var (
+	renewLeaderScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`)
+	releaseLeaderScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+)
+
+// redisLeaderLock - блокировка ведущего экземпляра в Redis (SET NX PX с продлением)
+type redisLeaderLock struct {
+	client *redis.Client
+	key    string
+	// owner - уникальный идентификатор экземпляра
+	owner string
+}
+
+// Acquire реализует scheduler.LeaderLock
+func (l *redisLeaderLock) Acquire(ctx context.Context, ttl time.Duration) (bool, error) {
+	acquired, err := l.client.SetNX(ctx, l.key, l.owner, ttl).Result()
+	if err != nil || acquired {
+		return acquired, err
+	}
+	renewed, err := renewLeaderScript.Run(ctx, l.client, []string{l.key}, l.owner, ttl.Milliseconds()).Int()
+	return renewed == 1, err
+}
+
+// Release реализует scheduler.LeaderLock
+func (l *redisLeaderLock) Release(ctx context.Context) error {
+	return releaseLeaderScript.Run(ctx, l.client, []string{l.key}, l.owner).Err()
+}
+
+// LeaderElection - участие экземпляра в выборе ведущего через Redis
+type LeaderElection struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartLeaderElection включает резервирование через Redis, если задан WHATSAPP_SCHEDULER_REDIS_URL.
+// Возвращает nil, если резервирование не настроено
+func StartLeaderElection(s *scheduler.Scheduler) (*LeaderElection, error) {
+	redisURL := os.Getenv(redisURLEnv)
+	if redisURL == "" {
+		return nil, nil
+	}
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", redisURLEnv, err)
+	}
+	key := os.Getenv(leaderKeyEnv)
+	if key == "" {
+		key = defaultLeaderKey
+	}
+
+	hostname, _ := os.Hostname()
+	lock := &redisLeaderLock{
+		client: redis.NewClient(options),
+		key:    key,
+		owner:  fmt.Sprintf("%s:%d:%d", hostname, os.Getpid(), time.Now().UnixNano()),
+	}
+	logger.Infof("👥 Экземпляр %s участвует в выборе ведущего (ключ %s)", lock.owner, key)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	election := &LeaderElection{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(election.done)
+		s.RunLeaderElection(ctx, lock, scheduler.DefaultLeaderTTL)
+	}()
+	return election, nil
+}
+
+// Stop прекращает участие в выборе и освобождает блокировку, чтобы резервный экземпляр
+// стал ведущим без ожидания ее истечения
+func (e *LeaderElection) Stop() {
+	if e == nil {
+		return
+	}
+	e.cancel()
+	<-e.done
+}
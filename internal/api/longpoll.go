@@ -0,0 +1,75 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+const (
+	// maxLongPollWait - максимальное ожидание изменений в параметре wait
+	maxLongPollWait = 60 * time.Second
+	// longPollCheckInterval - как часто ответ пересчитывается без событий планировщика
+	// (состояние подключения WhatsApp меняется без событий)
+	longPollCheckInterval = time.Second
+)
+
+// respondWatched отвечает JSON, полученным от render, с заголовком ETag. Если ETag совпадает
+// с If-None-Match, отвечает 304. С параметром wait (например, 30s) ответ на совпадающий ETag
+// откладывается до изменения данных или истечения wait, чтобы клиенты не опрашивали сервер каждую секунду
+func respondWatched(c *gin.Context, s *scheduler.Scheduler, render func() interface{}) {
+	var wait time.Duration
+	if value := c.Query("wait"); value != "" {
+		var err error
+		if wait, err = time.ParseDuration(value); err != nil || wait < 0 || wait > maxLongPollWait {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("параметр wait должен быть длительностью от 0s до %s", maxLongPollWait))
+			return
+		}
+	}
+	known := c.GetHeader("If-None-Match")
+
+	var events <-chan scheduler.Event
+	if wait > 0 && known != "" {
+		var unsubscribe func()
+		events, unsubscribe = s.Subscribe()
+		defer unsubscribe()
+	}
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(longPollCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		body, err := json.Marshal(render())
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		etag := fmt.Sprintf(`"%x"`, sha256.Sum256(body))
+		c.Header("ETag", etag)
+		if etag != known {
+			c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+			return
+		}
+		if wait == 0 {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-deadline.C:
+			// Последняя проверка перед ответом 304
+			wait = 0
+		case <-events:
+		case <-ticker.C:
+		}
+	}
+}
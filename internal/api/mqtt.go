@@ -0,0 +1,312 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/sirupsen/logrus"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+// logger - логгер API, общий с пакетами планировщика
+var logger = logrus.StandardLogger()
+
+const (
+	// mqttBrokerEnv - адрес MQTT брокера (например tcp://localhost:1883), без него MQTT отключен
+	mqttBrokerEnv   = "WHATSAPP_SCHEDULER_MQTT_BROKER"
+	mqttUsernameEnv = "WHATSAPP_SCHEDULER_MQTT_USERNAME"
+	mqttPasswordEnv = "WHATSAPP_SCHEDULER_MQTT_PASSWORD"
+	// mqttEventsTopicEnv - топик, в который публикуются события планировщика
+	mqttEventsTopicEnv = "WHATSAPP_SCHEDULER_MQTT_EVENTS_TOPIC"
+	// defaultMQTTEventsTopic - топик событий по умолчанию
+	defaultMQTTEventsTopic = "whatsapp-scheduler/events"
+	// mqttQoS - уровень гарантии доставки подписок и публикаций
+	mqttQoS = 1
+	// mqttTimeout - максимальное время ожидания операций с брокером
+	mqttTimeout = 10 * time.Second
+	// defaultMQTTTemplate - шаблон сообщения по умолчанию: содержимое MQTT сообщения как есть
+	defaultMQTTTemplate = "{{.Payload}}"
+)
+
+// MQTTRoute связывает топик MQTT с чатом: каждое сообщение топика отправляется в чат по шаблону
+type MQTTRoute struct {
+	// Topic - топик подписки, допускаются шаблоны + и #
+	Topic    string `json:"topic"`
+	ChatName string `json:"chat_name"`
+	// Template - шаблон text/template, доступны .Topic, .Payload, .Data (разобранный JSON)
+	// и функции formatDate и formatNumber (см. scheduler.TemplateFuncs)
+	Template string `json:"template,omitempty"`
+	template *template.Template
+	// strictTemplate - шаблон для строгого режима: отсутствующие ключи .Data - ошибка
+	strictTemplate *template.Template
+}
+
+// mqttTemplateData - данные, доступные в шаблоне маршрута
+type mqttTemplateData struct {
+	Topic   string
+	Payload string
+	Data    any
+}
+
+// MQTTBridge принимает сообщения IoT устройств и публикует события планировщика
+type MQTTBridge struct {
+	mutex       sync.Mutex
+	client      mqtt.Client
+	scheduler   *scheduler.Scheduler
+	routes      []MQTTRoute
+	eventsTopic string
+}
+
+// compile проверяет маршрут и подготавливает шаблон
+func (r *MQTTRoute) compile() error {
+	r.Topic = strings.TrimSpace(r.Topic)
+	r.ChatName = strings.TrimSpace(r.ChatName)
+	if r.Topic == "" {
+		return fmt.Errorf("пустой топик MQTT")
+	}
+	if r.ChatName == "" {
+		return fmt.Errorf("пустое название чата для топика '%s'", r.Topic)
+	}
+
+	text := r.Template
+	if strings.TrimSpace(text) == "" {
+		text = defaultMQTTTemplate
+	}
+	tmpl, err := template.New(r.Topic).Funcs(scheduler.TemplateFuncs()).Parse(text)
+	if err != nil {
+		return fmt.Errorf("ошибка шаблона для топика '%s': %v", r.Topic, err)
+	}
+	r.template = tmpl
+	if r.strictTemplate, err = tmpl.Clone(); err != nil {
+		return fmt.Errorf("ошибка шаблона для топика '%s': %v", r.Topic, err)
+	}
+	r.strictTemplate.Option("missingkey=error")
+	return nil
+}
+
+// compileMQTTRoutes проверяет маршруты и подготавливает их шаблоны
+func compileMQTTRoutes(routes []MQTTRoute) error {
+	topics := map[string]bool{}
+	for i := range routes {
+		if err := routes[i].compile(); err != nil {
+			return err
+		}
+		if topics[routes[i].Topic] {
+			return fmt.Errorf("топик MQTT '%s' указан несколько раз", routes[i].Topic)
+		}
+		topics[routes[i].Topic] = true
+	}
+	return nil
+}
+
+// render формирует текст сообщения из MQTT сообщения. В строгом режиме ссылка на отсутствующее
+// поле и пустой результат - ошибка
+func (r *MQTTRoute) render(topic string, payload []byte, strict bool) (string, error) {
+	data := mqttTemplateData{Topic: topic, Payload: string(payload)}
+	// Содержимое не обязано быть JSON, в этом случае .Data остается пустым
+	_ = json.Unmarshal(payload, &data.Data)
+
+	tmpl := r.template
+	if strict {
+		tmpl = r.strictTemplate
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("ошибка заполнения шаблона для топика '%s': %v", r.Topic, err)
+	}
+	text := strings.TrimSpace(buf.String())
+	if strict && text == "" {
+		return "", fmt.Errorf("шаблон для топика '%s' заполнен пустым текстом", r.Topic)
+	}
+	return text, nil
+}
+
+// StartMQTTBridge подключается к брокеру из переменных окружения.
+// Возвращает nil, если брокер не настроен
+func StartMQTTBridge(s *scheduler.Scheduler) (*MQTTBridge, error) {
+	broker := os.Getenv(mqttBrokerEnv)
+	if broker == "" {
+		return nil, nil
+	}
+
+	routes, err := loadMQTTRoutes(s.Storage())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки маршрутов MQTT: %v", err)
+	}
+
+	bridge := &MQTTBridge{scheduler: s, eventsTopic: os.Getenv(mqttEventsTopicEnv)}
+	if bridge.eventsTopic == "" {
+		bridge.eventsTopic = defaultMQTTEventsTopic
+	}
+	if err := compileMQTTRoutes(routes); err != nil {
+		return nil, err
+	}
+	bridge.routes = routes
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(fmt.Sprintf("whatsapp-scheduler-%d", time.Now().UnixNano())).
+		SetUsername(os.Getenv(mqttUsernameEnv)).
+		SetPassword(os.Getenv(mqttPasswordEnv)).
+		SetConnectRetry(true).
+		SetAutoReconnect(true).
+		// Отправка в WhatsApp может занимать время, обработчики не должны блокировать клиента
+		SetOrderMatters(false).
+		SetOnConnectHandler(func(mqtt.Client) {
+			logger.Infof("✅ Подключение к MQTT брокеру %s установлено", broker)
+			// Подписки не сохраняются между сессиями, восстанавливаем их после каждого подключения
+			bridge.mutex.Lock()
+			defer bridge.mutex.Unlock()
+			bridge.subscribe(bridge.routes)
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			logger.Warnf("⚠️ Потеряно подключение к MQTT брокеру: %v", err)
+		})
+	bridge.client = mqtt.NewClient(opts)
+
+	// При недоступном брокере подключение повторяется в фоне
+	bridge.client.Connect()
+	go bridge.publishEvents()
+	return bridge, nil
+}
+
+// SetRoutes заменяет маршруты и обновляет подписки
+func (b *MQTTBridge) SetRoutes(routes []MQTTRoute) error {
+	if err := compileMQTTRoutes(routes); err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.client.IsConnectionOpen() && len(b.routes) > 0 {
+		topics := make([]string, 0, len(b.routes))
+		for _, route := range b.routes {
+			topics = append(topics, route.Topic)
+		}
+		if token := b.client.Unsubscribe(topics...); token.WaitTimeout(mqttTimeout) && token.Error() != nil {
+			logger.Errorf("Ошибка отписки от топиков MQTT: %v", token.Error())
+		}
+	}
+	b.routes = routes
+	if b.client.IsConnectionOpen() {
+		b.subscribe(routes)
+	}
+	return nil
+}
+
+// subscribe подписывается на топики маршрутов, вызывается под мьютексом
+func (b *MQTTBridge) subscribe(routes []MQTTRoute) {
+	for _, route := range routes {
+		token := b.client.Subscribe(route.Topic, mqttQoS, func(_ mqtt.Client, msg mqtt.Message) {
+			b.handleMessage(route, msg)
+		})
+		if token.WaitTimeout(mqttTimeout) && token.Error() != nil {
+			logger.Errorf("Ошибка подписки на топик MQTT '%s': %v", route.Topic, token.Error())
+			continue
+		}
+		logger.Infof("📡 Подписка на топик MQTT '%s' -> чат '%s'", route.Topic, route.ChatName)
+	}
+}
+
+// handleMessage отправляет сообщение топика в связанный чат
+func (b *MQTTBridge) handleMessage(route MQTTRoute, msg mqtt.Message) {
+	strict := b.scheduler.Settings().StrictTemplates
+	text, err := route.render(msg.Topic(), msg.Payload(), strict)
+	if err != nil {
+		logger.Errorf("❌ %v", err)
+		if strict {
+			b.scheduler.ReportRenderError(fmt.Sprintf("топика MQTT '%s'", msg.Topic()), err)
+		}
+		return
+	}
+	if text == "" {
+		logger.Warnf("Пустое сообщение из топика MQTT '%s' не отправлено", msg.Topic())
+		return
+	}
+
+	if err := b.scheduler.Deliver(context.Background(), scheduler.OutgoingMessage{ChatName: route.ChatName, Message: text, LinkPreview: true}); err != nil {
+		logger.Errorf("❌ Ошибка отправки сообщения из топика MQTT '%s' в чат '%s': %v | UI: http://localhost:8080",
+			msg.Topic(), route.ChatName, err)
+	}
+}
+
+// publishEvents публикует события планировщика в топик событий
+func (b *MQTTBridge) publishEvents() {
+	events, _ := b.scheduler.Subscribe()
+	for evt := range events {
+		payload, err := json.Marshal(evt)
+		if err != nil {
+			logger.Errorf("Ошибка сериализации события: %v", err)
+			continue
+		}
+		if !b.client.IsConnectionOpen() {
+			continue
+		}
+		token := b.client.Publish(b.eventsTopic, mqttQoS, false, payload)
+		if token.WaitTimeout(mqttTimeout) && token.Error() != nil {
+			logger.Errorf("Ошибка публикации события в MQTT: %v", token.Error())
+		}
+	}
+}
+
+// loadMQTTRoutes загружает маршруты MQTT из хранилища
+func loadMQTTRoutes(st *scheduler.Storage) ([]MQTTRoute, error) {
+	rows, err := st.DB().Query(`SELECT topic, chat_name, template FROM mqtt_routes ORDER BY topic`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	routes := []MQTTRoute{}
+	for rows.Next() {
+		var route MQTTRoute
+		if err := rows.Scan(&route.Topic, &route.ChatName, &route.Template); err != nil {
+			return nil, err
+		}
+		for _, field := range []*string{&route.ChatName, &route.Template} {
+			if *field, err = st.DecryptField(*field); err != nil {
+				return nil, err
+			}
+		}
+		routes = append(routes, route)
+	}
+	return routes, rows.Err()
+}
+
+// saveMQTTRoutes заменяет маршруты MQTT
+func saveMQTTRoutes(st *scheduler.Storage, routes []MQTTRoute) error {
+	tx, err := st.DB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM mqtt_routes`); err != nil {
+		return err
+	}
+	for _, route := range routes {
+		chatName, err := st.EncryptField(route.ChatName)
+		if err != nil {
+			return err
+		}
+		template, err := st.EncryptField(route.Template)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO mqtt_routes (topic, chat_name, template) VALUES (?, ?, ?)`,
+			route.Topic, chatName, template); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
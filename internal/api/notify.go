@@ -0,0 +1,268 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+const (
+	SinkKindSlack   = "slack"
+	SinkKindDiscord = "discord"
+
+	// NotifySendFailed - ошибка отправки сообщения
+	NotifySendFailed = "send_failed"
+	// NotifyLoggedOut - сессия WhatsApp завершена
+	NotifyLoggedOut = "logged_out"
+	// NotifyAlert - прочие оповещения (приостановка чата, автоблокировка)
+	NotifyAlert = "alert"
+	// NotifyDailySummary - ежедневная сводка отправок
+	NotifyDailySummary = "daily_summary"
+
+	// summaryTimeEnv - время ежедневной сводки ЧЧ:ММ
+	summaryTimeEnv = "WHATSAPP_SCHEDULER_SUMMARY_TIME"
+	// defaultSummaryTime - время ежедневной сводки по умолчанию
+	defaultSummaryTime = "21:00"
+	// webhookTimeout - максимальное время запроса к вебхуку
+	webhookTimeout = 10 * time.Second
+)
+
+// notifyEvents - допустимые типы уведомлений
+var notifyEvents = []string{NotifySendFailed, NotifyLoggedOut, NotifyAlert, NotifyDailySummary}
+
+// NotificationSink - вебхук Slack или Discord с набором типов уведомлений
+type NotificationSink struct {
+	Kind   string   `json:"kind"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// Notifier рассылает уведомления о работе планировщика во внешние чаты
+type Notifier struct {
+	mutex       sync.RWMutex
+	scheduler   *scheduler.Scheduler
+	sinks       []NotificationSink
+	client      *http.Client
+	summaryTime time.Duration
+	digest      digestRecipients
+}
+
+// validate проверяет вебхук
+func (n *NotificationSink) validate() error {
+	n.URL = strings.TrimSpace(n.URL)
+	if n.Kind != SinkKindSlack && n.Kind != SinkKindDiscord {
+		return fmt.Errorf("неверный тип вебхука '%s', допустимо: slack, discord", n.Kind)
+	}
+	if u, err := url.Parse(n.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("неверный адрес вебхука '%s'", n.URL)
+	}
+	if len(n.Events) == 0 {
+		return fmt.Errorf("не указаны типы уведомлений для вебхука '%s'", n.URL)
+	}
+	for _, event := range n.Events {
+		if !slices.Contains(notifyEvents, event) {
+			return fmt.Errorf("неизвестный тип уведомления '%s', допустимо: %s", event, strings.Join(notifyEvents, ", "))
+		}
+	}
+	return nil
+}
+
+// validateSinks проверяет список вебхуков
+func validateSinks(sinks []NotificationSink) error {
+	urls := map[string]bool{}
+	for i := range sinks {
+		if err := sinks[i].validate(); err != nil {
+			return err
+		}
+		if urls[sinks[i].URL] {
+			return fmt.Errorf("вебхук '%s' указан несколько раз", sinks[i].URL)
+		}
+		urls[sinks[i].URL] = true
+	}
+	return nil
+}
+
+// parseClock разбирает время суток ЧЧ:ММ
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("неверное время '%s', пример: 21:00", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// StartNotifier загружает вебхуки и начинает рассылку уведомлений
+func StartNotifier(s *scheduler.Scheduler) (*Notifier, error) {
+	summaryTime := os.Getenv(summaryTimeEnv)
+	if summaryTime == "" {
+		summaryTime = defaultSummaryTime
+	}
+	at, err := parseClock(summaryTime)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", summaryTimeEnv, err)
+	}
+
+	digest, err := digestRecipientsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	sinks, err := loadNotificationSinks(s.Storage().DB())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки вебхуков: %v", err)
+	}
+
+	n := &Notifier{
+		scheduler:   s,
+		sinks:       sinks,
+		client:      &http.Client{Timeout: webhookTimeout},
+		summaryTime: at,
+		digest:      digest,
+	}
+	go n.watchEvents()
+	go n.dailySummaries()
+	return n, nil
+}
+
+// Sinks возвращает настроенные вебхуки
+func (n *Notifier) Sinks() []NotificationSink {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return append([]NotificationSink{}, n.sinks...)
+}
+
+// SetSinks проверяет и сохраняет вебхуки
+func (n *Notifier) SetSinks(sinks []NotificationSink) error {
+	if err := validateSinks(sinks); err != nil {
+		return err
+	}
+	if err := saveNotificationSinks(n.scheduler.Storage().DB(), sinks); err != nil {
+		return fmt.Errorf("ошибка сохранения вебхуков: %v", err)
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.sinks = sinks
+	return nil
+}
+
+// Notify отправляет текст во все вебхуки, подписанные на тип event
+func (n *Notifier) Notify(event, text string) {
+	for _, sink := range n.Sinks() {
+		if slices.Contains(sink.Events, event) {
+			if err := n.post(sink, text); err != nil {
+				logger.Errorf("Ошибка отправки уведомления в %s: %v", sink.Kind, err)
+			}
+		}
+	}
+}
+
+// post отправляет текст в вебхук в формате Slack или Discord
+func (n *Notifier) post(sink NotificationSink, text string) error {
+	payload := map[string]string{"text": text}
+	if sink.Kind == SinkKindDiscord {
+		payload = map[string]string{"content": text}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(sink.URL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("вебхук вернул %s", resp.Status)
+	}
+	return nil
+}
+
+// watchEvents превращает события планировщика в уведомления
+func (n *Notifier) watchEvents() {
+	events, _ := n.scheduler.Subscribe()
+	for evt := range events {
+		switch {
+		case evt.Kind == scheduler.EventKindSendResult && evt.Error != "":
+			n.Notify(NotifySendFailed, fmt.Sprintf("❌ WhatsApp Scheduler: ошибка отправки в чат '%s': %s", evt.ChatName, evt.Error))
+		case evt.Kind == scheduler.EventKindAlert && evt.Status == scheduler.AlertKindLoggedOut:
+			n.Notify(NotifyLoggedOut, "🔒 WhatsApp Scheduler: "+evt.Message)
+		case evt.Kind == scheduler.EventKindAlert && evt.Status == scheduler.AlertKindAccountFlagged:
+			n.Notify(NotifyAlert, "🛑 WhatsApp Scheduler: "+evt.Message)
+		case evt.Kind == scheduler.EventKindAlert:
+			n.Notify(NotifyAlert, "⚠️ WhatsApp Scheduler: "+evt.Message)
+		}
+	}
+}
+
+// dailySummaries раз в сутки отправляет сводку за прошедшие 24 часа в вебхуки и получателям дайджеста
+func (n *Notifier) dailySummaries() {
+	for {
+		now := time.Now()
+		next := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Add(n.summaryTime)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		time.Sleep(time.Until(next))
+
+		text, err := n.scheduler.Digest(next.AddDate(0, 0, -1), next)
+		if err != nil {
+			logger.Errorf("Ошибка формирования ежедневной сводки: %v", err)
+			continue
+		}
+		n.Notify(NotifyDailySummary, text)
+		n.digest.send(n.scheduler, text)
+	}
+}
+
+// loadNotificationSinks загружает вебхуки из хранилища
+func loadNotificationSinks(db *sql.DB) ([]NotificationSink, error) {
+	rows, err := db.Query(`SELECT url, kind, events FROM notification_sinks ORDER BY url`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sinks := []NotificationSink{}
+	for rows.Next() {
+		var sink NotificationSink
+		var events string
+		if err := rows.Scan(&sink.URL, &sink.Kind, &events); err != nil {
+			return nil, err
+		}
+		sink.Events = strings.Split(events, ",")
+		sinks = append(sinks, sink)
+	}
+	return sinks, rows.Err()
+}
+
+// saveNotificationSinks заменяет вебхуки
+func saveNotificationSinks(db *sql.DB, sinks []NotificationSink) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM notification_sinks`); err != nil {
+		return err
+	}
+	for _, sink := range sinks {
+		if _, err := tx.Exec(`INSERT INTO notification_sinks (url, kind, events) VALUES (?, ?, ?)`,
+			sink.URL, sink.Kind, strings.Join(sink.Events, ",")); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
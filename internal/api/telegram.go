@@ -0,0 +1,218 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+const (
+	// telegramTokenEnv - токен Telegram бота управления, без него бот отключен
+	telegramTokenEnv = "WHATSAPP_SCHEDULER_TELEGRAM_TOKEN"
+	// telegramChatEnv - ID чата оператора: бот выполняет команды только из него и шлет туда уведомления
+	telegramChatEnv = "WHATSAPP_SCHEDULER_TELEGRAM_CHAT_ID"
+	// telegramAPIURL - адрес Bot API
+	telegramAPIURL = "https://api.telegram.org/bot"
+	// telegramPollTimeout - время long polling запроса getUpdates
+	telegramPollTimeout = 30 * time.Second
+	// telegramRetryDelay - пауза перед повтором после ошибки Bot API
+	telegramRetryDelay = 5 * time.Second
+)
+
+const telegramHelp = `Команды:
+/tasks - активные задачи
+/pause ID - приостановить задачу
+/resume ID - возобновить задачу
+/stop ID - остановить задачу
+/send Чат | текст - отправить сообщение сейчас`
+
+// TelegramBot - бот удаленного управления планировщиком через Telegram
+type TelegramBot struct {
+	scheduler *scheduler.Scheduler
+	token     string
+	chatID    int64
+	client    *http.Client
+}
+
+// telegramUpdate - входящее обновление Bot API
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// StartTelegramBot запускает бота из переменных окружения.
+// Возвращает nil, если токен не задан
+func StartTelegramBot(s *scheduler.Scheduler) (*TelegramBot, error) {
+	token := os.Getenv(telegramTokenEnv)
+	if token == "" {
+		return nil, nil
+	}
+	chatID, err := strconv.ParseInt(os.Getenv(telegramChatEnv), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("для Telegram бота требуется %s с ID чата оператора", telegramChatEnv)
+	}
+
+	bot := &TelegramBot{
+		scheduler: s,
+		token:     token,
+		chatID:    chatID,
+		client:    &http.Client{Timeout: telegramPollTimeout + 10*time.Second},
+	}
+	go bot.poll()
+	go bot.forwardAlerts()
+	logger.Info("🤖 Telegram бот управления запущен")
+	return bot, nil
+}
+
+// call выполняет метод Bot API и разбирает поле result ответа
+func (b *TelegramBot) call(method string, params url.Values, result any) error {
+	resp, err := b.client.PostForm(telegramAPIURL+b.token+"/"+method, params)
+	if err != nil {
+		// Ошибка содержит URL с токеном, его нельзя писать в лог
+		return fmt.Errorf("ошибка запроса %s к Telegram", method)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("неверный ответ Telegram: %v", err)
+	}
+	if !body.OK {
+		return fmt.Errorf("ошибка Telegram: %s", body.Description)
+	}
+	if result != nil {
+		return json.Unmarshal(body.Result, result)
+	}
+	return nil
+}
+
+// send отправляет сообщение оператору
+func (b *TelegramBot) send(text string) {
+	params := url.Values{"chat_id": {strconv.FormatInt(b.chatID, 10)}, "text": {text}}
+	if err := b.call("sendMessage", params, nil); err != nil {
+		logger.Errorf("Ошибка отправки сообщения в Telegram: %v", err)
+	}
+}
+
+// poll получает команды через long polling
+func (b *TelegramBot) poll() {
+	var offset int64
+	for {
+		var updates []telegramUpdate
+		params := url.Values{
+			"offset":          {strconv.FormatInt(offset, 10)},
+			"timeout":         {strconv.Itoa(int(telegramPollTimeout.Seconds()))},
+			"allowed_updates": {`["message"]`},
+		}
+		if err := b.call("getUpdates", params, &updates); err != nil {
+			logger.Warnf("⚠️ %v", err)
+			time.Sleep(telegramRetryDelay)
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			if update.Message == nil {
+				continue
+			}
+			if update.Message.Chat.ID != b.chatID {
+				logger.Warnf("Команда Telegram из постороннего чата %d отклонена", update.Message.Chat.ID)
+				continue
+			}
+			b.send(b.handleCommand(update.Message.Text))
+		}
+	}
+}
+
+// handleCommand выполняет команду оператора и возвращает ответ
+func (b *TelegramBot) handleCommand(text string) string {
+	command, args, _ := strings.Cut(strings.TrimSpace(text), " ")
+	// В группах команды приходят в виде /tasks@имя_бота
+	command, _, _ = strings.Cut(command, "@")
+	args = strings.TrimSpace(args)
+
+	switch command {
+	case "/tasks":
+		tasks := b.scheduler.ListTasks()
+		if len(tasks) == 0 {
+			return "Нет активных задач"
+		}
+		var lines []string
+		for _, task := range tasks {
+			line := fmt.Sprintf("%s\n  чат: %s, каждые %d мин", task.ID, task.ChatName, task.Interval)
+			if !task.NextSendAt.IsZero() {
+				line += ", следующая отправка " + task.NextSendAt.Local().Format("15:04 02.01")
+			}
+			if task.Paused {
+				line += " (пауза)"
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n")
+	case "/pause", "/resume":
+		if args == "" {
+			return "Укажите ID задачи: " + command + " ID"
+		}
+		if !b.scheduler.PauseTask(args, command == "/pause") {
+			return "Задача не найдена"
+		}
+		if command == "/pause" {
+			return "Задача приостановлена"
+		}
+		return "Задача возобновлена"
+	case "/stop":
+		if args == "" {
+			return "Укажите ID задачи: /stop ID"
+		}
+		if !b.scheduler.StopTask(args) {
+			return "Задача не найдена"
+		}
+		return "Задача остановлена"
+	case "/send":
+		chatName, message, ok := strings.Cut(args, "|")
+		if !ok {
+			return "Формат: /send Чат | текст"
+		}
+		msg, _, err := scheduler.PrepareSend(scheduler.SendRequest{ChatName: chatName, Message: message}, b.scheduler.Now())
+		if err != nil {
+			return "❌ " + err.Error()
+		}
+		result, err := b.scheduler.DeliverResult(context.Background(), msg)
+		if err != nil {
+			return "❌ Ошибка отправки: " + err.Error()
+		}
+		return fmt.Sprintf("✅ Сообщение отправлено в чат %s (ID %s)", msg.ChatName, result.MessageID)
+	default:
+		return telegramHelp
+	}
+}
+
+// forwardAlerts пересылает оператору ошибки отправки и предупреждения планировщика
+func (b *TelegramBot) forwardAlerts() {
+	events, _ := b.scheduler.Subscribe()
+	for evt := range events {
+		switch {
+		case evt.Kind == scheduler.EventKindSendResult && evt.Error != "":
+			b.send(fmt.Sprintf("❌ Ошибка отправки в чат '%s': %s", evt.ChatName, evt.Error))
+		case evt.Kind == scheduler.EventKindAlert:
+			b.send("⚠️ " + evt.Message)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+// Package cli - команды управления задачами из терминала:
+// whatsapp-scheduler task add|list|stop и whatsapp-scheduler send
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"whatsapp-scheduler/pkg/scheduler"
+	"whatsapp-scheduler/pkg/whatsapp"
+)
+
+const (
+	// serverURLEnv - переменная окружения с адресом запущенного сервера
+	serverURLEnv = "WHATSAPP_SCHEDULER_URL"
+	// apiKeyEnv - переменная окружения с ключом API, если сервер требует ключ
+	apiKeyEnv = "WHATSAPP_SCHEDULER_API_KEY"
+	// defaultServerURL - адрес сервера по умолчанию
+	defaultServerURL = "http://localhost:8080"
+	// requestTimeout - максимальное время запроса к серверу
+	requestTimeout = time.Minute
+	// taskTimeFormat - формат времени, который принимает POST /schedule
+	taskTimeFormat = "2006-01-02T15:04:05.000Z"
+)
+
+const usage = `Использование:
+  whatsapp-scheduler task add --chat ЧАТ --message ТЕКСТ --every 30m [--delay 5m] [--start 2025-01-02T09:00] [--for 24h | --until 2025-01-03T18:00] [--replace]
+  whatsapp-scheduler task list
+  whatsapp-scheduler task stop ID
+  whatsapp-scheduler send --chat ЧАТ --message ТЕКСТ [--when "tomorrow 9am"]
+
+Все команды принимают --server (по умолчанию $` + serverURLEnv + ` или ` + defaultServerURL + `).
+Ключ API для сервера с ключами пользователей берется из $` + apiKeyEnv + `.
+Если сервер не запущен, команды task работают с базой данных напрямую: добавленная задача начнет
+выполняться после запуска сервера. send подключается к WhatsApp через сохраненную сессию.
+`
+
+// Paths - расположение баз данных приложения для работы без сервера
+type Paths struct {
+	Storage string
+	Session string
+}
+
+// IsCommand проверяет, что аргументы командной строки начинаются с команды CLI
+func IsCommand(args []string) bool {
+	return len(args) > 0 && (args[0] == "task" || args[0] == "send")
+}
+
+// Run выполняет команду CLI и возвращает код завершения процесса
+func Run(args []string, paths Paths) int {
+	var err error
+	switch {
+	case len(args) >= 2 && args[0] == "task" && args[1] == "add":
+		err = taskAdd(args[2:], paths)
+	case len(args) >= 2 && args[0] == "task" && args[1] == "list":
+		err = taskList(args[2:], paths)
+	case len(args) >= 2 && args[0] == "task" && args[1] == "stop":
+		err = taskStop(args[2:], paths)
+	case len(args) >= 1 && args[0] == "send":
+		err = send(args[1:], paths)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка:", err)
+		return 1
+	}
+	return 0
+}
+
+// newFlagSet создает набор флагов команды с общим флагом --server
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	server := os.Getenv(serverURLEnv)
+	if server == "" {
+		server = defaultServerURL
+	}
+	return flags, flags.String("server", server, "адрес запущенного сервера")
+}
+
+// parseTime разбирает время в формате RFC3339 или "2006-01-02T15:04" (локальное время)
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("неверное время '%s', пример: 2025-01-02T09:00", value)
+}
+
+func taskAdd(args []string, paths Paths) error {
+	flags, server := newFlagSet("task add")
+	chat := flags.String("chat", "", "название чата")
+	message := flags.String("message", "", "текст сообщения")
+	every := flags.Duration("every", 0, "интервал отправки, например 30m")
+	delay := flags.Duration("delay", 0, "максимальная случайная задержка, например 5m")
+	start := flags.String("start", "", "время начала (по умолчанию сейчас)")
+	until := flags.String("until", "", "время окончания")
+	duration := flags.Duration("for", 24*time.Hour, "длительность задачи, если не указан --until")
+	replace := flags.Bool("replace", false, "заменить активную задачу")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *every < time.Minute || *every%time.Minute != 0 {
+		return fmt.Errorf("--every должен быть целым числом минут, не меньше 1m")
+	}
+	if *delay%time.Minute != 0 {
+		return fmt.Errorf("--delay должен быть целым числом минут")
+	}
+
+	startTime := time.Now()
+	if *start != "" {
+		var err error
+		if startTime, err = parseTime(*start); err != nil {
+			return err
+		}
+	}
+	endTime := startTime.Add(*duration)
+	if *until != "" {
+		var err error
+		if endTime, err = parseTime(*until); err != nil {
+			return err
+		}
+	}
+
+	task := map[string]interface{}{
+		"chat_name":    *chat,
+		"message":      *message,
+		"interval":     int(*every / time.Minute),
+		"random_delay": int(*delay / time.Minute),
+		"start_time":   startTime.UTC().Format(taskTimeFormat),
+		"end_time":     endTime.UTC().Format(taskTimeFormat),
+	}
+	path := "/schedule"
+	if *replace {
+		path = "/replace-task"
+	}
+
+	var resp struct {
+		TaskID string `json:"task_id"`
+	}
+	status, err := call(*server, http.MethodPost, path, task, &resp)
+	if errors.Is(err, errServerUnavailable) {
+		return taskAddOffline(&scheduler.ScheduledTask{
+			ChatName:    *chat,
+			Message:     *message,
+			Interval:    int(*every / time.Minute),
+			RandomDelay: int(*delay / time.Minute),
+			StartTime:   startTime,
+			EndTime:     endTime,
+		}, *replace, paths)
+	}
+	if err != nil {
+		if status == http.StatusConflict {
+			return fmt.Errorf("%v, используйте --replace для замены", err)
+		}
+		return err
+	}
+	fmt.Println(resp.TaskID)
+	return nil
+}
+
+// taskRow - задача в выводе task list
+type taskRow struct {
+	ID        string    `json:"id"`
+	ChatName  string    `json:"chat_name"`
+	Message   string    `json:"message"`
+	Interval  int       `json:"interval"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+func taskList(args []string, paths Paths) error {
+	flags, server := newFlagSet("task list")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var tasks []taskRow
+	_, err := call(*server, http.MethodGet, "/tasks", nil, &tasks)
+	if errors.Is(err, errServerUnavailable) {
+		if tasks, err = taskListOffline(paths); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		fmt.Println("Нет активных задач")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tЧАТ\tИНТЕРВАЛ\tНАЧАЛО\tОКОНЧАНИЕ\tСООБЩЕНИЕ")
+	for _, task := range tasks {
+		fmt.Fprintf(w, "%s\t%s\t%d мин\t%s\t%s\t%s\n", task.ID, task.ChatName, task.Interval,
+			task.StartTime.Local().Format("02.01.2006 15:04"), task.EndTime.Local().Format("02.01.2006 15:04"),
+			strings.ReplaceAll(task.Message, "\n", " "))
+	}
+	return w.Flush()
+}
+
+func taskStop(args []string, paths Paths) error {
+	flags, server := newFlagSet("task stop")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("укажите ID задачи: task stop ID")
+	}
+
+	_, err := call(*server, http.MethodPost, "/stop/"+url.PathEscape(flags.Arg(0)), nil, nil)
+	if errors.Is(err, errServerUnavailable) {
+		err = taskStopOffline(flags.Arg(0), paths)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("Задача остановлена")
+	return nil
+}
+
+// taskAddOffline сохраняет задачу в базу данных остановленного сервера
+func taskAddOffline(task *scheduler.ScheduledTask, replace bool, paths Paths) error {
+	s, err := openOffline(paths)
+	if err != nil {
+		return err
+	}
+	id, err := s.AddStoredTask(scheduler.NewTaskFromRequest(task), replace)
+	if errors.Is(err, scheduler.ErrTaskExists) {
+		return fmt.Errorf("%v, используйте --replace для замены", err)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(id)
+	return nil
+}
+
+// taskListOffline возвращает задачи из базы данных остановленного сервера
+func taskListOffline(paths Paths) ([]taskRow, error) {
+	s, err := openOffline(paths)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.StoredTasks()
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]taskRow, 0, len(stored))
+	for _, task := range stored {
+		tasks = append(tasks, taskRow{
+			ID:        task.ID,
+			ChatName:  task.ChatName,
+			Message:   task.Message,
+			Interval:  task.Interval,
+			StartTime: task.StartTime,
+			EndTime:   task.EndTime,
+		})
+	}
+	return tasks, nil
+}
+
+// taskStopOffline удаляет задачу из базы данных остановленного сервера
+func taskStopOffline(id string, paths Paths) error {
+	s, err := openOffline(paths)
+	if err != nil {
+		return err
+	}
+	return s.StopStoredTask(id)
+}
+
+// openOffline открывает планировщик над базой данных без сервера и подключения к WhatsApp
+func openOffline(paths Paths) (*scheduler.Scheduler, error) {
+	storage, err := openStorage(paths)
+	if err != nil {
+		return nil, err
+	}
+	return scheduler.OpenOffline(storage)
+}
+
+// openStorage открывает базу данных приложения с ключом шифрования из переменных окружения
+func openStorage(paths Paths) (*scheduler.Storage, error) {
+	storage, err := scheduler.OpenStorage(paths.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := storage.EnableEncryptionFromEnv(); err != nil {
+		return nil, err
+	}
+	return storage, nil
+}
+
+func send(args []string, paths Paths) error {
+	flags, server := newFlagSet("send")
+	var req scheduler.SendRequest
+	flags.StringVar(&req.ChatName, "chat", "", "название чата")
+	flags.StringVar(&req.Message, "message", "", "текст сообщения")
+	flags.StringVar(&req.When, "when", "", "время отправки на естественном языке")
+	flags.StringVar(&req.Timezone, "timezone", "", "часовой пояс IANA для --when")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var resp struct {
+		Message   string `json:"message"`
+		MessageID string `json:"message_id"`
+	}
+	_, err := call(*server, http.MethodPost, "/send", req, &resp)
+	if errors.Is(err, errServerUnavailable) {
+		return sendDirect(req, paths)
+	}
+	if err != nil {
+		return err
+	}
+	if resp.MessageID != "" {
+		fmt.Printf("%s, ID %s\n", resp.Message, resp.MessageID)
+		return nil
+	}
+	fmt.Println(resp.Message)
+	return nil
+}
+
+// sendDirect отправляет сообщение без сервера через сохраненную сессию WhatsApp
+func sendDirect(req scheduler.SendRequest, paths Paths) error {
+	msg, scheduledAt, err := scheduler.PrepareSend(req, time.Now())
+	if err != nil {
+		return err
+	}
+	if scheduledAt != nil {
+		return fmt.Errorf("отложенная отправка требует запущенного сервера")
+	}
+
+	storage, err := openStorage(paths)
+	if err != nil {
+		return err
+	}
+	client := whatsapp.NewClient(storage)
+	client.RequireSession = true
+	if err := client.Connect(paths.Session); err != nil {
+		return err
+	}
+	defer client.Disconnect()
+
+	s, err := scheduler.NewScheduler(storage, client, scheduler.DefaultConfig())
+	if err != nil {
+		return err
+	}
+	result, err := s.DeliverResult(context.Background(), msg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Сообщение отправлено, ID %s\n", result.MessageID)
+	return nil
+}
+
+// errServerUnavailable - сервер не запущен или недоступен по сети
+var errServerUnavailable = errors.New("сервер недоступен")
+
+// call выполняет запрос к API сервера и разбирает JSON ответа в result.
+// Возвращает HTTP статус и ошибку из поля "error" ответа
+func call(server, method, path string, body, result interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimRight(server, "/")+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key := os.Getenv(apiKeyEnv); key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+
+	resp, err := (&http.Client{Timeout: requestTimeout}).Do(req)
+	if err != nil {
+		var netErr *net.OpError
+		if errors.As(err, &netErr) && netErr.Op == "dial" {
+			return 0, fmt.Errorf("%w (%s)", errServerUnavailable, server)
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return resp.StatusCode, errors.New(apiErr.Error)
+		}
+		return resp.StatusCode, fmt.Errorf("сервер вернул %s", resp.Status)
+	}
+	if result != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return resp.StatusCode, fmt.Errorf("неверный ответ сервера: %v", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,199 @@
+// Package tui - терминальная панель управления для работы по SSH без браузера
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"whatsapp-scheduler/pkg/scheduler"
+	"whatsapp-scheduler/pkg/whatsapp"
+)
+
+const (
+	// refreshInterval - период обновления статуса и обратных отсчетов
+	refreshInterval = time.Second
+	// maxResults - сколько последних событий показывать
+	maxResults = 100
+)
+
+// dashboard - состояние терминальной панели
+type dashboard struct {
+	app     *tview.Application
+	status  *tview.TextView
+	tasks   *tview.Table
+	results *tview.TextView
+
+	s  *scheduler.Scheduler
+	wa *whatsapp.Client
+	// taskIDs - ID задач в порядке строк таблицы
+	taskIDs []string
+}
+
+// Run показывает панель со статусом подключения, активными задачами и последними
+// отправками. Блокирует до выхода пользователя (q или Ctrl+C). wa может быть nil (режим --mock)
+func Run(s *scheduler.Scheduler, wa *whatsapp.Client) error {
+	d := &dashboard{
+		app:     tview.NewApplication(),
+		status:  tview.NewTextView().SetDynamicColors(true),
+		tasks:   tview.NewTable().SetSelectable(true, false).SetFixed(1, 0),
+		results: tview.NewTextView().SetDynamicColors(true).SetScrollable(true),
+		s:       s,
+		wa:      wa,
+	}
+	d.tasks.SetBorder(true).SetTitle(" Задачи ")
+	d.results.SetBorder(true).SetTitle(" Последние события ")
+	help := tview.NewTextView().SetDynamicColors(true).
+		SetText("[yellow]p[white] пауза/продолжить  [yellow]s[white] остановить  [yellow]Tab[white] переключить панель  [yellow]q[white] выход")
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(d.status, 1, 0, false).
+		AddItem(d.tasks, 0, 1, true).
+		AddItem(d.results, 0, 1, false).
+		AddItem(help, 1, 0, false)
+
+	d.app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Rune() == 'q':
+			d.app.Stop()
+			return nil
+		case event.Key() == tcell.KeyTab:
+			if d.tasks.HasFocus() {
+				d.app.SetFocus(d.results)
+			} else {
+				d.app.SetFocus(d.tasks)
+			}
+			return nil
+		case event.Rune() == 'p':
+			d.togglePause()
+			return nil
+		case event.Rune() == 's':
+			if task := d.selectedTask(); task != nil {
+				d.s.StopTask(task.ID)
+				d.refresh()
+			}
+			return nil
+		}
+		return event
+	})
+
+	events, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+	go d.watchEvents(events)
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	go func() {
+		for range ticker.C {
+			d.app.QueueUpdateDraw(d.refresh)
+		}
+	}()
+
+	d.refresh()
+	return d.app.SetRoot(layout, true).Run()
+}
+
+// refresh перерисовывает строку статуса и таблицу задач
+func (d *dashboard) refresh() {
+	connection := "[green]подключен"
+	switch {
+	case d.wa == nil:
+		connection = "[yellow]режим --mock"
+	case !d.wa.IsAuthorized():
+		connection = "[red]не авторизован"
+	case !d.wa.IsConnected():
+		connection = "[red]соединение потеряно"
+	}
+	d.status.SetText(fmt.Sprintf("WhatsApp: %s[white]  |  %s", connection, time.Now().Format("15:04:05 02.01.2006")))
+
+	tasks := d.s.ListTasks()
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+
+	d.tasks.Clear()
+	for col, title := range []string{"ID", "Чат", "Интервал", "До отправки", "Состояние"} {
+		d.tasks.SetCell(0, col, tview.NewTableCell(title).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+	}
+	d.taskIDs = d.taskIDs[:0]
+	for i, task := range tasks {
+		countdown := "-"
+		if !task.NextSendAt.IsZero() {
+			countdown = time.Until(task.NextSendAt).Truncate(time.Second).String()
+		}
+		state := "[green]активна"
+		if task.Paused {
+			state = "[yellow]пауза"
+		}
+
+		row := i + 1
+		d.tasks.SetCell(row, 0, tview.NewTableCell(task.ID))
+		d.tasks.SetCell(row, 1, tview.NewTableCell(tview.Escape(task.ChatName)).SetExpansion(1))
+		d.tasks.SetCell(row, 2, tview.NewTableCell(fmt.Sprintf("%d мин", task.Interval)))
+		d.tasks.SetCell(row, 3, tview.NewTableCell(countdown))
+		d.tasks.SetCell(row, 4, tview.NewTableCell(state))
+		d.taskIDs = append(d.taskIDs, task.ID)
+	}
+}
+
+// selectedTask возвращает задачу выбранной строки таблицы
+func (d *dashboard) selectedTask() *scheduler.ScheduledTask {
+	row, _ := d.tasks.GetSelection()
+	if row < 1 || row > len(d.taskIDs) {
+		return nil
+	}
+	for _, task := range d.s.ListTasks() {
+		if task.ID == d.taskIDs[row-1] {
+			return task
+		}
+	}
+	return nil
+}
+
+// togglePause приостанавливает или возобновляет выбранную задачу
+func (d *dashboard) togglePause() {
+	if task := d.selectedTask(); task != nil {
+		d.s.PauseTask(task.ID, !task.Paused)
+		d.refresh()
+	}
+}
+
+// watchEvents выводит события планировщика в панель последних событий
+func (d *dashboard) watchEvents(events <-chan scheduler.Event) {
+	var lines []string
+	for evt := range events {
+		lines = append(lines, formatEvent(evt))
+		if len(lines) > maxResults {
+			lines = lines[len(lines)-maxResults:]
+		}
+
+		text := ""
+		for i := len(lines) - 1; i >= 0; i-- {
+			text += lines[i] + "\n"
+		}
+		d.app.QueueUpdateDraw(func() {
+			d.results.SetText(text)
+		})
+	}
+}
+
+// formatEvent форматирует событие в строку панели
+func formatEvent(evt scheduler.Event) string {
+	at := evt.CreatedAt.Local().Format("15:04:05")
+	switch evt.Kind {
+	case scheduler.EventKindSendResult:
+		if evt.Error != "" {
+			return fmt.Sprintf("%s [red]✗[white] %s: %s", at, tview.Escape(evt.ChatName), tview.Escape(evt.Error))
+		}
+		return fmt.Sprintf("%s [green]✓[white] %s", at, tview.Escape(evt.ChatName))
+	case scheduler.EventKindTaskAdded:
+		return fmt.Sprintf("%s задача %s добавлена (%s)", at, evt.TaskID, tview.Escape(evt.ChatName))
+	case scheduler.EventKindTaskStopped:
+		return fmt.Sprintf("%s задача %s остановлена", at, evt.TaskID)
+	case scheduler.EventKindAlert:
+		return fmt.Sprintf("%s [yellow]⚠[white] %s", at, tview.Escape(evt.Message))
+	default:
+		return fmt.Sprintf("%s %s", at, evt.Kind)
+	}
+}
@@ -0,0 +1,328 @@
+// Package update - самообновление приложения из релизов GitHub:
+// проверка новой версии, загрузка, проверка контрольной суммы и подписи, замена исполняемого файла
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// releasesURL - последний релиз в GitHub
+	releasesURL = "https://api.github.com/repos/Pennywise007/WhatsappScheduler/releases/latest"
+	// checksumsAsset - файл релиза с SHA-256 бинарных файлов в формате sha256sum
+	checksumsAsset = "checksums.txt"
+	// signatureAsset - подпись ed25519 файла checksums.txt в base64
+	signatureAsset = "checksums.txt.sig"
+	// maxBinarySize - максимальный размер загружаемого бинарного файла
+	maxBinarySize = 200 << 20
+	// requestTimeout - максимальное время загрузки одного файла релиза
+	requestTimeout = 5 * time.Minute
+	// restartedEnv - переменная окружения процесса, запущенного Restart
+	restartedEnv = "WHATSAPP_SCHEDULER_RESTARTED"
+)
+
+// Version - версия сборки, задается при сборке:
+// go build -ldflags "-X whatsapp-scheduler/internal/update.Version=v1.2.3"
+var Version = "dev"
+
+// PublicKey - открытый ключ ed25519 в base64 для проверки подписи релиза, задается при сборке.
+// Без ключа обновление не устанавливается: контрольные суммы загружаются из того же релиза,
+// что и бинарный файл, и без подписи ничего не подтверждают
+var PublicKey = ""
+
+// ErrNotVerifiable - сборка не может проверить подлинность релиза: нет открытого ключа
+// или версии, с которой сравнивается релиз
+var ErrNotVerifiable = errors.New("обновление недоступно для этой сборки")
+
+// Release - опубликованный релиз
+type Release struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	// Available - релиз новее текущей версии
+	Available bool `json:"available"`
+
+	assets map[string]string
+}
+
+// AssetName возвращает имя бинарного файла релиза для текущей платформы
+func AssetName() string {
+	name := fmt.Sprintf("whatsapp-scheduler_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Check запрашивает последний релиз и сравнивает его с текущей версией
+func Check(ctx context.Context) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, releasesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса релизов GitHub: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub вернул %s", resp.Status)
+	}
+
+	var body struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+		Assets  []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("неверный ответ GitHub: %v", err)
+	}
+
+	release := &Release{
+		Version:   body.TagName,
+		URL:       body.HTMLURL,
+		Available: newerVersion(body.TagName, Version),
+		assets:    make(map[string]string),
+	}
+	for _, asset := range body.Assets {
+		release.assets[asset.Name] = asset.URL
+	}
+	return release, nil
+}
+
+// Supported проверяет, что сборка может установить обновление: при сборке заданы версия
+// и открытый ключ подписи релизов. Иначе возвращает ErrNotVerifiable с причиной
+func Supported() error {
+	if _, ok := parseVersion(Version); !ok {
+		return fmt.Errorf("%w: версия сборки %s не задана при сборке", ErrNotVerifiable, Version)
+	}
+	if PublicKey == "" {
+		return fmt.Errorf("%w: при сборке не задан открытый ключ подписи релизов", ErrNotVerifiable)
+	}
+	return nil
+}
+
+// Apply загружает бинарный файл релиза для текущей платформы, проверяет его подпись
+// и контрольную сумму и заменяет им исполняемый файл. Новая версия запускается после Restart
+func Apply(ctx context.Context, release *Release) error {
+	if err := Supported(); err != nil {
+		return err
+	}
+	if !release.Available {
+		return fmt.Errorf("релиз %s не новее текущей версии %s", release.Version, Version)
+	}
+	binaryURL, ok := release.assets[AssetName()]
+	if !ok {
+		return fmt.Errorf("в релизе %s нет сборки %s", release.Version, AssetName())
+	}
+	checksumsURL, ok := release.assets[checksumsAsset]
+	if !ok {
+		return fmt.Errorf("в релизе %s нет %s", release.Version, checksumsAsset)
+	}
+
+	checksums, err := download(ctx, checksumsURL)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(ctx, release, checksums); err != nil {
+		return err
+	}
+	expected, err := findChecksum(checksums, AssetName())
+	if err != nil {
+		return err
+	}
+
+	binary, err := download(ctx, binaryURL)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(binary)
+	if hex.EncodeToString(sum[:]) != expected {
+		return fmt.Errorf("контрольная сумма %s не совпадает, обновление отменено", AssetName())
+	}
+	return replaceExecutable(binary)
+}
+
+// verifySignature проверяет подпись файла контрольных сумм открытым ключом PublicKey
+func verifySignature(ctx context.Context, release *Release, checksums []byte) error {
+	if PublicKey == "" {
+		return fmt.Errorf("%w: при сборке не задан открытый ключ подписи релизов", ErrNotVerifiable)
+	}
+	key, err := base64.StdEncoding.DecodeString(PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("неверный открытый ключ обновлений")
+	}
+	signatureURL, ok := release.assets[signatureAsset]
+	if !ok {
+		return fmt.Errorf("в релизе %s нет подписи %s", release.Version, signatureAsset)
+	}
+	data, err := download(ctx, signatureURL)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || !ed25519.Verify(key, checksums, signature) {
+		return fmt.Errorf("подпись релиза %s не прошла проверку, обновление отменено", release.Version)
+	}
+	return nil
+}
+
+// findChecksum ищет SHA-256 файла name в выводе sha256sum
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("в %s нет контрольной суммы %s", checksumsAsset, name)
+}
+
+// download загружает файл релиза
+func download(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки обновления: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ошибка загрузки обновления: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBinarySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки обновления: %v", err)
+	}
+	if len(data) > maxBinarySize {
+		return nil, fmt.Errorf("файл обновления больше %d МБ", maxBinarySize>>20)
+	}
+	return data, nil
+}
+
+// replaceExecutable заменяет исполняемый файл. Запущенный файл переименовывается в .old
+// (Windows не позволяет перезаписать его) и удаляется при следующем запуске
+func replaceExecutable(binary []byte) error {
+	executable, err := executablePath()
+	if err != nil {
+		return err
+	}
+	tmp := executable + ".new"
+	if err := os.WriteFile(tmp, binary, 0755); err != nil {
+		return fmt.Errorf("ошибка записи обновления: %v", err)
+	}
+
+	old := executable + ".old"
+	os.Remove(old)
+	if err := os.Rename(executable, old); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("ошибка замены исполняемого файла: %v", err)
+	}
+	if err := os.Rename(tmp, executable); err != nil {
+		// Возвращаем прежнюю версию на место
+		os.Rename(old, executable)
+		os.Remove(tmp)
+		return fmt.Errorf("ошибка замены исполняемого файла: %v", err)
+	}
+	return nil
+}
+
+// Cleanup удаляет прежнюю версию, оставшуюся после обновления
+func Cleanup() {
+	if executable, err := executablePath(); err == nil {
+		os.Remove(executable + ".old")
+	}
+}
+
+// Restart запускает исполняемый файл (уже обновленный) с теми же аргументами.
+// Вызывается после остановки сервера, чтобы новый процесс смог занять порт
+func Restart() error {
+	executable, err := executablePath()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), restartedEnv+"=1")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Start()
+}
+
+// Restarted проверяет, что процесс запущен Restart после обновления
+func Restarted() bool {
+	return os.Getenv(restartedEnv) != ""
+}
+
+// executablePath возвращает путь к исполняемому файлу без символических ссылок
+func executablePath() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(executable)
+}
+
+// newerVersion проверяет, что версия latest (v1.2.3) новее current.
+// Сборка без версии (dev) не обновляется: неизвестно, новее ли релиз
+func newerVersion(latest, current string) bool {
+	latestParts, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	currentParts, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	for i := range latestParts {
+		if latestParts[i] != currentParts[i] {
+			return latestParts[i] > currentParts[i]
+		}
+	}
+	return false
+}
+
+// parseVersion разбирает версию вида v1.2.3, суффикс (-rc1) не учитывается
+func parseVersion(version string) ([3]int, bool) {
+	var parts [3]int
+	version, _, _ = strings.Cut(strings.TrimPrefix(version, "v"), "-")
+	fields := strings.Split(version, ".")
+	if len(fields) > len(parts) {
+		return parts, false
+	}
+	for i, field := range fields {
+		value, err := strconv.Atoi(field)
+		if err != nil || value < 0 {
+			return parts, false
+		}
+		parts[i] = value
+	}
+	return parts, true
+}
@@ -0,0 +1,54 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNewerVersion(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.2.4", "v1.2.3", true},
+		{"v2.0.0", "v1.9.9", true},
+		{"v1.2.3", "v1.2.3", false},
+		{"v1.2.2", "v1.2.3", false},
+		{"v1.3.0-rc1", "v1.2.3", true},
+		// Сборка без версии не обновляется ни до какого релиза
+		{"v9.9.9", "dev", false},
+		{"v1.0.0", "", false},
+		{"latest", "v1.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := newerVersion(tt.latest, tt.current); got != tt.want {
+			t.Errorf("newerVersion(%q, %q) = %v, ожидалось %v", tt.latest, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestApplyRefusesUnverifiableBuilds(t *testing.T) {
+	version, key := Version, PublicKey
+	t.Cleanup(func() { Version, PublicKey = version, key })
+
+	release := &Release{Version: "v2.0.0", Available: true, assets: map[string]string{}}
+	tests := []struct {
+		name, version, key string
+	}{
+		{"dev без ключа", "dev", ""},
+		{"dev с ключом", "dev", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="},
+		{"версия без ключа", "v1.0.0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Version, PublicKey = tt.version, tt.key
+			if err := Apply(context.Background(), release); !errors.Is(err, ErrNotVerifiable) {
+				t.Fatalf("Apply: %v, ожидалась ErrNotVerifiable", err)
+			}
+			if err := verifySignature(context.Background(), release, nil); tt.key == "" && !errors.Is(err, ErrNotVerifiable) {
+				t.Fatalf("verifySignature без ключа: %v, ожидалась ErrNotVerifiable", err)
+			}
+		})
+	}
+}
@@ -2,99 +2,68 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"math/rand"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
-	"slices"
-	"strings"
-	"sync"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/mdp/qrterminal/v3"
 	"github.com/sirupsen/logrus"
-	"go.mau.fi/whatsmeow"
-	"go.mau.fi/whatsmeow/proto/waE2E"
-	"go.mau.fi/whatsmeow/store/sqlstore"
-	waTypes "go.mau.fi/whatsmeow/types"
-	"go.mau.fi/whatsmeow/types/events"
-	"google.golang.org/protobuf/proto"
-
-	// not using this package directly, but it's required for
-	// the whatsmeow library to work with SQLite3
-	_ "github.com/mattn/go-sqlite3"
-)
-
-type Scheduler struct {
-	tasks  map[string]*ScheduledTask
-	mutex  sync.RWMutex
-	client *whatsmeow.Client
-}
-
-type ScheduledTask struct {
-	ID          string    `json:"id"`
-	ChatName    string    `json:"chat_name"`
-	Message     string    `json:"message"`
-	Interval    int       `json:"interval"`
-	RandomDelay int       `json:"random_delay"`
-	StartTime   time.Time `json:"start_time"`
-	EndTime     time.Time `json:"end_time"`
-	stopChan    chan bool
-}
-
-// UnmarshalJSON для правильного парсинга времени
-func (t *ScheduledTask) UnmarshalJSON(data []byte) error {
-	type Alias ScheduledTask
-	aux := &struct {
-		StartTime string `json:"start_time"`
-		EndTime   string `json:"end_time"`
-		*Alias
-	}{
-		Alias: (*Alias)(t),
-	}
-	if err := json.Unmarshal(data, &aux); err != nil {
-		return err
-	}
 
-	// Парсим время в формате "2006-01-02T15:04:05.000Z"
-	if aux.StartTime != "" {
-		startTime, err := time.Parse("2006-01-02T15:04:05.000Z", aux.StartTime)
-		if err != nil {
-			return fmt.Errorf("неверный формат времени начала: %v", err)
-		}
-		t.StartTime = startTime
-	}
-
-	if aux.EndTime != "" {
-		endTime, err := time.Parse("2006-01-02T15:04:05.000Z", aux.EndTime)
-		if err != nil {
-			return fmt.Errorf("неверный формат времени окончания: %v", err)
-		}
-		t.EndTime = endTime
-	}
-
-	return nil
-}
-
-type MessageRequest struct {
-	ChatName    string `json:"chat_name"`
-	Message     string `json:"message"`
-	Interval    int    `json:"interval"`
-	RandomDelay int    `json:"random_delay"`
-	StartTime   string `json:"start_time"`
-	EndTime     string `json:"end_time"`
-}
+	"whatsapp-scheduler/internal/api"
+	"whatsapp-scheduler/internal/cli"
+	"whatsapp-scheduler/internal/tui"
+	"whatsapp-scheduler/internal/update"
+	"whatsapp-scheduler/pkg/scheduler"
+	"whatsapp-scheduler/pkg/whatsapp"
+)
 
-var (
-	scheduler *Scheduler
-	logger    = logrus.New()
+const (
+	// rateLimitEnv - переменная окружения с лимитом отправок в минуту (0 - без ограничений)
+	rateLimitEnv = "WHATSAPP_SCHEDULER_RATE_LIMIT"
+	// breakerThresholdEnv - переменная окружения с количеством ошибок подряд до приостановки чата
+	breakerThresholdEnv = "WHATSAPP_SCHEDULER_BREAKER_THRESHOLD"
+	// sendTimeoutEnv - переменная окружения с таймаутом отправки сообщения в секундах
+	sendTimeoutEnv = "WHATSAPP_SCHEDULER_SEND_TIMEOUT"
+	// chatSendGapEnv - переменная окружения с минимальной паузой между сообщениями в один чат в секундах
+	chatSendGapEnv = "WHATSAPP_SCHEDULER_CHAT_SEND_GAP"
+	// maxConcurrentSendsEnv - переменная окружения с максимумом одновременных отправок (0 - без ограничения)
+	maxConcurrentSendsEnv = "WHATSAPP_SCHEDULER_MAX_CONCURRENT_SENDS"
+	// keepaliveIntervalEnv - переменная окружения с периодом отправки присутствия для поддержания сессии
+	// в секундах (0 - не отправлять)
+	keepaliveIntervalEnv = "WHATSAPP_SCHEDULER_KEEPALIVE_INTERVAL"
+	// grpcPortEnv - переменная окружения с портом gRPC сервера (0 - сервер отключен)
+	grpcPortEnv = "WHATSAPP_SCHEDULER_GRPC_PORT"
+	// defaultGRPCPort - порт gRPC сервера по умолчанию
+	defaultGRPCPort = 9090
+
+	// storagePathEnv - переменная окружения с путем к базе данных планировщика
+	storagePathEnv = "WHATSAPP_SCHEDULER_STORAGE_PATH"
+	// sessionPathEnv - переменная окружения с путем к базе данных сессии WhatsApp
+	// (например, на постоянном томе Kubernetes)
+	sessionPathEnv = "WHATSAPP_SCHEDULER_SESSION_PATH"
+	// shutdownTimeoutEnv - переменная окружения с временем на завершение отправок при остановке в секундах
+	shutdownTimeoutEnv = "WHATSAPP_SCHEDULER_SHUTDOWN_TIMEOUT"
+	// defaultShutdownTimeout - время на остановку, меньше периода ожидания пода Kubernetes по умолчанию (30 секунд)
+	defaultShutdownTimeout = 25 * time.Second
+
+	// defaultStoragePath - база данных планировщика по умолчанию
+	defaultStoragePath = "scheduler.db"
+	// defaultSessionPath - база данных сессии WhatsApp по умолчанию
+	defaultSessionPath = "whatsmeow.db"
+	// tuiLogPath - файл лога в режиме --tui, чтобы вывод не портил экран
+	tuiLogPath = "whatsapp-scheduler.log"
 )
 
+// logger - общий логгер приложения, его же используют пакеты планировщика
+var logger = logrus.StandardLogger()
+
 // openBrowser открывает браузер с указанным URL
 func openBrowser(url string) error {
 	var cmd *exec.Cmd
@@ -109,608 +78,305 @@ func openBrowser(url string) error {
 	return cmd.Start()
 }
 
-func main() {
-	defer func() {
-		if r := recover(); r != nil {
-			if scheduler != nil && scheduler.client != nil {
-				// Отключаем клиента WhatsApp при панике
-				scheduler.client.Disconnect()
-			}
-			logger.Errorf("Программа завершилась с ошибкой: %v", r)
-			logger.Info("Нажмите любую Enter для выхода...")
-			fmt.Scanln()
-			os.Exit(-1)
-		}
-	}()
-
-	// Инициализация логгера
-	logger.SetLevel(logrus.InfoLevel)
-	logger.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
-	})
-
-	// Инициализация планировщика
-	scheduler = &Scheduler{
-		tasks: make(map[string]*ScheduledTask),
-		mutex: sync.RWMutex{},
+// intFromEnv читает неотрицательное целое значение переменной окружения
+func intFromEnv(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
 	}
-
-	// Инициализация WhatsApp клиента
-	if err := initWhatsApp(); err != nil {
-		logger.Fatal("Ошибка инициализации WhatsApp:", err)
-	}
-
-	// Настройка Gin
-	gin.SetMode(gin.ReleaseMode)
-	r := gin.New()
-	r.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		// Логируем только ошибки и важные запросы, исключаем /tasks
-		if param.StatusCode >= 400 || (param.Path != "/tasks" && param.Method != "GET") {
-			return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %s\n",
-				param.TimeStamp.Format("2006/01/02 - 15:04:05"),
-				param.StatusCode,
-				param.Latency,
-				param.ClientIP,
-				param.Method,
-				param.Path,
-			)
-		}
-		return ""
-	}), gin.Recovery())
-
-	// Загрузка HTML шаблонов
-	r.LoadHTMLGlob("ui/*")
-	//r.Static("/static", "./static")
-
-	// Маршруты
-	r.GET("/", func(c *gin.Context) {
-		c.HTML(http.StatusOK, "index.html", gin.H{
-			"title": "WhatsApp Scheduler",
-		})
-	})
-
-	r.GET("/qr", func(c *gin.Context) {
-		if scheduler.client == nil || scheduler.client.Store.ID == nil {
-			c.JSON(http.StatusOK, gin.H{"qr": "Клиент не инициализирован", "authorized": false})
-			return
-		}
-
-		// Проверяем статус подключения
-		connected := scheduler.client.IsConnected()
-		if connected {
-			c.JSON(http.StatusOK, gin.H{"qr": "QR код уже отсканирован", "authorized": true, "connected": true})
-		} else {
-			c.JSON(http.StatusOK, gin.H{"qr": "QR код отсканирован, но соединение потеряно", "authorized": true, "connected": false})
-		}
-	})
-
-	r.GET("/status", func(c *gin.Context) {
-		if scheduler.client == nil {
-			c.JSON(http.StatusOK, gin.H{
-				"initialized": false,
-				"authorized":  false,
-				"connected":   false,
-				"message":     "Клиент не инициализирован",
-			})
-			return
-		}
-
-		authorized := scheduler.client.Store.ID != nil
-		connected := scheduler.client.IsConnected()
-
-		status := gin.H{
-			"initialized": true,
-			"authorized":  authorized,
-			"connected":   connected,
-		}
-
-		if !authorized {
-			status["message"] = "Требуется авторизация через QR код"
-		} else if !connected {
-			status["message"] = "Соединение потеряно, требуется переподключение"
-		} else {
-			status["message"] = "Готов к работе"
-		}
-
-		c.JSON(http.StatusOK, status)
-	})
-
-	r.POST("/schedule", func(c *gin.Context) {
-		var task ScheduledTask
-		if err := c.ShouldBindJSON(&task); err != nil {
-			fmt.Printf("schedule error: %s\n", err.Error())
-			c.JSON(400, gin.H{"error": "Ошибка парсинга JSON: " + err.Error()})
-			return
-		}
-
-		// Проверяем, есть ли уже активная задача
-		existingTask := scheduler.GetCurrentTask()
-		if existingTask != nil {
-			c.JSON(409, gin.H{
-				"error":         "Уже есть активная задача",
-				"existing_task": existingTask,
-				"message":       "Хотите заменить существующую задачу?",
-			})
-			return
-		}
-
-		// Создаем задачу
-		newTask := &ScheduledTask{
-			ChatName:    strings.TrimSpace(task.ChatName),
-			Message:     strings.TrimSpace(task.Message),
-			Interval:    task.Interval,
-			RandomDelay: task.RandomDelay,
-			StartTime:   task.StartTime,
-			EndTime:     task.EndTime,
-			stopChan:    make(chan bool),
-		}
-
-		taskID, err := scheduler.AddTask(newTask)
-		if err == nil {
-			c.JSON(200, gin.H{"message": "Задача добавлена", "task_id": taskID})
-		} else {
-			c.JSON(400, gin.H{"error": "Ошибка при добавлении задачи: " + err.Error()})
-		}
-	})
-
-	r.GET("/tasks", func(c *gin.Context) {
-		scheduler.mutex.RLock()
-		defer scheduler.mutex.RUnlock()
-
-		tasks := make([]*ScheduledTask, 0, len(scheduler.tasks))
-		for _, task := range scheduler.tasks {
-			tasks = append(tasks, task)
-		}
-		c.JSON(http.StatusOK, tasks)
-	})
-
-	r.POST("/stop/:id", func(c *gin.Context) {
-		id := c.Param("id")
-		if scheduler.StopTask(id) {
-			c.JSON(http.StatusOK, gin.H{"message": "Задача остановлена"})
-		} else {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
-		}
-	})
-
-	r.POST("/test", func(c *gin.Context) {
-		var req struct {
-			ChatName string `json:"chat_name"`
-			Message  string `json:"message"`
-		}
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		if err := scheduler.SendTestMessage(req.ChatName, req.Message); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   err.Error(),
-				"message": "Ошибка отправки тестового сообщения",
-			})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"message": "Тестовое сообщение успешно отправлено",
-			"chat":    req.ChatName,
-			"text":    req.Message,
-		})
-	})
-
-	// Endpoint для замены существующей задачи
-	r.POST("/replace-task", func(c *gin.Context) {
-		var task ScheduledTask
-		if err := c.ShouldBindJSON(&task); err != nil {
-			c.JSON(400, gin.H{"error": "Ошибка парсинга JSON: " + err.Error()})
-			return
-		}
-
-		task.StartTime = task.StartTime.In(time.Local)
-		task.EndTime = task.EndTime.In(time.Local)
-
-		// Создаем задачу
-		newTask := &ScheduledTask{
-			ChatName:    strings.TrimSpace(task.ChatName),
-			Message:     strings.TrimSpace(task.Message),
-			Interval:    task.Interval,
-			RandomDelay: task.RandomDelay,
-			StartTime:   task.StartTime,
-			EndTime:     task.EndTime,
-			stopChan:    make(chan bool),
-		}
-
-		taskID, err := scheduler.AddTask(newTask)
-		if err == nil {
-			c.JSON(200, gin.H{"message": "Задача заменена", "task_id": taskID})
-		} else {
-			c.JSON(400, gin.H{"error": "Ошибка при замене задачи: " + err.Error()})
-		}
-	})
-
-	// Запускаем сервер в горутине
-	go func() {
-		logger.Info("Сервер запущен на http://localhost:8080")
-		if err := r.Run(":8080"); err != nil {
-			logger.Fatal("Ошибка запуска сервера:", err)
-		}
-	}()
-
-	// Ждем немного для запуска сервера
-	time.Sleep(2 * time.Second)
-
-	// Открываем браузер
-	logger.Info("Открываем браузер... | UI: http://localhost:8080")
-	if err := openBrowser("http://localhost:8080"); err != nil {
-		logger.Warn("Не удалось открыть браузер автоматически:", err)
-		logger.Info("Пожалуйста, откройте браузер и перейдите по адресу: http://localhost:8080")
+	result, err := strconv.Atoi(value)
+	if err != nil || result < 0 {
+		logger.Fatalf("Неверное значение %s: %s", name, value)
 	}
-
-	// Ждем завершения программы
-	select {}
+	return result
 }
 
-func initWhatsApp() error {
-	// Создаем базу данных с поддержкой foreign keys
-	db, err := sql.Open("sqlite3", "whatsmeow.db?_foreign_keys=on")
-	if err != nil {
-		return fmt.Errorf("ошибка открытия БД: %v", err)
+// stringFromEnv читает значение переменной окружения или значение по умолчанию
+func stringFromEnv(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
 	}
-	defer db.Close()
+	return defaultValue
+}
 
-	// Включаем foreign keys
-	_, err = db.Exec("PRAGMA foreign_keys = ON")
+// runCheckUpdate выводит текущую и последнюю версию и возвращает код завершения процесса
+func runCheckUpdate() int {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	release, err := update.Check(ctx)
 	if err != nil {
-		return fmt.Errorf("ошибка включения foreign keys: %v", err)
+		fmt.Fprintln(os.Stderr, "Ошибка:", err)
+		return 1
 	}
-
-	container, err := sqlstore.New(context.Background(), "sqlite3", "whatsmeow.db?_foreign_keys=on", nil)
-	if err != nil {
-		return fmt.Errorf("ошибка создания контейнера: %v", err)
+	fmt.Printf("Текущая версия: %s\nПоследняя версия: %s\n", update.Version, release.Version)
+	if release.Available {
+		fmt.Printf("Доступно обновление: %s\nУстановите его кнопкой в веб-интерфейсе или запросом POST /admin/update\n", release.URL)
+	} else {
+		fmt.Println("Установлена последняя версия")
 	}
+	return 0
+}
 
-	deviceStore, err := container.GetFirstDevice(context.Background())
+// runSelfTest выполняет самопроверку без запуска сервера и задач, выводит результат
+// и возвращает код завершения процесса: 0 - все проверки пройдены
+func runSelfTest(storagePath, sessionPath string, sendToSelf bool) int {
+	storage, err := scheduler.OpenStorage(storagePath)
 	if err != nil {
-		return fmt.Errorf("ошибка получения устройства: %v", err)
+		fmt.Fprintln(os.Stderr, "Ошибка инициализации хранилища:", err)
+		return 1
 	}
-
-	client := whatsmeow.NewClient(deviceStore, nil)
-
-	// Упрощенный обработчик событий - только для логирования ошибок
-	client.AddEventHandler(func(evt interface{}) {
-		switch v := evt.(type) {
-		case *events.Receipt:
-			// Логируем только подтверждения отправки
-			if v.Type == events.ReceiptTypeDelivered || v.Type == events.ReceiptTypeRead {
-				logger.Debugf("Сообщение доставлено/прочитано: %s", v.MessageIDs)
-			}
-		case *events.Connected:
-			logger.Info("✅ Подключение к WhatsApp установлено")
-		case *events.Disconnected:
-			logger.Warn("⚠️ Отключение от WhatsApp")
-		}
-	})
-
-	if client.Store.ID == nil {
-		logger.Info("Клиент не авторизован. Сканируйте QR код: | UI: http://localhost:8080")
-		qrChan, _ := client.GetQRChannel(context.Background())
-		err = client.Connect()
-		if err != nil {
-			return fmt.Errorf("ошибка подключения: %v", err)
-		}
-		for evt := range qrChan {
-			if evt.Event == "code" {
-				qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
-			} else {
-				logger.Info("QR код отсканирован! Авторизация завершена. | UI: http://localhost:8080")
-				break
-			}
-		}
-	} else {
-		err = client.Connect()
-		if err != nil {
-			return fmt.Errorf("ошибка подключения: %v", err)
-		}
-		logger.Info("WhatsApp клиент подключен | UI: http://localhost:8080")
+	if _, err := storage.EnableEncryptionFromEnv(); err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка включения шифрования хранилища:", err)
+		return 1
 	}
-
-	// Проверяем статус подключения
-	if !client.IsConnected() {
-		return fmt.Errorf("не удалось установить подключение к WhatsApp")
+	client := whatsapp.NewClient(storage)
+	// Без сессии QR код не выводится: самопроверка запускается без присмотра
+	client.RequireSession = true
+	if err := client.Connect(sessionPath); err != nil {
+		logger.Warnf("Ошибка подключения к WhatsApp: %v", err)
 	}
+	defer client.Disconnect()
 
-	scheduler.client = client
-	return nil
-}
-
-// GetCurrentTask возвращает текущую активную задачу (если есть)
-func (s *Scheduler) GetCurrentTask() *ScheduledTask {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-
-	// Возвращаем первую найденную задачу (у нас только одна)
-	for _, task := range s.tasks {
-		return task
+	sched, err := scheduler.NewScheduler(storage, client, scheduler.DefaultConfig())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка инициализации планировщика:", err)
+		return 1
 	}
-	return nil
-}
-
-// AddTask добавляет новую задачу, заменяя существующую если нужно
-func (s *Scheduler) AddTask(task *ScheduledTask) (string, error) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	result := sched.SelfTest(ctx, sendToSelf)
 
-	// Проверяем, есть ли уже активная задача
-	var existingTask *ScheduledTask
-	for _, t := range s.tasks {
-		existingTask = t
-		break
+	for _, check := range result.Checks {
+		mark := map[string]string{scheduler.SelfTestPassed: "✅", scheduler.SelfTestFailed: "❌", scheduler.SelfTestSkipped: "⏭️"}[check.Status]
+		fmt.Printf("%s %-14s %s\n", mark, check.Name, check.Detail)
 	}
-
-	if existingTask != nil {
-		// Останавливаем существующую задачу
-		close(existingTask.stopChan)
-		delete(s.tasks, existingTask.ID)
-		logger.Infof("🔄 Остановлена существующая задача %s для замены новой | UI: http://localhost:8080", existingTask.ID)
+	if !result.Passed {
+		fmt.Println("Самопроверка не пройдена")
+		return 1
 	}
+	fmt.Println("Самопроверка пройдена")
+	return 0
+}
 
-	// Проверяем валидность данных
-	if task.ChatName == "" {
-		return "", fmt.Errorf("пустое название чата")
-	}
-	if task.Message == "" {
-		return "", fmt.Errorf("пустое сообщение")
-	}
-	if task.Interval <= 0 {
-		return "", fmt.Errorf("неверный интервал: %d", task.Interval)
+// shutdown останавливает процесс по сигналу: сервер перестает принимать запросы,
+// начатые отправки завершаются, блокировка ведущего освобождается
+func shutdown(timeout time.Duration, server *http.Server, sched *scheduler.Scheduler, election *api.LeaderElection, client *whatsapp.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Warnf("Ошибка остановки HTTP сервера: %v", err)
 	}
-	if task.StartTime.IsZero() {
-		return "", fmt.Errorf("неверное время начала")
+	if err := sched.Shutdown(ctx); err != nil {
+		logger.Warn(err)
 	}
-	if task.EndTime.IsZero() {
-		return "", fmt.Errorf("неверное время окончания")
+	election.Stop()
+	if client != nil {
+		client.Disconnect()
 	}
-
-	// Добавляем новую задачу
-	task.ID = fmt.Sprintf("task_%d", time.Now().UnixNano())
-	task.stopChan = make(chan bool)
-	s.tasks[task.ID] = task
-
-	logger.Infof("🚀 Добавлена задача %s для чата '%s' (интервал: %d мин, задержка: %d мин) | UI: http://localhost:8080",
-		task.ID, task.ChatName, task.Interval, task.RandomDelay)
-
-	// Запускаем задачу в горутине
-	go s.runTask(task)
-
-	return task.ID, nil
+	logger.Info("👋 Приложение остановлено")
 }
 
-func (s *Scheduler) StopTask(id string) bool {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	task, exists := s.tasks[id]
-	if !exists {
-		logger.Warnf("Попытка остановить несуществующую задачу: %s | UI: http://localhost:8080", id)
-		return false
+func main() {
+	storagePath := stringFromEnv(storagePathEnv, defaultStoragePath)
+	sessionPath := stringFromEnv(sessionPathEnv, defaultSessionPath)
+	if cli.IsCommand(os.Args[1:]) {
+		os.Exit(cli.Run(os.Args[1:], cli.Paths{Storage: storagePath, Session: sessionPath}))
 	}
 
-	close(task.stopChan)
-	delete(s.tasks, id)
-	logger.Infof("⏹️ Задача %s остановлена (чат: %s) | UI: http://localhost:8080", id, task.ChatName)
-	return true
-}
+	mock := flag.Bool("mock", false, "не подключаться к WhatsApp, а записывать сообщения в память и в лог")
+	dashboard := flag.Bool("tui", false, "показать панель управления в терминале вместо открытия браузера")
+	checkUpdate := flag.Bool("check-update", false, "проверить наличие новой версии и выйти")
+	selfTest := flag.Bool("self-test", false, "проверить хранилище, сессию WhatsApp, подключение и шаблоны задач и выйти")
+	selfTestSend := flag.Bool("self-test-send", false, "при самопроверке отправить сообщение в собственный чат")
+	readOnly := flag.Bool("read-only", false, "запустить в режиме только для чтения: отправки отключены, API и история доступны")
+	flag.Parse()
 
-func (s *Scheduler) runTask(task *ScheduledTask) {
-	logger.Infof("🔄 Запуск планировщика для задачи %s (чат: %s) | UI: http://localhost:8080", task.ID, task.ChatName)
+	if *checkUpdate {
+		os.Exit(runCheckUpdate())
+	}
+	if *selfTest {
+		os.Exit(runSelfTest(storagePath, sessionPath, *selfTestSend))
+	}
+	update.Cleanup()
 
+	var client *whatsapp.Client
 	defer func() {
-		s.mutex.Lock()
-		delete(s.tasks, task.ID)
-		s.mutex.Unlock()
+		if r := recover(); r != nil {
+			if client != nil {
+				// Отключаем клиента WhatsApp при панике
+				client.Disconnect()
+			}
+			logger.Errorf("Программа завершилась с ошибкой: %v", r)
+			logger.Info("Нажмите любую Enter для выхода...")
+			fmt.Scanln()
+			os.Exit(-1)
+		}
 	}()
 
-	if time.Now().After(task.EndTime) {
-		logger.Infof("⏰ Задача %s уже завершена по времени до первой отправки (чат: %s) | UI: http://localhost:8080", task.ID, task.ChatName)
-		return
-	}
+	// Инициализация логгера
+	logger.SetLevel(logrus.InfoLevel)
+	logger.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
 
-	if task.Interval <= 0 {
-		logger.Errorf("❌ Неверный интервал для задачи %s(не может быть меньше 1 минуты) (чат: %s) | UI: http://localhost:8080", task.ID, task.ChatName)
-		return
+	// Инициализация хранилища планировщика
+	storage, err := scheduler.OpenStorage(storagePath)
+	if err != nil {
+		logger.Fatal("Ошибка инициализации хранилища:", err)
 	}
-
-	if task.RandomDelay > task.Interval {
-		logger.Errorf("❌ Случайная задержка должна быть меньше интервала для задачи %s (чат: %s) | UI: http://localhost:8080", task.ID, task.ChatName)
-		return
+	if encrypted, err := storage.EnableEncryptionFromEnv(); err != nil {
+		logger.Fatal("Ошибка включения шифрования хранилища:", err)
+	} else if encrypted {
+		logger.Info("🔐 Шифрование хранилища включено")
 	}
 
-	// Проверяем корректность сравнения времени начала задачи и текущего времени.
-	now := time.Now()
-	nextSendTime := task.StartTime
-
-	// Если время начала в прошлом, вычисляем следующее время отправки
-	if nextSendTime.Before(now) {
-		intervalDuration := time.Duration(task.Interval) * time.Minute
-		// Вычисляем сколько интервалов прошло с момента startTime
-		timePassed := now.Sub(task.StartTime)
-		intervalsPassed := int(timePassed / intervalDuration)
-
-		// Следующее время отправки = startTime + (intervalsPassed + 1) * interval
-		nextSendTime = task.StartTime.Add(time.Duration(intervalsPassed+1) * intervalDuration)
-
-		logger.Infof("⏰ Время начала в прошлом. Следующая отправка запланирована на: %s | UI: http://localhost:8080",
-			nextSendTime.Format("15:04:05 02.01.2006"))
+	config := scheduler.Config{
+		RateLimit:          intFromEnv(rateLimitEnv, scheduler.DefaultRateLimit),
+		BreakerThreshold:   intFromEnv(breakerThresholdEnv, scheduler.DefaultBreakerThreshold),
+		SendTimeout:        time.Duration(intFromEnv(sendTimeoutEnv, int(scheduler.DefaultSendTimeout.Seconds()))) * time.Second,
+		ChatSendGap:        time.Duration(intFromEnv(chatSendGapEnv, int(scheduler.DefaultChatSendGap.Seconds()))) * time.Second,
+		MaxConcurrentSends: intFromEnv(maxConcurrentSendsEnv, scheduler.DefaultMaxConcurrentSends),
 	}
+	grpcPort := intFromEnv(grpcPortEnv, defaultGRPCPort)
+	keepaliveInterval := time.Duration(intFromEnv(keepaliveIntervalEnv, int(whatsapp.DefaultKeepaliveInterval.Seconds()))) * time.Second
+	shutdownTimeout := time.Duration(intFromEnv(shutdownTimeoutEnv, int(defaultShutdownTimeout.Seconds()))) * time.Second
 
-	// Основной цикл для повторных отправок
-	for {
-		// Добавляем случайную задержку
-		randomDelaySeconds := 0
-		if task.RandomDelay > 0 {
-			randomDelaySeconds = rand.Intn(task.RandomDelay * 60 /* minutes to seconds*/)
-		}
-		nextMessageTime := nextSendTime.Add(time.Duration(randomDelaySeconds) * time.Second)
-
-		if nextMessageTime.After(task.EndTime) {
-			logger.Infof("⏰ Задача %s завершена по времени (Чат: %s) | UI: http://localhost:8080", task.ID, task.ChatName)
-			return
-		}
-
-		// Логируем время до следующей отправки
-		timeUntilSend := time.Until(nextMessageTime)
-		logger.Infof("⏳ До отправки сообщения: %.2f минут (%s) | UI: http://localhost:8080",
-			timeUntilSend.Minutes(), nextMessageTime.Local().Format("15:04:05 02.01.2006"))
-
-		select {
-		case <-task.stopChan:
-			logger.Infof("🛑 Планировщик остановлен для задачи %s | UI: http://localhost:8080", task.ID)
-			return
-		case <-time.After(timeUntilSend):
-			logger.Infof("📤 Отправка сообщения по задаче %s в чат '%s' | UI: http://localhost:8080", task.ID, task.ChatName)
-			if err := s.sendMessage(task.ChatName, task.Message); err != nil {
-				logger.Errorf("❌ Ошибка отправки сообщения по задаче %s: %v | UI: http://localhost:8080", task.ID, err)
-			} else {
-				logger.Infof("✅ Сообщение по задаче %s отправлено успешно | UI: http://localhost:8080", task.ID)
-			}
-
-			nextSendTime = nextSendTime.Add(time.Duration(task.Interval) * time.Minute)
-		}
-	}
-}
-
-func (s *Scheduler) FindChatJIT(chatName string) waTypes.JID {
-	logger.Debugf("Ищем в контактах: %s", chatName)
-
-	nameMatches := []waTypes.JID{}
-
-	contacts, err := s.client.Store.Contacts.GetAllContacts(context.Background())
-	if err == nil {
-		// Ищем контакт по имени или JID
-		for jid, contact := range contacts {
-			if jid.String() == chatName {
-				logger.Debugf("Найден контакт по JID: %s", jid)
-				return jid
-			}
-			if contact.FullName == chatName {
-				logger.Debugf("Найден контакт по имени '%s': %s", contact.FullName, jid)
-				nameMatches = append(nameMatches, jid)
-			}
-		}
+	// Инициализация планировщика
+	var sender scheduler.MessageSender
+	if *mock {
+		logger.Warn("🧪 Режим --mock: сообщения не отправляются в WhatsApp, а только записываются в лог")
+		sender = scheduler.NewMemorySender()
 	} else {
-		logger.Errorf("Ошибка получения контактов: %v", err)
+		client = whatsapp.NewClient(storage)
+		sender = client
 	}
-
-	// Ищем группу по имени или JID
-	groups, err := s.client.GetJoinedGroups()
-	if err == nil {
-		for _, group := range groups {
-			// Можно добавить поиск по JID группы (если пользователь ввел JID)
-			if group.JID.String() == chatName {
-				logger.Debugf("Найдена группа по JID: %s", group.JID)
-				return group.JID
-			}
-			if group.Name == chatName {
-				logger.Debugf("Найдена группа по имени '%s': %s", group.Name, group.JID)
-				nameMatches = append(nameMatches, group.JID)
-			}
-		}
-	} else {
-		logger.Warnf("Ошибка получения групп: %v", err)
+	sched, err := scheduler.NewScheduler(storage, sender, config)
+	if err != nil {
+		logger.Fatal("Ошибка инициализации планировщика:", err)
 	}
-
-	if len(nameMatches) == 0 {
-		logger.Debugf("Контакты или группы не найдены по имени: %s", chatName)
-		// Пробуем создать JID из введенного текста (только если это похоже на номер телефона)
-		if len(chatName) >= 10 && len(chatName) <= 15 && strings.Map(func(r rune) rune {
-			if r >= '0' && r <= '9' {
-				return r
-			}
-			return -1
-		}, chatName) == chatName {
-			// Только если это чисто цифры (номер телефона)
-			targetJID := waTypes.NewJID(chatName, "s.whatsapp.net")
-			logger.Debugf("Создан JID из номера: %s", targetJID)
-			return targetJID
-		}
-		return waTypes.JID{}
+	if *readOnly {
+		sched.SetReadOnly(true)
 	}
 
-	if len(nameMatches) > 1 {
-		logger.Debugf("Найдено несколько контактов/групп с заданным именем: %v", nameMatches)
-		slices.SortFunc(nameMatches, func(a, b waTypes.JID) int {
-			return strings.Compare(a.String(), b.String())
-		})
+	bridge, err := api.StartMQTTBridge(sched)
+	if err != nil {
+		logger.Fatal("Ошибка инициализации MQTT:", err)
+	}
+	election, err := api.StartLeaderElection(sched)
+	if err != nil {
+		logger.Fatal("Ошибка инициализации резервирования:", err)
+	}
+	if _, err := api.StartTelegramBot(sched); err != nil {
+		logger.Fatal("Ошибка инициализации Telegram бота:", err)
+	}
+	notifier, err := api.StartNotifier(sched)
+	if err != nil {
+		logger.Fatal("Ошибка инициализации уведомлений:", err)
 	}
 
-	return nameMatches[0]
-}
+	auth, err := api.LoadAPIAuth()
+	if err != nil {
+		logger.Fatal("Ошибка ключей API:", err)
+	}
 
-func (s *Scheduler) sendMessage(chatName, message string) error {
-	if s.client == nil {
-		return fmt.Errorf("клиент не инициализирован")
+	// restart - запрос перезапуска после установки обновления, в режиме --tui не поддерживается
+	var restart chan struct{}
+	if !*dashboard {
+		restart = make(chan struct{}, 1)
+	}
+	server := &http.Server{Addr: ":8080"}
+	startServer := func() {
+		server.Handler = api.NewRouter(sched, client, bridge, notifier, restart, auth)
+		go func() {
+			logger.Info("Сервер запущен на http://localhost:8080")
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Fatal("Ошибка запуска сервера:", err)
+			}
+		}()
+	}
+	if !*dashboard {
+		// Сервер запускается до подключения к WhatsApp, чтобы /healthz и /readyz отвечали
+		// во время ожидания сканирования QR кода
+		startServer()
 	}
 
-	// Проверяем подключение
-	if !s.client.IsConnected() {
-		logger.Warnf("Клиент не подключен, пытаемся переподключиться...")
-		if err := s.client.Connect(); err != nil {
-			return fmt.Errorf("не удалось переподключиться к WhatsApp: %v", err)
+	// Инициализация WhatsApp клиента
+	if client != nil {
+		client.OnPresence = sched.UpdatePresence
+		client.OnMessage = sched.HandleIncoming
+		client.OnReceipt = sched.ReportReceipt
+		client.OnLoggedOut = sched.ReportLoggedOut
+		client.OnGroupJoin = sched.HandleGroupJoin
+		client.OnGroupMembership = sched.HandleGroupMembership
+		client.OnPaired = sched.ReportLoggedIn
+		client.OnConnected = sched.ReportConnected
+		client.PrepareImage = sched.PrepareImage
+		if err := client.Connect(sessionPath); err != nil {
+			logger.Fatal("Ошибка инициализации WhatsApp:", err)
+		}
+		client.StartKeepalive(keepaliveInterval)
+	}
+
+	if *dashboard {
+		// После подключения (и возможного QR кода) лог уходит в файл, терминал занимает панель
+		logFile, err := os.OpenFile(tuiLogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			logger.Fatal("Ошибка открытия файла лога:", err)
 		}
+		logger.Infof("Лог записывается в %s", tuiLogPath)
+		logger.SetOutput(logFile)
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true, DisableColors: true})
+		gin.DefaultWriter = logFile
+		gin.DefaultErrorWriter = logFile
 	}
 
-	// Очищаем входные данные
-	chatName = strings.TrimSpace(chatName)
-	message = strings.TrimSpace(message)
-
-	if chatName == "" {
-		return fmt.Errorf("название чата не может быть пустым")
+	if err := sched.RestoreTasks(); err != nil {
+		logger.Error(err)
 	}
 
-	if message == "" {
-		return fmt.Errorf("сообщение не может быть пустым")
+	if *dashboard {
+		startServer()
 	}
 
-	logger.Infof("Попытка отправки сообщения в чат '%s': %s", chatName, message)
-	targetJID := s.FindChatJIT(chatName)
-	if targetJID.IsEmpty() {
-		return fmt.Errorf("чат '%s' не найден. Убедитесь, что указали правильное имя чата или номер телефона", chatName)
+	if grpcPort > 0 {
+		if err := api.StartGRPCServer(sched, grpcPort, auth); err != nil {
+			logger.Fatal(err)
+		}
 	}
 
-	logger.Infof("Отправляем сообщение в %s (%s)", chatName, targetJID)
-
-	// Создаем сообщение
-	msg := &waE2E.Message{
-		Conversation: proto.String(message),
+	if *dashboard {
+		if err := tui.Run(sched, client); err != nil {
+			logger.Error("Ошибка панели управления:", err)
+		}
+		shutdown(shutdownTimeout, server, sched, election, client)
+		return
 	}
 
-	// Отправляем сообщение с таймаутом
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	// Ждем немного для запуска сервера
+	time.Sleep(2 * time.Second)
 
-	_, err := s.client.SendMessage(ctx, targetJID, msg)
-	if err != nil {
-		logger.Errorf("Ошибка отправки сообщения в %s: %v", targetJID, err)
-
-		// Проверяем тип ошибки
-		if strings.Contains(err.Error(), "timed out") {
-			return fmt.Errorf("таймаут отправки сообщения. Проверьте подключение к интернету и попробуйте снова")
-		} else if strings.Contains(err.Error(), "not found") {
-			return fmt.Errorf("чат '%s' не найден или недоступен", chatName)
-		} else if strings.Contains(err.Error(), "unauthorized") {
-			return fmt.Errorf("не авторизован в WhatsApp. Пожалуйста, отсканируйте QR код заново")
+	// Открываем браузер, после перезапуска для обновления он уже открыт
+	if !update.Restarted() {
+		logger.Info("Открываем браузер... | UI: http://localhost:8080")
+		if err := openBrowser("http://localhost:8080"); err != nil {
+			logger.Warn("Не удалось открыть браузер автоматически:", err)
+			logger.Info("Пожалуйста, откройте браузер и перейдите по адресу: http://localhost:8080")
 		}
-
-		return fmt.Errorf("ошибка отправки сообщения: %v", err)
 	}
 
-	logger.Infof("✅ Сообщение успешно отправлено в чат '%s' (%s): %s | UI: http://localhost:8080", chatName, targetJID, message)
-	return nil
-}
-
-func (s *Scheduler) SendTestMessage(chatName, message string) error {
-	logger.Infof("🧪 Отправка тестового сообщения в чат '%s' | UI: http://localhost:8080", chatName)
-	return s.sendMessage(chatName, message)
+	// Ждем сигнала остановки (SIGTERM при удалении пода Kubernetes) или перезапуска после обновления
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case sig := <-signals:
+		logger.Infof("Получен сигнал %v, остановка...", sig)
+		shutdown(shutdownTimeout, server, sched, election, client)
+	case <-restart:
+		logger.Info("⬆️ Перезапуск для применения обновления...")
+		shutdown(shutdownTimeout, server, sched, election, client)
+		if err := update.Restart(); err != nil {
+			logger.Fatal("Ошибка перезапуска, запустите приложение вручную:", err)
+		}
+	}
 }
@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// alertHistorySize - количество последних оповещений, хранимых в памяти
+	alertHistorySize = 100
+	// AlertKindLoggedOut - сессия WhatsApp завершена, требуется повторная авторизация
+	AlertKindLoggedOut = "logged_out"
+)
+
+// Alert - оповещение оператора о проблеме, требующей внимания
+type Alert struct {
+	Kind      string    `json:"kind"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AlertLog хранит последние оповещения
+type AlertLog struct {
+	mutex  sync.RWMutex
+	alerts []Alert
+}
+
+// alert регистрирует оповещение и выводит его в лог
+func (s *Scheduler) alert(kind, message string) {
+	Logger.Errorf("🚨 %s", message)
+	s.events.Publish(Event{Kind: EventKindAlert, Status: kind, Message: message})
+
+	s.alerts.mutex.Lock()
+	defer s.alerts.mutex.Unlock()
+	s.alerts.alerts = append(s.alerts.alerts, Alert{Kind: kind, Message: message, CreatedAt: time.Now()})
+	if len(s.alerts.alerts) > alertHistorySize {
+		s.alerts.alerts = s.alerts.alerts[len(s.alerts.alerts)-alertHistorySize:]
+	}
+}
+
+// ErrLoggedOut возвращается при отправке после завершения сессии WhatsApp до повторной авторизации
+var ErrLoggedOut = errors.New("сессия WhatsApp завершена, требуется повторная авторизация через QR код")
+
+// ReportLoggedOut оповещает о завершении сессии транспорта (выход с устройства, бан и т.п.)
+// и приостанавливает отправки до повторной авторизации (ReportLoggedIn)
+func (s *Scheduler) ReportLoggedOut(reason string) {
+	s.loggedOut.Store(true)
+	s.alert(AlertKindLoggedOut, fmt.Sprintf("Сессия WhatsApp завершена (%s), требуется повторная авторизация через QR код", reason))
+}
+
+// ReportLoggedIn возобновляет отправки после повторной авторизации
+func (s *Scheduler) ReportLoggedIn() {
+	if s.loggedOut.Swap(false) {
+		Logger.Info("🔓 Сессия WhatsApp восстановлена, отправки возобновлены")
+	}
+}
+
+// IsLoggedOut проверяет, что сессия завершена и отправки приостановлены
+func (s *Scheduler) IsLoggedOut() bool {
+	return s.loggedOut.Load()
+}
+
+// List возвращает оповещения, новые первыми
+func (l *AlertLog) List() []Alert {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	alerts := make([]Alert, 0, len(l.alerts))
+	for i := len(l.alerts) - 1; i >= 0; i-- {
+		alerts = append(alerts, l.alerts[i])
+	}
+	return alerts
+}
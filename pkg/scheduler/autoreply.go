@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// autoReplyNamePlaceholder - в тексте автоответа заменяется именем отправителя
+const autoReplyNamePlaceholder = "{{name}}"
+
+// businessDays - дни недели в BusinessDays
+var businessDays = map[string]time.Weekday{
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+	"sun": time.Sunday,
+}
+
+// validateBusinessHours проверяет рабочее время и автоответ
+func (st *Settings) validateBusinessHours() error {
+	if _, _, err := parseQuietHours(st.BusinessHours); err != nil {
+		return fmt.Errorf("неверные рабочие часы '%s', пример: 09:00-18:00", st.BusinessHours)
+	}
+	for _, day := range st.BusinessDays {
+		if _, ok := businessDays[day]; !ok {
+			return fmt.Errorf("неверный рабочий день '%s', допустимо: mon, tue, wed, thu, fri, sat, sun", day)
+		}
+	}
+	if st.AutoReplyMessage != "" && st.BusinessHours == "" && len(st.BusinessDays) == 0 {
+		return fmt.Errorf("для auto_reply_message нужно указать business_hours или business_days")
+	}
+	return ValidateMessageLength(st.AutoReplyMessage)
+}
+
+// inBusinessHours проверяет, что t попадает в рабочее время (в часовом поясе по умолчанию)
+func (s *Scheduler) inBusinessHours(t time.Time) bool {
+	settings := s.Settings()
+	t = t.In(s.Location())
+
+	if len(settings.BusinessDays) > 0 {
+		workday := false
+		for _, day := range settings.BusinessDays {
+			if businessDays[day] == t.Weekday() {
+				workday = true
+				break
+			}
+		}
+		if !workday {
+			return false
+		}
+	}
+
+	from, to, err := parseQuietHours(settings.BusinessHours)
+	if err != nil || from == to {
+		return true
+	}
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if from < to {
+		return clock >= from && clock < to
+	}
+	// Рабочие часы переходят через полночь
+	return clock >= from || clock < to
+}
+
+// autoReply отвечает на личное сообщение вне рабочего времени, не чаще раза в день каждому отправителю
+func (s *Scheduler) autoReply(msg IncomingMessage) {
+	template := s.Settings().AutoReplyMessage
+	if template == "" || !msg.Direct || msg.ChatJID == "" {
+		return
+	}
+	at := msg.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	if s.inBusinessHours(at) {
+		return
+	}
+
+	day := at.In(s.Location()).Format("2006-01-02")
+	claimed, err := s.storage.claimAutoReply(msg.ChatJID, day)
+	if err != nil {
+		Logger.Errorf("Ошибка проверки автоответа для %s: %v", msg.ChatJID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	chatName := msg.SenderName
+	if chatName == "" {
+		chatName = msg.ChatJID
+	}
+	// Отправка не должна задерживать обработку входящих сообщений
+	go func() {
+		err := s.Deliver(context.Background(), OutgoingMessage{
+			ChatName:    chatName,
+			ChatJID:     msg.ChatJID,
+			Message:     strings.ReplaceAll(template, autoReplyNamePlaceholder, msg.SenderName),
+			LinkPreview: true,
+		})
+		if err != nil {
+			Logger.Errorf("❌ Ошибка автоответа в чат '%s': %v", chatName, err)
+			// Следующее сообщение отправителя получит автоответ
+			if err := s.storage.releaseAutoReply(msg.ChatJID); err != nil {
+				Logger.Errorf("Ошибка сохранения автоответа для %s: %v", msg.ChatJID, err)
+			}
+			return
+		}
+		Logger.Infof("🌙 Отправлен автоответ вне рабочего времени в чат '%s'", chatName)
+	}()
+}
+
+// claimAutoReply отмечает автоответ отправителю senderJID в день day. false - сегодня ему уже отвечали
+func (st *Storage) claimAutoReply(senderJID, day string) (bool, error) {
+	res, err := st.db.Exec(`INSERT INTO auto_replies (sender_jid, replied_on) VALUES (?, ?)
+		ON CONFLICT (sender_jid) DO UPDATE SET replied_on = excluded.replied_on
+		WHERE auto_replies.replied_on != excluded.replied_on`, senderJID, day)
+	if err != nil {
+		return false, err
+	}
+	claimed, err := res.RowsAffected()
+	return claimed > 0, err
+}
+
+// releaseAutoReply снимает отметку автоответа, если он не был отправлен
+func (st *Storage) releaseAutoReply(senderJID string) error {
+	_, err := st.db.Exec(`DELETE FROM auto_replies WHERE sender_jid = ?`, senderJID)
+	return err
+}
+
+// DeleteSenderAutoReplies удаляет отметку автоответа отправителю
+func (st *Storage) DeleteSenderAutoReplies(senderJID string) (int64, error) {
+	res, err := st.db.Exec(`DELETE FROM auto_replies WHERE sender_jid = ?`, senderJID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
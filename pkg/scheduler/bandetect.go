@@ -0,0 +1,181 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Пороги обнаружения ограничения аккаунта по умолчанию
+const (
+	DefaultBanServerErrors  = 5
+	DefaultBanRejections    = 2
+	DefaultBanFailedChats   = 5
+	DefaultBanWindowMinutes = 30
+
+	// AlertKindAccountFlagged - ошибки отправки похожи на ограничение или бан аккаунта, задачи приостановлены
+	AlertKindAccountFlagged = "account_flagged"
+)
+
+// AccountHealth - состояние обнаружения ограничения аккаунта (GET /admin/account-health)
+type AccountHealth struct {
+	// Flagged - ошибки похожи на ограничение аккаунта, задачи приостановлены и включен режим
+	// только для чтения до ResetAccountHealth
+	Flagged    bool       `json:"flagged"`
+	Reason     string     `json:"reason,omitempty"`
+	DetectedAt *time.Time `json:"detected_at,omitempty"`
+	// PausedTasks - задачи, приостановленные при обнаружении
+	PausedTasks []string `json:"paused_tasks,omitempty"`
+	// Текущая серия ошибок с последней успешной отправки
+	ServerErrors int `json:"server_errors"`
+	Rejections   int `json:"rejections"`
+	FailedChats  int `json:"failed_chats"`
+}
+
+// banDetector отслеживает серию ошибок отправки с последней успешной отправки
+type banDetector struct {
+	mutex  sync.Mutex
+	health AccountHealth
+	// failedChats - чаты с ошибкой отправки в текущей серии
+	failedChats map[string]bool
+	// startedAt - первая ошибка текущей серии
+	startedAt time.Time
+	// readOnly - режим только для чтения включен при обнаружении
+	readOnly bool
+}
+
+// isDeliveryFailure проверяет, что ошибка отправки говорит о сервере WhatsApp, а не о неверном
+// чате, отсутствии подключения или отмене отправки
+func isDeliveryFailure(err error) bool {
+	for _, local := range []error{ErrChatNotFound, ErrAmbiguousChat, ErrDisconnected, ErrLoggedOut, context.Canceled} {
+		if errors.Is(err, local) {
+			return false
+		}
+	}
+	return true
+}
+
+// detectBan учитывает результат отправки и, если ошибки похожи на ограничение аккаунта
+// (серия ответов 5xx, отказы сервера вроде 479, ошибки отправки во многие чаты подряд),
+// приостанавливает все задачи, включает режим только для чтения и оповещает оператора
+func (s *Scheduler) detectBan(msg OutgoingMessage, sendErr error) {
+	settings := s.Settings()
+	if !settings.BanDetection {
+		return
+	}
+	d := &s.banDetector
+	d.mutex.Lock()
+	if sendErr == nil {
+		d.health.ServerErrors, d.health.Rejections, d.health.FailedChats = 0, 0, 0
+		d.failedChats = nil
+		d.mutex.Unlock()
+		return
+	}
+	if d.health.Flagged || !isDeliveryFailure(sendErr) {
+		d.mutex.Unlock()
+		return
+	}
+
+	now := time.Now()
+	if d.failedChats == nil || now.Sub(d.startedAt) > time.Duration(settings.BanWindowMinutes)*time.Minute {
+		d.health.ServerErrors, d.health.Rejections = 0, 0
+		d.failedChats = map[string]bool{}
+		d.startedAt = now
+	}
+	switch {
+	case errors.Is(sendErr, ErrServerUnavailable):
+		d.health.ServerErrors++
+	case errors.Is(sendErr, ErrServerRejected):
+		d.health.Rejections++
+	}
+	d.failedChats[msg.ChatName] = true
+	d.health.FailedChats = len(d.failedChats)
+
+	var reason string
+	switch {
+	case settings.BanServerErrors > 0 && d.health.ServerErrors >= settings.BanServerErrors:
+		reason = fmt.Sprintf("%d ошибок сервера WhatsApp подряд", d.health.ServerErrors)
+	case settings.BanRejections > 0 && d.health.Rejections >= settings.BanRejections:
+		reason = fmt.Sprintf("сервер WhatsApp %d раз отклонил сообщения", d.health.Rejections)
+	case settings.BanFailedChats > 0 && d.health.FailedChats >= settings.BanFailedChats:
+		reason = fmt.Sprintf("ошибки отправки в %d разных чатов подряд", d.health.FailedChats)
+	}
+	if reason == "" {
+		d.mutex.Unlock()
+		return
+	}
+	reason = fmt.Sprintf("%s, последняя: %v", reason, sendErr)
+	d.health.Flagged = true
+	d.health.Reason = reason
+	d.health.DetectedAt = &now
+	d.mutex.Unlock()
+
+	// Задачи приостанавливаются вне блокировки: PauseTask сохраняет их в хранилище
+	paused := []string{}
+	for _, task := range s.ListTasks() {
+		if !s.isPaused(task) && s.PauseTask(task.ID, true) {
+			paused = append(paused, task.ID)
+		}
+	}
+	readOnly := !s.IsReadOnly()
+	s.SetReadOnly(true)
+
+	d.mutex.Lock()
+	d.health.PausedTasks = paused
+	d.readOnly = readOnly
+	d.mutex.Unlock()
+
+	s.alert(AlertKindAccountFlagged, fmt.Sprintf("Похоже, аккаунт WhatsApp ограничен или помечен (%s). "+
+		"Приостановлено задач: %d, отправки отключены. Проверьте аккаунт на телефоне и сбросьте "+
+		"состояние через POST /admin/account-health/reset", reason, len(paused)))
+}
+
+// AccountHealth возвращает состояние обнаружения ограничения аккаунта
+func (s *Scheduler) AccountHealth() AccountHealth {
+	d := &s.banDetector
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	health := d.health
+	health.PausedTasks = append([]string{}, d.health.PausedTasks...)
+	return health
+}
+
+// ResetAccountHealth сбрасывает обнаружение ограничения аккаунта. С resume=true возобновляются
+// приостановленные при обнаружении задачи и выключается включенный им режим только для чтения.
+// Возвращает ID возобновленных задач
+func (s *Scheduler) ResetAccountHealth(resume bool) []string {
+	d := &s.banDetector
+	d.mutex.Lock()
+	paused, readOnly := d.health.PausedTasks, d.readOnly
+	d.health = AccountHealth{}
+	d.failedChats = nil
+	d.readOnly = false
+	d.mutex.Unlock()
+
+	resumed := []string{}
+	if !resume {
+		return resumed
+	}
+	for _, id := range paused {
+		if s.PauseTask(id, false) {
+			resumed = append(resumed, id)
+		}
+	}
+	if readOnly {
+		s.SetReadOnly(false)
+	}
+	return resumed
+}
+
+// validateBanDetection проверяет пороги обнаружения ограничения аккаунта
+func (st *Settings) validateBanDetection() error {
+	if st.BanServerErrors < 0 || st.BanRejections < 0 || st.BanFailedChats < 0 {
+		return fmt.Errorf("ban_server_errors, ban_rejections и ban_failed_chats не могут быть отрицательными")
+	}
+	if st.BanDetection && st.BanWindowMinutes <= 0 {
+		return fmt.Errorf("ban_window_minutes должен быть положительным")
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MaxBatchSize - максимальное количество сообщений в одном пакете
+const MaxBatchSize = 500
+
+// BatchStatusQueued - сообщение пакета ждет отправки. Остальные статусы совпадают со статусами истории
+const BatchStatusQueued = "queued"
+
+// ErrBatchNotFound - пакета с таким ID нет
+var ErrBatchNotFound = errors.New("пакет сообщений не найден")
+
+// errBatchInterrupted - сообщение пакета не отправлено из-за перезапуска приложения
+var errBatchInterrupted = errors.New("отправка прервана перезапуском приложения")
+
+// BatchItem - сообщение пакета и результат его отправки
+type BatchItem struct {
+	// Index - номер сообщения в запросе, начиная с 0
+	Index       int        `json:"index"`
+	ChatName    string     `json:"chat_name"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	MessageID   string     `json:"message_id,omitempty"`
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// BatchStatus - сводка по пакету сообщений
+type BatchStatus struct {
+	ID        string         `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	Total     int            `json:"total"`
+	Statuses  map[string]int `json:"statuses"`
+	Done      bool           `json:"done"`
+	Items     []BatchItem    `json:"items"`
+}
+
+// batchMessage - подготовленное сообщение пакета
+type batchMessage struct {
+	index       int
+	msg         OutgoingMessage
+	scheduledAt *time.Time
+}
+
+// SendBatch проверяет все сообщения пакета и ставит их в очередь отправки. Сообщения проходят общий
+// конвейер отправки (лимит, очереди чатов), сообщения одного чата отправляются по порядку
+// scheduled_at и номеру в пакете. Возвращает ID пакета для GET /send/batch/:id.
+// Если хотя бы одно сообщение неверно, пакет не принимается. Сообщения расходуют суточную квоту
+// пользователя API owner (пустой - без квоты)
+func (s *Scheduler) SendBatch(requests []SendRequest, owner string) (string, error) {
+	if len(requests) == 0 {
+		return "", fmt.Errorf("пустой пакет сообщений")
+	}
+	if len(requests) > MaxBatchSize {
+		return "", fmt.Errorf("в пакете %d сообщений, максимум %d", len(requests), MaxBatchSize)
+	}
+
+	messages := make([]batchMessage, len(requests))
+	for i, req := range requests {
+		if req.Timezone == "" {
+			req.Timezone = s.Settings().DefaultTimezone
+		}
+		msg, scheduledAt, err := PrepareSend(req, s.clock.Now())
+		if err != nil {
+			return "", fmt.Errorf("сообщение %d: %v", i, err)
+		}
+		msg.Owner = owner
+		messages[i] = batchMessage{index: i, msg: s.WithMessageID(msg), scheduledAt: scheduledAt}
+	}
+
+	batchID := fmt.Sprintf("batch_%d", time.Now().UnixNano())
+	if err := s.storage.addBatch(batchID, messages, time.Now()); err != nil {
+		return "", err
+	}
+
+	byChat := map[string][]batchMessage{}
+	for _, m := range messages {
+		byChat[m.msg.ChatName] = append(byChat[m.msg.ChatName], m)
+	}
+	for _, chatMessages := range byChat {
+		sort.SliceStable(chatMessages, func(i, j int) bool {
+			return batchTime(chatMessages[i]).Before(batchTime(chatMessages[j]))
+		})
+		go s.sendBatchChat(batchID, chatMessages)
+	}
+	Logger.Infof("📦 Пакет %s из %d сообщений в %d чатов поставлен в очередь", batchID, len(messages), len(byChat))
+	return batchID, nil
+}
+
+// batchTime возвращает время отправки сообщения пакета, нулевое - отправить сразу
+func batchTime(m batchMessage) time.Time {
+	if m.scheduledAt == nil {
+		return time.Time{}
+	}
+	return *m.scheduledAt
+}
+
+// sendBatchChat отправляет по порядку сообщения пакета в один чат
+func (s *Scheduler) sendBatchChat(batchID string, messages []batchMessage) {
+	for _, m := range messages {
+		if m.scheduledAt != nil && !s.clock.Sleep(context.Background(), m.scheduledAt.Sub(s.clock.Now())) {
+			return
+		}
+		result, err := s.DeliverResult(context.Background(), m.msg)
+		if err != nil {
+			Logger.Errorf("❌ Ошибка отправки сообщения %d пакета %s в чат '%s': %v", m.index, batchID, m.msg.ChatName, err)
+		}
+		if err := s.storage.updateBatchItem(batchID, m.index, result.MessageID, err); err != nil {
+			Logger.Errorf("Ошибка сохранения результата пакета %s: %v", batchID, err)
+		}
+	}
+}
+
+// addBatch сохраняет сообщения пакета со статусом queued
+func (st *Storage) addBatch(batchID string, messages []batchMessage, createdAt time.Time) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, m := range messages {
+		chatName, err := st.EncryptField(m.msg.ChatName)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO batch_items
+			(batch_id, idx, chat_name, status, scheduled_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			batchID, m.index, chatName, BatchStatusQueued, m.scheduledAt, createdAt, createdAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// updateBatchItem записывает результат отправки сообщения index пакета
+func (st *Storage) updateBatchItem(batchID string, index int, messageID string, sendErr error) error {
+	reason := ""
+	if sendErr != nil {
+		reason = sendErr.Error()
+	}
+	sealed, err := st.EncryptField(reason)
+	if err != nil {
+		return err
+	}
+	_, err = st.db.Exec(`UPDATE batch_items SET status = ?, error = ?, message_id = ?, updated_at = ?
+		WHERE batch_id = ? AND idx = ?`, SendStatus(sendErr), sealed, messageID, time.Now(), batchID, index)
+	return err
+}
+
+// failInterruptedBatches отмечает неотправленные сообщения пакетов, прерванных перезапуском
+func (st *Storage) failInterruptedBatches() error {
+	sealed, err := st.EncryptField(errBatchInterrupted.Error())
+	if err != nil {
+		return err
+	}
+	_, err = st.db.Exec(`UPDATE batch_items SET status = ?, error = ?, updated_at = ? WHERE status = ?`,
+		HistoryStatusFailed, sealed, time.Now(), BatchStatusQueued)
+	return err
+}
+
+// GetBatch возвращает состояние пакета или ErrBatchNotFound
+func (st *Storage) GetBatch(batchID string) (*BatchStatus, error) {
+	rows, err := st.db.Query(`SELECT idx, chat_name, status, error, message_id, scheduled_at, created_at, updated_at
+		FROM batch_items WHERE batch_id = ? ORDER BY idx`, batchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batch := &BatchStatus{ID: batchID, Statuses: map[string]int{}, Done: true, Items: []BatchItem{}}
+	for rows.Next() {
+		var item BatchItem
+		var scheduledAt sql.NullTime
+		if err := rows.Scan(&item.Index, &item.ChatName, &item.Status, &item.Error, &item.MessageID,
+			&scheduledAt, &batch.CreatedAt, &item.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := st.decryptFields(&item.ChatName, &item.Error); err != nil {
+			return nil, err
+		}
+		if scheduledAt.Valid {
+			item.ScheduledAt = &scheduledAt.Time
+		}
+		batch.Statuses[item.Status]++
+		if item.Status == BatchStatusQueued {
+			batch.Done = false
+		}
+		batch.Items = append(batch.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(batch.Items) == 0 {
+		return nil, ErrBatchNotFound
+	}
+	batch.Total = len(batch.Items)
+	return batch, nil
+}
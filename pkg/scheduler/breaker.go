@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen возвращается, когда отправки в чат приостановлены после серии ошибок
+var ErrCircuitOpen = errors.New("отправка в чат приостановлена после серии ошибок")
+
+const (
+	// DefaultBreakerThreshold - количество ошибок подряд до приостановки чата по умолчанию
+	DefaultBreakerThreshold = 5
+
+	alertKindCircuitOpen = "circuit_open"
+)
+
+// BreakerState - состояние отправок в один чат
+type BreakerState struct {
+	ChatName  string     `json:"chat_name"`
+	Failures  int        `json:"failures"`
+	Open      bool       `json:"open"`
+	LastError string     `json:"last_error,omitempty"`
+	OpenedAt  *time.Time `json:"opened_at,omitempty"`
+}
+
+// CircuitBreakers приостанавливает отправки задач в чаты, отправка в которые
+// завершилась ошибкой threshold раз подряд
+type CircuitBreakers struct {
+	mutex     sync.Mutex
+	threshold int
+	chats     map[string]*BreakerState
+}
+
+func newCircuitBreakers(threshold int) *CircuitBreakers {
+	return &CircuitBreakers{
+		threshold: threshold,
+		chats:     make(map[string]*BreakerState),
+	}
+}
+
+// SetThreshold меняет количество ошибок подряд до приостановки чата, 0 - без приостановки
+func (b *CircuitBreakers) SetThreshold(threshold int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.threshold = threshold
+}
+
+// Threshold возвращает количество ошибок подряд до приостановки чата
+func (b *CircuitBreakers) Threshold() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.threshold
+}
+
+// IsOpen проверяет, приостановлены ли отправки в чат
+func (b *CircuitBreakers) IsOpen(chatName string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	state, exists := b.chats[chatName]
+	return exists && state.Open
+}
+
+// RecordSuccess сбрасывает счетчик ошибок чата
+func (b *CircuitBreakers) RecordSuccess(chatName string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.chats, chatName)
+}
+
+// RecordFailure учитывает ошибку и возвращает true, если чат только что был приостановлен
+func (b *CircuitBreakers) RecordFailure(chatName string, err error) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.threshold <= 0 {
+		return false
+	}
+
+	state, exists := b.chats[chatName]
+	if !exists {
+		state = &BreakerState{ChatName: chatName}
+		b.chats[chatName] = state
+	}
+	state.Failures++
+	state.LastError = err.Error()
+
+	if state.Open || state.Failures < b.threshold {
+		return false
+	}
+	now := time.Now()
+	state.Open = true
+	state.OpenedAt = &now
+	return true
+}
+
+// Reset возобновляет отправки в чат, возвращает false, если чат не был приостановлен
+func (b *CircuitBreakers) Reset(chatName string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	state, exists := b.chats[chatName]
+	delete(b.chats, chatName)
+	return exists && state.Open
+}
+
+// List возвращает состояние всех чатов с ошибками
+func (b *CircuitBreakers) List() []BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	states := make([]BreakerState, 0, len(b.chats))
+	for _, state := range b.chats {
+		states = append(states, *state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ChatName < states[j].ChatName })
+	return states
+}
@@ -0,0 +1,350 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StaggerOptions - распределение отправок рассылки по времени вместо отправки всем сразу
+type StaggerOptions struct {
+	// SpreadMinutes - за сколько минут разослать сообщение всем получателям
+	SpreadMinutes int `json:"spread_minutes"`
+	// Shuffle - случайный порядок получателей в каждой отправке
+	Shuffle bool `json:"shuffle,omitempty"`
+}
+
+// validateRecipients проверяет список получателей рассылки и настройки распределения
+func (t *ScheduledTask) validateRecipients() error {
+	seen := map[string]bool{t.ChatName: true}
+	for i, recipient := range t.Recipients {
+		recipient = strings.TrimSpace(recipient)
+		if recipient == "" {
+			return fmt.Errorf("пустое название чата в списке получателей")
+		}
+		if seen[recipient] {
+			return fmt.Errorf("чат '%s' указан в получателях несколько раз", recipient)
+		}
+		seen[recipient] = true
+		t.Recipients[i] = recipient
+	}
+
+	if t.GroupMembers && (len(t.Recipients) > 0 || len(t.RecipientLists) > 0) {
+		return fmt.Errorf("нельзя одновременно указывать group_members и recipients или recipient_lists")
+	}
+	if t.SendOncePerRecipient && !t.broadcast() {
+		return fmt.Errorf("send_once_per_recipient применяется только к рассылке по recipients, recipient_lists или group_members")
+	}
+	if t.Stagger == nil {
+		return nil
+	}
+	if !t.broadcast() {
+		return fmt.Errorf("stagger применяется только к рассылке по recipients, recipient_lists или group_members")
+	}
+	if t.Stagger.SpreadMinutes <= 0 {
+		return fmt.Errorf("stagger.spread_minutes должен быть больше 0")
+	}
+	if t.Stagger.SpreadMinutes >= t.Interval {
+		return fmt.Errorf("рассылка за %d мин должна быть короче интервала %d мин", t.Stagger.SpreadMinutes, t.Interval)
+	}
+	return nil
+}
+
+// recipients возвращает все чаты задачи: основной ChatName и получателей рассылки
+func (t *ScheduledTask) recipients() []string {
+	return append([]string{t.ChatName}, t.Recipients...)
+}
+
+// broadcast проверяет, что задача - рассылка: по списку получателей, спискам получателей
+// или участникам группы
+func (t *ScheduledTask) broadcast() bool {
+	return len(t.Recipients) > 0 || len(t.RecipientLists) > 0 || t.GroupMembers
+}
+
+// occurrenceRecipients возвращает получателей рассылки planned, время отправки каждому по местному
+// времени (nil - задача без local_time) и языки получателей из списков (nil - рассылка без списков).
+// Участники группы и списки получателей раскрываются в момент первой отправки и берутся из отчета
+// при продолжении рассылки после перезапуска, чтобы номера получателей не сместились при изменении
+// состава группы или списков
+func (s *Scheduler) occurrenceRecipients(task *ScheduledTask, planned time.Time) ([]string, []time.Time, []string, error) {
+	if !task.GroupMembers && len(task.RecipientLists) == 0 {
+		recipients := task.recipients()
+		return recipients, task.localSendTimes(planned, recipients, nil), nil, nil
+	}
+	recipients, sendAt, languages, err := s.storage.reportRecipients(task.ID, planned)
+	if err != nil || len(recipients) > 0 {
+		return recipients, sendAt, languages, err
+	}
+	if !task.GroupMembers {
+		recipients, info, duplicates, err := s.mergeRecipients(task)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if duplicates > 0 {
+			Logger.Infof("🧮 Рассылка по задаче %s: %d получателей, убрано повторов из списков: %d",
+				task.ID, len(recipients), duplicates)
+			if err := s.storage.setReportDuplicates(task.ID, planned, duplicates); err != nil {
+				Logger.Errorf("Ошибка сохранения отчета о рассылке задачи %s: %v", task.ID, err)
+			}
+		}
+		languages := make([]string, len(recipients))
+		for i, recipient := range recipients {
+			languages[i] = info[recipient].Language
+		}
+		return recipients, task.localSendTimes(planned, recipients, info), languages, nil
+	}
+	lister, ok := s.sender.(GroupMemberLister)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("транспорт не поддерживает получение участников группы")
+	}
+	members, err := lister.GroupMembers(task.ChatName, task.ChatJID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(members) == 0 {
+		return nil, nil, nil, fmt.Errorf("в группе нет участников кроме вас")
+	}
+	Logger.Infof("👥 Рассылка по задаче %s участникам группы '%s': %d получателей", task.ID, task.ChatName, len(members))
+	return members, task.localSendTimes(planned, members, nil), nil, nil
+}
+
+// recipientJID возвращает JID получателя index: для основного чата - сохраненный ChatJID,
+// для участников группы - сам получатель
+func (t *ScheduledTask) recipientJID(recipients []string, index int) string {
+	switch {
+	case t.GroupMembers:
+		return recipients[index]
+	case index == 0:
+		return t.ChatJID
+	default:
+		return ""
+	}
+}
+
+// staggerGap возвращает паузу между отправками рассылки на count получателей
+func (t *ScheduledTask) staggerGap(count int) time.Duration {
+	if t.Stagger == nil || count == 0 {
+		return 0
+	}
+	return time.Duration(t.Stagger.SpreadMinutes) * time.Minute / time.Duration(count)
+}
+
+// deliverOccurrence отправляет сообщение получателям задачи. Для рассылки отмечает каждого
+// получателя, чтобы после перезапуска продолжить с оставшимися, а не отправлять всем заново,
+// и ведет отчет о рассылке (OccurrenceReport)
+func (s *Scheduler) deliverOccurrence(task *ScheduledTask, planned time.Time, msg OutgoingMessage) {
+	if !task.broadcast() {
+		s.deliverToRecipient(task, msg)
+		return
+	}
+	// Настройки могли измениться после создания задачи
+	if err := s.checkCompliance(task); err != nil {
+		s.alert(alertKindCompliance, fmt.Sprintf("Рассылка по задаче %s не выполнена: %v", task.ID, err))
+		return
+	}
+	recipients, sendAt, languages, err := s.occurrenceRecipients(task, planned)
+	if err != nil {
+		Logger.Errorf("❌ Ошибка получения получателей рассылки '%s' для задачи %s: %v", task.ChatName, task.ID, err)
+		return
+	}
+
+	delivered, err := s.storage.deliveredRecipients(task.ID)
+	if err != nil {
+		Logger.Errorf("Ошибка чтения прогресса рассылки задачи %s: %v", task.ID, err)
+	}
+	var pending []int
+	for index := range recipients {
+		if !delivered[index] {
+			pending = append(pending, index)
+		}
+	}
+	if len(delivered) > 0 {
+		Logger.Infof("♻️ Рассылка по задаче %s продолжается: осталось %d из %d получателей",
+			task.ID, len(pending), len(recipients))
+	}
+	if task.Stagger != nil && task.Stagger.Shuffle {
+		rand.Shuffle(len(pending), func(i, j int) { pending[i], pending[j] = pending[j], pending[i] })
+	}
+	if sendAt != nil && len(pending) > 0 {
+		sort.SliceStable(pending, func(i, j int) bool { return sendAt[pending[i]].Before(sendAt[pending[j]]) })
+		Logger.Infof("🌍 Рассылка по задаче %s по местному времени %s: с %s до %s", task.ID, task.LocalTime,
+			sendAt[pending[0]].Local().Format("15:04 02.01"), sendAt[pending[len(pending)-1]].Local().Format("15:04 02.01"))
+	}
+	occurrence, err := s.storage.startReport(task.ID, planned, recipients, sendAt, languages)
+	if err != nil {
+		Logger.Errorf("Ошибка создания отчета о рассылке задачи %s: %v", task.ID, err)
+	}
+	if task.SendOncePerRecipient && occurrence > 0 {
+		pending = s.skipReceived(task, occurrence, recipients, pending)
+	}
+	pending = s.skipSuppressed(task, occurrence, recipients, pending)
+	generator, _ := s.sender.(MessageIDGenerator)
+	message := msg.Message
+
+	gap := task.staggerGap(len(recipients))
+	for i, index := range pending {
+		if i > 0 && gap > 0 && !s.clock.Sleep(task.ctx, gap) {
+			return
+		}
+		if sendAt != nil {
+			if wait := sendAt[index].Sub(s.clock.Now()); wait > 0 && !s.clock.Sleep(task.ctx, wait) {
+				return
+			}
+			// Отправки по местному времени растягиваются на сутки, приостановка действует на оставшихся
+			if s.isPaused(task) {
+				Logger.Infof("⏸️ Задача %s приостановлена, рассылка по местному времени прервана", task.ID)
+				return
+			}
+		}
+		// Задачу могли остановить во время паузы; при завершении процесса оставшиеся
+		// получатели получат сообщение после перезапуска
+		if !s.isCurrent(task) || s.IsShuttingDown() {
+			return
+		}
+
+		msg.ChatName, msg.ChatJID = recipients[index], task.recipientJID(recipients, index)
+		if languages != nil {
+			msg.Message, msg.Language = task.localize(languages[index], message), languages[index]
+		}
+		if generator != nil {
+			msg.ID = generator.GenerateMessageID()
+		}
+		sendErr := s.deliverToRecipient(task, msg)
+		if errors.Is(sendErr, ErrShuttingDown) || (sendErr != nil && !s.isCurrent(task)) {
+			// Отправка прервана остановкой задачи или процесса, получатель остается в очереди
+			return
+		}
+		if occurrence > 0 {
+			if err := s.storage.updateReport(task.ID, occurrence, index, msg.ID, sendErr); err != nil {
+				Logger.Errorf("Ошибка обновления отчета о рассылке задачи %s: %v", task.ID, err)
+			}
+		}
+		if sendErr != nil {
+			continue
+		}
+		if err := s.storage.markRecipientDelivered(task.ID, index); err != nil {
+			Logger.Errorf("Ошибка сохранения прогресса рассылки задачи %s: %v", task.ID, err)
+		}
+	}
+}
+
+// skipReceived исключает из pending получателей, которые уже получили сообщение в прошлых рассылках
+func (s *Scheduler) skipReceived(task *ScheduledTask, occurrence int, recipients []string, pending []int) []int {
+	received, err := s.storage.receivedRecipients(task.ID, occurrence)
+	if err != nil {
+		Logger.Errorf("Ошибка чтения отчетов о рассылках задачи %s: %v", task.ID, err)
+		return pending
+	}
+	remaining := pending[:0]
+	for _, index := range pending {
+		if !received[recipients[index]] {
+			remaining = append(remaining, index)
+			continue
+		}
+		if err := s.storage.setReportStatus(task.ID, occurrence, index, ReportStatusSkipped); err != nil {
+			Logger.Errorf("Ошибка обновления отчета о рассылке задачи %s: %v", task.ID, err)
+		}
+	}
+	if skipped := len(pending) - len(remaining); skipped > 0 {
+		Logger.Infof("⏭️ Рассылка по задаче %s: %d получателей уже получили сообщение и пропущены", task.ID, skipped)
+	}
+	return remaining
+}
+
+// deliverToRecipient отправляет сообщение задачи в один чат
+func (s *Scheduler) deliverToRecipient(task *ScheduledTask, msg OutgoingMessage) error {
+	Logger.Infof("📤 Отправка сообщения по задаче %s в чат '%s'", task.ID, msg.ChatName)
+	if err := s.composeMessage(task, &msg); err != nil {
+		if !errors.Is(err, ErrTemplateRender) {
+			Logger.Errorf("❌ Сообщение по задаче %s для чата '%s' не отправлено: %v", task.ID, msg.ChatName, err)
+			return err
+		}
+		Logger.Errorf("❌ Ошибка заполнения шаблона задачи %s для чата '%s': %v", task.ID, msg.ChatName, err)
+		s.ReportRenderError(fmt.Sprintf("задачи %s", task.ID), err)
+		return err
+	}
+	if generator, ok := s.sender.(MessageIDGenerator); ok && msg.ID == "" {
+		// Закрепление и учет отклика ссылаются на отправленное сообщение по его ID
+		msg.ID = generator.GenerateMessageID()
+	}
+	if err := s.deliverTaskMessage(task.ctx, task, msg); err != nil {
+		Logger.Errorf("❌ Ошибка отправки сообщения по задаче %s в чат '%s': %v", task.ID, msg.ChatName, err)
+		s.markMissed(task, msg.Planned, err)
+		return err
+	}
+	Logger.Infof("✅ Сообщение по задаче %s в чат '%s' отправлено успешно", task.ID, msg.ChatName)
+	if task.Pin != "" {
+		s.rotatePin(task, msg)
+	}
+	return nil
+}
+
+// composeMessage готовит текст сообщения задачи получателю msg: вариант текста, шаблон и подпись.
+// Через него проходят и отправки, и POST /preview, чтобы предпросмотр совпадал с отправленным.
+// Длина проверяется после подписи: шаблон и подпись могут сделать текст длиннее допустимого
+func (s *Scheduler) composeMessage(task *ScheduledTask, msg *OutgoingMessage) error {
+	task.applyVariant(msg)
+	if err := s.renderTemplate(task, msg); err != nil {
+		return err
+	}
+	if err := s.ApplyFooter(task, msg); err != nil {
+		return err
+	}
+	if task.SplitLongMessages {
+		// Длинное сообщение будет разбито на части при отправке
+		return nil
+	}
+	return ValidateMessageLength(msg.Message)
+}
+
+// PreviewMessage возвращает сообщение задачи в чат task.ChatName, каким оно было бы отправлено сейчас
+// получателю с языком language (пустой - без перевода)
+func (s *Scheduler) PreviewMessage(ctx context.Context, task *ScheduledTask, language string) (OutgoingMessage, error) {
+	message, err := task.ResolveMessage(ctx)
+	if err != nil {
+		return OutgoingMessage{}, err
+	}
+	language = normalizeLanguage(language)
+	msg := OutgoingMessage{
+		TaskID:    task.ID,
+		ChatName:  task.ChatName,
+		Message:   task.localize(language, message),
+		Language:  language,
+		Poll:      task.Poll,
+		ForwardID: task.ForwardMessageID,
+		Planned:   s.clock.Now(),
+	}
+	if err := s.composeMessage(task, &msg); err != nil {
+		return OutgoingMessage{}, err
+	}
+	return msg, nil
+}
+
+// deliveredRecipients возвращает номера получателей, которым уже отправлена текущая рассылка задачи
+func (st *Storage) deliveredRecipients(taskID string) (map[int]bool, error) {
+	rows, err := st.db.Query(`SELECT recipient FROM task_deliveries WHERE task_id = ?`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	delivered := map[int]bool{}
+	for rows.Next() {
+		var index int
+		if err := rows.Scan(&index); err != nil {
+			return nil, err
+		}
+		delivered[index] = true
+	}
+	return delivered, rows.Err()
+}
+
+// markRecipientDelivered отмечает получателя текущей рассылки задачи
+func (st *Storage) markRecipientDelivered(taskID string, index int) error {
+	_, err := st.db.Exec(`INSERT OR IGNORE INTO task_deliveries (task_id, recipient) VALUES (?, ?)`, taskID, index)
+	return err
+}
@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// CalendarDefaultWeeks - горизонт календаря по умолчанию
+	CalendarDefaultWeeks = 4
+	// CalendarMaxWeeks - максимальный горизонт календаря
+	CalendarMaxWeeks = 52
+	// calendarMaxEventsPerTask ограничивает размер календаря для задач с маленьким интервалом
+	calendarMaxEventsPerTask = 1000
+)
+
+// BuildCalendar формирует iCalendar (RFC 5545) с предстоящими отправками задач
+func BuildCalendar(tasks []*ScheduledTask, from, to time.Time) string {
+	var b strings.Builder
+	writeLine := func(line string) {
+		b.WriteString(foldCalendarLine(line))
+		b.WriteString("\r\n")
+	}
+
+	stamp := time.Now().UTC().Format(calendarTimeFormat)
+
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//WhatsApp Scheduler//RU")
+	writeLine("CALSCALE:GREGORIAN")
+	writeLine("X-WR-CALNAME:WhatsApp Scheduler")
+
+	for _, task := range tasks {
+		for _, occurrence := range task.occurrencesBetween(from, to, calendarMaxEventsPerTask) {
+			// Событие занимает окно случайной задержки, но не меньше минуты
+			start, end := task.sendWindow(occurrence)
+			if end.Sub(start) < time.Minute {
+				end = start.Add(time.Minute)
+			}
+
+			writeLine("BEGIN:VEVENT")
+			writeLine(fmt.Sprintf("UID:%s-%d@whatsapp-scheduler", task.ID, occurrence.Unix()))
+			writeLine("DTSTAMP:" + stamp)
+			writeLine("DTSTART:" + start.UTC().Format(calendarTimeFormat))
+			writeLine("DTEND:" + end.UTC().Format(calendarTimeFormat))
+			writeLine("SUMMARY:" + escapeCalendarText("WhatsApp → "+task.ChatName))
+			description := task.Message
+			if task.MessageCommand != "" {
+				description = "$ " + task.MessageCommand
+			}
+			writeLine("DESCRIPTION:" + escapeCalendarText(description))
+			writeLine("END:VEVENT")
+		}
+	}
+
+	writeLine("END:VCALENDAR")
+	return b.String()
+}
+
+const calendarTimeFormat = "20060102T150405Z"
+
+// escapeCalendarText экранирует текстовые значения по RFC 5545
+func escapeCalendarText(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
+
+// foldCalendarLine переносит строки длиннее 75 байт, не разрывая UTF-8 символы
+func foldCalendarLine(line string) string {
+	const maxLength = 75
+	if len(line) <= maxLength {
+		return line
+	}
+
+	var b strings.Builder
+	lineLength := 0
+	for _, r := range line {
+		size := len(string(r))
+		if lineLength+size > maxLength {
+			b.WriteString("\r\n ")
+			lineLength = 1
+		}
+		b.WriteRune(r)
+		lineLength += size
+	}
+	return b.String()
+}
@@ -0,0 +1,495 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// campaignCheckInterval - как часто отправляются наступившие шаги кампаний
+	campaignCheckInterval = time.Minute
+	// maxCampaignSteps - максимальное количество шагов кампании
+	maxCampaignSteps = 50
+)
+
+// Состояния участия получателя в кампании
+const (
+	EnrollmentActive    = "active"
+	EnrollmentCompleted = "completed"
+	EnrollmentExited    = "exited"
+	EnrollmentFailed    = "failed"
+)
+
+// ErrCampaignNotFound - кампании с таким ID нет
+var ErrCampaignNotFound = errors.New("кампания не найдена")
+
+// CampaignStep - сообщение кампании
+type CampaignStep struct {
+	// DelayMinutes - пауза перед шагом: для первого шага от подписки получателя, для остальных от предыдущего шага
+	DelayMinutes int    `json:"delay_minutes"`
+	Message      string `json:"message"`
+	// Format - формат текста: plain или markdown
+	Format string `json:"format,omitempty"`
+}
+
+// Campaign - цепочка сообщений, которую каждый подписанный получатель проходит по своему расписанию
+type Campaign struct {
+	ID    string         `json:"id"`
+	Name  string         `json:"name"`
+	Steps []CampaignStep `json:"steps"`
+	// ExitOnReply - получатель выходит из кампании после любого своего сообщения
+	ExitOnReply bool `json:"exit_on_reply,omitempty"`
+	// ExitKeywords - получатель выходит из кампании, написав сообщение с одной из фраз (без учета регистра)
+	ExitKeywords []string  `json:"exit_keywords,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CampaignEnrollment - участие получателя в кампании
+type CampaignEnrollment struct {
+	ID         int64  `json:"id"`
+	CampaignID string `json:"campaign_id"`
+	ChatName   string `json:"chat_name"`
+	ChatJID    string `json:"chat_jid,omitempty"`
+	// Step - номер следующего шага, начиная с 0; после прохождения кампании равен количеству шагов
+	Step int `json:"step"`
+	// NextAt - время отправки следующего шага
+	NextAt time.Time `json:"next_at"`
+	Status string    `json:"status"`
+	// Reason - причина выхода из кампании или ошибка отправки
+	Reason     string    `json:"reason,omitempty"`
+	EnrolledAt time.Time `json:"enrolled_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// validate проверяет шаги кампании и условия выхода
+func (c *Campaign) validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return fmt.Errorf("пустое название кампании")
+	}
+	if len(c.Steps) == 0 || len(c.Steps) > maxCampaignSteps {
+		return fmt.Errorf("в кампании должно быть от 1 до %d шагов", maxCampaignSteps)
+	}
+	for i := range c.Steps {
+		step := &c.Steps[i]
+		step.Message = strings.TrimSpace(step.Message)
+		if step.Message == "" {
+			return fmt.Errorf("шаг %d: пустое сообщение", i+1)
+		}
+		if step.DelayMinutes < 0 {
+			return fmt.Errorf("шаг %d: delay_minutes не может быть отрицательным", i+1)
+		}
+		if err := ValidateFormat(step.Format); err != nil {
+			return fmt.Errorf("шаг %d: %v", i+1, err)
+		}
+		if err := ValidateMessageLength(ApplyFormat(step.Message, step.Format)); err != nil {
+			return fmt.Errorf("шаг %d: %v", i+1, err)
+		}
+	}
+	c.ExitKeywords = normalizeKeywords(c.ExitKeywords)
+	return nil
+}
+
+// exitReason возвращает причину выхода из кампании по сообщению получателя или пустую строку
+func (c *Campaign) exitReason(text string) string {
+	if c.ExitOnReply {
+		return "reply"
+	}
+	lower := strings.ToLower(text)
+	for _, keyword := range c.ExitKeywords {
+		if strings.Contains(lower, keyword) {
+			return "keyword: " + keyword
+		}
+	}
+	return ""
+}
+
+// AddCampaign сохраняет новую кампанию и возвращает ее ID
+func (s *Scheduler) AddCampaign(campaign *Campaign) (string, error) {
+	if err := campaign.validate(); err != nil {
+		return "", err
+	}
+	campaign.ID = fmt.Sprintf("campaign_%d", time.Now().UnixNano())
+	campaign.CreatedAt = time.Now()
+	if err := s.storage.SaveCampaign(campaign); err != nil {
+		return "", err
+	}
+	Logger.Infof("💧 Добавлена кампания %s '%s' из %d шагов", campaign.ID, campaign.Name, len(campaign.Steps))
+	return campaign.ID, nil
+}
+
+// EnrollInCampaign подписывает получателей на кампанию. Получатели, уже проходящие кампанию, пропускаются.
+// Возвращает новые подписки
+func (s *Scheduler) EnrollInCampaign(campaignID string, recipients []string) ([]CampaignEnrollment, error) {
+	campaign, err := s.storage.GetCampaign(campaignID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.storage.CampaignEnrollments(campaignID)
+	if err != nil {
+		return nil, err
+	}
+	active := map[string]bool{}
+	for _, enrollment := range existing {
+		if enrollment.Status == EnrollmentActive {
+			active[strings.ToLower(enrollment.ChatName)] = true
+		}
+	}
+
+	now := time.Now()
+	enrolled := []CampaignEnrollment{}
+	for _, chatName := range recipients {
+		chatName = strings.TrimSpace(chatName)
+		if chatName == "" || active[strings.ToLower(chatName)] {
+			continue
+		}
+		chatJID, err := s.resolveRecipient(chatName)
+		if err != nil {
+			return enrolled, err
+		}
+		enrollment := CampaignEnrollment{
+			CampaignID: campaignID,
+			ChatName:   chatName,
+			ChatJID:    chatJID,
+			NextAt:     now.Add(time.Duration(campaign.Steps[0].DelayMinutes) * time.Minute),
+			Status:     EnrollmentActive,
+			EnrolledAt: now,
+			UpdatedAt:  now,
+		}
+		if enrollment.ID, err = s.storage.addEnrollment(enrollment); err != nil {
+			return enrolled, err
+		}
+		active[strings.ToLower(chatName)] = true
+		enrolled = append(enrolled, enrollment)
+	}
+	if len(enrolled) > 0 {
+		Logger.Infof("💧 На кампанию %s подписано получателей: %d", campaignID, len(enrolled))
+	}
+	return enrolled, nil
+}
+
+// UnenrollFromCampaign выводит получателей из кампании и возвращает их количество
+func (s *Scheduler) UnenrollFromCampaign(campaignID string, recipients []string) (int, error) {
+	if _, err := s.storage.GetCampaign(campaignID); err != nil {
+		return 0, err
+	}
+	enrollments, err := s.storage.CampaignEnrollments(campaignID)
+	if err != nil {
+		return 0, err
+	}
+	exited := 0
+	for _, enrollment := range enrollments {
+		if enrollment.Status != EnrollmentActive || !containsFold(recipients, enrollment.ChatName) {
+			continue
+		}
+		enrollment.Status, enrollment.Reason = EnrollmentExited, "manual"
+		if err := s.storage.updateEnrollment(enrollment); err != nil {
+			return exited, err
+		}
+		exited++
+	}
+	return exited, nil
+}
+
+// resolveRecipient возвращает JID получателя кампании: указанный явно (содержит @) или найденный
+// транспортом по названию. Пустой JID - транспорт не умеет искать чаты или недоступен
+func (s *Scheduler) resolveRecipient(chatName string) (string, error) {
+	if strings.Contains(chatName, "@") {
+		return chatName, nil
+	}
+	resolver, ok := s.sender.(ChatResolver)
+	if !ok {
+		return "", nil
+	}
+	candidates, err := resolver.ResolveChat(chatName)
+	if err != nil {
+		Logger.Warnf("Проверка чата '%s' пропущена: %v", chatName, err)
+		return "", nil
+	}
+	if len(candidates) > 1 {
+		return "", AmbiguousChatError(chatName, candidates)
+	}
+	if len(candidates) == 1 {
+		return candidates[0].JID, nil
+	}
+	return "", nil
+}
+
+// runCampaigns периодически отправляет наступившие шаги кампаний
+func (s *Scheduler) runCampaigns() {
+	for range time.Tick(campaignCheckInterval) {
+		s.advanceCampaigns()
+	}
+}
+
+// advanceCampaigns отправляет получателям наступившие шаги кампаний. В тихие часы шаги ждут их окончания,
+// получатели из списка исключений выходят из кампании
+func (s *Scheduler) advanceCampaigns() {
+	now := time.Now()
+	if !s.IsLeader() || s.IsLoggedOut() || s.IsShuttingDown() || s.inQuietHours(now) {
+		return
+	}
+	due, err := s.storage.dueEnrollments(now)
+	if err != nil {
+		Logger.Errorf("Ошибка чтения шагов кампаний: %v", err)
+		return
+	}
+	if len(due) == 0 {
+		return
+	}
+	suppressions, err := s.storage.ListSuppressions()
+	if err != nil {
+		Logger.Errorf("Ошибка чтения списка исключений рассылок: %v", err)
+		return
+	}
+	suppressed := map[string]bool{}
+	for _, suppression := range suppressions {
+		suppressed[suppression.JID] = true
+	}
+
+	campaigns := map[string]*Campaign{}
+	for _, enrollment := range due {
+		campaign, ok := campaigns[enrollment.CampaignID]
+		if !ok {
+			if campaign, err = s.storage.GetCampaign(enrollment.CampaignID); err != nil && !errors.Is(err, ErrCampaignNotFound) {
+				Logger.Errorf("Ошибка чтения кампании %s: %v", enrollment.CampaignID, err)
+				continue
+			}
+			campaigns[enrollment.CampaignID] = campaign
+		}
+
+		switch {
+		case campaign == nil:
+			enrollment.Status, enrollment.Reason = EnrollmentFailed, ErrCampaignNotFound.Error()
+		case suppressed[enrollment.ChatName] || (enrollment.ChatJID != "" && suppressed[enrollment.ChatJID]):
+			enrollment.Status, enrollment.Reason = EnrollmentExited, "suppressed"
+		default:
+			step := campaign.Steps[enrollment.Step]
+			err := s.Deliver(context.Background(), OutgoingMessage{
+				TaskID:      campaign.ID,
+				ChatName:    enrollment.ChatName,
+				ChatJID:     enrollment.ChatJID,
+				Message:     ApplyFormat(step.Message, step.Format),
+				LinkPreview: true,
+			})
+			if errors.Is(err, ErrShuttingDown) || errors.Is(err, ErrStandby) || errors.Is(err, ErrLoggedOut) || errors.Is(err, ErrReadOnly) {
+				return
+			}
+			if IsRetryable(err) {
+				// Шаг повторится при следующей проверке
+				continue
+			}
+			if err != nil {
+				Logger.Errorf("❌ Ошибка отправки шага %d кампании %s в чат '%s': %v",
+					enrollment.Step+1, campaign.ID, enrollment.ChatName, err)
+				enrollment.Status, enrollment.Reason = EnrollmentFailed, err.Error()
+				break
+			}
+
+			Logger.Infof("💧 Шаг %d из %d кампании %s отправлен в чат '%s'",
+				enrollment.Step+1, len(campaign.Steps), campaign.ID, enrollment.ChatName)
+			enrollment.Step++
+			if enrollment.Step == len(campaign.Steps) {
+				enrollment.Status = EnrollmentCompleted
+			} else {
+				enrollment.NextAt = time.Now().Add(time.Duration(campaign.Steps[enrollment.Step].DelayMinutes) * time.Minute)
+			}
+		}
+		if err := s.storage.updateEnrollment(enrollment); err != nil {
+			Logger.Errorf("Ошибка сохранения прогресса кампании %s для чата '%s': %v",
+				enrollment.CampaignID, enrollment.ChatName, err)
+		}
+	}
+}
+
+// checkCampaignExits выводит из кампаний получателя, написавшего в чат msg.ChatJID,
+// если сообщение выполняет условие выхода
+func (s *Scheduler) checkCampaignExits(msg IncomingMessage) {
+	if msg.ChatJID == "" {
+		return
+	}
+	enrollments, err := s.storage.activeChatEnrollments(msg.ChatJID)
+	if err != nil {
+		Logger.Errorf("Ошибка чтения кампаний чата %s: %v", msg.ChatJID, err)
+		return
+	}
+	for _, enrollment := range enrollments {
+		campaign, err := s.storage.GetCampaign(enrollment.CampaignID)
+		if err != nil {
+			continue
+		}
+		reason := campaign.exitReason(msg.Text)
+		if reason == "" {
+			continue
+		}
+		enrollment.Status, enrollment.Reason = EnrollmentExited, reason
+		if err := s.storage.updateEnrollment(enrollment); err != nil {
+			Logger.Errorf("Ошибка выхода чата '%s' из кампании %s: %v", enrollment.ChatName, campaign.ID, err)
+			continue
+		}
+		Logger.Infof("🚪 Чат '%s' вышел из кампании %s (%s)", enrollment.ChatName, campaign.ID, reason)
+	}
+}
+
+// SaveCampaign сохраняет кампанию
+func (st *Storage) SaveCampaign(campaign *Campaign) error {
+	data, err := json.Marshal(campaign)
+	if err != nil {
+		return err
+	}
+	sealed, err := st.EncryptField(string(data))
+	if err != nil {
+		return err
+	}
+	_, err = st.db.Exec(`INSERT INTO campaigns (id, data, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data`, campaign.ID, sealed, campaign.CreatedAt)
+	return err
+}
+
+// GetCampaign возвращает кампанию или ErrCampaignNotFound
+func (st *Storage) GetCampaign(id string) (*Campaign, error) {
+	var data string
+	err := st.db.QueryRow(`SELECT data FROM campaigns WHERE id = ?`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrCampaignNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return st.decodeCampaign(data)
+}
+
+// ListCampaigns возвращает кампании, новые первыми
+func (st *Storage) ListCampaigns() ([]*Campaign, error) {
+	rows, err := st.db.Query(`SELECT data FROM campaigns ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	campaigns := []*Campaign{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		campaign, err := st.decodeCampaign(data)
+		if err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	return campaigns, rows.Err()
+}
+
+// decodeCampaign расшифровывает и разбирает сохраненную кампанию
+func (st *Storage) decodeCampaign(data string) (*Campaign, error) {
+	if err := st.decryptFields(&data); err != nil {
+		return nil, err
+	}
+	campaign := &Campaign{}
+	if err := json.Unmarshal([]byte(data), campaign); err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// DeleteCampaign удаляет кампанию вместе с подписками, false - кампании не было
+func (st *Storage) DeleteCampaign(id string) (bool, error) {
+	if _, err := st.db.Exec(`DELETE FROM campaign_enrollments WHERE campaign_id = ?`, id); err != nil {
+		return false, err
+	}
+	res, err := st.db.Exec(`DELETE FROM campaigns WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := res.RowsAffected()
+	return deleted > 0, err
+}
+
+// addEnrollment сохраняет подписку получателя и возвращает ее ID
+func (st *Storage) addEnrollment(enrollment CampaignEnrollment) (int64, error) {
+	chatName, err := st.EncryptField(enrollment.ChatName)
+	if err != nil {
+		return 0, err
+	}
+	res, err := st.db.Exec(`INSERT INTO campaign_enrollments
+		(campaign_id, chat_name, chat_jid, step, next_at, status, reason, enrolled_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		enrollment.CampaignID, chatName, enrollment.ChatJID, enrollment.Step, enrollment.NextAt,
+		enrollment.Status, enrollment.Reason, enrollment.EnrolledAt, enrollment.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// updateEnrollment сохраняет шаг, время следующего шага и состояние подписки
+func (st *Storage) updateEnrollment(enrollment CampaignEnrollment) error {
+	_, err := st.db.Exec(`UPDATE campaign_enrollments SET step = ?, next_at = ?, status = ?, reason = ?, updated_at = ?
+		WHERE id = ?`, enrollment.Step, enrollment.NextAt, enrollment.Status, enrollment.Reason, time.Now(), enrollment.ID)
+	return err
+}
+
+// CampaignEnrollments возвращает подписки на кампанию в порядке подписки
+func (st *Storage) CampaignEnrollments(campaignID string) ([]CampaignEnrollment, error) {
+	return st.queryEnrollments(`WHERE campaign_id = ? ORDER BY id`, campaignID)
+}
+
+// dueEnrollments возвращает активные подписки, следующий шаг которых наступил к now
+func (st *Storage) dueEnrollments(now time.Time) ([]CampaignEnrollment, error) {
+	return st.queryEnrollments(`WHERE status = ? AND next_at <= ? ORDER BY next_at`, EnrollmentActive, now)
+}
+
+// activeChatEnrollments возвращает активные подписки чата chatJID
+func (st *Storage) activeChatEnrollments(chatJID string) ([]CampaignEnrollment, error) {
+	return st.queryEnrollments(`WHERE status = ? AND chat_jid = ?`, EnrollmentActive, chatJID)
+}
+
+// queryEnrollments возвращает подписки по условию where
+func (st *Storage) queryEnrollments(where string, args ...any) ([]CampaignEnrollment, error) {
+	rows, err := st.db.Query(`SELECT id, campaign_id, chat_name, chat_jid, step, next_at, status, reason, enrolled_at, updated_at
+		FROM campaign_enrollments `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enrollments := []CampaignEnrollment{}
+	for rows.Next() {
+		var e CampaignEnrollment
+		if err := rows.Scan(&e.ID, &e.CampaignID, &e.ChatName, &e.ChatJID, &e.Step, &e.NextAt,
+			&e.Status, &e.Reason, &e.EnrolledAt, &e.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := st.decryptFields(&e.ChatName); err != nil {
+			return nil, err
+		}
+		enrollments = append(enrollments, e)
+	}
+	return enrollments, rows.Err()
+}
+
+// DeleteRecipientEnrollments удаляет подписки получателя с JID jid или одним из названий names
+func (st *Storage) DeleteRecipientEnrollments(jid string, names []string) (int64, error) {
+	enrollments, err := st.queryEnrollments(``)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	for _, enrollment := range enrollments {
+		if enrollment.ChatJID != jid && !containsFold(names, enrollment.ChatName) {
+			continue
+		}
+		if _, err := st.db.Exec(`DELETE FROM campaign_enrollments WHERE id = ?`, enrollment.ID); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
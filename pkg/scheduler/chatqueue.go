@@ -0,0 +1,113 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultChatSendGap - минимальная пауза между сообщениями в один чат по умолчанию
+	DefaultChatSendGap = 3 * time.Second
+	// DefaultMaxConcurrentSends - сколько сообщений по умолчанию отправляется одновременно во все чаты
+	DefaultMaxConcurrentSends = 2
+)
+
+// chatQueues выстраивает отправки в каждый чат в очередь: сообщения в один чат уходят строго
+// по одному и не чаще, чем раз в gap, даже если их отправляют несколько задач одновременно
+type chatQueues struct {
+	mutex sync.Mutex
+	gap   time.Duration
+	chats map[string]*chatQueue
+}
+
+// chatQueue - очередь отправок в один чат
+type chatQueue struct {
+	// slot занят на время отправки, ожидающие отправки блокируются на записи в него
+	slot     chan struct{}
+	lastSent time.Time
+}
+
+func newChatQueues(gap time.Duration) *chatQueues {
+	return &chatQueues{
+		gap:   gap,
+		chats: make(map[string]*chatQueue),
+	}
+}
+
+// setGap меняет минимальную паузу между сообщениями в один чат
+func (q *chatQueues) setGap(gap time.Duration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.gap = gap
+}
+
+// acquire дожидается очереди отправки в чат и паузы после предыдущего сообщения.
+// После отправки нужно вызвать возвращенную функцию release
+func (q *chatQueues) acquire(ctx context.Context, chatName string) (func(), error) {
+	key := strings.ToLower(strings.TrimSpace(chatName))
+	q.mutex.Lock()
+	chat, exists := q.chats[key]
+	if !exists {
+		chat = &chatQueue{slot: make(chan struct{}, 1)}
+		q.chats[key] = chat
+	}
+	q.mutex.Unlock()
+
+	select {
+	case chat.slot <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("отправка в чат '%s' отменена в очереди: %v", chatName, ctx.Err())
+	}
+
+	q.mutex.Lock()
+	gap := q.gap
+	q.mutex.Unlock()
+
+	// lastSent меняется только владельцем slot
+	if wait := time.Until(chat.lastSent.Add(gap)); wait > 0 {
+		Logger.Debugf("Пауза %s перед отправкой в чат '%s'", wait.Truncate(time.Millisecond), chatName)
+		if !sleepContext(ctx, wait) {
+			<-chat.slot
+			return nil, fmt.Errorf("отправка в чат '%s' отменена в очереди: %v", chatName, ctx.Err())
+		}
+	}
+
+	return func() {
+		chat.lastSent = time.Now()
+		<-chat.slot
+	}, nil
+}
+
+// sendSlots ограничивает количество одновременных вызовов SendMessage по всем чатам,
+// nil - без ограничения
+type sendSlots chan struct{}
+
+func newSendSlots(limit int) sendSlots {
+	if limit <= 0 {
+		return nil
+	}
+	return make(sendSlots, limit)
+}
+
+// acquire дожидается свободного слота отправки. После отправки нужно вызвать release
+func (s sendSlots) acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("отправка отменена в ожидании свободного слота: %v", ctx.Err())
+	}
+}
+
+// release освобождает слот отправки
+func (s sendSlots) release() {
+	if s != nil {
+		<-s
+	}
+}
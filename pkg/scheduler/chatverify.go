@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	// chatVerifyInterval - период повторной проверки чатов задач
+	chatVerifyInterval = 30 * time.Minute
+	// AlertKindChatChanged - чат задачи переименован или пропал из контактов, отправки приостановлены
+	AlertKindChatChanged = "chat_changed"
+)
+
+// pinTaskChat запоминает JID, найденный по ChatName при создании задачи, чтобы отправлять
+// именно в этот чат и заметить, если название перестанет ему соответствовать
+func (t *ScheduledTask) pinTaskChat(candidates []ChatCandidate) {
+	if t.ChatJID == "" && len(candidates) == 1 {
+		t.ChatJID = candidates[0].JID
+	}
+}
+
+// verifyTaskChats периодически проверяет, что названия чатов задач соответствуют сохраненным JID
+func (s *Scheduler) verifyTaskChats() {
+	resolver, ok := s.sender.(ChatResolver)
+	if !ok {
+		return
+	}
+	for range time.Tick(chatVerifyInterval) {
+		for _, task := range s.ListTasks() {
+			s.verifyTaskChat(resolver, task)
+		}
+	}
+}
+
+// verifyTaskChat проверяет чат задачи. Если название больше не соответствует сохраненному JID
+// (контакт переименован или удален), задача помечается требующей внимания и отправки по ней
+// пропускаются; когда соответствие восстанавливается, отметка снимается
+func (s *Scheduler) verifyTaskChat(resolver ChatResolver, task *ScheduledTask) {
+	// Задача, меняющая название группы, сама расходится с ChatName
+	if task.ChatJID == "" || task.renamesGroup() {
+		return
+	}
+	candidates, err := resolver.ResolveChat(task.ChatName)
+	if err != nil {
+		Logger.Debugf("Проверка чата задачи %s пропущена: %v", task.ID, err)
+		return
+	}
+
+	problem := ""
+	if !slices.ContainsFunc(candidates, func(c ChatCandidate) bool { return c.JID == task.ChatJID }) {
+		problem = fmt.Sprintf("чат '%s' (%s) больше не найден в контактах и группах", task.ChatName, task.ChatJID)
+		if current, err := resolver.ResolveChat(task.ChatJID); err == nil && len(current) == 1 && current[0].Name != "" {
+			problem = fmt.Sprintf("чат %s переименован: '%s' вместо '%s'", task.ChatJID, current[0].Name, task.ChatName)
+		}
+	}
+
+	// task - копия из ListTasks, отметка ставится на активной задаче того же запуска
+	s.mutex.Lock()
+	live, exists := s.tasks[task.ID]
+	if !exists || live.generation != task.generation || live.NeedsAttention == problem {
+		s.mutex.Unlock()
+		return
+	}
+	live.NeedsAttention = problem
+	if err := s.storage.SaveTask(live); err != nil {
+		Logger.Errorf("Ошибка сохранения задачи %s: %v", task.ID, err)
+	}
+	s.mutex.Unlock()
+
+	if problem != "" {
+		s.alert(AlertKindChatChanged, fmt.Sprintf("Задача %s требует внимания: %s, отправки приостановлены", task.ID, problem))
+	} else {
+		Logger.Infof("✅ Чат задачи %s снова соответствует названию '%s', отправки возобновлены", task.ID, task.ChatName)
+	}
+}
+
+// needsAttention возвращает причину, по которой отправки задачи приостановлены до вмешательства
+func (s *Scheduler) needsAttention(task *ScheduledTask) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return task.NeedsAttention
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock - источник времени для цикла задачи и отправок по расписанию.
+// По умолчанию реальное время, для симуляции и тестов - SimulatedClock
+type Clock interface {
+	Now() time.Time
+	// Sleep ждет d и возвращает false, если ожидание прервано отменой ctx
+	Sleep(ctx context.Context, d time.Duration) bool
+}
+
+// realClock - системное время
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) bool {
+	return sleepContext(ctx, d)
+}
+
+// SimulatedClock - часы, которые не ждут: Sleep сразу переводит время вперед.
+// Ожидание дальше until прерывается, как при остановке задачи
+type SimulatedClock struct {
+	mutex sync.Mutex
+	now   time.Time
+	until time.Time
+}
+
+// NewSimulatedClock создает часы, идущие от start до until (until нулевой - без ограничения)
+func NewSimulatedClock(start, until time.Time) *SimulatedClock {
+	return &SimulatedClock{now: start, until: until}
+}
+
+// Now возвращает текущее симулированное время
+func (c *SimulatedClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Sleep переводит часы на d вперед без ожидания
+func (c *SimulatedClock) Sleep(ctx context.Context, d time.Duration) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+	if !c.until.IsZero() && c.now.After(c.until) {
+		c.now = c.until
+		return false
+	}
+	return true
+}
+
+// SetClock заменяет источник времени планировщика. Вызывается до запуска задач
+func (s *Scheduler) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// Now возвращает текущее время по часам планировщика
+func (s *Scheduler) Now() time.Time {
+	return s.clock.Now()
+}
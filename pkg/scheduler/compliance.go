@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// alertKindCompliance - рассылка не выполнена из-за нарушения режима согласия
+const alertKindCompliance = "compliance"
+
+// optOutReason - причина в списке исключений для отписавшихся получателей
+const optOutReason = "opt-out"
+
+// ErrComplianceViolation - в режиме согласия рассылка без подписи с отпиской не выполняется
+var ErrComplianceViolation = errors.New("рассылка нарушает режим согласия")
+
+// validateCompliance проверяет настройки режима согласия: отписка должна обрабатываться
+func (st *Settings) validateCompliance() error {
+	st.OptOutKeywords = normalizeKeywords(st.OptOutKeywords)
+	if st.ComplianceMode && len(st.OptOutKeywords) == 0 {
+		return fmt.Errorf("для compliance_mode нужно указать opt_out_keywords")
+	}
+	return nil
+}
+
+// checkCompliance в режиме согласия (Settings.ComplianceMode) проверяет, что рассылка подписана
+// текстом с одним из слов отписки. Задачи с одним чатом не проверяются
+func (s *Scheduler) checkCompliance(task *ScheduledTask) error {
+	settings := s.Settings()
+	if !settings.ComplianceMode || !task.broadcast() {
+		return nil
+	}
+	footer := strings.ToLower(s.footer(task))
+	if footer == "" {
+		return fmt.Errorf("%w: нет подписи с отпиской (message_footer или footer)", ErrComplianceViolation)
+	}
+	for _, keyword := range settings.OptOutKeywords {
+		if strings.Contains(footer, keyword) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: подпись не содержит слова отписки (%s)", ErrComplianceViolation,
+		strings.Join(settings.OptOutKeywords, ", "))
+}
+
+// handleOptOut добавляет в список исключений рассылок отправителя личного сообщения,
+// состоящего из слова отписки
+func (s *Scheduler) handleOptOut(msg IncomingMessage) {
+	if !msg.Direct || msg.SenderJID == "" {
+		return
+	}
+	text := strings.ToLower(strings.TrimSpace(msg.Text))
+	for _, keyword := range s.Settings().OptOutKeywords {
+		if text != keyword {
+			continue
+		}
+		if err := s.storage.AddSuppression(msg.SenderJID, optOutReason); err != nil {
+			Logger.Errorf("Ошибка добавления %s в список исключений: %v", msg.SenderJID, err)
+			return
+		}
+		Logger.Infof("🚫 %s (%s) отписался от рассылок", msg.SenderName, msg.SenderJID)
+		return
+	}
+}
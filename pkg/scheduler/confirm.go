@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultConfirmRecipients - с какого количества получателей рассылка требует подтверждения
+	DefaultConfirmRecipients = 50
+	// previewMaxOccurrences - сколько отправок задачи учитывается в предпросмотре рассылки
+	previewMaxOccurrences = 1000
+	// previewSampleRecipients - сколько получателей показывается в предпросмотре рассылки
+	previewSampleRecipients = 10
+)
+
+// ErrTaskNotPending - задача уже подтверждена или не требовала подтверждения
+var ErrTaskNotPending = errors.New("задача не ожидает подтверждения")
+
+// BroadcastPreview - объем рассылки, который нужно подтвердить перед запуском задачи
+type BroadcastPreview struct {
+	// Recipients - количество получателей, 0 - участников группы или списки получателей не удалось получить
+	Recipients int `json:"recipients"`
+	// DuplicatesCollapsed - сколько повторов убрано при объединении списков получателей
+	DuplicatesCollapsed int `json:"duplicates_collapsed,omitempty"`
+	// Occurrences - количество плановых отправок до времени окончания (не больше previewMaxOccurrences)
+	Occurrences int `json:"occurrences"`
+	// Messages - всего сообщений: получатели на отправки
+	Messages    int        `json:"messages"`
+	FirstSendAt *time.Time `json:"first_send_at,omitempty"`
+	// SampleRecipients - первые получатели списка для проверки
+	SampleRecipients []string `json:"sample_recipients"`
+}
+
+// countRecipients возвращает получателей рассылки для предпросмотра и количество убранных повторов,
+// nil - получателей не удалось узнать. Участники группы и чаты списков ищутся через транспорт,
+// поэтому вызывается до блокировки
+func (s *Scheduler) countRecipients(task *ScheduledTask) ([]string, int) {
+	if len(task.RecipientLists) > 0 {
+		recipients, _, duplicates, err := s.mergeRecipients(task)
+		if err != nil {
+			Logger.Warnf("Не удалось объединить списки получателей для предпросмотра рассылки: %v", err)
+			return nil, 0
+		}
+		return recipients, duplicates
+	}
+	if !task.GroupMembers {
+		return task.recipients(), 0
+	}
+	lister, ok := s.sender.(GroupMemberLister)
+	if !ok {
+		return nil, 0
+	}
+	members, err := lister.GroupMembers(task.ChatName, task.ChatJID)
+	if err != nil {
+		Logger.Warnf("Не удалось получить участников группы '%s' для предпросмотра рассылки: %v", task.ChatName, err)
+		return nil, 0
+	}
+	return members, 0
+}
+
+// requireConfirmation переводит рассылку на threshold получателей и больше в ожидание
+// подтверждения (ConfirmTask) и заполняет предпросмотр. Рассылка, число получателей
+// которой неизвестно (recipients = nil), тоже требует подтверждения
+func (t *ScheduledTask) requireConfirmation(recipients []string, duplicates, threshold int) {
+	if threshold <= 0 || !t.broadcast() || (recipients != nil && len(recipients) < threshold) {
+		return
+	}
+	occurrences := t.occurrencesBetween(t.StartTime, t.EndTime, previewMaxOccurrences)
+	preview := &BroadcastPreview{
+		Recipients:          len(recipients),
+		DuplicatesCollapsed: duplicates,
+		Occurrences:         len(occurrences),
+		Messages:            len(recipients) * len(occurrences),
+		SampleRecipients:    recipients[:min(len(recipients), previewSampleRecipients)],
+	}
+	if len(occurrences) > 0 {
+		preview.FirstSendAt = &occurrences[0]
+	}
+	t.PendingConfirmation = true
+	t.Preview = preview
+}
+
+// ConfirmTask запускает рассылку, ожидающую подтверждения. Отправки начинаются с ближайшего
+// планового времени после подтверждения
+func (s *Scheduler) ConfirmTask(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	task, exists := s.tasks[id]
+	if !exists {
+		return fmt.Errorf("%w: '%s'", ErrTaskNotFound, id)
+	}
+	if !task.PendingConfirmation {
+		return fmt.Errorf("%w: '%s'", ErrTaskNotPending, id)
+	}
+	task.PendingConfirmation = false
+	if err := s.storage.SaveTask(task); err != nil {
+		Logger.Errorf("Ошибка сохранения задачи %s: %v", id, err)
+	}
+	close(task.confirmed)
+	Logger.Infof("✅ Рассылка по задаче %s подтверждена (чат: %s)", id, task.ChatName)
+	return nil
+}
+
+// awaitConfirmation ждет подтверждения рассылки, false - задача остановлена раньше
+func (s *Scheduler) awaitConfirmation(task *ScheduledTask) bool {
+	s.mutex.RLock()
+	pending := task.PendingConfirmation
+	s.mutex.RUnlock()
+	if !pending {
+		return true
+	}
+	Logger.Infof("✋ Задача %s ждет подтверждения рассылки: POST /tasks/%s/confirm", task.ID, task.ID)
+	select {
+	case <-task.confirmed:
+		return s.isCurrent(task)
+	case <-task.ctx.Done():
+		return false
+	}
+}
+
+// validateConfirmation проверяет порог подтверждения рассылки
+func (st *Settings) validateConfirmation() error {
+	if st.ConfirmRecipients < 0 {
+		return fmt.Errorf("confirm_recipients не может быть отрицательным")
+	}
+	return nil
+}
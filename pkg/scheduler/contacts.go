@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"strings"
+	"sync"
+)
+
+// BlockRules - ключевые слова, при получении которых в личном чате отправитель блокируется
+type BlockRules struct {
+	mutex    sync.RWMutex
+	Keywords []string `json:"keywords"`
+}
+
+// matchKeyword возвращает ключевое слово блокировки, найденное в тексте
+func (r *BlockRules) matchKeyword(text string) string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	text = strings.ToLower(text)
+	for _, keyword := range r.Keywords {
+		if strings.Contains(text, keyword) {
+			return keyword
+		}
+	}
+	return ""
+}
+
+// List возвращает ключевые слова блокировки
+func (r *BlockRules) List() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return append([]string{}, r.Keywords...)
+}
+
+// normalizeKeywords приводит ключевые слова к нижнему регистру и убирает пустые
+func normalizeKeywords(keywords []string) []string {
+	normalized := []string{}
+	for _, keyword := range keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			normalized = append(normalized, keyword)
+		}
+	}
+	return normalized
+}
+
+// loadBlockRules загружает ключевые слова блокировки из хранилища
+func (st *Storage) loadBlockRules(rules *BlockRules) error {
+	rows, err := st.db.Query(`SELECT keyword FROM block_keywords ORDER BY keyword`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	keywords := []string{}
+	for rows.Next() {
+		var keyword string
+		if err := rows.Scan(&keyword); err != nil {
+			return err
+		}
+		keywords = append(keywords, keyword)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rules.mutex.Lock()
+	rules.Keywords = keywords
+	rules.mutex.Unlock()
+	return nil
+}
+
+// SaveBlockRules заменяет ключевые слова блокировки
+func (st *Storage) SaveBlockRules(rules *BlockRules, keywords []string) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM block_keywords`); err != nil {
+		return err
+	}
+	for _, keyword := range normalizeKeywords(keywords) {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO block_keywords (keyword) VALUES (?)`, keyword); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return st.loadBlockRules(rules)
+}
@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// digestMaxTimesPerTask - сколько времен отправки задачи показывать в плане на следующий день
+const digestMaxTimesPerTask = 12
+
+// Digest формирует сводку для оператора: отправки за период [from, to) по чатам, ошибки
+// и план отправок активных задач на следующие сутки после to
+func (s *Scheduler) Digest(from, to time.Time) (string, error) {
+	summary, err := s.storage.SummarizeHistory(from, to)
+	if err != nil {
+		return "", err
+	}
+
+	lines := []string{
+		fmt.Sprintf("📊 Сводка WhatsApp Scheduler за %s - %s", from.Format("15:04 02.01"), to.Format("15:04 02.01")),
+		fmt.Sprintf("Отправлено: %d, ошибок: %d, отклонено лимитом: %d", summary.Sent, summary.Failed, summary.RateLimited),
+	}
+	if len(summary.Chats) > 0 {
+		lines = append(lines, "", "✅ Отправки по чатам:")
+		for _, chat := range sortedKeys(summary.Chats) {
+			lines = append(lines, fmt.Sprintf("• %s: %d", chat, summary.Chats[chat]))
+		}
+	}
+	if len(summary.FailedChats) > 0 {
+		lines = append(lines, "", "❌ Ошибки по чатам:")
+		for _, chat := range sortedKeys(summary.FailedChats) {
+			lines = append(lines, fmt.Sprintf("• %s: %d", chat, summary.FailedChats[chat]))
+		}
+	}
+
+	lines = append(lines, "", "🗓️ План на следующие сутки:")
+	planned := false
+	for _, task := range s.ListTasks() {
+		occurrences := task.occurrencesBetween(to, to.Add(24*time.Hour), digestMaxTimesPerTask+1)
+		if len(occurrences) == 0 {
+			continue
+		}
+		planned = true
+
+		times := make([]string, 0, len(occurrences))
+		for i, occurrence := range occurrences {
+			if i == digestMaxTimesPerTask {
+				times = append(times, "...")
+				break
+			}
+			times = append(times, occurrence.Local().Format("15:04"))
+		}
+		line := fmt.Sprintf("• %s: %s", task.ChatName, strings.Join(times, ", "))
+		if task.Paused {
+			line += " (задача на паузе)"
+		}
+		lines = append(lines, line)
+	}
+	if !planned {
+		lines = append(lines, "• отправок не запланировано")
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// sortedKeys возвращает ключи в алфавитном порядке
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
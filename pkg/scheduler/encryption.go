@@ -0,0 +1,190 @@
+package scheduler
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// encryptionKeyEnv - ключ шифрования хранилища в base64 (32 байта, AES-256)
+	encryptionKeyEnv = "WHATSAPP_SCHEDULER_ENCRYPTION_KEY"
+	// encryptionKeyFileEnv - путь к файлу с ключом в base64 (например, секрет Docker/Kubernetes)
+	encryptionKeyFileEnv = "WHATSAPP_SCHEDULER_ENCRYPTION_KEY_FILE"
+	// encryptedPrefix - префикс зашифрованного значения в базе
+	encryptedPrefix = "enc:v1:"
+)
+
+// encryptionKeyFromEnv возвращает ключ шифрования хранилища из переменных окружения,
+// nil - шифрование не настроено
+func encryptionKeyFromEnv() ([]byte, error) {
+	value := os.Getenv(encryptionKeyEnv)
+	if path := os.Getenv(encryptionKeyFileEnv); value == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения ключа шифрования: %v", err)
+		}
+		value = string(data)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("ключ шифрования должен быть 32 байтами в base64 (openssl rand -base64 32)")
+	}
+	return key, nil
+}
+
+// EnableEncryption включает шифрование AES-GCM текстов сообщений, названий чатов и задач в хранилище
+// и шифрует сохраненные ранее открытые данные
+func (st *Storage) EnableEncryption(key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("неверный ключ шифрования: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	st.aead = aead
+	return st.encryptExisting()
+}
+
+// EnableEncryptionFromEnv включает шифрование, если ключ задан в WHATSAPP_SCHEDULER_ENCRYPTION_KEY
+// или WHATSAPP_SCHEDULER_ENCRYPTION_KEY_FILE. Возвращает true, если шифрование включено
+func (st *Storage) EnableEncryptionFromEnv() (bool, error) {
+	key, err := encryptionKeyFromEnv()
+	if err != nil || key == nil {
+		return false, err
+	}
+	return true, st.EnableEncryption(key)
+}
+
+// EncryptField шифрует значение для записи в базу. Без ключа возвращает значение как есть
+func (st *Storage) EncryptField(value string) (string, error) {
+	if st.aead == nil || value == "" {
+		return value, nil
+	}
+	nonce := make([]byte, st.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := st.aead.Seal(nonce, nonce, []byte(value), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptField расшифровывает значение из базы. Открытые значения (записанные до включения
+// шифрования) возвращаются как есть
+func (st *Storage) DecryptField(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	if st.aead == nil {
+		return "", fmt.Errorf("данные зашифрованы, задайте %s", encryptionKeyEnv)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(sealed) < st.aead.NonceSize() {
+		return "", fmt.Errorf("поврежденное зашифрованное значение")
+	}
+	nonceSize := st.aead.NonceSize()
+	plain, err := st.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("ошибка расшифровки: неверный ключ или поврежденные данные")
+	}
+	return string(plain), nil
+}
+
+// decryptFields расшифровывает несколько значений на месте
+func (st *Storage) decryptFields(values ...*string) error {
+	for _, value := range values {
+		plain, err := st.DecryptField(*value)
+		if err != nil {
+			return err
+		}
+		*value = plain
+	}
+	return nil
+}
+
+// encryptedColumns - зашифрованные колонки таблиц по первичному ключу
+var encryptedColumns = []struct {
+	table   string
+	key     string
+	columns []string
+}{
+	{"history", "id", []string{"chat_name", "chat_jid", "message", "error"}},
+	{"tasks", "id", []string{"data"}},
+	{"mqtt_routes", "topic", []string{"chat_name", "template"}},
+	{"broadcast_reports", "rowid", []string{"chat_name", "error"}},
+	{"stored_messages", "id", []string{"sender_jid", "text", "data"}},
+	{"incoming_media", "message_id", []string{"sender_jid"}},
+	{"polls", "message_id", []string{"question", "options"}},
+	{"poll_votes", "rowid", []string{"options"}},
+	{"escalations", "message_id", []string{"chat_name", "escalate_to", "message"}},
+	{"campaigns", "id", []string{"data"}},
+	{"campaign_enrollments", "id", []string{"chat_name"}},
+	{"recipient_lists", "id", []string{"data"}},
+	{"group_welcomes", "group_jid", []string{"message"}},
+	{"batch_items", "rowid", []string{"chat_name", "error"}},
+	{"scheduled_sends", "id", []string{"data"}},
+}
+
+// encryptExisting шифрует открытые значения, сохраненные до включения шифрования
+func (st *Storage) encryptExisting() error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	encrypted := 0
+	for _, table := range encryptedColumns {
+		for _, column := range table.columns {
+			rows, err := tx.Query(fmt.Sprintf(`SELECT %s, %s FROM %s WHERE %s != '' AND %s NOT LIKE ?`,
+				table.key, column, table.table, column, column), encryptedPrefix+"%")
+			if err != nil {
+				return err
+			}
+			values := map[string]string{}
+			for rows.Next() {
+				var key string
+				var value string
+				if err := rows.Scan(&key, &value); err != nil {
+					rows.Close()
+					return err
+				}
+				values[key] = value
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			for key, value := range values {
+				sealed, err := st.EncryptField(value)
+				if err != nil {
+					return err
+				}
+				if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = ? WHERE %s = ?`, table.table, column, table.key),
+					sealed, key); err != nil {
+					return err
+				}
+			}
+			encrypted += len(values)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if encrypted > 0 {
+		Logger.Infof("🔐 Зашифровано %d сохраненных значений", encrypted)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncryptFieldRoundTrip(t *testing.T) {
+	s, _ := newTestScheduler(t)
+	if err := s.storage.EnableEncryption(bytes.Repeat([]byte{1}, 32)); err != nil {
+		t.Fatalf("EnableEncryption: %v", err)
+	}
+	other, _ := newTestScheduler(t)
+	if err := other.storage.EnableEncryption(bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatalf("EnableEncryption: %v", err)
+	}
+	plain, _ := newTestScheduler(t)
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"ascii", "Deploy finished"},
+		{"unicode", "Привет, 👋 мир"},
+		{"prefix-like", "enc:v0:not encrypted"},
+		{"long", strings.Repeat("x", 10000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, err := s.storage.EncryptField(tt.value)
+			if err != nil {
+				t.Fatalf("EncryptField: %v", err)
+			}
+			if tt.value != "" && (!strings.HasPrefix(sealed, encryptedPrefix) || strings.Contains(sealed, tt.value)) {
+				t.Fatalf("EncryptField(%q) = %q, want an %s value without the plain text", tt.value, sealed, encryptedPrefix)
+			}
+			got, err := s.storage.DecryptField(sealed)
+			if err != nil || got != tt.value {
+				t.Fatalf("DecryptField = %q, %v; want %q", got, err, tt.value)
+			}
+			// Открытые значения, записанные до включения шифрования, читаются как есть
+			if got, err := s.storage.DecryptField(tt.value); err != nil || got != tt.value {
+				t.Errorf("DecryptField(plain) = %q, %v; want %q", got, err, tt.value)
+			}
+			if tt.value == "" {
+				return
+			}
+			if _, err := other.storage.DecryptField(sealed); err == nil {
+				t.Error("DecryptField with a wrong key succeeded")
+			}
+			if _, err := plain.storage.DecryptField(sealed); err == nil {
+				t.Error("DecryptField without a key succeeded")
+			}
+		})
+	}
+
+	// Одинаковые значения шифруются с разными nonce
+	first, _ := s.storage.EncryptField("same")
+	second, _ := s.storage.EncryptField("same")
+	if first == second {
+		t.Error("equal values produced equal ciphertexts")
+	}
+}
+
+func TestEnableEncryptionMigratesExisting(t *testing.T) {
+	s, _ := newTestScheduler(t)
+	st := s.storage
+	if err := st.AddHistory(&HistoryEntry{ChatName: "Team", ChatJID: "123@g.us", Message: "Hello", Status: "failed",
+		Error: "timeout", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("AddHistory: %v", err)
+	}
+	if err := st.SaveMessage(StoredMessage{ID: "msg1", ChatJID: "123@g.us", SenderJID: "49151@s.whatsapp.net",
+		Text: "Incoming", Data: []byte("raw")}); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	task := &ScheduledTask{ID: "task_1", ChatName: "Team", Message: "Daily", Interval: 60}
+	if err := st.SaveTask(task); err != nil {
+		t.Fatalf("SaveTask: %v", err)
+	}
+
+	key := bytes.Repeat([]byte{3}, 32)
+	if err := st.EnableEncryption(key); err != nil {
+		t.Fatalf("EnableEncryption: %v", err)
+	}
+	// Повторное включение (следующий запуск) не шифрует значения дважды
+	if err := st.EnableEncryption(key); err != nil {
+		t.Fatalf("EnableEncryption again: %v", err)
+	}
+
+	tests := []struct {
+		table, key, id, column, want string
+	}{
+		{"history", "id", "1", "chat_name", "Team"},
+		{"history", "id", "1", "chat_jid", "123@g.us"},
+		{"history", "id", "1", "message", "Hello"},
+		{"history", "id", "1", "error", "timeout"},
+		{"stored_messages", "id", "msg1", "sender_jid", "49151@s.whatsapp.net"},
+		{"stored_messages", "id", "msg1", "text", "Incoming"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.table+"."+tt.column, func(t *testing.T) {
+			var raw string
+			if err := st.db.QueryRow(fmt.Sprintf(`SELECT %s FROM %s WHERE %s = ?`, tt.column, tt.table, tt.key), tt.id).
+				Scan(&raw); err != nil {
+				t.Fatalf("select: %v", err)
+			}
+			if !strings.HasPrefix(raw, encryptedPrefix) {
+				t.Fatalf("%s.%s = %q, want encrypted", tt.table, tt.column, raw)
+			}
+			if got, err := st.DecryptField(raw); err != nil || got != tt.want {
+				t.Errorf("decrypted %s.%s = %q, %v; want %q", tt.table, tt.column, got, err, tt.want)
+			}
+		})
+	}
+
+	// Данные читаются через обычные методы хранилища
+	history, err := st.ListHistory(10)
+	if err != nil || len(history) != 1 || history[0].Message != "Hello" || history[0].Error != "timeout" {
+		t.Fatalf("ListHistory = %+v, %v", history, err)
+	}
+	msg, err := st.GetStoredMessage("msg1")
+	if err != nil || msg.Text != "Incoming" || string(msg.Data) != "raw" {
+		t.Fatalf("GetStoredMessage = %+v, %v", msg, err)
+	}
+	tasks, _, err := st.loadTasks()
+	if err != nil || len(tasks) != 1 || tasks[0].Message != "Daily" {
+		t.Fatalf("loadTasks = %+v, %v", tasks, err)
+	}
+	var raw string
+	if err := st.db.QueryRow(`SELECT data FROM tasks WHERE id = ?`, "task_1").Scan(&raw); err != nil || strings.Contains(raw, "Daily") {
+		t.Errorf("tasks.data = %q, %v; want the task encrypted", raw, err)
+	}
+}
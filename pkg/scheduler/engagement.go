@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DefaultEngagementWindowHours - сколько часов после отправки учитываются реакции и ответы
+const DefaultEngagementWindowHours = 24
+
+// Виды отклика на сообщение задачи
+const (
+	engagementReaction = "reaction"
+	engagementReply    = "reply"
+)
+
+// OccurrenceEngagement - отклик на одну рассылку задачи: сколько участников поставили реакцию
+// и ответили в течение окна EngagementWindowHours после отправки
+type OccurrenceEngagement struct {
+	// Occurrence - номер рассылки (как в отчете о рассылке), начиная с 1
+	Occurrence int       `json:"occurrence"`
+	PlannedAt  time.Time `json:"planned_at"`
+	Sent       int       `json:"sent"`
+	Reactions  int       `json:"reactions"`
+	Replies    int       `json:"replies"`
+}
+
+// trackEngagement запоминает отправленное сообщение рассылки, чтобы учитывать отклик на него
+func (s *Scheduler) trackEngagement(msg OutgoingMessage, chatJID string) {
+	if s.storage == nil || msg.TaskID == "" || msg.ID == "" || msg.Planned.IsZero() {
+		return
+	}
+	if err := s.storage.addEngagementMessage(msg.ID, msg.TaskID, msg.Planned, chatJID); err != nil {
+		Logger.Errorf("Ошибка сохранения сообщения задачи %s для учета отклика: %v", msg.TaskID, err)
+	}
+}
+
+// recordEngagement учитывает реакцию или ответ на сообщение задачи. Реакции и ответы,
+// пришедшие позже окна отклика, а также на сообщения не из задач, игнорируются
+func (s *Scheduler) recordEngagement(msg IncomingMessage) {
+	window := time.Duration(s.Settings().EngagementWindowHours) * time.Hour
+	at := msg.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	var messageID, kind string
+	var err error
+	switch {
+	case msg.ReactionToID != "":
+		messageID, kind = msg.ReactionToID, engagementReaction
+	case msg.ReplyToID != "":
+		messageID, kind = msg.ReplyToID, engagementReply
+	case msg.Direct && msg.Text != "":
+		// В личном чате любое сообщение получателя - ответ на последнее сообщение задачи
+		if messageID, err = s.storage.lastEngagementMessage(msg.ChatJID, at.Add(-window), at); err != nil {
+			Logger.Errorf("Ошибка поиска сообщения задачи для ответа: %v", err)
+		}
+		kind = engagementReply
+	}
+	if messageID == "" {
+		return
+	}
+
+	sentAt, err := s.storage.engagementSentAt(messageID)
+	if err == sql.ErrNoRows || (err == nil && at.Sub(sentAt) > window) {
+		return
+	}
+	if err != nil {
+		Logger.Errorf("Ошибка чтения сообщения задачи %s для учета отклика: %v", messageID, err)
+		return
+	}
+
+	if kind == engagementReaction && msg.Reaction == "" {
+		// Реакция снята
+		err = s.storage.removeEngagement(messageID, msg.SenderJID, kind)
+	} else {
+		err = s.storage.setEngagement(messageID, msg.SenderJID, kind, at)
+	}
+	if err != nil {
+		Logger.Errorf("Ошибка сохранения отклика на сообщение %s: %v", messageID, err)
+	}
+}
+
+// addEngagementMessage запоминает сообщение рассылки planned задачи, отправленное в чат chatJID
+func (st *Storage) addEngagementMessage(messageID, taskID string, planned time.Time, chatJID string) error {
+	_, err := st.db.Exec(`INSERT OR REPLACE INTO engagement_messages (message_id, task_id, planned_at, chat_jid, sent_at)
+		VALUES (?, ?, ?, ?, ?)`, messageID, taskID, planned.UTC(), chatJID, time.Now())
+	return err
+}
+
+// lastEngagementMessage возвращает последнее сообщение задачи, отправленное в чат между from и to,
+// или пустую строку
+func (st *Storage) lastEngagementMessage(chatJID string, from, to time.Time) (string, error) {
+	var messageID string
+	err := st.db.QueryRow(`SELECT message_id FROM engagement_messages
+		WHERE chat_jid = ? AND sent_at >= ? AND sent_at <= ? ORDER BY sent_at DESC LIMIT 1`,
+		chatJID, from, to).Scan(&messageID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return messageID, err
+}
+
+// engagementSentAt возвращает время отправки сообщения задачи или sql.ErrNoRows
+func (st *Storage) engagementSentAt(messageID string) (time.Time, error) {
+	var sentAt time.Time
+	err := st.db.QueryRow(`SELECT sent_at FROM engagement_messages WHERE message_id = ?`, messageID).Scan(&sentAt)
+	return sentAt, err
+}
+
+// setEngagement сохраняет отклик участника, повторная реакция заменяет предыдущую
+func (st *Storage) setEngagement(messageID, senderJID, kind string, at time.Time) error {
+	_, err := st.db.Exec(`INSERT OR REPLACE INTO engagement_events (message_id, sender_jid, kind, created_at)
+		VALUES (?, ?, ?, ?)`, messageID, senderJID, kind, at)
+	return err
+}
+
+// removeEngagement удаляет отклик участника
+func (st *Storage) removeEngagement(messageID, senderJID, kind string) error {
+	_, err := st.db.Exec(`DELETE FROM engagement_events WHERE message_id = ? AND sender_jid = ? AND kind = ?`,
+		messageID, senderJID, kind)
+	return err
+}
+
+// TaskEngagement возвращает отклик на рассылки задачи, запланированные начиная с since (старые первыми)
+func (st *Storage) TaskEngagement(taskID string, since time.Time) ([]OccurrenceEngagement, error) {
+	rows, err := st.db.Query(`SELECT m.planned_at,
+			(SELECT r.occurrence FROM broadcast_reports r WHERE r.task_id = m.task_id AND r.planned_at = m.planned_at LIMIT 1),
+			COUNT(DISTINCT m.message_id),
+			COUNT(CASE WHEN e.kind = ? THEN 1 END),
+			COUNT(CASE WHEN e.kind = ? THEN 1 END)
+		FROM engagement_messages m LEFT JOIN engagement_events e ON e.message_id = m.message_id
+		WHERE m.task_id = ? GROUP BY m.planned_at ORDER BY m.planned_at`,
+		engagementReaction, engagementReply, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	engagement := []OccurrenceEngagement{}
+	for number := 1; rows.Next(); number++ {
+		var item OccurrenceEngagement
+		var occurrence sql.NullInt64
+		if err := rows.Scan(&item.PlannedAt, &occurrence, &item.Sent, &item.Reactions, &item.Replies); err != nil {
+			return nil, err
+		}
+		// У задач без рассылки по списку нет отчетов, рассылки нумеруются по порядку
+		item.Occurrence = number
+		if occurrence.Valid {
+			item.Occurrence = int(occurrence.Int64)
+		}
+		if !item.PlannedAt.Before(since) {
+			engagement = append(engagement, item)
+		}
+	}
+	return engagement, rows.Err()
+}
+
+// DeleteSenderEngagement удаляет реакции и ответы участника на сообщения задач
+func (st *Storage) DeleteSenderEngagement(senderJID string) (int64, error) {
+	res, err := st.db.Exec(`DELETE FROM engagement_events WHERE sender_jid = ?`, senderJID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
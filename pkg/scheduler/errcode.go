@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"errors"
+)
+
+// Коды ошибок API: не меняются между версиями, в отличие от текста ошибки
+const (
+	ErrorCodeInvalidRequest      = "INVALID_REQUEST"
+	ErrorCodeInvalidJSON         = "INVALID_JSON"
+	ErrorCodeInvalidInterval     = "INVALID_INTERVAL"
+	ErrorCodeNotFound            = "NOT_FOUND"
+	ErrorCodeTaskNotFound        = "TASK_NOT_FOUND"
+	ErrorCodeChatNotFound        = "CHAT_NOT_FOUND"
+	ErrorCodeAmbiguousChat       = "AMBIGUOUS_CHAT"
+	ErrorCodeConflict            = "CONFLICT"
+	ErrorCodeNotAuthorized       = "NOT_AUTHORIZED"
+	ErrorCodeRateLimited         = "RATE_LIMITED"
+	ErrorCodeQuotaExceeded       = "QUOTA_EXCEEDED"
+	ErrorCodeSendTimeout         = "SEND_TIMEOUT"
+	ErrorCodeDisconnected        = "DISCONNECTED"
+	ErrorCodeServerUnavailable   = "SERVER_UNAVAILABLE"
+	ErrorCodeServerRejected      = "SERVER_REJECTED"
+	ErrorCodeModerationRejected  = "MODERATION_REJECTED"
+	ErrorCodeTemplateRender      = "TEMPLATE_RENDER"
+	ErrorCodeComplianceViolation = "COMPLIANCE_VIOLATION"
+	ErrorCodeCircuitOpen         = "CHAT_PAUSED"
+	ErrorCodeStandby             = "STANDBY"
+	ErrorCodeReadOnly            = "READ_ONLY"
+	ErrorCodeShuttingDown        = "SHUTTING_DOWN"
+	ErrorCodeUnavailable         = "UNAVAILABLE"
+	ErrorCodeInternal            = "INTERNAL_ERROR"
+)
+
+// ErrChatNotFound - по названию не найден ни один чат
+var ErrChatNotFound = errors.New("чат не найден")
+
+// ErrInvalidInterval - неверный интервал или правило повторения задачи
+var ErrInvalidInterval = errors.New("неверный интервал")
+
+// ErrTaskNotFound - активной задачи с таким ID нет
+var ErrTaskNotFound = errors.New("задача не найдена")
+
+// Ошибки отправки, в которые транспорт переводит ошибки библиотеки WhatsApp
+var (
+	// ErrNotAuthorized - сервер WhatsApp отказал в отправке в чат (нет прав, не участник группы)
+	ErrNotAuthorized = errors.New("нет прав на отправку в чат")
+	// ErrSendTimeout - сервер не подтвердил отправку вовремя
+	ErrSendTimeout = errors.New("таймаут отправки сообщения")
+	// ErrDisconnected - нет подключения к серверу WhatsApp
+	ErrDisconnected = errors.New("нет подключения к WhatsApp")
+	// ErrServerUnavailable - временная ошибка на стороне сервера WhatsApp
+	ErrServerUnavailable = errors.New("сервер WhatsApp временно недоступен")
+	// ErrServerRejected - сервер WhatsApp отклонил сообщение с кодом ошибки (например, 479)
+	ErrServerRejected = errors.New("сервер WhatsApp отклонил сообщение")
+)
+
+// retryableErrors - ошибки, после которых отправку имеет смысл повторить позже
+var retryableErrors = []error{ErrRateLimited, ErrCircuitOpen, ErrSendTimeout, ErrDisconnected, ErrServerUnavailable}
+
+// errorCodes - коды ошибок планировщика
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrChatNotFound, ErrorCodeChatNotFound},
+	{ErrAmbiguousChat, ErrorCodeAmbiguousChat},
+	{ErrInvalidInterval, ErrorCodeInvalidInterval},
+	{ErrTaskNotFound, ErrorCodeTaskNotFound},
+	{ErrTaskNotPending, ErrorCodeConflict},
+	{ErrTaskExists, ErrorCodeConflict},
+	{ErrQuotaExceeded, ErrorCodeQuotaExceeded},
+	{ErrRateLimited, ErrorCodeRateLimited},
+	{ErrNotAuthorized, ErrorCodeNotAuthorized},
+	{ErrSendTimeout, ErrorCodeSendTimeout},
+	{ErrDisconnected, ErrorCodeDisconnected},
+	{ErrServerUnavailable, ErrorCodeServerUnavailable},
+	{ErrServerRejected, ErrorCodeServerRejected},
+	{ErrLoggedOut, ErrorCodeNotAuthorized},
+	{ErrModerationRejected, ErrorCodeModerationRejected},
+	{ErrTemplateRender, ErrorCodeTemplateRender},
+	{ErrComplianceViolation, ErrorCodeComplianceViolation},
+	{ErrCircuitOpen, ErrorCodeCircuitOpen},
+	{ErrStandby, ErrorCodeStandby},
+	{ErrReadOnly, ErrorCodeReadOnly},
+	{ErrShuttingDown, ErrorCodeShuttingDown},
+	{ErrCampaignNotFound, ErrorCodeNotFound},
+	{ErrRecipientListNotFound, ErrorCodeNotFound},
+	{ErrScheduledSendNotFound, ErrorCodeNotFound},
+	{ErrBatchNotFound, ErrorCodeNotFound},
+	{ErrMediaNotFound, ErrorCodeNotFound},
+	{ErrTranscodeNotFound, ErrorCodeNotFound},
+	{ErrReportNotFound, ErrorCodeNotFound},
+	{ErrStoredMessageNotFound, ErrorCodeNotFound},
+	{ErrIncomingMediaNotFound, ErrorCodeNotFound},
+	{ErrPollNotFound, ErrorCodeNotFound},
+}
+
+// ErrorCode возвращает код ошибки планировщика или пустую строку, если ошибка не из планировщика
+func ErrorCode(err error) string {
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			return known.code
+		}
+	}
+	return ""
+}
+
+// IsRetryable проверяет, что отправка не удалась по временной причине и ее можно повторить позже
+func IsRetryable(err error) bool {
+	for _, retryable := range retryableErrors {
+		if errors.Is(err, retryable) {
+			return true
+		}
+	}
+	return false
+}
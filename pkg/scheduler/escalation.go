@@ -0,0 +1,201 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// escalationCheckInterval - как часто проверяются сообщения, оставшиеся без ответа
+	escalationCheckInterval = time.Minute
+	// maxEscalationHours - максимальное время ожидания ответа
+	maxEscalationHours = 30 * 24
+	// escalationChatPlaceholder - в тексте эскалации заменяется названием чата, не ответившего на сообщение
+	escalationChatPlaceholder = "{{chat_name}}"
+)
+
+// Escalation - сообщение, отправляемое, если получатель не ответил на сообщение задачи в течение AfterHours.
+// Ответом считается цитата сообщения, а в личном чате - любое сообщение получателя после отправки
+type Escalation struct {
+	AfterHours int `json:"after_hours"`
+	// ChatName - кому отправить эскалацию (например, руководителю), пусто - в тот же чат
+	ChatName string `json:"chat_name,omitempty"`
+	// Message - текст эскалации, {{chat_name}} заменяется названием чата, не ответившего на сообщение
+	Message string `json:"message"`
+}
+
+// validate проверяет эскалацию и очищает ее поля
+func (e *Escalation) validate() error {
+	e.ChatName = strings.TrimSpace(e.ChatName)
+	e.Message = strings.TrimSpace(e.Message)
+	if e.AfterHours <= 0 || e.AfterHours > maxEscalationHours {
+		return fmt.Errorf("escalation.after_hours должен быть в диапазоне 1..%d", maxEscalationHours)
+	}
+	if e.Message == "" {
+		return fmt.Errorf("пустое сообщение эскалации")
+	}
+	return ValidateMessageLength(e.Message)
+}
+
+// pendingEscalation - сообщение задачи, на которое не ответили вовремя
+type pendingEscalation struct {
+	MessageID  string
+	TaskID     string
+	ChatName   string
+	ChatJID    string
+	EscalateTo string
+	Message    string
+}
+
+// trackEscalation запоминает отправленное сообщение задачи с эскалацией, чтобы дождаться ответа на него
+func (s *Scheduler) trackEscalation(msg OutgoingMessage, chatJID string) {
+	if s.storage == nil || msg.Escalation == nil || msg.ID == "" {
+		return
+	}
+	if err := s.storage.addEscalation(msg, chatJID, time.Now()); err != nil {
+		Logger.Errorf("Ошибка сохранения эскалации сообщения задачи %s: %v", msg.TaskID, err)
+	}
+}
+
+// resolveEscalations отменяет эскалацию сообщений, на которые пришел ответ
+func (s *Scheduler) resolveEscalations(msg IncomingMessage) {
+	if msg.ReactionToID != "" {
+		return
+	}
+	at := msg.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	var err error
+	switch {
+	case msg.ReplyToID != "":
+		err = s.storage.resolveEscalation(msg.ReplyToID, at)
+	case msg.Direct:
+		err = s.storage.resolveChatEscalations(msg.ChatJID, at)
+	}
+	if err != nil {
+		Logger.Errorf("Ошибка отмены эскалации по ответу из чата %s: %v", msg.ChatJID, err)
+	}
+}
+
+// runEscalations периодически отправляет эскалации по сообщениям, оставшимся без ответа
+func (s *Scheduler) runEscalations() {
+	for range time.Tick(escalationCheckInterval) {
+		s.escalateDue()
+	}
+}
+
+// escalateDue отправляет эскалации, время ожидания ответа которых истекло
+func (s *Scheduler) escalateDue() {
+	if !s.IsLeader() || s.IsLoggedOut() || s.IsShuttingDown() {
+		return
+	}
+	due, err := s.storage.dueEscalations(time.Now())
+	if err != nil {
+		Logger.Errorf("Ошибка чтения эскалаций: %v", err)
+		return
+	}
+
+	for _, escalation := range due {
+		msg := OutgoingMessage{
+			TaskID:      escalation.TaskID,
+			ChatName:    escalation.EscalateTo,
+			Message:     strings.ReplaceAll(escalation.Message, escalationChatPlaceholder, escalation.ChatName),
+			LinkPreview: true,
+		}
+		if msg.ChatName == "" {
+			msg.ChatName, msg.ChatJID = escalation.ChatName, escalation.ChatJID
+		}
+		err := s.Deliver(context.Background(), msg)
+		if errors.Is(err, ErrShuttingDown) || errors.Is(err, ErrStandby) || errors.Is(err, ErrLoggedOut) || errors.Is(err, ErrReadOnly) {
+			// Эскалация будет отправлена после перезапуска, восстановления сессии или выключения режима только для чтения
+			return
+		}
+		if err != nil {
+			Logger.Errorf("❌ Ошибка отправки эскалации по задаче %s в чат '%s': %v", escalation.TaskID, msg.ChatName, err)
+		} else {
+			Logger.Infof("📣 Нет ответа из чата '%s', эскалация по задаче %s отправлена в чат '%s'",
+				escalation.ChatName, escalation.TaskID, msg.ChatName)
+		}
+		if err := s.storage.markEscalated(escalation.MessageID, time.Now()); err != nil {
+			Logger.Errorf("Ошибка сохранения эскалации сообщения %s: %v", escalation.MessageID, err)
+		}
+	}
+}
+
+// addEscalation запоминает сообщение msg, отправленное в чат chatJID в sentAt, для ожидания ответа
+func (st *Storage) addEscalation(msg OutgoingMessage, chatJID string, sentAt time.Time) error {
+	chatName, err := st.EncryptField(msg.ChatName)
+	if err != nil {
+		return err
+	}
+	escalateTo, err := st.EncryptField(msg.Escalation.ChatName)
+	if err != nil {
+		return err
+	}
+	message, err := st.EncryptField(msg.Escalation.Message)
+	if err != nil {
+		return err
+	}
+	_, err = st.db.Exec(`INSERT OR REPLACE INTO escalations
+		(message_id, task_id, chat_name, chat_jid, escalate_to, message, sent_at, due_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		msg.ID, msg.TaskID, chatName, chatJID, escalateTo, message, sentAt,
+		sentAt.Add(time.Duration(msg.Escalation.AfterHours)*time.Hour))
+	return err
+}
+
+// resolveEscalation отмечает ответ на сообщение messageID
+func (st *Storage) resolveEscalation(messageID string, at time.Time) error {
+	_, err := st.db.Exec(`UPDATE escalations SET replied_at = ?
+		WHERE message_id = ? AND replied_at IS NULL AND escalated_at IS NULL`, at, messageID)
+	return err
+}
+
+// resolveChatEscalations отмечает ответ на все сообщения, отправленные в чат chatJID до at
+func (st *Storage) resolveChatEscalations(chatJID string, at time.Time) error {
+	_, err := st.db.Exec(`UPDATE escalations SET replied_at = ?
+		WHERE chat_jid = ? AND sent_at <= ? AND replied_at IS NULL AND escalated_at IS NULL`, at, chatJID, at)
+	return err
+}
+
+// dueEscalations возвращает сообщения без ответа, время ожидания которых истекло к now
+func (st *Storage) dueEscalations(now time.Time) ([]pendingEscalation, error) {
+	rows, err := st.db.Query(`SELECT message_id, task_id, chat_name, chat_jid, escalate_to, message FROM escalations
+		WHERE due_at <= ? AND replied_at IS NULL AND escalated_at IS NULL ORDER BY due_at`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []pendingEscalation
+	for rows.Next() {
+		var e pendingEscalation
+		if err := rows.Scan(&e.MessageID, &e.TaskID, &e.ChatName, &e.ChatJID, &e.EscalateTo, &e.Message); err != nil {
+			return nil, err
+		}
+		if err := st.decryptFields(&e.ChatName, &e.EscalateTo, &e.Message); err != nil {
+			return nil, err
+		}
+		due = append(due, e)
+	}
+	return due, rows.Err()
+}
+
+// markEscalated отмечает, что эскалация сообщения messageID обработана
+func (st *Storage) markEscalated(messageID string, at time.Time) error {
+	_, err := st.db.Exec(`UPDATE escalations SET escalated_at = ? WHERE message_id = ?`, at, messageID)
+	return err
+}
+
+// DeleteChatEscalations удаляет ожидание ответа от чата chatJID
+func (st *Storage) DeleteChatEscalations(chatJID string) (int64, error) {
+	res, err := st.db.Exec(`DELETE FROM escalations WHERE chat_jid = ?`, chatJID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
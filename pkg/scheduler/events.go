@@ -0,0 +1,87 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	EventKindSendResult  = "send_result"
+	EventKindTaskAdded   = "task_added"
+	EventKindTaskStopped = "task_stopped"
+	EventKindAlert       = "alert"
+)
+
+// eventBufferSize - размер очереди подписчика, при переполнении события отбрасываются
+const eventBufferSize = 64
+
+// Event - событие планировщика, рассылаемое подписчикам
+type Event struct {
+	Kind      string    `json:"kind"`
+	TaskID    string    `json:"task_id,omitempty"`
+	ChatName  string    `json:"chat_name,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EventBus рассылает события всем подписчикам
+type EventBus struct {
+	mutex       sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+// Subscribe регистрирует подписчика, возвращает канал событий и функцию отписки
+func (b *EventBus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+
+	b.mutex.Lock()
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan Event]struct{})
+	}
+	b.subscribers[ch] = struct{}{}
+	b.mutex.Unlock()
+
+	return ch, func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe подписывается на события планировщика, возвращает канал событий и функцию отписки.
+// Медленные подписчики теряют события, а не задерживают отправки
+func (s *Scheduler) Subscribe() (<-chan Event, func()) {
+	return s.events.Subscribe()
+}
+
+// OnEvent вызывает handler для каждого события планировщика в отдельной горутине
+func (s *Scheduler) OnEvent(handler func(Event)) {
+	events, _ := s.events.Subscribe()
+	go func() {
+		for evt := range events {
+			handler(evt)
+		}
+	}()
+}
+
+// Publish отправляет событие подписчикам, не блокируясь на медленных получателях
+func (b *EventBus) Publish(evt Event) {
+	if evt.CreatedAt.IsZero() {
+		evt.CreatedAt = time.Now()
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- evt:
+		default:
+			Logger.Warnf("Очередь подписчика событий переполнена, событие %s отброшено", evt.Kind)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dateFormat - формат дат исключений
+const dateFormat = "2006-01-02"
+
+// Exclusions - даты и календари праздников, в которые отправки не выполняются
+type Exclusions struct {
+	Dates            []string `json:"dates"`
+	HolidayCalendars []string `json:"holiday_calendars"`
+}
+
+// globalExclusions - исключения, действующие для всех задач
+var globalExclusions = struct {
+	sync.RWMutex
+	Exclusions
+}{}
+
+// Validate проверяет формат дат и наличие календарей праздников
+func (e Exclusions) Validate() error {
+	for _, date := range e.Dates {
+		if _, err := time.Parse(dateFormat, date); err != nil {
+			return fmt.Errorf("неверный формат даты исключения '%s', ожидается ГГГГ-ММ-ДД", date)
+		}
+	}
+	for _, country := range e.HolidayCalendars {
+		if _, ok := holidayCalendars[strings.ToUpper(country)]; !ok {
+			return fmt.Errorf("неизвестный календарь праздников '%s'", country)
+		}
+	}
+	return nil
+}
+
+// excludes проверяет, попадает ли момент времени (по локальной дате) в исключения
+func (e Exclusions) excludes(t time.Time) bool {
+	date := t.In(time.Local)
+	if slices.Contains(e.Dates, date.Format(dateFormat)) {
+		return true
+	}
+	for _, country := range e.HolidayCalendars {
+		if isHoliday(strings.ToUpper(country), date) {
+			return true
+		}
+	}
+	return false
+}
+
+// GlobalExclusions возвращает исключения, действующие для всех задач
+func GlobalExclusions() Exclusions {
+	globalExclusions.RLock()
+	defer globalExclusions.RUnlock()
+	return globalExclusions.Exclusions
+}
+
+// isExcluded проверяет исключения задачи и глобальные исключения
+func (t *ScheduledTask) isExcluded(at time.Time) bool {
+	if (Exclusions{Dates: t.ExcludedDates, HolidayCalendars: t.HolidayCalendars}).excludes(at) {
+		return true
+	}
+
+	globalExclusions.RLock()
+	defer globalExclusions.RUnlock()
+	return globalExclusions.excludes(at)
+}
+
+// loadGlobalExclusions загружает глобальные исключения из хранилища
+func (st *Storage) loadGlobalExclusions() error {
+	rows, err := st.db.Query(`SELECT kind, value FROM exclusions ORDER BY value`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	exclusions := Exclusions{Dates: []string{}, HolidayCalendars: []string{}}
+	for rows.Next() {
+		var kind, value string
+		if err := rows.Scan(&kind, &value); err != nil {
+			return err
+		}
+		switch kind {
+		case exclusionKindDate:
+			exclusions.Dates = append(exclusions.Dates, value)
+		case exclusionKindHolidays:
+			exclusions.HolidayCalendars = append(exclusions.HolidayCalendars, value)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	globalExclusions.Lock()
+	globalExclusions.Exclusions = exclusions
+	globalExclusions.Unlock()
+	return nil
+}
+
+// SaveGlobalExclusions заменяет глобальные исключения
+func (st *Storage) SaveGlobalExclusions(exclusions Exclusions) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM exclusions`); err != nil {
+		return err
+	}
+	for _, date := range exclusions.Dates {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO exclusions (kind, value) VALUES (?, ?)`, exclusionKindDate, date); err != nil {
+			return err
+		}
+	}
+	for _, country := range exclusions.HolidayCalendars {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO exclusions (kind, value) VALUES (?, ?)`, exclusionKindHolidays, strings.ToUpper(country)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return st.loadGlobalExclusions()
+}
+
+const (
+	exclusionKindDate     = "date"
+	exclusionKindHolidays = "holidays"
+)
+
+// holidayRule описывает праздник: фиксированную дату или n-й день недели месяца
+type holidayRule struct {
+	month   time.Month
+	day     int          // фиксированный день месяца (0 - плавающий праздник)
+	weekday time.Weekday // день недели плавающего праздника
+	week    int          // номер дня недели в месяце, -1 - последний
+}
+
+// holidayCalendars - встроенные календари государственных праздников
+var holidayCalendars = map[string][]holidayRule{
+	"RU": {
+		{month: time.January, day: 1}, {month: time.January, day: 2}, {month: time.January, day: 3},
+		{month: time.January, day: 4}, {month: time.January, day: 5}, {month: time.January, day: 6},
+		{month: time.January, day: 7}, {month: time.January, day: 8},
+		{month: time.February, day: 23},
+		{month: time.March, day: 8},
+		{month: time.May, day: 1},
+		{month: time.May, day: 9},
+		{month: time.June, day: 12},
+		{month: time.November, day: 4},
+	},
+	"US": {
+		{month: time.January, day: 1},
+		{month: time.January, weekday: time.Monday, week: 3},  // Martin Luther King Jr. Day
+		{month: time.February, weekday: time.Monday, week: 3}, // Presidents' Day
+		{month: time.May, weekday: time.Monday, week: -1},     // Memorial Day
+		{month: time.June, day: 19},
+		{month: time.July, day: 4},
+		{month: time.September, weekday: time.Monday, week: 1}, // Labor Day
+		{month: time.October, weekday: time.Monday, week: 2},   // Columbus Day
+		{month: time.November, day: 11},
+		{month: time.November, weekday: time.Thursday, week: 4}, // Thanksgiving
+		{month: time.December, day: 25},
+	},
+	"GB": {
+		{month: time.January, day: 1},
+		{month: time.May, weekday: time.Monday, week: 1},
+		{month: time.May, weekday: time.Monday, week: -1},
+		{month: time.August, weekday: time.Monday, week: -1},
+		{month: time.December, day: 25},
+		{month: time.December, day: 26},
+	},
+}
+
+// isHoliday проверяет, является ли дата праздником в календаре страны
+func isHoliday(country string, date time.Time) bool {
+	for _, rule := range holidayCalendars[country] {
+		if date.Month() != rule.month {
+			continue
+		}
+		if rule.day != 0 {
+			if date.Day() == rule.day {
+				return true
+			}
+			continue
+		}
+		if date.Weekday() != rule.weekday {
+			continue
+		}
+		if rule.week == -1 {
+			// Последний такой день недели, если через неделю уже другой месяц
+			if date.AddDate(0, 0, 7).Month() != rule.month {
+				return true
+			}
+		} else if (date.Day()-1)/7+1 == rule.week {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// Способы распределения вариантов сообщения задачи
+const (
+	// VariantByOccurrence - все получатели одной рассылки получают один вариант
+	VariantByOccurrence = "occurrence"
+	// VariantByRecipient - каждый получатель рассылки всегда получает один и тот же вариант
+	VariantByRecipient = "recipient"
+)
+
+// VariantReport - сравнение одного варианта сообщения задачи
+type VariantReport struct {
+	// Variant - буква варианта: A, B, C...
+	Variant string `json:"variant"`
+	Message string `json:"message,omitempty"`
+	Sent    int    `json:"sent"`
+	Read    int    `json:"read"`
+	// Reactions и Replies - участники, отреагировавшие и ответившие в окне EngagementWindowHours
+	Reactions    int     `json:"reactions"`
+	Replies      int     `json:"replies"`
+	ReadRate     float64 `json:"read_rate"`
+	ReactionRate float64 `json:"reaction_rate"`
+	ReplyRate    float64 `json:"reply_rate"`
+}
+
+// variantLabel возвращает букву варианта по номеру
+func variantLabel(index int) string {
+	return string(rune('A' + index))
+}
+
+// validateVariants проверяет варианты сообщения и способ их распределения
+func (t *ScheduledTask) validateVariants() error {
+	t.VariantAssignment = strings.TrimSpace(t.VariantAssignment)
+	if len(t.Variants) == 0 {
+		if t.VariantAssignment != "" {
+			return fmt.Errorf("variant_assignment применяется только вместе с variants")
+		}
+		return nil
+	}
+
+	if len(t.Variants) < 2 || len(t.Variants) > 26 {
+		return fmt.Errorf("вариантов сообщения должно быть от 2 до 26")
+	}
+	if t.Message != "" || t.MessageCommand != "" || t.ForwardMessageID != "" {
+		return fmt.Errorf("variants заменяют message и не сочетаются с message_command и forward_message_id")
+	}
+	for i, variant := range t.Variants {
+		variant = strings.TrimSpace(variant)
+		if variant == "" {
+			return fmt.Errorf("пустой вариант сообщения %s", variantLabel(i))
+		}
+		if !t.SplitLongMessages {
+			if err := ValidateMessageLength(variant); err != nil {
+				return fmt.Errorf("вариант %s: %v", variantLabel(i), err)
+			}
+		}
+		t.Variants[i] = variant
+	}
+
+	switch t.VariantAssignment {
+	case "":
+		t.VariantAssignment = VariantByOccurrence
+	case VariantByOccurrence:
+	case VariantByRecipient:
+		if !t.broadcast() {
+			return fmt.Errorf("variant_assignment recipient применяется только к рассылке по списку recipients или group_members")
+		}
+	default:
+		return fmt.Errorf("неверный variant_assignment '%s', допустимо: %s, %s",
+			t.VariantAssignment, VariantByOccurrence, VariantByRecipient)
+	}
+	return nil
+}
+
+// variantFor детерминированно выбирает вариант сообщения для рассылки planned или получателя chatName,
+// чтобы после перезапуска и при повторных отправках получатель видел тот же вариант
+func (t *ScheduledTask) variantFor(planned time.Time, chatName string) int {
+	key := fmt.Sprintf("%s|%d", t.ID, planned.Unix())
+	if t.VariantAssignment == VariantByRecipient {
+		key = t.ID + "|" + chatName
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return int(hash.Sum32() % uint32(len(t.Variants)))
+}
+
+// applyVariant подставляет в сообщение вариант текста, назначенный получателю
+func (t *ScheduledTask) applyVariant(msg *OutgoingMessage) {
+	if len(t.Variants) == 0 {
+		return
+	}
+	variant := t.variantFor(msg.Planned, msg.ChatName)
+	msg.Variant = variantLabel(variant)
+	msg.Message = ApplyFormat(t.Variants[variant], t.Format)
+}
+
+// trackVariant запоминает вариант, отправленный сообщением, для сравнения вариантов
+func (s *Scheduler) trackVariant(msg OutgoingMessage) {
+	if s.storage == nil || msg.Variant == "" || msg.ID == "" {
+		return
+	}
+	if err := s.storage.addVariantMessage(msg.ID, msg.TaskID, msg.Variant); err != nil {
+		Logger.Errorf("Ошибка сохранения варианта сообщения задачи %s: %v", msg.TaskID, err)
+	}
+}
+
+// VariantReports сравнивает варианты сообщения задачи, подставляя их текст, если задача активна
+func (s *Scheduler) VariantReports(taskID string) ([]VariantReport, error) {
+	reports, err := s.storage.VariantReports(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.RLock()
+	task := s.tasks[taskID]
+	s.mutex.RUnlock()
+	if task != nil {
+		for i := range reports {
+			if index := int(reports[i].Variant[0] - 'A'); index < len(task.Variants) {
+				reports[i].Message = task.Variants[index]
+			}
+		}
+	}
+	return reports, nil
+}
+
+// addVariantMessage запоминает вариант variant, отправленный сообщением messageID
+func (st *Storage) addVariantMessage(messageID, taskID, variant string) error {
+	_, err := st.db.Exec(`INSERT OR REPLACE INTO experiment_messages (message_id, task_id, variant) VALUES (?, ?, ?)`,
+		messageID, taskID, variant)
+	return err
+}
+
+// markVariantsRead отмечает прочитанными сообщения с вариантами по подтверждению прочтения
+func (st *Storage) markVariantsRead(messageIDs []string) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	args := []any{time.Now()}
+	for _, id := range messageIDs {
+		args = append(args, id)
+	}
+	_, err := st.db.Exec(`UPDATE experiment_messages SET read_at = ? WHERE read_at IS NULL
+		AND message_id IN (?`+strings.Repeat(", ?", len(messageIDs)-1)+`)`, args...)
+	return err
+}
+
+// VariantReports подсчитывает по вариантам сообщения задачи отправки, прочтения, реакции и ответы
+func (st *Storage) VariantReports(taskID string) ([]VariantReport, error) {
+	rows, err := st.db.Query(`SELECT x.variant, COUNT(DISTINCT x.message_id),
+			COUNT(DISTINCT CASE WHEN x.read_at IS NOT NULL THEN x.message_id END),
+			COUNT(CASE WHEN e.kind = ? THEN 1 END),
+			COUNT(CASE WHEN e.kind = ? THEN 1 END)
+		FROM experiment_messages x LEFT JOIN engagement_events e ON e.message_id = x.message_id
+		WHERE x.task_id = ? GROUP BY x.variant ORDER BY x.variant`,
+		engagementReaction, engagementReply, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []VariantReport{}
+	for rows.Next() {
+		var report VariantReport
+		if err := rows.Scan(&report.Variant, &report.Sent, &report.Read, &report.Reactions, &report.Replies); err != nil {
+			return nil, err
+		}
+		if report.Sent > 0 {
+			report.ReadRate = float64(report.Read) / float64(report.Sent)
+			report.ReactionRate = float64(report.Reactions) / float64(report.Sent)
+			report.ReplyRate = float64(report.Replies) / float64(report.Sent)
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
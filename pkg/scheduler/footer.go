@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+const (
+	// maxFooterLength - максимальная длина подписи сообщений
+	maxFooterLength = 500
+	// footerSeparator - отделяет подпись от текста сообщения
+	footerSeparator = "\n\n"
+)
+
+// validateFooter проверяет длину подписи сообщений и ее шаблон
+func validateFooter(footer string) error {
+	if length := utf8.RuneCountInString(footer); length > maxFooterLength {
+		return fmt.Errorf("подпись сообщений длиннее %d символов", maxFooterLength)
+	}
+	if _, err := template.New("footer").Funcs(footerFuncs(taskTemplateData{})).Parse(footer); err != nil {
+		return fmt.Errorf("ошибка шаблона подписи: %v", err)
+	}
+	return nil
+}
+
+// validateLengthWithFooter проверяет, что сообщение помещается в одно сообщение WhatsApp вместе
+// с подписью footer. Шаблон подписи заполняется при отправке, поэтому под нее резервируется
+// maxFooterLength символов
+func validateLengthWithFooter(message, footer string) error {
+	if footer == "" {
+		return ValidateMessageLength(message)
+	}
+	limit := MaxMessageLength - maxFooterLength - len(footerSeparator)
+	if length := utf8.RuneCountInString(message); length > limit {
+		return fmt.Errorf("сообщение слишком длинное: %d символов, с подписью допустимо не больше %d", length, limit)
+	}
+	return nil
+}
+
+// footerFuncs возвращает функции шаблона подписи: функции шаблонов сообщений и прежние подстановки
+// {{chat_name}} - название чата получателя, {{date}} (ГГГГ-ММ-ДД), {{time}} (ЧЧ:ММ) и {{weekday}}
+func footerFuncs(data taskTemplateData) template.FuncMap {
+	funcs := TemplateFuncs()
+	funcs["chat_name"] = func() string { return data.ChatName }
+	funcs["date"] = func() string { return data.Time.Format("2006-01-02") }
+	funcs["time"] = func() string { return data.Time.Format("15:04") }
+	funcs["weekday"] = func() string { return lookupLocale(data.Locale).Weekdays[data.Time.Weekday()] }
+	return funcs
+}
+
+// renderFooter заполняет шаблон подписи теми же данными и функциями, что и шаблон сообщения задачи
+func renderFooter(footer string, data taskTemplateData) (string, error) {
+	tmpl, err := template.New("footer").Funcs(footerFuncs(data)).Parse(footer)
+	if err != nil {
+		return "", fmt.Errorf("%w: подпись: %v", ErrTemplateRender, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("%w: подпись: %v", ErrTemplateRender, err)
+	}
+	return b.String(), nil
+}
+
+// footer возвращает подпись сообщений задачи: собственную или из настроек, пусто - без подписи
+func (s *Scheduler) footer(task *ScheduledTask) string {
+	if task.DisableFooter {
+		return ""
+	}
+	if task.Footer != "" {
+		return task.Footer
+	}
+	return s.Settings().MessageFooter
+}
+
+// ApplyFooter добавляет подпись к тексту сообщения задачи. Опросы и пересылки не подписываются:
+// текст опроса - его вопрос, а пересылаемое сообщение не меняется
+func (s *Scheduler) ApplyFooter(task *ScheduledTask, msg *OutgoingMessage) error {
+	footer := s.footer(task)
+	if footer == "" || msg.Poll != nil || msg.ForwardID != "" {
+		return nil
+	}
+	rendered, err := renderFooter(footer, s.templateData(task, *msg))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(msg.Message) == "" {
+		msg.Message = rendered
+		return nil
+	}
+	msg.Message += footerSeparator + rendered
+	return nil
+}
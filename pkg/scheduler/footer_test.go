@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageLengthWithFooter(t *testing.T) {
+	// Самое длинное сообщение, к которому помещается любая подпись
+	budget := MaxMessageLength - maxFooterLength - len(footerSeparator)
+	tests := []struct {
+		name string
+		task ScheduledTask
+		// wantAddErr - задача отклоняется при добавлении, wantSendErr - сообщение не готовится к отправке
+		wantAddErr  bool
+		wantSendErr bool
+	}{
+		{name: "no footer", task: ScheduledTask{Message: strings.Repeat("a", MaxMessageLength), DisableFooter: true}},
+		{name: "fits with footer", task: ScheduledTask{Message: strings.Repeat("a", budget)}},
+		{
+			// Подпись из настроек короче резерва, но при добавлении место оставляется под любую
+			name:       "no room for a footer",
+			task:       ScheduledTask{Message: strings.Repeat("a", budget+1)},
+			wantAddErr: true,
+		},
+		{
+			name: "long message is split",
+			task: ScheduledTask{Message: strings.Repeat("a", MaxMessageLength), SplitLongMessages: true},
+		},
+		{
+			// Подпись с шаблоном проверяется после заполнения
+			name:        "rendered footer over the limit",
+			task:        ScheduledTask{Message: strings.Repeat("a", budget), Footer: "{{chat_name}}"},
+			wantSendErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestScheduler(t)
+			settings := s.Settings()
+			settings.MessageFooter = "Sent by the scheduler"
+			if err := s.SetSettings(settings); err != nil {
+				t.Fatalf("SetSettings: %v", err)
+			}
+
+			start := time.Now().Add(time.Hour)
+			task := tt.task
+			task.ChatName = strings.Repeat("Team ", 120)
+			task.Interval, task.StartTime, task.EndTime = 30, start, start.Add(time.Hour)
+			if err := s.prepareTask(&task, nil, 0, 0); (err != nil) != tt.wantAddErr {
+				t.Fatalf("prepareTask() error = %v, wantErr %v", err, tt.wantAddErr)
+			}
+
+			msg := OutgoingMessage{ChatName: task.ChatName, Message: task.Message}
+			if err := s.composeMessage(&task, &msg); (err != nil) != tt.wantSendErr {
+				t.Fatalf("composeMessage() error = %v, wantErr %v", err, tt.wantSendErr)
+			}
+		})
+	}
+}
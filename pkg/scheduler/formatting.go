@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	formatPlain    = "plain"
+	formatMarkdown = "markdown"
+)
+
+var (
+	markdownHeading    = regexp.MustCompile(`^\s{0,3}#{1,6}\s+(.+?)\s*#*\s*$`)
+	markdownListItem   = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	markdownBold       = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*|__(\S(?:.*?\S)?)__`)
+	markdownItalic     = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*`)
+	markdownStrike     = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	markdownInlineCode = regexp.MustCompile("`([^`]+)`")
+)
+
+// ValidateFormat проверяет название формата сообщения
+func ValidateFormat(format string) error {
+	if format != "" && format != formatPlain && format != formatMarkdown {
+		return fmt.Errorf("неизвестный формат '%s', допустимо: %s, %s", format, formatPlain, formatMarkdown)
+	}
+	return nil
+}
+
+// ApplyFormat преобразует текст в разметку WhatsApp согласно формату
+func ApplyFormat(text, format string) string {
+	if format == formatMarkdown {
+		return markdownToWhatsApp(text)
+	}
+	return text
+}
+
+// markdownToWhatsApp преобразует упрощенный Markdown в разметку WhatsApp:
+// **жирный** → *жирный*, *курсив* → _курсив_, ~~зачеркнутый~~ → ~зачеркнутый~,
+// `код` → ```код```, заголовки → жирный текст, списки "- " → "• "
+func markdownToWhatsApp(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	inCodeBlock := false
+	for i, line := range lines {
+		// Содержимое блоков кода не трогаем, WhatsApp использует тот же синтаксис ```
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCodeBlock = !inCodeBlock
+			lines[i] = strings.TrimSpace(line)[:3]
+			if rest := strings.TrimSpace(line)[3:]; !inCodeBlock && rest != "" {
+				lines[i] = rest + "```"
+			}
+			continue
+		}
+		if inCodeBlock {
+			continue
+		}
+		lines[i] = convertMarkdownLine(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// convertMarkdownLine преобразует одну строку вне блока кода
+func convertMarkdownLine(line string) string {
+	if match := markdownHeading.FindStringSubmatch(line); match != nil {
+		return "*" + match[1] + "*"
+	}
+	line = markdownListItem.ReplaceAllString(line, "$1• ")
+
+	// Встроенный код обрабатываем отдельно, чтобы не менять его содержимое
+	var b strings.Builder
+	last := 0
+	for _, loc := range markdownInlineCode.FindAllStringSubmatchIndex(line, -1) {
+		b.WriteString(convertMarkdownEmphasis(line[last:loc[0]]))
+		b.WriteString("```" + line[loc[2]:loc[3]] + "```")
+		last = loc[1]
+	}
+	b.WriteString(convertMarkdownEmphasis(line[last:]))
+	return b.String()
+}
+
+// convertMarkdownEmphasis преобразует жирный, курсив и зачеркнутый текст
+func convertMarkdownEmphasis(text string) string {
+	// Жирный текст временно помечаем, чтобы не спутать его звездочки с курсивом
+	const boldMarker = "\x00"
+	text = markdownBold.ReplaceAllStringFunc(text, func(match string) string {
+		return boldMarker + match[2:len(match)-2] + boldMarker
+	})
+	text = markdownItalic.ReplaceAllString(text, "_${1}_")
+	text = markdownStrike.ReplaceAllString(text, "~${1}~")
+	return strings.ReplaceAll(text, boldMarker, "*")
+}
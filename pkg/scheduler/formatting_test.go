@@ -0,0 +1,51 @@
+package scheduler
+
+import "testing"
+
+func TestApplyFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		text   string
+		want   string
+	}{
+		{"plain keeps markup", formatPlain, "**bold** *italic*", "**bold** *italic*"},
+		{"default keeps markup", "", "# Title", "# Title"},
+		{"bold", formatMarkdown, "**bold** and __bold__", "*bold* and *bold*"},
+		{"italic", formatMarkdown, "*italic*", "_italic_"},
+		{"bold and italic", formatMarkdown, "**bold** then *italic*", "*bold* then _italic_"},
+		{"strikethrough", formatMarkdown, "~~gone~~", "~gone~"},
+		{"inline code kept", formatMarkdown, "run `**x**` now", "run ```**x**``` now"},
+		{"heading", formatMarkdown, "## Release notes ##", "*Release notes*"},
+		{"list items", formatMarkdown, "- first\n  * nested\n+ third", "• first\n  • nested\n• third"},
+		{"code block kept", formatMarkdown, "```go\n**x** - y\n```\n**after**", "```\n**x** - y\n```\n*after*"},
+		{"windows line endings", formatMarkdown, "**a**\r\n*b*", "*a*\n_b_"},
+		{"arithmetic is not emphasis", formatMarkdown, "2 * 3 * 4", "2 * 3 * 4"},
+		{"unicode", formatMarkdown, "**Привет** ~~мир~~", "*Привет* ~мир~"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ApplyFormat(tt.text, tt.format); got != tt.want {
+				t.Errorf("ApplyFormat(%q, %q) = %q, want %q", tt.text, tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr bool
+	}{
+		{"", false},
+		{formatPlain, false},
+		{formatMarkdown, false},
+		{"html", true},
+		{"Markdown", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateFormat(tt.format); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateFormat(%q) error = %v, wantErr %v", tt.format, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// storedMessagesLimit - сколько последних сообщений хранится для пересылки
+const storedMessagesLimit = 1000
+
+// ErrStoredMessageNotFound - сообщения с таким ID нет среди сохраненных для пересылки
+var ErrStoredMessageNotFound = errors.New("сообщение для пересылки не найдено")
+
+// StoredMessage - сообщение, сохраненное транспортом для пересылки задачами с ForwardMessageID
+type StoredMessage struct {
+	ID        string `json:"id"`
+	ChatJID   string `json:"chat_jid"`
+	SenderJID string `json:"sender_jid,omitempty"`
+	Text      string `json:"text,omitempty"`
+	// Data - сообщение в формате транспорта
+	Data      []byte    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	// MediaID - вложение сообщения, сохраненное в медиатеку (GET /messages/:id/media)
+	MediaID string `json:"media_id,omitempty"`
+}
+
+// SaveMessage сохраняет сообщение для пересылки, вытесняя самые старые сверх storedMessagesLimit
+func (st *Storage) SaveMessage(msg StoredMessage) error {
+	senderJID, err := st.EncryptField(msg.SenderJID)
+	if err != nil {
+		return err
+	}
+	text, err := st.EncryptField(msg.Text)
+	if err != nil {
+		return err
+	}
+	data, err := st.EncryptField(base64.StdEncoding.EncodeToString(msg.Data))
+	if err != nil {
+		return err
+	}
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+
+	if _, err := st.db.Exec(`INSERT OR REPLACE INTO stored_messages (id, chat_jid, sender_jid, text, data, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, msg.ID, msg.ChatJID, senderJID, text, data, msg.CreatedAt); err != nil {
+		return err
+	}
+	_, err = st.db.Exec(`DELETE FROM stored_messages WHERE id NOT IN
+		(SELECT id FROM stored_messages ORDER BY created_at DESC LIMIT ?)`, storedMessagesLimit)
+	return err
+}
+
+// GetStoredMessage возвращает сохраненное сообщение или ErrStoredMessageNotFound
+func (st *Storage) GetStoredMessage(id string) (*StoredMessage, error) {
+	msg := &StoredMessage{}
+	var data string
+	err := st.db.QueryRow(`SELECT id, chat_jid, sender_jid, text, data, created_at FROM stored_messages WHERE id = ?`, id).
+		Scan(&msg.ID, &msg.ChatJID, &msg.SenderJID, &msg.Text, &data, &msg.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrStoredMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := st.decryptFields(&msg.SenderJID, &msg.Text, &data); err != nil {
+		return nil, err
+	}
+	if msg.Data, err = base64.StdEncoding.DecodeString(data); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// ListStoredMessages возвращает последние сохраненные сообщения (chatJID пустой - из всех чатов)
+func (st *Storage) ListStoredMessages(chatJID string, limit int) ([]StoredMessage, error) {
+	rows, err := st.db.Query(`SELECT m.id, m.chat_jid, m.sender_jid, m.text, m.created_at, COALESCE(i.media_id, '')
+		FROM stored_messages m LEFT JOIN incoming_media i ON i.message_id = m.id
+		WHERE ? = '' OR m.chat_jid = ? ORDER BY m.created_at DESC LIMIT ?`, chatJID, chatJID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []StoredMessage{}
+	for rows.Next() {
+		var msg StoredMessage
+		if err := rows.Scan(&msg.ID, &msg.ChatJID, &msg.SenderJID, &msg.Text, &msg.CreatedAt, &msg.MediaID); err != nil {
+			return nil, err
+		}
+		if err := st.decryptFields(&msg.SenderJID, &msg.Text); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
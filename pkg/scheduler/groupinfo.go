@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Поля группы, которые может обновлять задача
+const (
+	GroupInfoSubject     = "subject"
+	GroupInfoDescription = "description"
+)
+
+// Ограничения WhatsApp на длину названия и описания группы
+const (
+	maxGroupSubjectLength     = 100
+	maxGroupDescriptionLength = 2048
+)
+
+// weekdayNames - названия дней недели для {{weekday}}
+var weekdayNames = [...]string{"воскресенье", "понедельник", "вторник", "среда", "четверг", "пятница", "суббота"}
+
+// GroupInfoUpdater - необязательная возможность транспорта: изменение названия и описания группы
+// для задач с GroupInfo
+type GroupInfoUpdater interface {
+	// SetGroupInfo меняет поле field группы (subject или description) на value
+	SetGroupInfo(ctx context.Context, chatName, chatJID, field, value string) error
+}
+
+// GroupInfoUpdate - обновление названия или описания группы по шаблону вместо отправки сообщения.
+// В шаблоне {{date}} заменяется датой (ГГГГ-ММ-ДД), {{time}} - временем (ЧЧ:ММ), {{weekday}} - днем недели
+// плановой отправки в часовом поясе задачи
+type GroupInfoUpdate struct {
+	// Field - subject (название) или description (описание)
+	Field    string `json:"field"`
+	Template string `json:"template"`
+}
+
+// validate проверяет обновление группы
+func (g *GroupInfoUpdate) validate() error {
+	g.Field = strings.TrimSpace(g.Field)
+	g.Template = strings.TrimSpace(g.Template)
+	limit := maxGroupDescriptionLength
+	switch g.Field {
+	case GroupInfoSubject:
+		limit = maxGroupSubjectLength
+	case GroupInfoDescription:
+	default:
+		return fmt.Errorf("неверное поле группы '%s', допустимо: subject, description", g.Field)
+	}
+	if g.Template == "" && g.Field == GroupInfoSubject {
+		return fmt.Errorf("пустой шаблон названия группы")
+	}
+	// Длина проверяется по самой длинной подстановке: дате и воскресенью
+	if length := utf8.RuneCountInString(g.render(time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC))); length > limit {
+		return fmt.Errorf("group_info: длина %d символов превышает ограничение WhatsApp %d", length, limit)
+	}
+	return nil
+}
+
+// render подставляет в шаблон дату и время at
+func (g GroupInfoUpdate) render(at time.Time) string {
+	return strings.NewReplacer(
+		"{{date}}", at.Format("2006-01-02"),
+		"{{time}}", at.Format("15:04"),
+		"{{weekday}}", weekdayNames[at.Weekday()],
+	).Replace(g.Template)
+}
+
+// renamesGroup проверяет, что задача меняет название своей группы
+func (t *ScheduledTask) renamesGroup() bool {
+	return t.GroupInfo != nil && t.GroupInfo.Field == GroupInfoSubject
+}
+
+// applyGroupInfo обновляет название или описание группы задачи по шаблону для плановой отправки planned
+func (s *Scheduler) applyGroupInfo(task *ScheduledTask, planned time.Time) {
+	updater, ok := s.sender.(GroupInfoUpdater)
+	if !ok {
+		Logger.Warnf("✏️ Транспорт не поддерживает изменение названия и описания группы, задача %s", task.ID)
+		return
+	}
+
+	update := *task.GroupInfo
+	value := update.render(planned.In(task.location()))
+	err := updater.SetGroupInfo(task.ctx, task.ChatName, task.ChatJID, update.Field, value)
+	s.recordHistory(OutgoingMessage{TaskID: task.ID, ChatName: task.ChatName, Message: fmt.Sprintf("✏️ %s: %s", update.Field, value)},
+		SendResult{ChatJID: task.ChatJID}, err)
+	if err != nil {
+		Logger.Errorf("❌ Не удалось изменить %s группы '%s' по задаче %s: %v", update.Field, task.ChatName, task.ID, err)
+		return
+	}
+	Logger.Infof("✏️ %s группы '%s' изменено по задаче %s: %s", update.Field, task.ChatName, task.ID, value)
+}
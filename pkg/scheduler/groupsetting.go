@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Настройки группы, которые может менять задача
+const (
+	// GroupSettingAnnounce - писать в группу могут только администраторы
+	GroupSettingAnnounce = "announce"
+	// GroupSettingLocked - менять название, описание и фото группы могут только администраторы
+	GroupSettingLocked = "locked"
+	// GroupSettingJoinApproval - вступление в группу по ссылке требует одобрения администратора
+	GroupSettingJoinApproval = "join_approval"
+	// GroupSettingAdminAdd - добавлять участников могут только администраторы
+	GroupSettingAdminAdd = "admin_add"
+)
+
+// maxGroupSettingRevertMinutes - максимальное время до возврата настройки группы
+const maxGroupSettingRevertMinutes = 7 * 24 * 60
+
+// groupSettings - настройки группы, доступные задачам
+var groupSettings = map[string]bool{
+	GroupSettingAnnounce:     true,
+	GroupSettingLocked:       true,
+	GroupSettingJoinApproval: true,
+	GroupSettingAdminAdd:     true,
+}
+
+// GroupSettingChanger - необязательная возможность транспорта: изменение настроек группы
+// для задач с GroupSetting
+type GroupSettingChanger interface {
+	// SetGroupSetting включает или выключает настройку setting группы
+	SetGroupSetting(ctx context.Context, chatName, chatJID, setting string, enabled bool) error
+}
+
+// GroupSettingChange - изменение настройки группы вместо отправки сообщения
+type GroupSettingChange struct {
+	// Setting - announce, locked, join_approval или admin_add
+	Setting string `json:"setting"`
+	Enabled bool   `json:"enabled"`
+	// RevertAfterMinutes - вернуть настройку через N минут (например, закрыть группу в 22:00 и открыть
+	// через 600 минут), 0 - не возвращать. Возврат не переживает перезапуск приложения
+	RevertAfterMinutes int `json:"revert_after_minutes,omitempty"`
+}
+
+// validate проверяет изменение настройки группы
+func (g *GroupSettingChange) validate() error {
+	g.Setting = strings.TrimSpace(g.Setting)
+	if !groupSettings[g.Setting] {
+		return fmt.Errorf("неверная настройка группы '%s', допустимо: announce, locked, join_approval, admin_add", g.Setting)
+	}
+	if g.RevertAfterMinutes < 0 || g.RevertAfterMinutes > maxGroupSettingRevertMinutes {
+		return fmt.Errorf("group_setting.revert_after_minutes должен быть в диапазоне 0..%d", maxGroupSettingRevertMinutes)
+	}
+	return nil
+}
+
+// String описывает изменение для журнала и истории
+func (g GroupSettingChange) String() string {
+	return describeGroupSetting(g.Setting, g.Enabled)
+}
+
+// describeGroupSetting описывает значение настройки группы
+func describeGroupSetting(setting string, enabled bool) string {
+	state := "off"
+	if enabled {
+		state = "on"
+	}
+	return fmt.Sprintf("⚙️ %s: %s", setting, state)
+}
+
+// groupAction проверяет, что задача меняет группу (GroupSetting или GroupInfo) вместо отправки сообщения
+func (t *ScheduledTask) groupAction() bool {
+	return t.GroupSetting != nil || t.GroupInfo != nil
+}
+
+// validateGroupAction проверяет задачу, меняющую настройку, название или описание группы вместо отправки сообщения
+func (t *ScheduledTask) validateGroupAction() error {
+	if t.GroupSetting != nil && t.GroupInfo != nil {
+		return fmt.Errorf("group_setting и group_info нельзя сочетать в одной задаче")
+	}
+	if t.GroupSetting != nil {
+		if err := t.GroupSetting.validate(); err != nil {
+			return err
+		}
+	}
+	if t.GroupInfo != nil {
+		if err := t.GroupInfo.validate(); err != nil {
+			return err
+		}
+	}
+	if t.Message != "" || t.MessageCommand != "" || t.media() != nil || t.ForwardMessageID != "" || len(t.Variants) > 0 ||
+		t.Interactive != nil || t.Poll != nil || t.Pin != "" || t.Escalation != nil || t.broadcast() {
+		return fmt.Errorf("group_setting и group_info нельзя сочетать с сообщением, вложениями, опросами и рассылкой")
+	}
+	if t.ChatJID != "" && !isGroupJID(t.ChatJID) {
+		return fmt.Errorf("чат '%s' не является группой", t.ChatName)
+	}
+	// После переименования группу можно найти только по JID
+	if t.renamesGroup() && t.ChatJID == "" {
+		return fmt.Errorf("для group_info с field=subject нужен JID группы: укажите chat_jid или дождитесь подключения WhatsApp")
+	}
+	return nil
+}
+
+// applyGroupSetting меняет настройку группы задачи и, если нужно, возвращает ее через RevertAfterMinutes
+func (s *Scheduler) applyGroupSetting(task *ScheduledTask) {
+	change := *task.GroupSetting
+	if err := s.setGroupSetting(task.ctx, task, change.Setting, change.Enabled); err != nil {
+		return
+	}
+	if change.RevertAfterMinutes == 0 {
+		return
+	}
+	// Возврат не зависит от задачи: группа не должна остаться закрытой после замены задачи
+	go func() {
+		if !s.clock.Sleep(context.Background(), time.Duration(change.RevertAfterMinutes)*time.Minute) {
+			return
+		}
+		s.setGroupSetting(context.Background(), task, change.Setting, !change.Enabled)
+	}()
+}
+
+// setGroupSetting меняет настройку группы задачи и записывает результат в историю
+func (s *Scheduler) setGroupSetting(ctx context.Context, task *ScheduledTask, setting string, enabled bool) error {
+	changer, ok := s.sender.(GroupSettingChanger)
+	if !ok {
+		Logger.Warnf("⚙️ Транспорт не поддерживает изменение настроек группы, задача %s", task.ID)
+		return fmt.Errorf("транспорт не поддерживает изменение настроек группы")
+	}
+
+	description := describeGroupSetting(setting, enabled)
+	err := changer.SetGroupSetting(ctx, task.ChatName, task.ChatJID, setting, enabled)
+	s.recordHistory(OutgoingMessage{TaskID: task.ID, ChatName: task.ChatName, Message: description}, SendResult{ChatJID: task.ChatJID}, err)
+	if err != nil {
+		Logger.Errorf("❌ Не удалось изменить настройку группы '%s' по задаче %s (%s): %v", task.ChatName, task.ID, description, err)
+		return err
+	}
+	Logger.Infof("⚙️ Настройка группы '%s' изменена по задаче %s: %s", task.ChatName, task.ID, description)
+	return nil
+}
@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultHealthCheckTimeout - таймаут проверки по умолчанию
+const defaultHealthCheckTimeout = 10 * time.Second
+
+// HealthCheck - HTTP проверка перед отправкой: сообщение отправляется, только если
+// URL вернул ожидаемый статус и (если задано) поле JSON ответа равно ожидаемому значению
+type HealthCheck struct {
+	URL string `json:"url"`
+	// ExpectStatus - ожидаемый HTTP статус, по умолчанию 200
+	ExpectStatus int `json:"expect_status,omitempty"`
+	// JSONPath - путь к полю ответа через точку, например "status" или "checks.0.state"
+	JSONPath string `json:"json_path,omitempty"`
+	// JSONEquals - ожидаемое значение поля JSONPath
+	JSONEquals json.RawMessage `json:"json_equals,omitempty"`
+	// TimeoutSeconds - таймаут запроса, по умолчанию 10 секунд
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+func (h *HealthCheck) validate() error {
+	parsed, err := url.Parse(h.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("неверный URL проверки '%s'", h.URL)
+	}
+	if (h.JSONPath == "") != (len(h.JSONEquals) == 0) {
+		return fmt.Errorf("json_path и json_equals должны указываться вместе")
+	}
+	if len(h.JSONEquals) > 0 && !json.Valid(h.JSONEquals) {
+		return fmt.Errorf("json_equals должен быть корректным JSON значением")
+	}
+	if h.TimeoutSeconds < 0 {
+		return fmt.Errorf("timeout_seconds не может быть отрицательным")
+	}
+	return nil
+}
+
+// Check выполняет проверку, ошибка означает, что отправку нужно пропустить
+func (h *HealthCheck) Check(ctx context.Context) error {
+	timeout := defaultHealthCheckTimeout
+	if h.TimeoutSeconds > 0 {
+		timeout = time.Duration(h.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("запрос не выполнен: %v", err)
+	}
+	defer resp.Body.Close()
+
+	expectStatus := h.ExpectStatus
+	if expectStatus == 0 {
+		expectStatus = http.StatusOK
+	}
+	if resp.StatusCode != expectStatus {
+		return fmt.Errorf("статус ответа %d, ожидался %d", resp.StatusCode, expectStatus)
+	}
+
+	if h.JSONPath == "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("ошибка чтения ответа: %v", err)
+	}
+	var document, expected any
+	if err := json.Unmarshal(body, &document); err != nil {
+		return fmt.Errorf("ответ не является JSON: %v", err)
+	}
+	if err := json.Unmarshal(h.JSONEquals, &expected); err != nil {
+		return err
+	}
+
+	actual, found := lookupJSONPath(document, h.JSONPath)
+	if !found {
+		return fmt.Errorf("поле '%s' отсутствует в ответе", h.JSONPath)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		return fmt.Errorf("поле '%s' равно %v, ожидалось %v", h.JSONPath, actual, expected)
+	}
+	return nil
+}
+
+// lookupJSONPath находит значение в декодированном JSON по пути через точку
+func lookupJSONPath(document any, path string) (any, bool) {
+	current := document
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]any:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	HistoryStatusSent        = "sent"
+	HistoryStatusFailed      = "failed"
+	HistoryStatusRateLimited = "rate_limited"
+)
+
+// HistoryEntry - запись об одной попытке отправки сообщения
+type HistoryEntry struct {
+	ID        int64     `json:"id"`
+	TaskID    string    `json:"task_id,omitempty"`
+	ChatName  string    `json:"chat_name"`
+	ChatJID   string    `json:"chat_jid,omitempty"`
+	Message   string    `json:"message"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// MessageID - ID отправленного сообщения в WhatsApp, пустой для неудачных отправок
+	MessageID string `json:"message_id,omitempty"`
+	// SentAt - время отправки по серверу WhatsApp
+	SentAt *time.Time `json:"sent_at,omitempty"`
+}
+
+// AddHistory сохраняет запись об отправке
+func (st *Storage) AddHistory(entry *HistoryEntry) error {
+	sealed := []string{entry.ChatName, entry.ChatJID, entry.Message, entry.Error}
+	for i := range sealed {
+		var err error
+		if sealed[i], err = st.EncryptField(sealed[i]); err != nil {
+			return err
+		}
+	}
+
+	res, err := st.db.Exec(`INSERT INTO history (task_id, chat_name, chat_jid, message, status, error, created_at, message_id, sent_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.TaskID, sealed[0], sealed[1], sealed[2], entry.Status, sealed[3], entry.CreatedAt, entry.MessageID, entry.SentAt)
+	if err != nil {
+		return err
+	}
+	entry.ID, err = res.LastInsertId()
+	return err
+}
+
+// ListHistory возвращает последние записи истории (новые первыми)
+func (st *Storage) ListHistory(limit int) ([]HistoryEntry, error) {
+	rows, err := st.db.Query(`SELECT id, task_id, chat_name, chat_jid, message, status, error, created_at, message_id, sent_at
+		FROM history ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []HistoryEntry{}
+	for rows.Next() {
+		var entry HistoryEntry
+		var sentAt sql.NullTime
+		if err := rows.Scan(&entry.ID, &entry.TaskID, &entry.ChatName, &entry.ChatJID,
+			&entry.Message, &entry.Status, &entry.Error, &entry.CreatedAt, &entry.MessageID, &sentAt); err != nil {
+			return nil, err
+		}
+		if sentAt.Valid {
+			entry.SentAt = &sentAt.Time
+		}
+		if err := st.decryptFields(&entry.ChatName, &entry.ChatJID, &entry.Message, &entry.Error); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// HistorySummary - сводка отправок за период
+type HistorySummary struct {
+	Sent        int `json:"sent"`
+	Failed      int `json:"failed"`
+	RateLimited int `json:"rate_limited"`
+	// Chats - количество успешных отправок по чатам
+	Chats map[string]int `json:"chats"`
+	// FailedChats - количество ошибок отправки по чатам
+	FailedChats map[string]int `json:"failed_chats"`
+}
+
+// SummarizeHistory подсчитывает отправки в интервале [from, to).
+// Зашифрованные названия чатов различаются в каждой записи, поэтому группы суммируются после расшифровки
+func (st *Storage) SummarizeHistory(from, to time.Time) (HistorySummary, error) {
+	summary := HistorySummary{Chats: map[string]int{}, FailedChats: map[string]int{}}
+	rows, err := st.db.Query(`SELECT chat_name, status, COUNT(*) FROM history
+		WHERE created_at >= ? AND created_at < ? GROUP BY chat_name, status`, from, to)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var chatName, status string
+		var count int
+		if err := rows.Scan(&chatName, &status, &count); err != nil {
+			return summary, err
+		}
+		if err := st.decryptFields(&chatName); err != nil {
+			return summary, err
+		}
+		switch status {
+		case HistoryStatusSent:
+			summary.Sent += count
+			summary.Chats[chatName] += count
+		case HistoryStatusFailed:
+			summary.Failed += count
+			summary.FailedChats[chatName] += count
+		case HistoryStatusRateLimited:
+			summary.RateLimited += count
+		}
+	}
+	return summary, rows.Err()
+}
@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	humanizeGaussian  = "gaussian"
+	humanizeLogNormal = "lognormal"
+)
+
+// HumanizeOptions - настройки "человеческого" разброса времени отправки
+type HumanizeOptions struct {
+	// Distribution - распределение смещения: gaussian (вокруг планового времени)
+	// или lognormal (только задержка, с длинным хвостом)
+	Distribution string `json:"distribution"`
+	// SpreadSeconds - стандартное отклонение (gaussian) или медиана задержки (lognormal)
+	SpreadSeconds int `json:"spread_seconds"`
+	// PauseChance - вероятность дополнительной длинной паузы (0..1)
+	PauseChance float64 `json:"pause_chance,omitempty"`
+	// PauseMinutes - максимальная длительность длинной паузы
+	PauseMinutes int `json:"pause_minutes,omitempty"`
+}
+
+func (h *HumanizeOptions) validate() error {
+	if h.Distribution != humanizeGaussian && h.Distribution != humanizeLogNormal {
+		return fmt.Errorf("неизвестное распределение '%s', допустимо: %s, %s", h.Distribution, humanizeGaussian, humanizeLogNormal)
+	}
+	if h.SpreadSeconds <= 0 {
+		return fmt.Errorf("spread_seconds должен быть больше 0")
+	}
+	if h.PauseChance < 0 || h.PauseChance > 1 {
+		return fmt.Errorf("pause_chance должен быть в диапазоне 0..1")
+	}
+	if h.PauseMinutes < 0 {
+		return fmt.Errorf("pause_minutes не может быть отрицательным")
+	}
+	if h.SpreadSeconds > maxDelayMinutes*60 || h.PauseMinutes > maxDelayMinutes {
+		return fmt.Errorf("spread_seconds и pause_minutes не могут превышать %d мин", maxDelayMinutes)
+	}
+	return nil
+}
+
+// offset возвращает смещение от планового времени. Смещение ограничено половиной интервала,
+// чтобы соседние отправки не менялись местами, и никогда не дает отправку ровно в 00 секунд
+func (h *HumanizeOptions) offset(occurrence time.Time, interval time.Duration) time.Duration {
+	spread := float64(h.SpreadSeconds) * float64(time.Second)
+
+	var offset time.Duration
+	switch h.Distribution {
+	case humanizeLogNormal:
+		offset = time.Duration(spread * math.Exp(rand.NormFloat64()*0.5))
+	default:
+		offset = time.Duration(rand.NormFloat64() * spread)
+	}
+
+	// Иногда человек отвлекается
+	if h.PauseMinutes > 0 && rand.Float64() < h.PauseChance {
+		offset += time.Duration(rand.Int64N(int64(h.PauseMinutes) * int64(time.Minute)))
+	}
+
+	limit := interval/2 - time.Second
+	offset = max(-limit, min(offset, limit))
+
+	// Не отправляем ровно в начале минуты
+	if occurrence.Add(offset).Second() == 0 {
+		shift := time.Duration(1+rand.IntN(59)) * time.Second
+		if offset+shift <= limit {
+			offset += shift
+		} else {
+			offset -= shift
+		}
+	}
+	return offset
+}
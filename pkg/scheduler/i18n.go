@@ -0,0 +1,291 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// DefaultLocale - локаль formatDate и formatNumber, если язык получателя не указан или не поддерживается
+const DefaultLocale = "ru"
+
+// Locale - названия и форматы даты и чисел языка для функций шаблонов formatDate и formatNumber.
+// В форматах дат {weekday}, {day}, {month}, {year}, {DD} и {MM} заменяются частями даты
+type Locale struct {
+	// Months - названия месяцев с января в именительном падеже (формат month)
+	Months [12]string
+	// MonthsInDate - названия месяцев внутри даты ("2 июня"), пустые - как Months
+	MonthsInDate [12]string
+	// Weekdays - названия дней недели с воскресенья
+	Weekdays [7]string
+	// Formats - форматы дат по названию: long, date, day, short
+	Formats map[string]string
+	// DecimalSeparator и GroupSeparator - разделители дробной части и разрядов чисел
+	DecimalSeparator string
+	GroupSeparator   string
+}
+
+var (
+	localesMutex sync.RWMutex
+	// locales - локали по коду языка в нижнем регистре (ru, en, pt-br), см. RegisterLocale
+	locales = map[string]Locale{
+		"ru": {
+			Months: [12]string{"январь", "февраль", "март", "апрель", "май", "июнь",
+				"июль", "август", "сентябрь", "октябрь", "ноябрь", "декабрь"},
+			MonthsInDate: [12]string{"января", "февраля", "марта", "апреля", "мая", "июня",
+				"июля", "августа", "сентября", "октября", "ноября", "декабря"},
+			Weekdays: weekdayNames,
+			Formats: map[string]string{"long": "{weekday}, {day} {month}", "date": "{day} {month} {year}",
+				"day": "{day} {month}", "short": "{DD}.{MM}.{year}"},
+			DecimalSeparator: ",",
+			GroupSeparator:   "\u00a0",
+		},
+		"en": {
+			Months: [12]string{"January", "February", "March", "April", "May", "June",
+				"July", "August", "September", "October", "November", "December"},
+			Weekdays: [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+			Formats: map[string]string{"long": "{weekday}, {month} {day}", "date": "{month} {day}, {year}",
+				"day": "{month} {day}", "short": "{MM}/{DD}/{year}"},
+			DecimalSeparator: ".",
+			GroupSeparator:   ",",
+		},
+		"de": {
+			Months: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni",
+				"Juli", "August", "September", "Oktober", "November", "Dezember"},
+			Weekdays: [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+			Formats: map[string]string{"long": "{weekday}, {day}. {month}", "date": "{day}. {month} {year}",
+				"day": "{day}. {month}", "short": "{DD}.{MM}.{year}"},
+			DecimalSeparator: ",",
+			GroupSeparator:   ".",
+		},
+		"fr": {
+			Months: [12]string{"janvier", "février", "mars", "avril", "mai", "juin",
+				"juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+			Weekdays: [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+			Formats: map[string]string{"long": "{weekday} {day} {month}", "date": "{day} {month} {year}",
+				"day": "{day} {month}", "short": "{DD}/{MM}/{year}"},
+			DecimalSeparator: ",",
+			GroupSeparator:   "\u00a0",
+		},
+		"es": {
+			Months: [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio",
+				"julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+			Weekdays: [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+			Formats: map[string]string{"long": "{weekday}, {day} de {month}", "date": "{day} de {month} de {year}",
+				"day": "{day} de {month}", "short": "{DD}/{MM}/{year}"},
+			DecimalSeparator: ",",
+			GroupSeparator:   ".",
+		},
+		"pt": {
+			Months: [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho",
+				"julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+			Weekdays: [7]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira",
+				"sexta-feira", "sábado"},
+			Formats: map[string]string{"long": "{weekday}, {day} de {month}", "date": "{day} de {month} de {year}",
+				"day": "{day} de {month}", "short": "{DD}/{MM}/{year}"},
+			DecimalSeparator: ",",
+			GroupSeparator:   ".",
+		},
+	}
+)
+
+// RegisterLocale добавляет или заменяет локаль языка code (en, pt_BR) для formatDate и formatNumber
+func RegisterLocale(code string, locale Locale) error {
+	code = normalizeLanguage(code)
+	if !languageTag.MatchString(code) {
+		return fmt.Errorf("неверный код языка '%s'", code)
+	}
+	localesMutex.Lock()
+	defer localesMutex.Unlock()
+	locales[code] = locale
+	return nil
+}
+
+// lookupLocale возвращает локаль языка (ru_RU, pt-BR): полную, иначе основного языка без региона,
+// иначе DefaultLocale
+func lookupLocale(code string) Locale {
+	code = normalizeLanguage(code)
+	localesMutex.RLock()
+	defer localesMutex.RUnlock()
+	if locale, ok := locales[code]; ok {
+		return locale
+	}
+	if base, _, ok := strings.Cut(code, "-"); ok {
+		if locale, ok := locales[base]; ok {
+			return locale
+		}
+	}
+	return locales[DefaultLocale]
+}
+
+// FormatDate форматирует дату на языке locale: long ("понедельник, 2 июня"), date ("2 июня 2025"),
+// day ("2 июня"), short ("02.06.2025"), weekday, month или time (ЧЧ:ММ).
+// value - time.Time, строка RFC 3339 или ГГГГ-ММ-ДД, либо Unix время в секундах
+func FormatDate(locale, format string, value any) (string, error) {
+	at, err := templateTime(value)
+	if err != nil {
+		return "", err
+	}
+	l := lookupLocale(locale)
+	month := l.MonthsInDate[at.Month()-1]
+	if month == "" {
+		month = l.Months[at.Month()-1]
+	}
+	switch format {
+	case "weekday":
+		return l.Weekdays[at.Weekday()], nil
+	case "month":
+		return l.Months[at.Month()-1], nil
+	case "time":
+		return at.Format("15:04"), nil
+	}
+	pattern, ok := l.Formats[format]
+	if !ok {
+		return "", fmt.Errorf("неизвестный формат даты '%s'", format)
+	}
+	return strings.NewReplacer(
+		"{weekday}", l.Weekdays[at.Weekday()],
+		"{day}", strconv.Itoa(at.Day()),
+		"{month}", month,
+		"{year}", strconv.Itoa(at.Year()),
+		"{DD}", at.Format("02"),
+		"{MM}", at.Format("01"),
+	).Replace(pattern), nil
+}
+
+// templateTime приводит значение из шаблона ко времени
+func templateTime(value any) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v != nil {
+			return *v, nil
+		}
+	case string:
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if at, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return at, nil
+			}
+		}
+	case float64:
+		return time.Unix(int64(v), 0), nil
+	case int:
+		return time.Unix(int64(v), 0), nil
+	case int64:
+		return time.Unix(v, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("formatDate: значение '%v' не является датой", value)
+}
+
+// FormatNumber форматирует число с разделителями разрядов и дробной части языка locale ("1 234,5").
+// decimals - количество знаков после запятой, без него - сколько нужно
+func FormatNumber(locale string, value any, decimals ...int) (string, error) {
+	var number float64
+	switch v := value.(type) {
+	case float64:
+		number = v
+	case int:
+		number = float64(v)
+	case int64:
+		number = float64(v)
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return "", fmt.Errorf("formatNumber: значение '%s' не является числом", v)
+		}
+		number = parsed
+	default:
+		return "", fmt.Errorf("formatNumber: значение '%v' не является числом", value)
+	}
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return "", fmt.Errorf("formatNumber: значение '%v' не является числом", value)
+	}
+	precision := -1
+	if len(decimals) > 0 {
+		precision = max(0, decimals[0])
+	}
+
+	l := lookupLocale(locale)
+	text := strconv.FormatFloat(math.Abs(number), 'f', precision, 64)
+	integer, fraction, _ := strings.Cut(text, ".")
+	var b strings.Builder
+	if number < 0 && strings.Trim(text, "0.") != "" {
+		b.WriteString("-")
+	}
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(l.GroupSeparator)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(l.DecimalSeparator)
+		b.WriteString(fraction)
+	}
+	return b.String(), nil
+}
+
+// TemplateFuncs возвращает функции шаблонов сообщений: formatDate и formatNumber
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"formatDate":   FormatDate,
+		"formatNumber": FormatNumber,
+	}
+}
+
+// taskTemplateData - данные шаблона сообщения задачи (ScheduledTask.Template)
+type taskTemplateData struct {
+	// Time - плановое время отправки в часовом поясе задачи
+	Time     time.Time
+	ChatName string
+	// Locale - язык получателя из списков получателей, пустой - DefaultLocale
+	Locale string
+}
+
+// templateData возвращает данные шаблона сообщения задачи и подписи для получателя msg
+func (s *Scheduler) templateData(task *ScheduledTask, msg OutgoingMessage) taskTemplateData {
+	at := msg.Planned
+	if at.IsZero() {
+		at = s.clock.Now()
+	}
+	return taskTemplateData{Time: at.In(task.location()), ChatName: msg.ChatName, Locale: msg.Language}
+}
+
+// validateTemplate проверяет, что текст, варианты и переводы задачи с Template - корректные шаблоны
+func (t *ScheduledTask) validateTemplate() error {
+	if !t.Template {
+		return nil
+	}
+	texts := append([]string{t.Message}, t.Variants...)
+	for _, text := range t.Translations {
+		texts = append(texts, text)
+	}
+	for _, text := range texts {
+		if _, err := template.New(t.ID).Funcs(TemplateFuncs()).Parse(text); err != nil {
+			return fmt.Errorf("ошибка шаблона сообщения: %v", err)
+		}
+	}
+	return nil
+}
+
+// renderTemplate заполняет шаблон сообщения задачи с Template для получателя msg
+func (s *Scheduler) renderTemplate(task *ScheduledTask, msg *OutgoingMessage) error {
+	if !task.Template {
+		return nil
+	}
+	tmpl, err := template.New(task.ID).Funcs(TemplateFuncs()).Parse(msg.Message)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTemplateRender, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, s.templateData(task, *msg)); err != nil {
+		return fmt.Errorf("%w: %v", ErrTemplateRender, err)
+	}
+	msg.Message = b.String()
+	return nil
+}
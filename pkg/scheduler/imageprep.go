@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // декодер PNG для обработки изображений перед отправкой
+)
+
+// Обработка изображений перед отправкой по умолчанию
+const (
+	DefaultImageMaxDimension = 1600
+	DefaultImageQuality      = 80
+)
+
+// maxImageDimension - верхняя граница настройки image_max_dimension
+const maxImageDimension = 4096
+
+// exifOrientationTag - тег EXIF с ориентацией снимка
+const exifOrientationTag = 0x0112
+
+// processableImage проверяет, что изображение обрабатывается перед отправкой. GIF не трогаем,
+// чтобы не потерять анимацию
+func processableImage(mimeType string) bool {
+	return mimeType == "image/jpeg" || mimeType == "image/png"
+}
+
+// PrepareImage уменьшает изображение так, чтобы большая сторона не превышала maxDimension
+// (0 - без уменьшения), и перекодирует его в JPEG с качеством quality. Метаданные EXIF,
+// в том числе координаты GPS, при перекодировании отбрасываются, ориентация снимка применяется к пикселям.
+// Файлы других форматов возвращаются без изменений
+func PrepareImage(data []byte, mimeType string, maxDimension, quality int) ([]byte, string, error) {
+	if !processableImage(mimeType) {
+		return data, mimeType, nil
+	}
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка чтения изображения: %v", err)
+	}
+	if mimeType == "image/jpeg" {
+		source = applyOrientation(source, jpegOrientation(data))
+	}
+	if maxDimension > 0 {
+		source = DownscaleImage(source, maxDimension)
+	}
+
+	// У JPEG нет прозрачности: прозрачные области PNG заливаются белым, а не черным
+	flat := image.NewRGBA(source.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), source, source.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/jpeg", nil
+}
+
+// PrepareImage обрабатывает изображение по настройкам process_images, image_max_dimension и image_quality.
+// Если изображение не удалось обработать, возвращается исходный файл: отправка важнее обработки
+func (s *Scheduler) PrepareImage(data []byte, mimeType string) ([]byte, string) {
+	settings := s.Settings()
+	if !settings.ProcessImages || !processableImage(mimeType) {
+		return data, mimeType
+	}
+	prepared, preparedMime, err := PrepareImage(data, mimeType, settings.ImageMaxDimension, settings.ImageQuality)
+	if err != nil {
+		Logger.Warnf("⚠️ Изображение отправляется без обработки: %v", err)
+		return data, mimeType
+	}
+	Logger.Debugf("🖼️ Изображение обработано: %d -> %d байт", len(data), len(prepared))
+	return prepared, preparedMime
+}
+
+// validateImageProcessing проверяет настройки обработки изображений
+func (st *Settings) validateImageProcessing() error {
+	if st.ImageMaxDimension < 0 || st.ImageMaxDimension > maxImageDimension {
+		return fmt.Errorf("image_max_dimension должен быть в диапазоне 0..%d", maxImageDimension)
+	}
+	if st.ImageQuality < 1 || st.ImageQuality > 100 {
+		return fmt.Errorf("image_quality должен быть в диапазоне 1..100")
+	}
+	return nil
+}
+
+// jpegOrientation находит ориентацию снимка в блоке EXIF (APP1) файла JPEG, 1 - без поворота
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for pos := 2; pos+4 <= len(data) && data[pos] == 0xFF; {
+		marker := data[pos+1]
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if marker == 0xDA || length < 2 || pos+2+length > len(data) {
+			break
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation читает тег ориентации из первого каталога (IFD0) блока TIFF
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == exifOrientationTag {
+			if value := int(order.Uint16(tiff[entry+8:])); value >= 1 && value <= 8 {
+				return value
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// applyOrientation поворачивает и отражает изображение по ориентации EXIF, чтобы снимок
+// выглядел правильно и без метаданных
+func applyOrientation(source image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return source
+	}
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	// Ориентации 5-8 меняют ширину и высоту местами
+	swap := orientation >= 5
+	newWidth, newHeight := width, height
+	if swap {
+		newWidth, newHeight = height, width
+	}
+
+	result := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		for x := 0; x < newWidth; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // отражение по горизонтали
+				sx, sy = width-1-x, y
+			case 3: // поворот на 180°
+				sx, sy = width-1-x, height-1-y
+			case 4: // отражение по вертикали
+				sx, sy = x, height-1-y
+			case 5: // транспонирование
+				sx, sy = y, x
+			case 6: // поворот на 90° по часовой стрелке
+				sx, sy = y, height-1-x
+			case 7: // поперечное транспонирование
+				sx, sy = width-1-y, height-1-x
+			case 8: // поворот на 90° против часовой стрелки
+				sx, sy = width-1-y, x
+			}
+			result.Set(x, y, source.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+	return result
+}
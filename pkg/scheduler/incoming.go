@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"fmt"
+)
+
+const alertKindAutoBlock = "auto_block"
+
+// HandleIncoming обрабатывает входящее сообщение, полученное транспортом
+func (s *Scheduler) HandleIncoming(msg IncomingMessage) {
+	// Фразы включения задач принимаются и от владельца аккаунта
+	s.handleTriggers(msg)
+	if msg.FromMe {
+		return
+	}
+	s.recordEngagement(msg)
+	s.resolveEscalations(msg)
+	s.saveIncomingMedia(msg)
+	if msg.Text == "" {
+		return
+	}
+	s.checkCampaignExits(msg)
+	s.handleOptOut(msg)
+	s.autoReply(msg)
+
+	// Автоматическая блокировка применяется только к личным чатам и не выполняется в режиме только для чтения
+	if msg.Direct && !s.IsReadOnly() {
+		if keyword := s.blockRules.matchKeyword(msg.Text); keyword != "" {
+			blocker, ok := s.sender.(ContactBlocker)
+			if !ok {
+				return
+			}
+			if err := blocker.BlockContact(msg.SenderJID); err != nil {
+				Logger.Errorf("Ошибка автоматической блокировки %s: %v", msg.SenderJID, err)
+				return
+			}
+			s.alert(alertKindAutoBlock, fmt.Sprintf("Пользователь %s (%s) заблокирован автоматически по ключевому слову '%s'",
+				msg.SenderName, msg.SenderJID, keyword))
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultIncomingMediaRetentionDays - сколько дней хранятся вложения входящих сообщений
+	DefaultIncomingMediaRetentionDays = 30
+	// DefaultIncomingMediaMaxMB - общий объем вложений входящих сообщений в медиатеке
+	DefaultIncomingMediaMaxMB = 500
+	// incomingMediaDownloadTimeout - максимальное время скачивания вложения входящего сообщения
+	incomingMediaDownloadTimeout = 2 * time.Minute
+)
+
+// ErrIncomingMediaNotFound - вложение сообщения не сохранено или уже удалено по сроку хранения
+var ErrIncomingMediaNotFound = errors.New("вложение сообщения не сохранено")
+
+// IncomingMedia - вложение входящего сообщения (изображение или документ), которое транспорт
+// скачивает по запросу
+type IncomingMedia struct {
+	MimeType string
+	FileName string
+	Size     int64
+	// Download скачивает и расшифровывает вложение
+	Download func(ctx context.Context) ([]byte, error)
+}
+
+// saveIncomingMedia сохраняет вложение входящего сообщения в медиатеку, если включена
+// настройка download_incoming_media. Скачивание выполняется в фоне
+func (s *Scheduler) saveIncomingMedia(msg IncomingMessage) {
+	settings := s.Settings()
+	if msg.Media == nil || msg.ID == "" || !settings.DownloadIncomingMedia {
+		return
+	}
+	if msg.Media.Size > MaxMediaSize {
+		Logger.Warnf("Вложение сообщения %s не сохранено: %d МБ больше %d МБ", msg.ID, msg.Media.Size>>20, MaxMediaSize>>20)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), incomingMediaDownloadTimeout)
+		defer cancel()
+		data, err := msg.Media.Download(ctx)
+		if err != nil {
+			Logger.Warnf("Ошибка скачивания вложения сообщения %s: %v", msg.ID, err)
+			return
+		}
+		asset, err := s.storage.saveIncomingMedia(msg, data)
+		if err != nil {
+			Logger.Errorf("Ошибка сохранения вложения сообщения %s: %v", msg.ID, err)
+			return
+		}
+		Logger.Infof("📥 Вложение сообщения %s от %s сохранено в медиатеку: %s", msg.ID, msg.SenderJID, asset.ID)
+		s.purgeIncomingMedia()
+	}()
+}
+
+// purgeIncomingMedia удаляет вложения входящих сообщений старше incoming_media_retention_days
+// и самые старые сверх incoming_media_max_mb
+func (s *Scheduler) purgeIncomingMedia() {
+	settings := s.Settings()
+	var before time.Time
+	if settings.IncomingMediaRetentionDays > 0 {
+		before = retentionCutoff(settings.IncomingMediaRetentionDays)
+	}
+	deleted, err := s.storage.purgeIncomingMedia(before, int64(settings.IncomingMediaMaxMB)<<20)
+	if err != nil {
+		Logger.Errorf("Ошибка удаления вложений входящих сообщений: %v", err)
+		return
+	}
+	if deleted > 0 {
+		Logger.Infof("🧹 Удалено вложений входящих сообщений: %d", deleted)
+	}
+}
+
+// validateIncomingMedia проверяет настройки хранения вложений входящих сообщений
+func (st *Settings) validateIncomingMedia() error {
+	if st.IncomingMediaRetentionDays < 0 || st.IncomingMediaMaxMB < 0 {
+		return fmt.Errorf("incoming_media_retention_days и incoming_media_max_mb не могут быть отрицательными")
+	}
+	return nil
+}
+
+// saveIncomingMedia добавляет вложение в медиатеку и связывает его с сообщением
+func (st *Storage) saveIncomingMedia(msg IncomingMessage, data []byte) (*MediaAsset, error) {
+	asset, _, err := st.addMedia(data, msg.Media.FileName, msg.Media.MimeType, true)
+	if err != nil {
+		return nil, err
+	}
+	senderJID, err := st.EncryptField(msg.SenderJID)
+	if err != nil {
+		return nil, err
+	}
+	createdAt := msg.Timestamp
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	_, err = st.db.Exec(`INSERT OR REPLACE INTO incoming_media (message_id, media_id, chat_jid, sender_jid, created_at)
+		VALUES (?, ?, ?, ?, ?)`, msg.ID, asset.ID, msg.ChatJID, senderJID, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
+
+// IncomingMedia возвращает файл медиатеки, сохраненный из входящего сообщения с ID messageID
+func (st *Storage) IncomingMedia(messageID string) (*MediaAsset, error) {
+	var mediaID string
+	err := st.db.QueryRow(`SELECT media_id FROM incoming_media WHERE message_id = ?`, messageID).Scan(&mediaID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: '%s'", ErrIncomingMediaNotFound, messageID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return st.GetMedia(mediaID)
+}
+
+// purgeIncomingMedia удаляет сохраненные из входящих сообщений файлы медиатеки, созданные раньше
+// before (нулевое - без срока), и самые старые, не уместившиеся в maxBytes (0 - без ограничения).
+// Файлы, загруженные вручную, не удаляются, даже если совпадают с вложением
+func (st *Storage) purgeIncomingMedia(before time.Time, maxBytes int64) (int, error) {
+	rows, err := st.db.Query(`SELECT id, sha256, size, created_at FROM media WHERE incoming = 1 ORDER BY created_at DESC`)
+	if err != nil {
+		return 0, err
+	}
+	var expired []MediaAsset
+	var total int64
+	for rows.Next() {
+		var asset MediaAsset
+		if err := rows.Scan(&asset.ID, &asset.SHA256, &asset.Size, &asset.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		total += asset.Size
+		if (!before.IsZero() && asset.CreatedAt.Before(before)) || (maxBytes > 0 && total > maxBytes) {
+			expired = append(expired, asset)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, asset := range expired {
+		if err := st.deleteMedia(asset, true); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
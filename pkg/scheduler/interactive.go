@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// InteractiveList - список с выбором одного пункта, открываемый кнопкой
+	InteractiveList = "list"
+	// InteractiveButtons - кнопки быстрого ответа под сообщением
+	InteractiveButtons = "buttons"
+
+	// maxInteractiveButtons - сколько кнопок быстрого ответа показывает WhatsApp
+	maxInteractiveButtons = 3
+	// maxInteractiveRows - сколько пунктов помещается во все разделы списка
+	maxInteractiveRows = 10
+	// maxButtonTextLength - длина подписи кнопки, которую WhatsApp отображает без обрезки
+	maxButtonTextLength = 20
+)
+
+// InteractiveContent - интерактивное содержимое сообщения задачи: список или кнопки быстрого ответа.
+// Текст сообщения задачи становится телом интерактивного сообщения
+type InteractiveContent struct {
+	// Type - list или buttons
+	Type string `json:"type"`
+	// Title - заголовок списка
+	Title string `json:"title,omitempty"`
+	// Footer - подпись мелким шрифтом под сообщением
+	Footer string `json:"footer,omitempty"`
+	// ButtonText - текст кнопки, открывающей список
+	ButtonText string `json:"button_text,omitempty"`
+	// Sections - разделы списка
+	Sections []ListSection `json:"sections,omitempty"`
+	// Buttons - подписи кнопок быстрого ответа
+	Buttons []string `json:"buttons,omitempty"`
+}
+
+// ListSection - раздел списка
+type ListSection struct {
+	Title string    `json:"title,omitempty"`
+	Rows  []ListRow `json:"rows"`
+}
+
+// ListRow - пункт списка, ID возвращается в ответе получателя (по умолчанию номер пункта)
+type ListRow struct {
+	ID          string `json:"id,omitempty"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+// validate проверяет интерактивное содержимое, очищает подписи и проставляет ID пунктов
+func (c *InteractiveContent) validate() error {
+	c.Type = strings.ToLower(strings.TrimSpace(c.Type))
+	c.Title = strings.TrimSpace(c.Title)
+	c.Footer = strings.TrimSpace(c.Footer)
+	c.ButtonText = strings.TrimSpace(c.ButtonText)
+
+	switch c.Type {
+	case InteractiveButtons:
+		if len(c.Sections) > 0 || c.ButtonText != "" {
+			return fmt.Errorf("sections и button_text применяются только к интерактивному списку")
+		}
+		if len(c.Buttons) == 0 || len(c.Buttons) > maxInteractiveButtons {
+			return fmt.Errorf("должно быть от 1 до %d кнопок", maxInteractiveButtons)
+		}
+		for i, button := range c.Buttons {
+			button = strings.TrimSpace(button)
+			if button == "" {
+				return fmt.Errorf("пустая подпись кнопки %d", i+1)
+			}
+			if utf8.RuneCountInString(button) > maxButtonTextLength {
+				return fmt.Errorf("подпись кнопки '%s' длиннее %d символов", button, maxButtonTextLength)
+			}
+			c.Buttons[i] = button
+		}
+	case InteractiveList:
+		if len(c.Buttons) > 0 {
+			return fmt.Errorf("buttons применяются только к кнопкам быстрого ответа")
+		}
+		if c.ButtonText == "" {
+			return fmt.Errorf("для интерактивного списка нужен button_text")
+		}
+		if utf8.RuneCountInString(c.ButtonText) > maxButtonTextLength {
+			return fmt.Errorf("button_text длиннее %d символов", maxButtonTextLength)
+		}
+		if len(c.Sections) == 0 {
+			return fmt.Errorf("в интерактивном списке нет разделов")
+		}
+		rows := 0
+		for i := range c.Sections {
+			section := &c.Sections[i]
+			section.Title = strings.TrimSpace(section.Title)
+			if len(section.Rows) == 0 {
+				return fmt.Errorf("в разделе %d нет пунктов", i+1)
+			}
+			for j := range section.Rows {
+				row := &section.Rows[j]
+				rows++
+				row.Title = strings.TrimSpace(row.Title)
+				row.Description = strings.TrimSpace(row.Description)
+				row.ID = strings.TrimSpace(row.ID)
+				if row.Title == "" {
+					return fmt.Errorf("пустое название пункта %d в разделе %d", j+1, i+1)
+				}
+				if row.ID == "" {
+					row.ID = fmt.Sprint(rows)
+				}
+			}
+		}
+		if rows > maxInteractiveRows {
+			return fmt.Errorf("в интерактивном списке больше %d пунктов", maxInteractiveRows)
+		}
+	default:
+		return fmt.Errorf("неизвестный тип интерактивного сообщения '%s', допустимо: list, buttons", c.Type)
+	}
+	return nil
+}
+
+// FallbackText возвращает текст сообщения с вариантами ответа в виде нумерованного списка
+// для отправки обычным сообщением, если получатель или сервер не принимает интерактивное
+func (c *InteractiveContent) FallbackText(body string) string {
+	var builder strings.Builder
+	if c.Title != "" {
+		builder.WriteString("*" + c.Title + "*\n")
+	}
+	builder.WriteString(body)
+
+	number := 0
+	option := func(title, description string) {
+		number++
+		fmt.Fprintf(&builder, "\n%d. %s", number, title)
+		if description != "" {
+			builder.WriteString(" - " + description)
+		}
+	}
+	builder.WriteString("\n")
+	for _, button := range c.Buttons {
+		option(button, "")
+	}
+	for _, section := range c.Sections {
+		if section.Title != "" {
+			builder.WriteString("\n_" + section.Title + "_")
+		}
+		for _, row := range section.Rows {
+			option(row.Title, row.Description)
+		}
+	}
+
+	if c.Footer != "" {
+		builder.WriteString("\n\n" + c.Footer)
+	}
+	return builder.String()
+}
@@ -0,0 +1,87 @@
+package scheduler
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// languageTag - код языка BCP 47 в нижнем регистре: en, pt-br, zh-hant
+var languageTag = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// normalizeLanguage приводит код языка к нижнему регистру с дефисом (pt_BR -> pt-br)
+func normalizeLanguage(language string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(language), "_", "-"))
+}
+
+// validateLanguages проверяет языки записей списка получателей
+func (l *RecipientList) validateLanguages() error {
+	if len(l.Languages) == 0 {
+		l.Languages = nil
+		return nil
+	}
+	languages := map[string]string{}
+	for entry, language := range l.Languages {
+		entry, language = strings.TrimSpace(entry), normalizeLanguage(language)
+		if !slices.Contains(l.Entries, entry) {
+			return fmt.Errorf("язык указан для записи '%s', которой нет в списке", entry)
+		}
+		if !languageTag.MatchString(language) {
+			return fmt.Errorf("запись '%s': неверный код языка '%s'", entry, language)
+		}
+		languages[entry] = language
+	}
+	l.Languages = languages
+	return nil
+}
+
+// validateTranslations проверяет переводы сообщения задачи. Message остается текстом
+// по умолчанию для получателей без языка или без перевода на их язык
+func (t *ScheduledTask) validateTranslations() error {
+	if len(t.Translations) == 0 {
+		t.Translations = nil
+		return nil
+	}
+	if len(t.RecipientLists) == 0 {
+		return fmt.Errorf("translations применяются только к рассылке по recipient_lists, языки берутся из списков")
+	}
+	if t.Message == "" || t.MessageCommand != "" || len(t.Variants) > 0 {
+		return fmt.Errorf("translations требуют message как текст по умолчанию и не сочетаются с message_command и variants")
+	}
+	translations := map[string]string{}
+	for language, text := range t.Translations {
+		language, text = normalizeLanguage(language), strings.TrimSpace(text)
+		if !languageTag.MatchString(language) {
+			return fmt.Errorf("неверный код языка перевода '%s'", language)
+		}
+		if text == "" {
+			return fmt.Errorf("пустой перевод сообщения на язык '%s'", language)
+		}
+		if !t.SplitLongMessages {
+			if err := ValidateMessageLength(text); err != nil {
+				return fmt.Errorf("перевод '%s': %v", language, err)
+			}
+		}
+		translations[language] = text
+	}
+	t.Translations = translations
+	return nil
+}
+
+// localize возвращает текст сообщения на языке получателя: перевод на язык (pt-br), иначе на основной
+// язык без региона (pt), иначе message - текст по умолчанию
+func (t *ScheduledTask) localize(language, message string) string {
+	if language == "" || len(t.Translations) == 0 {
+		return message
+	}
+	if text, ok := t.Translations[language]; ok {
+		return ApplyFormat(text, t.Format)
+	}
+	if base, _, ok := strings.Cut(language, "-"); ok {
+		if text, ok := t.Translations[base]; ok {
+			return ApplyFormat(text, t.Format)
+		}
+	}
+	return message
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// DefaultLeaderTTL - время жизни блокировки ведущего экземпляра без продления
+	DefaultLeaderTTL = 15 * time.Second
+
+	alertKindLeader = "leader"
+)
+
+// ErrStandby возвращается при отправке с резервного экземпляра
+var ErrStandby = errors.New("экземпляр работает в резервном режиме, сообщения отправляет ведущий")
+
+// LeaderLock - распределенная блокировка, которую держит ведущий экземпляр
+type LeaderLock interface {
+	// Acquire захватывает блокировку или продлевает уже захваченную на ttl.
+	// Возвращает true, если блокировка принадлежит этому экземпляру
+	Acquire(ctx context.Context, ttl time.Duration) (bool, error)
+	// Release освобождает блокировку, если она принадлежит этому экземпляру
+	Release(ctx context.Context) error
+}
+
+// RunLeaderElection включает резервирование: отправлять сообщения будет только экземпляр,
+// удерживающий lock. Блокировка продлевается каждую треть ttl; если ведущий перестал
+// ее продлевать, через ttl ее захватывает резервный экземпляр. Блокирует до отмены ctx
+func (s *Scheduler) RunLeaderElection(ctx context.Context, lock LeaderLock, ttl time.Duration) {
+	s.standby.Store(true)
+	Logger.Info("👥 Резервирование включено, ожидание блокировки ведущего экземпляра")
+
+	for {
+		acquireCtx, cancel := context.WithTimeout(ctx, ttl/3)
+		leader, err := lock.Acquire(acquireCtx, ttl)
+		cancel()
+		if err != nil {
+			// Без связи с хранилищем блокировки нельзя быть уверенным, что другой экземпляр не стал ведущим
+			Logger.Errorf("Ошибка блокировки ведущего экземпляра: %v", err)
+			leader = false
+		}
+
+		if wasStandby := s.standby.Swap(!leader); wasStandby && leader {
+			s.alert(alertKindLeader, "Экземпляр стал ведущим и отправляет сообщения")
+		} else if !wasStandby && !leader {
+			s.alert(alertKindLeader, "Экземпляр потерял блокировку ведущего и перешел в резервный режим")
+		}
+
+		if !sleepContext(ctx, ttl/3) {
+			releaseCtx, cancel := context.WithTimeout(context.Background(), ttl/3)
+			if err := lock.Release(releaseCtx); err != nil {
+				Logger.Errorf("Ошибка освобождения блокировки ведущего экземпляра: %v", err)
+			}
+			cancel()
+			s.standby.Store(true)
+			return
+		}
+	}
+}
+
+// IsLeader проверяет, что экземпляр отправляет сообщения (без резервирования - всегда)
+func (s *Scheduler) IsLeader() bool {
+	return !s.standby.Load()
+}
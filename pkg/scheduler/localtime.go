@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// countryTimezones - часовые пояса стран по коду ISO 3166-1 для колонки часового пояса в списках
+// получателей. Для стран с несколькими поясами берется пояс столицы или самого населенного региона
+var countryTimezones = map[string]string{
+	"AE": "Asia/Dubai",
+	"AR": "America/Argentina/Buenos_Aires",
+	"AT": "Europe/Vienna",
+	"AU": "Australia/Sydney",
+	"BE": "Europe/Brussels",
+	"BR": "America/Sao_Paulo",
+	"BY": "Europe/Minsk",
+	"CA": "America/Toronto",
+	"CH": "Europe/Zurich",
+	"CL": "America/Santiago",
+	"CN": "Asia/Shanghai",
+	"CO": "America/Bogota",
+	"CZ": "Europe/Prague",
+	"DE": "Europe/Berlin",
+	"DK": "Europe/Copenhagen",
+	"EG": "Africa/Cairo",
+	"ES": "Europe/Madrid",
+	"FI": "Europe/Helsinki",
+	"FR": "Europe/Paris",
+	"GB": "Europe/London",
+	"GE": "Asia/Tbilisi",
+	"GR": "Europe/Athens",
+	"ID": "Asia/Jakarta",
+	"IE": "Europe/Dublin",
+	"IL": "Asia/Jerusalem",
+	"IN": "Asia/Kolkata",
+	"IT": "Europe/Rome",
+	"JP": "Asia/Tokyo",
+	"KE": "Africa/Nairobi",
+	"KR": "Asia/Seoul",
+	"KZ": "Asia/Almaty",
+	"MX": "America/Mexico_City",
+	"MY": "Asia/Kuala_Lumpur",
+	"NG": "Africa/Lagos",
+	"NL": "Europe/Amsterdam",
+	"NO": "Europe/Oslo",
+	"NZ": "Pacific/Auckland",
+	"PH": "Asia/Manila",
+	"PK": "Asia/Karachi",
+	"PL": "Europe/Warsaw",
+	"PT": "Europe/Lisbon",
+	"RS": "Europe/Belgrade",
+	"RU": "Europe/Moscow",
+	"SA": "Asia/Riyadh",
+	"SE": "Europe/Stockholm",
+	"SG": "Asia/Singapore",
+	"TH": "Asia/Bangkok",
+	"TR": "Europe/Istanbul",
+	"UA": "Europe/Kyiv",
+	"US": "America/New_York",
+	"UZ": "Asia/Tashkent",
+	"VN": "Asia/Ho_Chi_Minh",
+	"ZA": "Africa/Johannesburg",
+}
+
+// recipientLocation возвращает часовой пояс получателя по имени IANA или коду страны
+func recipientLocation(zone string) (*time.Location, error) {
+	if len(zone) == 2 {
+		name, ok := countryTimezones[strings.ToUpper(zone)]
+		if !ok {
+			return nil, fmt.Errorf("нет часового пояса для страны '%s', укажите пояс IANA", zone)
+		}
+		zone = name
+	}
+	if zone == "" {
+		return nil, fmt.Errorf("пустой часовой пояс")
+	}
+	return loadTimezone(zone)
+}
+
+// validateLocalTime проверяет отправку рассылки по местному времени получателей
+func (t *ScheduledTask) validateLocalTime() error {
+	if t.LocalTime == "" {
+		return nil
+	}
+	if _, err := time.Parse("15:04", t.LocalTime); err != nil {
+		return fmt.Errorf("local_time должен быть в формате ЧЧ:ММ")
+	}
+	if !t.broadcast() {
+		return fmt.Errorf("local_time применяется только к рассылке по recipients, recipient_lists или group_members")
+	}
+	if t.Stagger != nil {
+		return fmt.Errorf("нельзя одновременно указывать local_time и stagger")
+	}
+	// Получатели в разных поясах получают сообщение в течение суток после планового времени
+	if t.Interval < minutesPerDay {
+		return fmt.Errorf("рассылка по местному времени требует интервала не меньше суток")
+	}
+	return nil
+}
+
+// localSendTimes возвращает время отправки каждому получателю рассылки planned: ближайшее LocalTime
+// по часам получателя не раньше planned. info - часовые пояса получателей из списков, остальные
+// получают сообщение по часам задачи. nil - задача без local_time
+func (t *ScheduledTask) localSendTimes(planned time.Time, recipients []string, info map[string]recipientInfo) []time.Time {
+	if t.LocalTime == "" {
+		return nil
+	}
+	at, _ := time.Parse("15:04", t.LocalTime)
+	sendAt := make([]time.Time, len(recipients))
+	for i, recipient := range recipients {
+		location := t.location()
+		if zone := info[recipient].Timezone; zone != "" {
+			if loc, err := recipientLocation(zone); err != nil {
+				Logger.Warnf("Часовой пояс получателя '%s' задачи %s не распознан: %v", recipient, t.ID, err)
+			} else {
+				location = loc
+			}
+		}
+		local := planned.In(location)
+		year, month, day := local.Date()
+		next := time.Date(year, month, day, at.Hour(), at.Minute(), 0, 0, location)
+		if next.Before(planned) {
+			next = time.Date(year, month, day+1, at.Hour(), at.Minute(), 0, 0, location)
+		}
+		sendAt[i] = next
+	}
+	return sendAt
+}
+
+// validateTimezones проверяет часовые пояса записей списка получателей
+func (l *RecipientList) validateTimezones() error {
+	if len(l.Timezones) == 0 {
+		l.Timezones = nil
+		return nil
+	}
+	zones := map[string]string{}
+	for entry, zone := range l.Timezones {
+		entry, zone = strings.TrimSpace(entry), strings.TrimSpace(zone)
+		if !slices.Contains(l.Entries, entry) {
+			return fmt.Errorf("часовой пояс указан для записи '%s', которой нет в списке", entry)
+		}
+		if _, err := recipientLocation(zone); err != nil {
+			return fmt.Errorf("запись '%s': %v", entry, err)
+		}
+		zones[entry] = zone
+	}
+	l.Timezones = zones
+	return nil
+}
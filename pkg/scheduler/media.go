@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// mediaDir - каталог файлов медиатеки
+	mediaDir = "media"
+	// MaxMediaSize - максимальный размер файла медиатеки
+	MaxMediaSize = 64 << 20
+)
+
+// ErrMediaNotFound - файла медиатеки с таким ID нет
+var ErrMediaNotFound = errors.New("файл медиатеки не найден")
+
+// MediaAsset - файл медиатеки
+type MediaAsset struct {
+	ID        string    `json:"id"`
+	SHA256    string    `json:"sha256"`
+	FileName  string    `json:"file_name"`
+	MimeType  string    `json:"mime_type"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+	// Incoming - файл сохранен из входящего сообщения и удаляется по сроку хранения, см. SaveIncomingMedia
+	Incoming bool `json:"incoming,omitempty"`
+}
+
+// Path возвращает путь к файлу на диске
+func (a *MediaAsset) Path() string {
+	return filepath.Join(mediaDir, a.SHA256)
+}
+
+// AddMedia сохраняет файл в медиатеку. Файлы с одинаковым содержимым хранятся один раз,
+// второй параметр сообщает, был ли файл уже в медиатеке
+func (st *Storage) AddMedia(data []byte, fileName, mimeType string) (*MediaAsset, bool, error) {
+	return st.addMedia(data, fileName, mimeType, false)
+}
+
+// addMedia сохраняет файл в медиатеку. Загруженный вручную файл, совпавший с сохраненным
+// из входящего сообщения, больше не удаляется по сроку хранения
+func (st *Storage) addMedia(data []byte, fileName, mimeType string, incoming bool) (*MediaAsset, bool, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	if existing, err := st.GetMedia(hash[:16]); err == nil {
+		if existing.Incoming && !incoming {
+			if _, err := st.db.Exec(`UPDATE media SET incoming = 0 WHERE id = ?`, existing.ID); err != nil {
+				return nil, false, err
+			}
+			existing.Incoming = false
+		}
+		return existing, true, nil
+	}
+
+	asset := &MediaAsset{
+		ID:        hash[:16],
+		SHA256:    hash,
+		FileName:  fileName,
+		MimeType:  mimeType,
+		Size:      int64(len(data)),
+		CreatedAt: time.Now(),
+		Incoming:  incoming,
+	}
+	if err := os.MkdirAll(mediaDir, 0o755); err != nil {
+		return nil, false, fmt.Errorf("ошибка создания каталога медиатеки: %v", err)
+	}
+	if err := os.WriteFile(asset.Path(), data, 0o644); err != nil {
+		return nil, false, fmt.Errorf("ошибка сохранения файла: %v", err)
+	}
+
+	_, err := st.db.Exec(`INSERT INTO media (id, sha256, file_name, mime_type, size, created_at, incoming) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		asset.ID, asset.SHA256, asset.FileName, asset.MimeType, asset.Size, asset.CreatedAt, asset.Incoming)
+	if err != nil {
+		return nil, false, err
+	}
+	return asset, false, nil
+}
+
+// GetMedia возвращает файл медиатеки по идентификатору
+func (st *Storage) GetMedia(id string) (*MediaAsset, error) {
+	asset := &MediaAsset{}
+	err := st.db.QueryRow(`SELECT id, sha256, file_name, mime_type, size, created_at, incoming FROM media WHERE id = ?`, id).
+		Scan(&asset.ID, &asset.SHA256, &asset.FileName, &asset.MimeType, &asset.Size, &asset.CreatedAt, &asset.Incoming)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: '%s'", ErrMediaNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
+
+// ListMedia возвращает файлы медиатеки, новые первыми
+func (st *Storage) ListMedia() ([]MediaAsset, error) {
+	rows, err := st.db.Query(`SELECT id, sha256, file_name, mime_type, size, created_at, incoming FROM media ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assets := []MediaAsset{}
+	for rows.Next() {
+		var asset MediaAsset
+		if err := rows.Scan(&asset.ID, &asset.SHA256, &asset.FileName, &asset.MimeType, &asset.Size, &asset.CreatedAt, &asset.Incoming); err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+	return assets, rows.Err()
+}
+
+// validateMediaURL проверяет адрес вложения, скачиваемого при отправке
+func validateMediaURL(mediaURL string) error {
+	parsed, err := url.Parse(mediaURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("неверный адрес вложения '%s'", mediaURL)
+	}
+	return nil
+}
+
+// DownloadMedia скачивает вложение, возвращая содержимое, MIME тип и имя файла
+func DownloadMedia(ctx context.Context, mediaURL string) ([]byte, string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("ошибка скачивания вложения %s: %v", mediaURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", "", fmt.Errorf("ошибка скачивания вложения %s: статус %d", mediaURL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxMediaSize+1))
+	if err != nil {
+		return nil, "", "", fmt.Errorf("ошибка скачивания вложения %s: %v", mediaURL, err)
+	}
+	if len(data) > MaxMediaSize {
+		return nil, "", "", fmt.Errorf("вложение %s больше %d МБ", mediaURL, MaxMediaSize>>20)
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	fileName := path.Base(resp.Request.URL.Path)
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		fileName = params["filename"]
+	}
+	return data, mimeType, fileName, nil
+}
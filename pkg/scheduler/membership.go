@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// MembershipLogDefaultLimit - количество записей журнала участников группы по умолчанию
+	MembershipLogDefaultLimit = 100
+	// MembershipLogMaxLimit - максимальное количество записей журнала участников группы в ответе
+	MembershipLogMaxLimit = 1000
+)
+
+// Действия в журнале участников группы
+const (
+	MembershipJoined = "joined"
+	MembershipLeft   = "left"
+)
+
+// GroupMembershipChange - изменение состава участников группы, передается транспортом
+type GroupMembershipChange struct {
+	GroupJID string
+	// Joined - вступившие или добавленные участники
+	Joined []string
+	// Left - вышедшие или удаленные участники
+	Left []string
+	// ActorJID - кто изменил состав (администратор, добавивший или удаливший участника), может быть пустым
+	ActorJID  string
+	Timestamp time.Time
+}
+
+// MembershipEvent - запись журнала участников группы
+type MembershipEvent struct {
+	ID        int64     `json:"id"`
+	GroupJID  string    `json:"group_jid"`
+	Action    string    `json:"action"`
+	MemberJID string    `json:"member_jid"`
+	ActorJID  string    `json:"actor_jid,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// isGroupJID проверяет, что jid - JID группы
+func isGroupJID(jid string) bool {
+	return strings.HasSuffix(jid, "@g.us")
+}
+
+// trackPostedGroup запоминает группу, в которую отправлено сообщение, чтобы вести журнал ее участников
+func (s *Scheduler) trackPostedGroup(chatJID string) {
+	if s.storage == nil || !isGroupJID(chatJID) {
+		return
+	}
+	if err := s.storage.markGroupPosted(chatJID, time.Now()); err != nil {
+		Logger.Errorf("Ошибка сохранения группы %s: %v", chatJID, err)
+	}
+}
+
+// HandleGroupMembership сохраняет в журнал изменение состава группы, в которую отправлял планировщик.
+// Вызывается транспортом
+func (s *Scheduler) HandleGroupMembership(change GroupMembershipChange) {
+	if len(change.Joined) == 0 && len(change.Left) == 0 {
+		return
+	}
+	posted, err := s.storage.isGroupPosted(change.GroupJID)
+	if err != nil {
+		Logger.Errorf("Ошибка чтения группы %s: %v", change.GroupJID, err)
+		return
+	}
+	if !posted {
+		return
+	}
+	if change.Timestamp.IsZero() {
+		change.Timestamp = time.Now()
+	}
+	if err := s.storage.addMembershipEvents(change); err != nil {
+		Logger.Errorf("Ошибка сохранения журнала участников группы %s: %v", change.GroupJID, err)
+	}
+}
+
+// markGroupPosted отмечает отправку в группу groupJID в at
+func (st *Storage) markGroupPosted(groupJID string, at time.Time) error {
+	_, err := st.db.Exec(`INSERT INTO posted_groups (group_jid, last_posted_at) VALUES (?, ?)
+		ON CONFLICT (group_jid) DO UPDATE SET last_posted_at = excluded.last_posted_at`, groupJID, at)
+	return err
+}
+
+// isGroupPosted проверяет, что планировщик отправлял сообщения в группу groupJID
+func (st *Storage) isGroupPosted(groupJID string) (bool, error) {
+	var count int
+	err := st.db.QueryRow(`SELECT COUNT(*) FROM posted_groups WHERE group_jid = ?`, groupJID).Scan(&count)
+	return count > 0, err
+}
+
+// addMembershipEvents сохраняет изменение состава группы, по записи на участника
+func (st *Storage) addMembershipEvents(change GroupMembershipChange) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for action, members := range map[string][]string{MembershipJoined: change.Joined, MembershipLeft: change.Left} {
+		for _, member := range members {
+			if _, err := tx.Exec(`INSERT INTO group_membership_log (group_jid, action, member_jid, actor_jid, created_at)
+				VALUES (?, ?, ?, ?, ?)`, change.GroupJID, action, member, change.ActorJID, change.Timestamp); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// GroupMembershipLog возвращает журнал участников группы groupJID в промежутке [from, to],
+// новые записи первыми. Нулевые from и to не ограничивают промежуток
+func (st *Storage) GroupMembershipLog(groupJID string, from, to time.Time, limit int) ([]MembershipEvent, error) {
+	query := `SELECT id, group_jid, action, member_jid, actor_jid, created_at FROM group_membership_log WHERE group_jid = ?`
+	args := []interface{}{groupJID}
+	if !from.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, from)
+	}
+	if !to.IsZero() {
+		query += ` AND created_at <= ?`
+		args = append(args, to)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := st.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []MembershipEvent{}
+	for rows.Next() {
+		var e MembershipEvent
+		if err := rows.Scan(&e.ID, &e.GroupJID, &e.Action, &e.MemberJID, &e.ActorJID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// DeleteMemberMembershipLog удаляет записи журнала участников групп об участнике memberJID
+// и убирает его из записей, где он изменял состав группы
+func (st *Storage) DeleteMemberMembershipLog(memberJID string) (int64, error) {
+	res, err := st.db.Exec(`DELETE FROM group_membership_log WHERE member_jid = ?`, memberJID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := st.db.Exec(`UPDATE group_membership_log SET actor_jid = '' WHERE actor_jid = ?`, memberJID); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
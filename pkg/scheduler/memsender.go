@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SentMessage - сообщение, записанное MemorySender
+type SentMessage struct {
+	OutgoingMessage
+	SentAt time.Time
+}
+
+// MemorySender - MessageSender, который ничего не отправляет, а запоминает сообщения.
+// Используется в тестах и в режиме --mock
+type MemorySender struct {
+	mutex    sync.Mutex
+	messages []SentMessage
+	err      error
+}
+
+// NewMemorySender создает пустой MemorySender
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+// SendMessage записывает сообщение; JID получателя совпадает с названием чата
+func (m *MemorySender) SendMessage(ctx context.Context, msg OutgoingMessage) (SendResult, error) {
+	if err := ctx.Err(); err != nil {
+		return SendResult{}, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.err != nil {
+		return SendResult{}, m.err
+	}
+	sent := SentMessage{OutgoingMessage: msg, SentAt: time.Now()}
+	m.messages = append(m.messages, sent)
+	Logger.Infof("🧪 [mock] Сообщение для чата '%s': %s", msg.ChatName, msg.Message)
+	return SendResult{ChatJID: msg.ChatName, MessageID: msg.ID, Timestamp: sent.SentAt}, nil
+}
+
+// Messages возвращает копию записанных сообщений в порядке отправки
+func (m *MemorySender) Messages() []SentMessage {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]SentMessage(nil), m.messages...)
+}
+
+// Reset очищает записанные сообщения
+func (m *MemorySender) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.messages = nil
+}
+
+// FailWith заставляет последующие отправки возвращать err (nil - отправки снова успешны)
+func (m *MemorySender) FailWith(err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.err = err
+}
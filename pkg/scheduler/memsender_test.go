@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemorySender(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	errOffline := errors.New("offline")
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		failWith error
+		wantErr  error
+		wantSent int
+	}{
+		{name: "records the message", ctx: context.Background(), wantSent: 1},
+		{name: "canceled context", ctx: canceled, wantErr: context.Canceled},
+		{name: "injected error", ctx: context.Background(), failWith: errOffline, wantErr: errOffline},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := NewMemorySender()
+			sender.FailWith(tt.failWith)
+			result, err := sender.SendMessage(tt.ctx, OutgoingMessage{ID: "msg1", ChatName: "Team", Message: "Hello"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SendMessage error = %v, want %v", err, tt.wantErr)
+			}
+			sent := sender.Messages()
+			if len(sent) != tt.wantSent {
+				t.Fatalf("recorded %d messages, want %d", len(sent), tt.wantSent)
+			}
+			if tt.wantSent > 0 && (result.ChatJID != "Team" || result.MessageID != "msg1" || sent[0].Message != "Hello") {
+				t.Errorf("result %+v, recorded %+v", result, sent[0])
+			}
+		})
+	}
+
+	// После FailWith(nil) и Reset отправки снова записываются с чистого листа
+	sender := NewMemorySender()
+	sender.FailWith(errOffline)
+	sender.FailWith(nil)
+	if _, err := sender.SendMessage(context.Background(), OutgoingMessage{ChatName: "Team"}); err != nil || len(sender.Messages()) != 1 {
+		t.Fatalf("SendMessage after FailWith(nil): %v, recorded %d", err, len(sender.Messages()))
+	}
+	sender.Reset()
+	if sent := sender.Messages(); len(sent) != 0 {
+		t.Errorf("Messages after Reset = %+v, want none", sent)
+	}
+}
@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MaxMessageLength - максимальная длина текстового сообщения WhatsApp в символах
+	MaxMessageLength = 65536
+	// defaultSplitDelay - пауза между частями длинного сообщения по умолчанию
+	defaultSplitDelay = 2 * time.Second
+	// splitPartPrefixReserve - место под префикс нумерации "(12/34) "
+	splitPartPrefixReserve = 16
+)
+
+// ValidateMessageLength проверяет, что сообщение помещается в одно сообщение WhatsApp
+func ValidateMessageLength(message string) error {
+	if length := utf8.RuneCountInString(message); length > MaxMessageLength {
+		return fmt.Errorf("сообщение слишком длинное: %d символов при максимуме %d", length, MaxMessageLength)
+	}
+	return nil
+}
+
+// SplitMessage разбивает текст на пронумерованные части не длиннее limit символов,
+// по возможности по границам абзацев, строк и слов
+func SplitMessage(message string, limit int) []string {
+	if utf8.RuneCountInString(message) <= limit {
+		return []string{message}
+	}
+
+	partLimit := limit - splitPartPrefixReserve
+	parts := []string{}
+	rest := []rune(message)
+	for len(rest) > partLimit {
+		cut := partLimit
+		chunk := string(rest[:partLimit])
+		for _, separator := range []string{"\n\n", "\n", " "} {
+			// Не дробим слишком мелко: граница должна быть во второй половине части
+			if i := strings.LastIndex(chunk, separator); i > len(chunk)/2 {
+				cut = utf8.RuneCountInString(chunk[:i])
+				break
+			}
+		}
+		parts = append(parts, strings.TrimSpace(string(rest[:cut])))
+		rest = []rune(strings.TrimLeft(string(rest[cut:]), " \n"))
+	}
+	if len(rest) > 0 {
+		parts = append(parts, string(rest))
+	}
+
+	for i := range parts {
+		parts[i] = fmt.Sprintf("(%d/%d) %s", i+1, len(parts), parts[i])
+	}
+	return parts
+}
+
+// deliverTaskMessage отправляет сообщение задачи, при необходимости разбивая его на части
+func (s *Scheduler) deliverTaskMessage(ctx context.Context, task *ScheduledTask, msg OutgoingMessage) error {
+	if err := ValidateMessageLength(msg.Message); err == nil {
+		return s.Deliver(ctx, msg)
+	} else if !task.SplitLongMessages {
+		return err
+	}
+
+	delay := defaultSplitDelay
+	if task.SplitDelaySeconds > 0 {
+		delay = time.Duration(task.SplitDelaySeconds) * time.Second
+	}
+
+	parts := SplitMessage(msg.Message, MaxMessageLength)
+	Logger.Infof("✂️ Сообщение по задаче %s разбито на %d частей", task.ID, len(parts))
+	for i, part := range parts {
+		if i > 0 {
+			if !s.clock.Sleep(ctx, delay) {
+				return fmt.Errorf("задача остановлена после отправки %d из %d частей", i, len(parts))
+			}
+		}
+
+		msg.Message = part
+		if i > 0 {
+			// Подтверждения отслеживаются по первой части
+			msg.ID = ""
+		}
+		if err := s.Deliver(ctx, msg); err != nil {
+			return fmt.Errorf("ошибка отправки части %d из %d: %v", i+1, len(parts), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	// Части до 40 символов: 24 символа текста и место под префикс нумерации
+	const limit = 40
+	tests := []struct {
+		name    string
+		message string
+		want    []string
+	}{
+		{"short", "Hello", []string{"Hello"}},
+		{"exactly the limit", strings.Repeat("a", limit), []string{strings.Repeat("a", limit)}},
+		{
+			"paragraph boundary",
+			"First paragraph here.\n\nSecond paragraph text.",
+			[]string{"(1/2) First paragraph here.", "(2/2) Second paragraph text."},
+		},
+		{
+			"line boundary",
+			"Line number one\nLine number two\nLine three",
+			[]string{"(1/3) Line number one", "(2/3) Line number two", "(3/3) Line three"},
+		},
+		{
+			"word boundary",
+			"one two three four five six seven eight nine",
+			[]string{"(1/2) one two three four five", "(2/2) six seven eight nine"},
+		},
+		{
+			"multibyte words",
+			strings.TrimSpace(strings.Repeat("слово ", 8)),
+			[]string{"(1/2) слово слово слово слово", "(2/2) слово слово слово слово"},
+		},
+		{
+			"no separators",
+			strings.Repeat("x", 50),
+			[]string{"(1/3) " + strings.Repeat("x", 24), "(2/3) " + strings.Repeat("x", 24), "(3/3) xx"},
+		},
+		{
+			// Пробел в начале части не дробит текст на слишком мелкие куски
+			"separator too early",
+			"ab abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMN",
+			[]string{"(1/2) ab abcdefghijklmnopqrstu", "(2/2) vwxyzABCDEFGHIJKLMN"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitMessage(tt.message, limit)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("SplitMessage() = %q, want %q", got, tt.want)
+			}
+			for _, part := range got {
+				if length := utf8.RuneCountInString(part); length > limit {
+					t.Errorf("part %q has %d characters, limit %d", part, length, limit)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMessageLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		wantErr bool
+	}{
+		{"empty", "", false},
+		{"at the limit", strings.Repeat("a", MaxMessageLength), false},
+		{"multibyte at the limit", strings.Repeat("я", MaxMessageLength), false},
+		{"over the limit", strings.Repeat("a", MaxMessageLength+1), true},
+	}
+	for _, tt := range tests {
+		if err := ValidateMessageLength(tt.message); (err != nil) != tt.wantErr {
+			t.Errorf("%s: ValidateMessageLength() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
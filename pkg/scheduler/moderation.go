@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// alertKindModeration - сообщение не прошло проверку перед отправкой
+	alertKindModeration = "moderation"
+	// moderationTimeout - максимальное время ответа сервиса модерации
+	moderationTimeout = 10 * time.Second
+)
+
+// ErrModerationRejected - сообщение содержит запрещенную фразу или отклонено сервисом модерации
+var ErrModerationRejected = errors.New("сообщение отклонено модерацией")
+
+// moderationRequest - тело запроса к сервису модерации (Settings.ModerationURL)
+type moderationRequest struct {
+	TaskID   string `json:"task_id,omitempty"`
+	ChatName string `json:"chat_name"`
+	Message  string `json:"message"`
+}
+
+// moderationResponse - ответ сервиса модерации
+type moderationResponse struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// validateModerationURL проверяет адрес сервиса модерации
+func validateModerationURL(value string) error {
+	if value == "" {
+		return nil
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("неверный moderation_url '%s'", value)
+	}
+	return nil
+}
+
+// moderationText возвращает весь текст, который увидит получатель: сообщение, варианты ответа
+// интерактивного сообщения и опроса
+func moderationText(msg OutgoingMessage) string {
+	text := msg.Message
+	if msg.Interactive != nil {
+		text = msg.Interactive.FallbackText(text)
+	}
+	if msg.Poll != nil {
+		text += "\n" + strings.Join(msg.Poll.Options, "\n")
+	}
+	return text
+}
+
+// moderate проверяет сообщение перед отправкой по списку запрещенных фраз и сервисом модерации.
+// Возвращает ошибку с ErrModerationRejected, если сообщение отправлять нельзя
+func (s *Scheduler) moderate(ctx context.Context, msg OutgoingMessage) error {
+	settings := s.Settings()
+	if len(settings.ModerationBlocklist) == 0 && settings.ModerationURL == "" {
+		return nil
+	}
+	text := moderationText(msg)
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+
+	lower := strings.ToLower(text)
+	for _, phrase := range settings.ModerationBlocklist {
+		if strings.Contains(lower, phrase) {
+			return fmt.Errorf("%w: запрещенная фраза '%s'", ErrModerationRejected, phrase)
+		}
+	}
+
+	if settings.ModerationURL == "" {
+		return nil
+	}
+	verdict, err := checkModeration(ctx, settings.ModerationURL, moderationRequest{
+		TaskID:   msg.TaskID,
+		ChatName: msg.ChatName,
+		Message:  text,
+	})
+	if err != nil {
+		if settings.ModerationFailOpen {
+			Logger.Warnf("Сервис модерации недоступен, сообщение в чат '%s' отправляется без проверки: %v", msg.ChatName, err)
+			return nil
+		}
+		return fmt.Errorf("%w: сервис модерации недоступен: %v", ErrModerationRejected, err)
+	}
+	if !verdict.Allowed {
+		if verdict.Reason == "" {
+			return ErrModerationRejected
+		}
+		return fmt.Errorf("%w: %s", ErrModerationRejected, verdict.Reason)
+	}
+	return nil
+}
+
+// checkModeration отправляет сообщение на проверку сервису модерации
+func checkModeration(ctx context.Context, endpoint string, request moderationRequest) (*moderationResponse, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, moderationTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("статус ответа %d", resp.StatusCode)
+	}
+	verdict := &moderationResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(verdict); err != nil {
+		return nil, fmt.Errorf("неверный ответ: %v", err)
+	}
+	return verdict, nil
+}
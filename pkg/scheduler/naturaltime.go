@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/olebedev/when"
+	"github.com/olebedev/when/rules/common"
+	"github.com/olebedev/when/rules/en"
+	"github.com/olebedev/when/rules/ru"
+)
+
+// naturalTimeParser разбирает выражения вида "in 2 hours", "tomorrow 9am", "завтра в 10:00"
+var naturalTimeParser = func() *when.Parser {
+	parser := when.New(nil)
+	parser.Add(en.All...)
+	parser.Add(ru.All...)
+	parser.Add(common.All...)
+	return parser
+}()
+
+// loadTimezone возвращает часовой пояс по имени IANA, пустое имя означает локальное время
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("неизвестный часовой пояс '%s': %v", name, err)
+	}
+	return location, nil
+}
+
+// parseNaturalTimeFrom вычисляет момент времени из выражения на естественном языке относительно base.
+// Время суток ("next monday 09:00") считается по часам часового пояса base, поэтому
+// переход на летнее время между base и результатом учитывается
+func parseNaturalTimeFrom(text string, base time.Time) (time.Time, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return time.Time{}, fmt.Errorf("пустое выражение времени")
+	}
+
+	// Доли секунды base иначе переходят в результат ("09:00:00.338")
+	result, err := naturalTimeParser.Parse(text, base.Truncate(time.Second))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ошибка разбора времени '%s': %v", text, err)
+	}
+	if result == nil {
+		return time.Time{}, fmt.Errorf("не удалось распознать время '%s'", text)
+	}
+	if !result.Time.After(base) {
+		return time.Time{}, fmt.Errorf("время '%s' (%s) уже прошло", text, result.Time.Format("15:04:05 02.01.2006"))
+	}
+	return result.Time, nil
+}
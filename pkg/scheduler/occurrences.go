@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"time"
+)
+
+const (
+	// OccurrencesDefaultRange - промежуток предпросмотра отправок по умолчанию
+	OccurrencesDefaultRange = 7 * 24 * time.Hour
+	// OccurrencesMaxRange - максимальный промежуток предпросмотра отправок
+	OccurrencesMaxRange = 366 * 24 * time.Hour
+	// occurrencesMaxResults ограничивает ответ для задач с маленьким интервалом
+	occurrencesMaxResults = 1000
+)
+
+// Причины, по которым плановая отправка не состоится
+const (
+	OccurrenceSkippedExcluded   = "excluded"
+	OccurrenceSkippedQuietHours = "quiet_hours"
+	OccurrenceSkippedPaused     = "paused"
+)
+
+// Occurrence - плановая отправка задачи
+type Occurrence struct {
+	Planned time.Time `json:"planned"`
+	// WindowStart и WindowEnd - промежуток, в котором случайная задержка выберет время отправки
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+	// Skipped - причина пропуска отправки: исключенная дата или праздник, тихие часы, пауза задачи
+	Skipped string `json:"skipped,omitempty"`
+	// PartlyQuiet - часть окна отправки попадает в тихие часы, отправка может быть пропущена
+	PartlyQuiet bool `json:"partly_quiet,omitempty"`
+}
+
+// OccurrencePreview - плановые отправки задачи в промежутке [From, To]
+type OccurrencePreview struct {
+	TaskID      string       `json:"task_id"`
+	From        time.Time    `json:"from"`
+	To          time.Time    `json:"to"`
+	Occurrences []Occurrence `json:"occurrences"`
+	// Truncated - в промежутке больше occurrencesMaxResults отправок, возвращены первые
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// TaskOccurrences вычисляет плановые отправки активной задачи в промежутке [from, to] по тем же правилам,
+// что и цикл задачи: интервал или правило повторения, окно случайной задержки, исключения дат, тихие часы
+// и время окончания. Пропускаемые отправки возвращаются с причиной. false - задача не найдена
+func (s *Scheduler) TaskOccurrences(taskID string, from, to time.Time) (*OccurrencePreview, bool) {
+	s.mutex.RLock()
+	task, exists := s.tasks[taskID]
+	paused := exists && task.Paused
+	s.mutex.RUnlock()
+	if !exists {
+		return nil, false
+	}
+
+	preview := &OccurrencePreview{TaskID: taskID, From: from, To: to, Occurrences: []Occurrence{}}
+	if task.EndTime.Before(to) {
+		to = task.EndTime
+	}
+	if task.Interval <= 0 {
+		return preview, true
+	}
+
+	for next := task.plannedOccurrence(from.Add(-time.Nanosecond)); !next.After(to); next = task.plannedOccurrence(next) {
+		if len(preview.Occurrences) == occurrencesMaxResults {
+			preview.Truncated = true
+			break
+		}
+
+		start, end := task.sendWindow(next)
+		occurrence := Occurrence{Planned: next, WindowStart: start, WindowEnd: end}
+		// Группа меняется и в тихие часы
+		startQuiet := !task.groupAction() && s.inQuietHours(start)
+		endQuiet := !task.groupAction() && s.inQuietHours(end)
+		switch {
+		case task.isExcluded(next):
+			occurrence.Skipped = OccurrenceSkippedExcluded
+		case paused:
+			occurrence.Skipped = OccurrenceSkippedPaused
+		case startQuiet && endQuiet:
+			occurrence.Skipped = OccurrenceSkippedQuietHours
+		case startQuiet || endQuiet:
+			occurrence.PartlyQuiet = true
+		}
+		preview.Occurrences = append(preview.Occurrences, occurrence)
+	}
+	return preview, true
+}
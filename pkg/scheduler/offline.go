@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTaskExists - в хранилище уже есть задача, а замена не запрошена
+var ErrTaskExists = errors.New("уже есть активная задача")
+
+// offlineSender - транспорт планировщика без подключения к WhatsApp (OpenOffline): задачи только
+// сохраняются в хранилище, отправляет их запущенный сервер
+type offlineSender struct{}
+
+// SendMessage всегда возвращает ErrDisconnected
+func (offlineSender) SendMessage(context.Context, OutgoingMessage) (SendResult, error) {
+	return SendResult{}, ErrDisconnected
+}
+
+// OpenOffline открывает планировщик над хранилищем без подключения к WhatsApp, чтобы управлять
+// сохраненными задачами при остановленном сервере (CLI). Задачи не запускаются: их запустит
+// RestoreTasks при следующем старте сервера
+func OpenOffline(storage *Storage) (*Scheduler, error) {
+	return NewScheduler(storage, offlineSender{}, DefaultConfig())
+}
+
+// StoredTasks возвращает задачи из хранилища, не запуская их
+func (s *Scheduler) StoredTasks() ([]*ScheduledTask, error) {
+	tasks, _, err := s.storage.loadTasks()
+	return tasks, err
+}
+
+// AddStoredTask проверяет задачу так же, как AddTask, и сохраняет ее в хранилище без запуска.
+// Без replace при сохраненной задаче возвращает ErrTaskExists, с replace - заменяет ее.
+// Названия чатов не проверяются: транспорт найдет их при отправке
+func (s *Scheduler) AddStoredTask(task *ScheduledTask, replace bool) (string, error) {
+	stored, err := s.StoredTasks()
+	if err != nil {
+		return "", err
+	}
+	if len(stored) > 0 && !replace {
+		return "", fmt.Errorf("%w: %s", ErrTaskExists, stored[len(stored)-1].ID)
+	}
+
+	confirmRecipients := s.Settings().ConfirmRecipients
+	var recipients []string
+	var duplicates int
+	if confirmRecipients > 0 && task.broadcast() {
+		recipients, duplicates = s.countRecipients(task)
+	}
+	if err := s.prepareTask(task, recipients, duplicates, confirmRecipients); err != nil {
+		return "", err
+	}
+
+	// Сервер восстанавливает одну задачу, поэтому заменяемые удаляются
+	for _, existing := range stored {
+		if err := s.storage.DeleteTask(existing.ID); err != nil {
+			return "", err
+		}
+		Logger.Infof("🔄 Сохраненная задача %s удалена для замены новой", existing.ID)
+	}
+	s.mutex.Lock()
+	task.ID = s.newTaskID()
+	s.mutex.Unlock()
+	if err := s.storage.SaveTask(task); err != nil {
+		return "", err
+	}
+	Logger.Infof("💾 Задача %s для чата '%s' сохранена и начнет выполняться после запуска сервера", task.ID, task.ChatName)
+	return task.ID, nil
+}
+
+// StopStoredTask удаляет задачу из хранилища или возвращает ErrTaskNotFound
+func (s *Scheduler) StopStoredTask(id string) error {
+	stored, err := s.StoredTasks()
+	if err != nil {
+		return err
+	}
+	for _, task := range stored {
+		if task.ID == id {
+			return s.storage.DeleteTask(id)
+		}
+	}
+	return fmt.Errorf("%w: '%s'", ErrTaskNotFound, id)
+}
@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoredTasksOffline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.db")
+	storage, err := OpenStorage(path)
+	if err != nil {
+		t.Fatalf("OpenStorage: %v", err)
+	}
+	t.Cleanup(func() { storage.db.Close() })
+	s, err := OpenOffline(storage)
+	if err != nil {
+		t.Fatalf("OpenOffline: %v", err)
+	}
+	start := time.Now().Add(24 * time.Hour)
+	newTask := func(message string) *ScheduledTask {
+		return NewTaskFromRequest(&ScheduledTask{
+			ChatName:  "Team",
+			Message:   message,
+			Interval:  30,
+			StartTime: start,
+			EndTime:   start.Add(time.Hour),
+		})
+	}
+
+	first, err := s.AddStoredTask(newTask("first"), false)
+	if err != nil {
+		t.Fatalf("AddStoredTask: %v", err)
+	}
+	if _, err := s.AddStoredTask(newTask("second"), false); !errors.Is(err, ErrTaskExists) {
+		t.Errorf("AddStoredTask without replace: error %v, want ErrTaskExists", err)
+	}
+	if _, err := s.AddStoredTask(newTask(""), true); err == nil {
+		t.Errorf("AddStoredTask accepted an empty message")
+	}
+	second, err := s.AddStoredTask(newTask("second"), true)
+	if err != nil {
+		t.Fatalf("AddStoredTask with replace: %v", err)
+	}
+
+	stored, err := s.StoredTasks()
+	if err != nil {
+		t.Fatalf("StoredTasks: %v", err)
+	}
+	if len(stored) != 1 || stored[0].ID != second || stored[0].Message != "second" {
+		t.Fatalf("stored %+v, want only %s", stored, second)
+	}
+	if len(s.ListTasks()) != 0 {
+		t.Errorf("offline scheduler started a task")
+	}
+	if err := s.StopStoredTask(first); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("StopStoredTask(replaced): error %v, want ErrTaskNotFound", err)
+	}
+
+	// Сервер запускает сохраненную задачу при старте
+	server, err := NewScheduler(storage, NewMemorySender(), Config{})
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	if err := server.RestoreTasks(); err != nil {
+		t.Fatalf("RestoreTasks: %v", err)
+	}
+	if tasks := server.ListTasks(); len(tasks) != 1 || tasks[0].ID != second {
+		t.Fatalf("restored %d tasks, want %s", len(tasks), second)
+	}
+	server.StopTask(second)
+
+	if _, err := s.AddStoredTask(newTask("third"), false); err != nil {
+		t.Fatalf("AddStoredTask after stop: %v", err)
+	}
+	stored, _ = s.StoredTasks()
+	if err := s.StopStoredTask(stored[0].ID); err != nil {
+		t.Fatalf("StopStoredTask: %v", err)
+	}
+	if stored, _ := s.StoredTasks(); len(stored) != 0 {
+		t.Errorf("%d tasks left after StopStoredTask", len(stored))
+	}
+}
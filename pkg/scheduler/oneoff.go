@@ -0,0 +1,205 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrScheduledSendNotFound - отложенной разовой отправки с таким ID нет
+var ErrScheduledSendNotFound = errors.New("отложенная отправка не найдена")
+
+// oneOffRetryDelay - через сколько повторяется разовая отправка, отклоненная по временной причине
+// (резервный экземпляр, режим только для чтения, нет подключения)
+const oneOffRetryDelay = time.Minute
+
+// ScheduledSend - отложенная разовая отправка (POST /send с scheduled_at, when или delay_seconds).
+// Хранится до отправки, чтобы пережить перезапуск процесса
+type ScheduledSend struct {
+	ID        string    `json:"id"`
+	ChatName  string    `json:"chat_name"`
+	MessageID string    `json:"message_id,omitempty"`
+	SendAt    time.Time `json:"send_at"`
+	CreatedAt time.Time `json:"created_at"`
+	msg       OutgoingMessage
+}
+
+// oneOffSends - ожидающие отложенные разовые отправки. ctx отменяется при Shutdown,
+// cancels - отмена ожидания каждой отправки по ID
+type oneOffSends struct {
+	mutex   sync.Mutex
+	ctx     context.Context
+	stop    context.CancelFunc
+	cancels map[string]context.CancelFunc
+}
+
+// newOneOffSends создает состояние отложенных разовых отправок
+func newOneOffSends() oneOffSends {
+	ctx, stop := context.WithCancel(context.Background())
+	return oneOffSends{ctx: ctx, stop: stop, cancels: map[string]context.CancelFunc{}}
+}
+
+// ScheduleOneOff сохраняет разовую отправку в хранилище и откладывает ее до указанного времени.
+// Возвращает ID отправки для GET /send/scheduled и отмены
+func (s *Scheduler) ScheduleOneOff(msg OutgoingMessage, at time.Time) (string, error) {
+	if s.IsShuttingDown() {
+		return "", ErrShuttingDown
+	}
+	send := &ScheduledSend{
+		ID:        fmt.Sprintf("send_%d", time.Now().UnixNano()),
+		ChatName:  msg.ChatName,
+		MessageID: msg.ID,
+		SendAt:    at,
+		CreatedAt: s.clock.Now(),
+		msg:       msg,
+	}
+	if err := s.storage.saveScheduledSend(send); err != nil {
+		return "", fmt.Errorf("ошибка сохранения отложенной отправки: %v", err)
+	}
+	Logger.Infof("🕑 Разовая отправка %s в чат '%s' запланирована на %s",
+		send.ID, msg.ChatName, at.Local().Format("15:04:05 02.01.2006"))
+	s.startOneOff(send)
+	return send.ID, nil
+}
+
+// startOneOff запускает ожидание отложенной отправки
+func (s *Scheduler) startOneOff(send *ScheduledSend) {
+	s.oneOffs.mutex.Lock()
+	ctx, cancel := context.WithCancel(s.oneOffs.ctx)
+	s.oneOffs.cancels[send.ID] = cancel
+	s.oneOffs.mutex.Unlock()
+
+	go s.runOneOff(ctx, send)
+}
+
+// runOneOff дожидается времени отправки и отправляет сообщение. Отправка, прерванная остановкой
+// процесса, остается в хранилище и возобновится после перезапуска. Отклоненная по временной причине
+// тоже остается в хранилище и повторяется через oneOffRetryDelay (после исчерпания квоты - в начале
+// следующих суток); удаляется только доставленная или не доставленная окончательно
+func (s *Scheduler) runOneOff(ctx context.Context, send *ScheduledSend) {
+	slept := s.clock.Sleep(ctx, send.SendAt.Sub(s.clock.Now()))
+
+	// После начала отправки ее уже нельзя отменить
+	s.oneOffs.mutex.Lock()
+	cancel, waiting := s.oneOffs.cancels[send.ID]
+	delete(s.oneOffs.cancels, send.ID)
+	s.oneOffs.mutex.Unlock()
+	if !waiting {
+		return
+	}
+	cancel()
+	if !slept {
+		return
+	}
+	// Начатая отправка не прерывается: Shutdown дожидается ее завершения
+	err := s.Deliver(context.WithoutCancel(ctx), send.msg)
+	if errors.Is(err, ErrShuttingDown) {
+		return
+	}
+	if errors.Is(err, ErrStandby) || errors.Is(err, ErrReadOnly) || errors.Is(err, ErrLoggedOut) || IsRetryable(err) {
+		retry := *send
+		retry.SendAt = s.clock.Now().Add(oneOffRetryDelay)
+		if errors.Is(err, ErrQuotaExceeded) {
+			// Квота восстановится только в начале следующих суток
+			retry.SendAt = s.Quota(send.msg.Owner).Reset
+		}
+		Logger.Warnf("⏳ Разовая отправка %s в чат '%s' будет повторена в %s: %v",
+			send.ID, send.ChatName, retry.SendAt.Local().Format("15:04:05 02.01.2006"), err)
+		s.startOneOff(&retry)
+		return
+	}
+	if err != nil {
+		Logger.Errorf("❌ Ошибка разовой отправки %s в чат '%s': %v", send.ID, send.ChatName, err)
+	}
+	if err := s.storage.deleteScheduledSend(send.ID); err != nil {
+		Logger.Errorf("Ошибка удаления отложенной отправки %s из хранилища: %v", send.ID, err)
+	}
+}
+
+// ScheduledSends возвращает ожидающие отложенные разовые отправки, ближайшие первыми
+func (s *Scheduler) ScheduledSends() ([]*ScheduledSend, error) {
+	return s.storage.loadScheduledSends()
+}
+
+// CancelScheduledSend отменяет отложенную разовую отправку или возвращает ErrScheduledSendNotFound
+func (s *Scheduler) CancelScheduledSend(id string) error {
+	s.oneOffs.mutex.Lock()
+	cancel, waiting := s.oneOffs.cancels[id]
+	delete(s.oneOffs.cancels, id)
+	s.oneOffs.mutex.Unlock()
+	if !waiting {
+		return fmt.Errorf("%w: '%s'", ErrScheduledSendNotFound, id)
+	}
+	cancel()
+
+	if err := s.storage.deleteScheduledSend(id); err != nil {
+		return fmt.Errorf("ошибка удаления отложенной отправки: %v", err)
+	}
+	Logger.Infof("⏹️ Отложенная отправка %s отменена", id)
+	return nil
+}
+
+// restoreScheduledSends возобновляет отложенные отправки, сохраненные до перезапуска.
+// Пропущенные за время остановки отправляются сразу
+func (s *Scheduler) restoreScheduledSends() error {
+	sends, err := s.storage.loadScheduledSends()
+	if err != nil {
+		return err
+	}
+	for _, send := range sends {
+		Logger.Infof("♻️ Восстановлена отложенная отправка %s в чат '%s'", send.ID, send.ChatName)
+		s.startOneOff(send)
+	}
+	return nil
+}
+
+// saveScheduledSend сохраняет отложенную разовую отправку
+func (st *Storage) saveScheduledSend(send *ScheduledSend) error {
+	data, err := json.Marshal(send.msg)
+	if err != nil {
+		return err
+	}
+	sealed, err := st.EncryptField(string(data))
+	if err != nil {
+		return err
+	}
+	_, err = st.db.Exec(`INSERT INTO scheduled_sends (id, data, send_at, created_at) VALUES (?, ?, ?, ?)`,
+		send.ID, sealed, send.SendAt, send.CreatedAt)
+	return err
+}
+
+// loadScheduledSends загружает отложенные разовые отправки, ближайшие первыми
+func (st *Storage) loadScheduledSends() ([]*ScheduledSend, error) {
+	rows, err := st.db.Query(`SELECT id, data, send_at, created_at FROM scheduled_sends ORDER BY send_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sends := []*ScheduledSend{}
+	for rows.Next() {
+		send := &ScheduledSend{}
+		var data string
+		if err := rows.Scan(&send.ID, &data, &send.SendAt, &send.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := st.decryptFields(&data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &send.msg); err != nil {
+			return nil, err
+		}
+		send.ChatName, send.MessageID = send.msg.ChatName, send.msg.ID
+		sends = append(sends, send)
+	}
+	return sends, rows.Err()
+}
+
+// deleteScheduledSend удаляет отложенную разовую отправку
+func (st *Storage) deleteScheduledSend(id string) error {
+	_, err := st.db.Exec(`DELETE FROM scheduled_sends WHERE id = ?`, id)
+	return err
+}
@@ -0,0 +1,28 @@
+package scheduler
+
+// OwnedBy проверяет, что задача принадлежит пользователю owner. Пустой owner - любой владелец
+func (t *ScheduledTask) OwnedBy(owner string) bool {
+	return owner == "" || t.Owner == owner
+}
+
+// TasksOwnedBy возвращает копии активных задач пользователя owner (пусто - все задачи)
+func (s *Scheduler) TasksOwnedBy(owner string) []*ScheduledTask {
+	tasks := s.ListTasks()
+	owned := make([]*ScheduledTask, 0, len(tasks))
+	for _, task := range tasks {
+		if task.OwnedBy(owner) {
+			owned = append(owned, task)
+		}
+	}
+	return owned
+}
+
+// TaskOwnedBy проверяет, что активная задача id есть и принадлежит пользователю owner
+// (пусто - любому)
+func (s *Scheduler) TaskOwnedBy(id, owner string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	task, exists := s.tasks[id]
+	return exists && task.OwnedBy(owner)
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskOwnership(t *testing.T) {
+	s, _ := newTestScheduler(t)
+	start := time.Now().Add(24 * time.Hour)
+	request := &ScheduledTask{
+		ChatName:  "Team",
+		Message:   "Standup",
+		Interval:  60,
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+		Owner:     "mallory",
+	}
+	task := NewTaskFromRequest(request)
+	if task.Owner != "" {
+		t.Fatalf("NewTaskFromRequest kept owner %q from the request", task.Owner)
+	}
+	task.Owner = "alice"
+	id, err := s.AddTask(task)
+	if err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+
+	tests := []struct {
+		owner   string
+		want    bool
+		listLen int
+	}{
+		{"", true, 1},
+		{"alice", true, 1},
+		{"bob", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run("owner="+tt.owner, func(t *testing.T) {
+			if got := s.TaskOwnedBy(id, tt.owner); got != tt.want {
+				t.Errorf("TaskOwnedBy(%s) = %v, want %v", tt.owner, got, tt.want)
+			}
+			if got := s.TasksOwnedBy(tt.owner); len(got) != tt.listLen {
+				t.Errorf("TasksOwnedBy(%s) = %d tasks, want %d", tt.owner, len(got), tt.listLen)
+			}
+		})
+	}
+	if s.TaskOwnedBy("task_missing", "") {
+		t.Error("TaskOwnedBy(missing) = true")
+	}
+
+	// Владелец сохраняется вместе с задачей и восстанавливается после перезапуска
+	tasks, _, err := s.storage.loadTasks()
+	if err != nil || len(tasks) != 1 || tasks[0].Owner != "alice" {
+		t.Fatalf("loadTasks = %+v, %v; want the owner alice", tasks, err)
+	}
+}
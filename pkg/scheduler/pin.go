@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MessagePinner - необязательная возможность транспорта: закрепление сообщений в чате
+// для задач с Pin
+type MessagePinner interface {
+	// PinMessage закрепляет отправленное сообщение messageID в чате на duration
+	PinMessage(ctx context.Context, chatName, chatJID, messageID string, duration time.Duration) error
+	// UnpinMessage открепляет ранее закрепленное сообщение
+	UnpinMessage(ctx context.Context, chatName, chatJID, messageID string) error
+}
+
+// pinDurations - сроки закрепления, доступные в WhatsApp
+var pinDurations = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// ParsePinDuration разбирает срок закрепления сообщения: 24h, 7d, 30d
+func ParsePinDuration(value string) (time.Duration, error) {
+	duration, ok := pinDurations[value]
+	if !ok {
+		return 0, fmt.Errorf("неверный срок закрепления '%s', допустимо: 24h, 7d, 30d", value)
+	}
+	return duration, nil
+}
+
+// pinnedMessage возвращает сообщение, закрепленное задачей в чате, или пустую строку
+func (st *Storage) pinnedMessage(taskID, chatName string) (string, error) {
+	var messageID string
+	err := st.db.QueryRow(`SELECT message_id FROM task_pins WHERE task_id = ? AND chat_name = ?`,
+		taskID, chatName).Scan(&messageID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return messageID, err
+}
+
+// setPinnedMessage запоминает сообщение, закрепленное задачей в чате
+func (st *Storage) setPinnedMessage(taskID, chatName, messageID string) error {
+	_, err := st.db.Exec(`INSERT OR REPLACE INTO task_pins (task_id, chat_name, message_id) VALUES (?, ?, ?)`,
+		taskID, chatName, messageID)
+	return err
+}
+
+// rotatePin закрепляет только что отправленное сообщение задачи и открепляет предыдущее,
+// чтобы закрепленным всегда оставалось последнее сообщение. Ошибки закрепления не влияют на отправку
+func (s *Scheduler) rotatePin(task *ScheduledTask, msg OutgoingMessage) {
+	pinner, ok := s.sender.(MessagePinner)
+	if !ok || msg.ID == "" {
+		Logger.Warnf("📌 Транспорт не поддерживает закрепление сообщений, задача %s", task.ID)
+		return
+	}
+	duration, err := ParsePinDuration(task.Pin)
+	if err != nil {
+		return
+	}
+
+	previous, err := s.storage.pinnedMessage(task.ID, msg.ChatName)
+	if err != nil {
+		Logger.Errorf("Ошибка чтения закрепленного сообщения задачи %s: %v", task.ID, err)
+	}
+	if err := pinner.PinMessage(task.ctx, msg.ChatName, msg.ChatJID, msg.ID, duration); err != nil {
+		Logger.Errorf("📌 Не удалось закрепить сообщение задачи %s в чате '%s': %v", task.ID, msg.ChatName, err)
+		return
+	}
+	if err := s.storage.setPinnedMessage(task.ID, msg.ChatName, msg.ID); err != nil {
+		Logger.Errorf("Ошибка сохранения закрепленного сообщения задачи %s: %v", task.ID, err)
+	}
+	if previous != "" && previous != msg.ID {
+		if err := pinner.UnpinMessage(task.ctx, msg.ChatName, msg.ChatJID, previous); err != nil {
+			Logger.Warnf("📌 Не удалось открепить предыдущее сообщение задачи %s в чате '%s': %v",
+				task.ID, msg.ChatName, err)
+		}
+	}
+	Logger.Infof("📌 Сообщение задачи %s закреплено в чате '%s'", task.ID, msg.ChatName)
+}
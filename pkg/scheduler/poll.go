@@ -0,0 +1,211 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxPollOptions - сколько вариантов ответа допускает опрос WhatsApp
+const maxPollOptions = 12
+
+// ErrPollNotFound - опрос с таким ID сообщения не отправлялся планировщиком
+var ErrPollNotFound = errors.New("опрос не найден")
+
+// PollContent - опрос, отправляемый задачей. Текст сообщения задачи становится вопросом опроса
+type PollContent struct {
+	Options []string `json:"options"`
+	// SelectableCount - сколько вариантов можно выбрать (0 - любое количество)
+	SelectableCount int `json:"selectable_count,omitempty"`
+}
+
+// validate проверяет варианты ответа опроса и очищает их
+func (p *PollContent) validate() error {
+	if len(p.Options) < 2 || len(p.Options) > maxPollOptions {
+		return fmt.Errorf("в опросе должно быть от 2 до %d вариантов ответа", maxPollOptions)
+	}
+	seen := map[string]bool{}
+	for i, option := range p.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return fmt.Errorf("пустой вариант ответа %d", i+1)
+		}
+		if seen[option] {
+			return fmt.Errorf("вариант ответа '%s' указан несколько раз", option)
+		}
+		seen[option] = true
+		p.Options[i] = option
+	}
+	if p.SelectableCount < 0 || p.SelectableCount > len(p.Options) {
+		return fmt.Errorf("selectable_count должен быть в диапазоне 0..%d", len(p.Options))
+	}
+	return nil
+}
+
+// Poll - опрос, отправленный планировщиком, голоса по которому собираются транспортом
+type Poll struct {
+	MessageID string    `json:"message_id"`
+	TaskID    string    `json:"task_id,omitempty"`
+	ChatJID   string    `json:"chat_jid"`
+	Question  string    `json:"question"`
+	Options   []string  `json:"options"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PollOptionResult - голоса за вариант ответа
+type PollOptionResult struct {
+	Option string   `json:"option"`
+	Votes  int      `json:"votes"`
+	Voters []string `json:"voters"`
+}
+
+// PollResults - итоги опроса по последнему голосу каждого участника
+type PollResults struct {
+	Poll
+	TotalVoters int                `json:"total_voters"`
+	Results     []PollOptionResult `json:"results"`
+}
+
+// SavePoll запоминает отправленный опрос для сбора голосов
+func (st *Storage) SavePoll(poll Poll) error {
+	options, err := json.Marshal(poll.Options)
+	if err != nil {
+		return err
+	}
+	question, err := st.EncryptField(poll.Question)
+	if err != nil {
+		return err
+	}
+	encryptedOptions, err := st.EncryptField(string(options))
+	if err != nil {
+		return err
+	}
+	if poll.CreatedAt.IsZero() {
+		poll.CreatedAt = time.Now()
+	}
+	_, err = st.db.Exec(`INSERT OR REPLACE INTO polls (message_id, task_id, chat_jid, question, options, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, poll.MessageID, poll.TaskID, poll.ChatJID, question, encryptedOptions, poll.CreatedAt)
+	return err
+}
+
+// GetPoll возвращает отправленный опрос или ErrPollNotFound
+func (st *Storage) GetPoll(messageID string) (*Poll, error) {
+	poll := &Poll{}
+	var options string
+	err := st.db.QueryRow(`SELECT message_id, task_id, chat_jid, question, options, created_at FROM polls WHERE message_id = ?`,
+		messageID).Scan(&poll.MessageID, &poll.TaskID, &poll.ChatJID, &poll.Question, &options, &poll.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrPollNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := st.decryptFields(&poll.Question, &options); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(options), &poll.Options); err != nil {
+		return nil, err
+	}
+	return poll, nil
+}
+
+// ListPolls возвращает последние отправленные опросы (taskID пустой - всех задач)
+func (st *Storage) ListPolls(taskID string, limit int) ([]Poll, error) {
+	rows, err := st.db.Query(`SELECT message_id, task_id, chat_jid, question, options, created_at FROM polls
+		WHERE ? = '' OR task_id = ? ORDER BY created_at DESC LIMIT ?`, taskID, taskID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	polls := []Poll{}
+	for rows.Next() {
+		var poll Poll
+		var options string
+		if err := rows.Scan(&poll.MessageID, &poll.TaskID, &poll.ChatJID, &poll.Question, &options, &poll.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := st.decryptFields(&poll.Question, &options); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(options), &poll.Options); err != nil {
+			return nil, err
+		}
+		polls = append(polls, poll)
+	}
+	return polls, rows.Err()
+}
+
+// RecordPollVote сохраняет голос участника, заменяя его предыдущий голос.
+// Пустой selected - участник отозвал голос
+func (st *Storage) RecordPollVote(messageID, voterJID string, selected []string, votedAt time.Time) error {
+	if len(selected) == 0 {
+		_, err := st.db.Exec(`DELETE FROM poll_votes WHERE message_id = ? AND voter_jid = ?`, messageID, voterJID)
+		return err
+	}
+	data, err := json.Marshal(selected)
+	if err != nil {
+		return err
+	}
+	options, err := st.EncryptField(string(data))
+	if err != nil {
+		return err
+	}
+	_, err = st.db.Exec(`INSERT OR REPLACE INTO poll_votes (message_id, voter_jid, options, voted_at) VALUES (?, ?, ?, ?)`,
+		messageID, voterJID, options, votedAt)
+	return err
+}
+
+// PollResults подсчитывает голоса за каждый вариант ответа опроса
+func (st *Storage) PollResults(messageID string) (*PollResults, error) {
+	poll, err := st.GetPoll(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &PollResults{Poll: *poll, Results: make([]PollOptionResult, len(poll.Options))}
+	index := map[string]int{}
+	for i, option := range poll.Options {
+		results.Results[i] = PollOptionResult{Option: option, Voters: []string{}}
+		index[option] = i
+	}
+
+	rows, err := st.db.Query(`SELECT voter_jid, options FROM poll_votes WHERE message_id = ? ORDER BY voted_at`, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var voter, data string
+		if err := rows.Scan(&voter, &data); err != nil {
+			return nil, err
+		}
+		if err := st.decryptFields(&data); err != nil {
+			return nil, err
+		}
+		var selected []string
+		if err := json.Unmarshal([]byte(data), &selected); err != nil {
+			return nil, err
+		}
+		results.TotalVoters++
+		for _, option := range selected {
+			if i, ok := index[option]; ok {
+				results.Results[i].Votes++
+				results.Results[i].Voters = append(results.Results[i].Voters, voter)
+			}
+		}
+	}
+	return results, rows.Err()
+}
+
+// DeleteVoterPollVotes удаляет голоса участника во всех опросах
+func (st *Storage) DeleteVoterPollVotes(voterJID string) (int64, error) {
+	res, err := st.db.Exec(`DELETE FROM poll_votes WHERE voter_jid = ?`, voterJID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	presenceModeOnline  = "online"
+	presenceModeOffline = "offline"
+
+	// presenceCheckInterval - как часто перепроверять присутствие во время ожидания
+	presenceCheckInterval = 15 * time.Second
+)
+
+// PresenceCondition - условие отправки по присутствию получателя в сети
+type PresenceCondition struct {
+	// Mode - online: отправлять, когда получатель в сети или был недавно; offline: когда не в сети
+	Mode string `json:"mode"`
+	// RecentMinutes - для online: считать "в сети" тех, кто был онлайн не позднее N минут назад
+	RecentMinutes int `json:"recent_minutes,omitempty"`
+	// MaxDelayMinutes - максимальная отсрочка отправки в ожидании условия
+	MaxDelayMinutes int `json:"max_delay_minutes"`
+	// SkipOnTimeout - пропустить отправку, если условие не выполнилось за MaxDelayMinutes
+	SkipOnTimeout bool `json:"skip_on_timeout,omitempty"`
+}
+
+func (p *PresenceCondition) validate() error {
+	if p.Mode != presenceModeOnline && p.Mode != presenceModeOffline {
+		return fmt.Errorf("неизвестный режим присутствия '%s', допустимо: %s, %s", p.Mode, presenceModeOnline, presenceModeOffline)
+	}
+	if p.RecentMinutes < 0 {
+		return fmt.Errorf("recent_minutes не может быть отрицательным")
+	}
+	if p.MaxDelayMinutes <= 0 {
+		return fmt.Errorf("max_delay_minutes должен быть больше 0")
+	}
+	return nil
+}
+
+// presenceState - последнее известное присутствие пользователя
+type presenceState struct {
+	online   bool
+	lastSeen time.Time
+}
+
+// PresenceTracker хранит присутствие пользователей, на которых оформлена подписка
+type PresenceTracker struct {
+	mutex  sync.RWMutex
+	states map[string]presenceState
+}
+
+func newPresenceTracker() *PresenceTracker {
+	return &PresenceTracker{
+		states: make(map[string]presenceState),
+	}
+}
+
+// Update обрабатывает событие присутствия пользователя
+func (p *PresenceTracker) Update(jid string, online bool, lastSeen time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	state := presenceState{online: online, lastSeen: lastSeen}
+	if state.online {
+		state.lastSeen = time.Now()
+	}
+	p.states[jid] = state
+}
+
+// forget удаляет сохраненное присутствие пользователя
+func (p *PresenceTracker) forget(jid string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.states, jid)
+}
+
+// UpdatePresence передает планировщику присутствие пользователя, полученное транспортом
+func (s *Scheduler) UpdatePresence(jid string, online bool, lastSeen time.Time) {
+	s.presence.Update(jid, online, lastSeen)
+}
+
+// matches проверяет, выполняется ли условие для пользователя
+func (p *PresenceTracker) matches(jid string, condition *PresenceCondition) bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	state := p.states[jid]
+	switch condition.Mode {
+	case presenceModeOffline:
+		return !state.online
+	default:
+		if state.online {
+			return true
+		}
+		recent := time.Duration(condition.RecentMinutes) * time.Minute
+		return recent > 0 && !state.lastSeen.IsZero() && time.Since(state.lastSeen) <= recent
+	}
+}
+
+// waitForPresence откладывает отправку по задаче, пока не выполнится условие присутствия.
+// Возвращает false, если отправку нужно пропустить (задача остановлена или истекло ожидание со SkipOnTimeout)
+func (s *Scheduler) waitForPresence(task *ScheduledTask) bool {
+	condition := task.Presence
+	if condition == nil {
+		return true
+	}
+
+	subscriber, ok := s.sender.(PresenceSubscriber)
+	if !ok {
+		Logger.Warnf("Условие присутствия для задачи %s не применяется: транспорт не поддерживает присутствие", task.ID)
+		return true
+	}
+	jid, err := subscriber.SubscribePresence(task.ChatName)
+	if err != nil {
+		Logger.Warnf("Условие присутствия для задачи %s не применяется: %v", task.ID, err)
+		return true
+	}
+
+	deadline := s.clock.Now().Add(time.Duration(condition.MaxDelayMinutes) * time.Minute)
+	logged := false
+	for {
+		if s.presence.matches(jid, condition) {
+			return true
+		}
+		if !s.clock.Now().Before(deadline) {
+			if condition.SkipOnTimeout {
+				Logger.Infof("⏭️ Условие присутствия (%s) для задачи %s не выполнилось, отправка пропущена",
+					condition.Mode, task.ID)
+				return false
+			}
+			Logger.Infof("⌛ Условие присутствия (%s) для задачи %s не выполнилось, отправляем по истечении ожидания",
+				condition.Mode, task.ID)
+			return true
+		}
+		if !logged {
+			Logger.Infof("👀 Ожидание присутствия (%s) получателя '%s' не дольше %d мин",
+				condition.Mode, task.ChatName, condition.MaxDelayMinutes)
+			logged = true
+		}
+
+		if !s.clock.Sleep(task.ctx, min(presenceCheckInterval, deadline.Sub(s.clock.Now()))) {
+			return false
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package scheduler
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// DeleteHistoryBefore удаляет записи истории старше before и возвращает их количество
+func (st *Storage) DeleteHistoryBefore(before time.Time) (int64, error) {
+	res, err := st.db.Exec(`DELETE FROM history WHERE created_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteRecipientHistory удаляет записи истории, отправленные получателю с JID jid
+// или в чат с одним из названий names. Поля могут быть зашифрованы, поэтому
+// записи сравниваются после расшифровки
+func (st *Storage) DeleteRecipientHistory(jid string, names []string) (int64, error) {
+	rows, err := st.db.Query(`SELECT id, chat_name, chat_jid FROM history`)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var chatName, chatJID string
+		if err := rows.Scan(&id, &chatName, &chatJID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if err := st.decryptFields(&chatName, &chatJID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if chatJID == jid || containsFold(names, chatName) {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := st.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	for _, id := range ids {
+		if _, err := tx.Exec(`DELETE FROM history WHERE id = ?`, id); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(ids)), tx.Commit()
+}
+
+// DeleteRecipientReports удаляет получателя с одним из названий names из отчетов о рассылках
+func (st *Storage) DeleteRecipientReports(names []string) (int64, error) {
+	rows, err := st.db.Query(`SELECT rowid, chat_name FROM broadcast_reports`)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var chatName string
+		if err := rows.Scan(&id, &chatName); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if err := st.decryptFields(&chatName); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if containsFold(names, chatName) {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := st.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	for _, id := range ids {
+		if _, err := tx.Exec(`DELETE FROM broadcast_reports WHERE rowid = ?`, id); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(ids)), tx.Commit()
+}
+
+// DeleteRecipientMessages удаляет сохраненные сообщения из личного чата с jid и от отправителя jid
+// в группах. JID отправителя может быть зашифрован, поэтому сравнивается после расшифровки
+func (st *Storage) DeleteRecipientMessages(jid string) (int64, error) {
+	rows, err := st.db.Query(`SELECT id, chat_jid, sender_jid FROM stored_messages`)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id, chatJID, senderJID string
+		if err := rows.Scan(&id, &chatJID, &senderJID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if err := st.decryptFields(&senderJID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if chatJID == jid || senderJID == jid {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := st.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	for _, id := range ids {
+		if _, err := tx.Exec(`DELETE FROM stored_messages WHERE id = ?`, id); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(ids)), tx.Commit()
+}
+
+// DeleteRecipientIncomingMedia удаляет вложения сообщений из личного чата с jid и от отправителя jid
+// в группах вместе с файлами медиатеки. Файл с тем же содержимым из сообщений других отправителей
+// или загруженный вручную остается
+func (st *Storage) DeleteRecipientIncomingMedia(jid string) (int64, error) {
+	rows, err := st.db.Query(`SELECT message_id, media_id, chat_jid, sender_jid FROM incoming_media`)
+	if err != nil {
+		return 0, err
+	}
+	var messageIDs []string
+	mediaIDs := map[string]bool{}
+	for rows.Next() {
+		var messageID, mediaID, chatJID, senderJID string
+		if err := rows.Scan(&messageID, &mediaID, &chatJID, &senderJID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if err := st.decryptFields(&senderJID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if chatJID == jid || senderJID == jid {
+			messageIDs = append(messageIDs, messageID)
+			mediaIDs[mediaID] = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, messageID := range messageIDs {
+		if _, err := st.db.Exec(`DELETE FROM incoming_media WHERE message_id = ?`, messageID); err != nil {
+			return 0, err
+		}
+	}
+	for mediaID := range mediaIDs {
+		var references int
+		if err := st.db.QueryRow(`SELECT COUNT(*) FROM incoming_media WHERE media_id = ?`, mediaID).Scan(&references); err != nil {
+			return 0, err
+		}
+		if references > 0 {
+			continue
+		}
+		asset, err := st.GetMedia(mediaID)
+		if errors.Is(err, ErrMediaNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err := st.deleteMedia(*asset, true); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(messageIDs)), nil
+}
+
+// ForgetRecipient удаляет историю отправок получателю, его записи в отчетах о рассылках, голоса в опросах,
+// реакции и ответы на сообщения задач, сохраненные сообщения с вложениями и присутствие в сети.
+// names - названия, под которыми получатель мог указываться в задачах (номер, имя контакта)
+func (s *Scheduler) ForgetRecipient(jid string, names []string) (int64, error) {
+	s.presence.forget(jid)
+	deleted, err := s.storage.DeleteRecipientHistory(jid, names)
+	if err != nil {
+		return 0, err
+	}
+	reports, err := s.storage.DeleteRecipientReports(append(names, jid))
+	if err != nil {
+		return 0, err
+	}
+	votes, err := s.storage.DeleteVoterPollVotes(jid)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.storage.DeleteSenderEngagement(jid); err != nil {
+		return 0, err
+	}
+	if _, err := s.storage.DeleteChatEscalations(jid); err != nil {
+		return 0, err
+	}
+	if _, err := s.storage.DeleteRecipientEnrollments(jid, names); err != nil {
+		return 0, err
+	}
+	if err := s.storage.DeleteRecipientFromLists(jid, names); err != nil {
+		return 0, err
+	}
+	if _, err := s.storage.DeleteSenderAutoReplies(jid); err != nil {
+		return 0, err
+	}
+	if _, err := s.storage.DeleteMemberMembershipLog(jid); err != nil {
+		return 0, err
+	}
+	media, err := s.storage.DeleteRecipientIncomingMedia(jid)
+	if err != nil {
+		return 0, err
+	}
+	messages, err := s.storage.DeleteRecipientMessages(jid)
+	if err != nil {
+		return 0, err
+	}
+	Logger.Infof("🧹 Удалены данные получателя %s: %d записей истории, %d записей отчетов о рассылках, %d голосов в опросах, "+
+		"%d сохраненных сообщений, %d вложений", jid, deleted, reports, votes, messages, media)
+	return deleted, nil
+}
+
+// containsFold проверяет, что values содержит value без учета регистра
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if v != "" && strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(value)) {
+			return true
+		}
+	}
+	return false
+}
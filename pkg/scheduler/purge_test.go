@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestForgetRecipientMessagesAndMedia(t *testing.T) {
+	const (
+		anna  = "491511111111@s.whatsapp.net"
+		boris = "491512222222@s.whatsapp.net"
+		group = "120363000000000000@g.us"
+	)
+	// Личный чат с Анной, ее сообщение в группе и сообщения Бориса
+	messages := []IncomingMessage{
+		{ID: "anna_direct", ChatJID: anna, SenderJID: anna, Text: "STOP", Direct: true},
+		{ID: "anna_group", ChatJID: group, SenderJID: anna, Text: "photo"},
+		{ID: "boris_direct", ChatJID: boris, SenderJID: boris, Text: "hi", Direct: true},
+		{ID: "boris_group", ChatJID: group, SenderJID: boris, Text: "same photo"},
+	}
+	attachments := map[string][]byte{
+		"anna_direct": []byte("anna document"),
+		"anna_group":  []byte("shared photo"),
+		"boris_group": []byte("shared photo"),
+	}
+
+	for _, encrypted := range []bool{false, true} {
+		name := "plain"
+		if encrypted {
+			name = "encrypted"
+		}
+		t.Run(name, func(t *testing.T) {
+			chdirTemp(t)
+			s, _ := newTestScheduler(t)
+			if encrypted {
+				if err := s.storage.EnableEncryption(bytes.Repeat([]byte{7}, 32)); err != nil {
+					t.Fatalf("EnableEncryption: %v", err)
+				}
+			}
+			for _, msg := range messages {
+				if err := s.storage.SaveMessage(StoredMessage{ID: msg.ID, ChatJID: msg.ChatJID, SenderJID: msg.SenderJID, Text: msg.Text}); err != nil {
+					t.Fatalf("SaveMessage: %v", err)
+				}
+				if data, ok := attachments[msg.ID]; ok {
+					msg.Media = &IncomingMedia{FileName: msg.ID + ".bin", MimeType: "application/octet-stream"}
+					if _, err := s.storage.saveIncomingMedia(msg, data); err != nil {
+						t.Fatalf("saveIncomingMedia: %v", err)
+					}
+				}
+			}
+			annaDocument, err := s.storage.IncomingMedia("anna_direct")
+			if err != nil {
+				t.Fatalf("IncomingMedia: %v", err)
+			}
+
+			if _, err := s.ForgetRecipient(anna, []string{"491511111111", "Anna"}); err != nil {
+				t.Fatalf("ForgetRecipient: %v", err)
+			}
+
+			stored, err := s.storage.ListStoredMessages("", 10)
+			if err != nil {
+				t.Fatalf("ListStoredMessages: %v", err)
+			}
+			var remaining []string
+			for _, msg := range stored {
+				remaining = append(remaining, msg.ID)
+				if msg.SenderJID == anna || msg.ChatJID == anna {
+					t.Errorf("message %s from %s in %s kept", msg.ID, msg.SenderJID, msg.ChatJID)
+				}
+			}
+			if len(remaining) != 2 {
+				t.Errorf("stored messages %v, want only Boris's", remaining)
+			}
+
+			for _, id := range []string{"anna_direct", "anna_group"} {
+				if _, err := s.storage.IncomingMedia(id); err == nil {
+					t.Errorf("attachment of %s kept", id)
+				}
+			}
+			if _, err := os.Stat(annaDocument.Path()); !os.IsNotExist(err) {
+				t.Errorf("file %s kept on disk: %v", annaDocument.Path(), err)
+			}
+			// Такое же фото от Бориса остается вместе с файлом
+			shared, err := s.storage.IncomingMedia("boris_group")
+			if err != nil {
+				t.Fatalf("attachment of boris_group deleted: %v", err)
+			}
+			if _, err := os.Stat(shared.Path()); err != nil {
+				t.Errorf("shared file deleted: %v", err)
+			}
+		})
+	}
+}
+
+// chdirTemp переходит во временный каталог на время теста: файлы медиатеки пишутся в рабочий каталог
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Chdir: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+}
@@ -0,0 +1,187 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRateLimit - лимит отправок в минуту по умолчанию
+const DefaultRateLimit = 20
+
+// ErrRateLimited возвращается, когда превышен лимит отправки сообщений
+var ErrRateLimited = errors.New("превышен лимит отправки сообщений, попробуйте позже")
+
+// RateLimiter ограничивает количество отправок в скользящем окне времени
+type RateLimiter struct {
+	mutex  sync.Mutex
+	limit  int
+	window time.Duration
+	sent   []time.Time
+}
+
+func newRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:  limit,
+		window: window,
+	}
+}
+
+// SetLimit меняет лимит отправок в окне, 0 - без ограничений
+func (l *RateLimiter) SetLimit(limit int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.limit = limit
+}
+
+// Allow проверяет лимит и, если отправка разрешена, учитывает её
+func (l *RateLimiter) Allow() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.limit <= 0 {
+		return true
+	}
+
+	// Отбрасываем отправки, вышедшие за пределы окна
+	cutoff := time.Now().Add(-l.window)
+	i := 0
+	for i < len(l.sent) && !l.sent[i].After(cutoff) {
+		i++
+	}
+	l.sent = l.sent[i:]
+
+	if len(l.sent) >= l.limit {
+		return false
+	}
+	l.sent = append(l.sent, time.Now())
+	return true
+}
+
+// ErrQuotaExceeded возвращается, когда пользователь API исчерпал суточную квоту отправок
+var ErrQuotaExceeded = fmt.Errorf("суточная квота отправок пользователя исчерпана (%w)", ErrRateLimited)
+
+// AdminUser - пользователь ключа администратора API, суточные квоты на него не действуют
+const AdminUser = "admin"
+
+// SendQuotas считает отправки пользователей API за текущие сутки (Settings.UserDailyQuota).
+// Счетчики хранятся в памяти и начинаются заново после перезапуска
+type SendQuotas struct {
+	mutex sync.Mutex
+	// day - начало суток, за которые посчитаны отправки
+	day  time.Time
+	used map[string]int
+}
+
+func newSendQuotas() *SendQuotas {
+	return &SendQuotas{used: map[string]int{}}
+}
+
+// reserve учитывает отправку пользователя user за сутки day, если квота limit не исчерпана (0 - без ограничений)
+func (q *SendQuotas) reserve(user string, limit int, day time.Time) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.rollover(day)
+	if limit > 0 && q.used[user] >= limit {
+		return false
+	}
+	q.used[user]++
+	return true
+}
+
+// release возвращает в квоту неотправленное сообщение пользователя user за сутки day
+func (q *SendQuotas) release(user string, day time.Time) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.day.Equal(day) && q.used[user] > 0 {
+		q.used[user]--
+	}
+}
+
+// usedBy возвращает количество отправок пользователя user за сутки day
+func (q *SendQuotas) usedBy(user string, day time.Time) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.rollover(day)
+	return q.used[user]
+}
+
+// rollover начинает подсчет заново с наступлением новых суток
+func (q *SendQuotas) rollover(day time.Time) {
+	if !q.day.Equal(day) {
+		q.day = day
+		q.used = map[string]int{}
+	}
+}
+
+// QuotaStatus - суточная квота отправок пользователя API
+type QuotaStatus struct {
+	// Limit - максимум отправок за сутки, 0 - без ограничений
+	Limit int `json:"limit"`
+	Used  int `json:"used"`
+	// Reset - начало следующих суток, когда квота восстанавливается
+	Reset time.Time `json:"reset"`
+}
+
+// Remaining возвращает количество отправок, оставшихся до конца суток
+func (q QuotaStatus) Remaining() int {
+	return max(q.Limit-q.Used, 0)
+}
+
+// userQuota возвращает суточную квоту пользователя user, 0 - без ограничений
+func (st *Settings) userQuota(user string) int {
+	if user == "" || user == AdminUser {
+		return 0
+	}
+	if limit, ok := st.UserQuotas[user]; ok {
+		return limit
+	}
+	return st.UserDailyQuota
+}
+
+// validateQuotas проверяет суточные квоты пользователей
+func (st *Settings) validateQuotas() error {
+	if st.UserDailyQuota < 0 {
+		return fmt.Errorf("user_daily_quota не может быть отрицательным")
+	}
+	for user, limit := range st.UserQuotas {
+		if strings.TrimSpace(user) == "" || limit < 0 {
+			return fmt.Errorf("неверная квота '%s': %d, нужны имя пользователя и неотрицательное число", user, limit)
+		}
+	}
+	return nil
+}
+
+// quotaDay возвращает начало текущих и следующих суток в часовом поясе по умолчанию
+func (s *Scheduler) quotaDay() (time.Time, time.Time) {
+	now := s.clock.Now().In(s.Location())
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return day, day.AddDate(0, 0, 1)
+}
+
+// Quota возвращает суточную квоту отправок пользователя user и сколько из нее израсходовано
+func (s *Scheduler) Quota(user string) QuotaStatus {
+	settings := s.Settings()
+	day, reset := s.quotaDay()
+	return QuotaStatus{Limit: settings.userQuota(user), Used: s.quotas.usedBy(user, day), Reset: reset}
+}
+
+// reserveQuota учитывает отправку пользователя user в его суточной квоте или возвращает ErrQuotaExceeded.
+// release возвращает отправку в квоту, если сообщение не было отправлено
+func (s *Scheduler) reserveQuota(user string) (release func(), err error) {
+	settings := s.Settings()
+	limit := settings.userQuota(user)
+	if limit == 0 {
+		return func() {}, nil
+	}
+	day, _ := s.quotaDay()
+	if !s.quotas.reserve(user, limit, day) {
+		return nil, ErrQuotaExceeded
+	}
+	return func() { s.quotas.release(user, day) }, nil
+}
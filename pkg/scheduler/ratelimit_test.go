@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUserDailyQuota(t *testing.T) {
+	now := time.Date(2025, 6, 2, 22, 0, 0, 0, time.UTC)
+	s, sender := newTestScheduler(t)
+	s.SetClock(NewSimulatedClock(now, time.Time{}))
+	settings := s.Settings()
+	settings.DefaultTimezone = "UTC"
+	settings.UserDailyQuota = 2
+	settings.UserQuotas = map[string]int{"bob": 1, "carol": 0}
+	if err := s.SetSettings(settings); err != nil {
+		t.Fatalf("SetSettings: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		owner string
+		fail  error
+		want  error
+	}{
+		{"alice first", "alice", nil, nil},
+		{"failed send is not counted", "alice", ErrDisconnected, ErrDisconnected},
+		{"alice second", "alice", nil, nil},
+		{"alice over quota", "alice", nil, ErrQuotaExceeded},
+		{"bob own quota", "bob", nil, nil},
+		{"bob over own quota", "bob", nil, ErrQuotaExceeded},
+		{"carol unlimited", "carol", nil, nil},
+		{"carol unlimited again", "carol", nil, nil},
+		{"carol unlimited third", "carol", nil, nil},
+		{"admin unlimited", AdminUser, nil, nil},
+		{"admin unlimited again", AdminUser, nil, nil},
+		{"admin unlimited third", AdminUser, nil, nil},
+		{"no owner", "", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender.FailWith(tt.fail)
+			_, err := s.DeliverResult(context.Background(), OutgoingMessage{ChatName: "Team", Message: "Hi", Owner: tt.owner})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("DeliverResult(%s) = %v, want %v", tt.owner, err, tt.want)
+			}
+		})
+	}
+	sender.FailWith(nil)
+
+	quota := s.Quota("alice")
+	reset := time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC)
+	if quota.Limit != 2 || quota.Used != 2 || quota.Remaining() != 0 || !quota.Reset.Equal(reset) {
+		t.Errorf("Quota(alice) = %+v, want 2 of 2 used until %v", quota, reset)
+	}
+	if !errors.Is(ErrQuotaExceeded, ErrRateLimited) || ErrorCode(ErrQuotaExceeded) != ErrorCodeQuotaExceeded ||
+		SendStatus(ErrQuotaExceeded) != HistoryStatusRateLimited {
+		t.Error("ErrQuotaExceeded is not reported as a rate limit with its own code")
+	}
+
+	// С началом новых суток квота восстанавливается
+	s.SetClock(NewSimulatedClock(reset.Add(time.Minute), time.Time{}))
+	if quota := s.Quota("alice"); quota.Used != 0 || quota.Remaining() != 2 {
+		t.Errorf("Quota(alice) next day = %+v, want the quota restored", quota)
+	}
+	if _, err := s.DeliverResult(context.Background(), OutgoingMessage{ChatName: "Team", Message: "Hi", Owner: "alice"}); err != nil {
+		t.Errorf("DeliverResult next day: %v", err)
+	}
+}
+
+func TestValidateQuotas(t *testing.T) {
+	tests := []struct {
+		name    string
+		daily   int
+		quotas  map[string]int
+		wantErr bool
+	}{
+		{"unlimited", 0, nil, false},
+		{"daily and per user", 100, map[string]int{"alice": 10, "bob": 0}, false},
+		{"negative daily", -1, nil, true},
+		{"negative user quota", 10, map[string]int{"alice": -5}, true},
+		{"blank user", 10, map[string]int{" ": 5}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := Settings{UserDailyQuota: tt.daily, UserQuotas: tt.quotas}
+			if err := st.validateQuotas(); (err != nil) != tt.wantErr {
+				t.Errorf("validateQuotas() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package scheduler
+
+import (
+	"errors"
+)
+
+const alertKindReadOnly = "read_only"
+
+// ErrReadOnly возвращается при отправке в режиме только для чтения
+var ErrReadOnly = errors.New("включен режим только для чтения, отправка отключена")
+
+// SetReadOnly включает или выключает режим только для чтения: все отправки (задачи, POST /send,
+// пакеты, кампании, автоответы) отклоняются с ErrReadOnly, а API, история и статус доступны.
+// Удобно на время миграции или при подозрении на блокировку аккаунта
+func (s *Scheduler) SetReadOnly(enabled bool) {
+	if s.readOnly.Swap(enabled) == enabled {
+		return
+	}
+	if enabled {
+		s.alert(alertKindReadOnly, "Включен режим только для чтения, сообщения не отправляются")
+	} else {
+		s.alert(alertKindReadOnly, "Режим только для чтения выключен, отправка возобновлена")
+	}
+}
+
+// IsReadOnly проверяет, что включен режим только для чтения
+func (s *Scheduler) IsReadOnly() bool {
+	return s.readOnly.Load()
+}
@@ -0,0 +1,491 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxRecipientListEntries - максимальное количество получателей в списке
+const maxRecipientListEntries = 10000
+
+// Результаты проверки получателя списка
+const (
+	RecipientValid         = "valid"
+	RecipientMalformed     = "malformed"
+	RecipientDuplicate     = "duplicate"
+	RecipientNotOnWhatsApp = "not_on_whatsapp"
+	RecipientNotFound      = "not_found"
+	RecipientAmbiguous     = "ambiguous"
+	// RecipientUnchecked - транспорт не смог проверить получателя, он остается в списке
+	RecipientUnchecked = "unchecked"
+)
+
+// ErrRecipientListNotFound - списка получателей с таким ID нет
+var ErrRecipientListNotFound = errors.New("список получателей не найден")
+
+// NumberChecker - необязательная возможность транспорта: проверка, зарегистрированы ли номера в WhatsApp
+type NumberChecker interface {
+	// CheckNumbers принимает номера из цифр и возвращает JID зарегистрированных
+	CheckNumbers(phones []string) (map[string]string, error)
+}
+
+// RecipientList - сохраненный список получателей (номера, JID или названия чатов)
+// для подписки на кампании
+type RecipientList struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Entries []string `json:"entries"`
+	// Timezones - часовой пояс IANA или код страны (DE, US) записи для рассылки по местному
+	// времени получателей (ScheduledTask.LocalTime)
+	Timezones map[string]string `json:"timezones,omitempty"`
+	// Languages - язык записи (en, pt-BR) для выбора перевода сообщения (ScheduledTask.Translations)
+	Languages map[string]string `json:"languages,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// RecipientCheck - результат проверки одного получателя списка
+type RecipientCheck struct {
+	Entry  string `json:"entry"`
+	Status string `json:"status"`
+	JID    string `json:"jid,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// RecipientListReport - отчет о проверке списка получателей
+type RecipientListReport struct {
+	ListID string `json:"list_id"`
+	Total  int    `json:"total"`
+	// Counts - количество получателей по результатам проверки
+	Counts  map[string]int   `json:"counts"`
+	Entries []RecipientCheck `json:"entries"`
+	// Removed - получатели, удаленные из списка при проверке с удалением
+	Removed   []string  `json:"removed"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// validate проверяет название и получателей списка
+func (l *RecipientList) validate() error {
+	l.Name = strings.TrimSpace(l.Name)
+	if l.Name == "" {
+		return fmt.Errorf("пустое название списка получателей")
+	}
+	entries := []string{}
+	for _, entry := range l.Entries {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) > maxRecipientListEntries {
+		return fmt.Errorf("в списке получателей больше %d записей", maxRecipientListEntries)
+	}
+	l.Entries = entries
+	if err := l.validateTimezones(); err != nil {
+		return err
+	}
+	return l.validateLanguages()
+}
+
+// AddRecipientList сохраняет новый список получателей и возвращает его ID
+func (s *Scheduler) AddRecipientList(list *RecipientList) (string, error) {
+	if err := list.validate(); err != nil {
+		return "", err
+	}
+	list.ID = fmt.Sprintf("list_%d", time.Now().UnixNano())
+	list.CreatedAt = time.Now()
+	list.UpdatedAt = list.CreatedAt
+	if err := s.storage.SaveRecipientList(list); err != nil {
+		return "", err
+	}
+	Logger.Infof("📇 Добавлен список получателей %s '%s': %d записей", list.ID, list.Name, len(list.Entries))
+	return list.ID, nil
+}
+
+// UpdateRecipientList заменяет название и получателей списка id
+func (s *Scheduler) UpdateRecipientList(id string, list *RecipientList) error {
+	existing, err := s.storage.GetRecipientList(id)
+	if err != nil {
+		return err
+	}
+	if err := list.validate(); err != nil {
+		return err
+	}
+	list.ID, list.CreatedAt, list.UpdatedAt = existing.ID, existing.CreatedAt, time.Now()
+	return s.storage.SaveRecipientList(list)
+}
+
+// ValidateRecipientList проверяет получателей списка: формат номеров, повторы, регистрацию номеров
+// в WhatsApp и поиск чатов по названию. С removeInvalid из списка удаляются неверные номера,
+// повторы, незарегистрированные номера и ненайденные чаты; неоднозначные и непроверенные остаются
+func (s *Scheduler) ValidateRecipientList(id string, removeInvalid bool) (*RecipientListReport, error) {
+	list, err := s.storage.GetRecipientList(id)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := make([]RecipientCheck, len(list.Entries))
+	phones := map[string][]int{}
+	seen := map[string]string{}
+	for i, entry := range list.Entries {
+		checks[i] = RecipientCheck{Entry: entry}
+		key, phone, reason := recipientKey(entry)
+		switch {
+		case reason != "":
+			checks[i].Status, checks[i].Reason = RecipientMalformed, reason
+		case seen[key] != "":
+			checks[i].Status, checks[i].Reason = RecipientDuplicate, fmt.Sprintf("повтор '%s'", seen[key])
+		case phone != "":
+			seen[key] = entry
+			phones[phone] = append(phones[phone], i)
+		default:
+			seen[key] = entry
+			checks[i] = s.checkRecipientChat(entry)
+		}
+	}
+	s.checkRecipientNumbers(phones, checks)
+
+	// Номер и название могут указывать на один и тот же чат
+	jids := map[string]string{}
+	for i := range checks {
+		check := &checks[i]
+		if check.Status != RecipientValid || check.JID == "" {
+			continue
+		}
+		if first, exists := jids[check.JID]; exists {
+			check.Status, check.Reason = RecipientDuplicate, fmt.Sprintf("тот же чат, что '%s'", first)
+			continue
+		}
+		jids[check.JID] = check.Entry
+	}
+
+	report := &RecipientListReport{
+		ListID:    list.ID,
+		Total:     len(checks),
+		Counts:    map[string]int{},
+		Entries:   checks,
+		Removed:   []string{},
+		CheckedAt: time.Now(),
+	}
+	kept := []string{}
+	for _, check := range checks {
+		report.Counts[check.Status]++
+		switch check.Status {
+		case RecipientMalformed, RecipientDuplicate, RecipientNotOnWhatsApp, RecipientNotFound:
+			report.Removed = append(report.Removed, check.Entry)
+		default:
+			kept = append(kept, check.Entry)
+		}
+	}
+	if !removeInvalid || len(report.Removed) == 0 {
+		report.Removed = []string{}
+		return report, nil
+	}
+	list.Entries, list.UpdatedAt = kept, time.Now()
+	list.pruneColumns()
+	if err := s.storage.SaveRecipientList(list); err != nil {
+		return nil, err
+	}
+	Logger.Infof("🧹 Из списка получателей %s удалено неверных записей: %d", list.ID, len(report.Removed))
+	return report, nil
+}
+
+// recipientKey возвращает ключ получателя для поиска повторов и номер из цифр, если получатель -
+// номер телефона или личный JID. Непустой reason - запись не похожа ни на номер, ни на JID
+func recipientKey(entry string) (key, phone, reason string) {
+	if user, server, ok := strings.Cut(entry, "@"); ok {
+		if user == "" || server == "" {
+			return "", "", "неверный JID"
+		}
+		if server != "s.whatsapp.net" {
+			return strings.ToLower(entry), "", ""
+		}
+		entry = user
+	} else if !strings.HasPrefix(entry, "+") && strings.Trim(entry, "0123456789 -()") != "" {
+		// Название контакта или группы, запись с + - всегда номер
+		return strings.ToLower(entry), "", ""
+	}
+
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		if strings.ContainsRune("+ -()", r) {
+			return -1
+		}
+		return 'x'
+	}, entry)
+	if strings.Contains(digits, "x") || len(digits) < 10 || len(digits) > 15 {
+		return "", "", "номер должен состоять из 10-15 цифр с кодом страны"
+	}
+	return digits, digits, ""
+}
+
+// checkRecipientNumbers проверяет регистрацию номеров в WhatsApp. phones - номер и индексы его записей
+func (s *Scheduler) checkRecipientNumbers(phones map[string][]int, checks []RecipientCheck) {
+	if len(phones) == 0 {
+		return
+	}
+	numbers := make([]string, 0, len(phones))
+	for phone := range phones {
+		numbers = append(numbers, phone)
+	}
+	var registered map[string]string
+	reason := ""
+	checker, ok := s.sender.(NumberChecker)
+	if !ok {
+		reason = "транспорт не поддерживает проверку номеров"
+	} else if result, err := checker.CheckNumbers(numbers); err != nil {
+		reason = err.Error()
+	} else {
+		registered = result
+	}
+
+	for phone, indexes := range phones {
+		for _, i := range indexes {
+			switch jid, exists := registered[phone]; {
+			case reason != "":
+				checks[i].Status, checks[i].Reason = RecipientUnchecked, reason
+			case exists:
+				checks[i].Status, checks[i].JID = RecipientValid, jid
+			default:
+				checks[i].Status = RecipientNotOnWhatsApp
+			}
+		}
+	}
+}
+
+// checkRecipientChat ищет чат по названию или JID группы
+func (s *Scheduler) checkRecipientChat(entry string) RecipientCheck {
+	check := RecipientCheck{Entry: entry}
+	resolver, ok := s.sender.(ChatResolver)
+	if !ok {
+		check.Status, check.Reason = RecipientUnchecked, "транспорт не поддерживает поиск чатов"
+		return check
+	}
+	candidates, err := resolver.ResolveChat(entry)
+	switch {
+	case err != nil:
+		check.Status, check.Reason = RecipientUnchecked, err.Error()
+	case len(candidates) == 0:
+		check.Status = RecipientNotFound
+	case len(candidates) > 1:
+		check.Status, check.Reason = RecipientAmbiguous, AmbiguousChatError(entry, candidates).Error()
+	default:
+		check.Status, check.JID = RecipientValid, candidates[0].JID
+	}
+	return check
+}
+
+// SaveRecipientList сохраняет список получателей
+func (st *Storage) SaveRecipientList(list *RecipientList) error {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	sealed, err := st.EncryptField(string(data))
+	if err != nil {
+		return err
+	}
+	_, err = st.db.Exec(`INSERT INTO recipient_lists (id, data, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data`, list.ID, sealed, list.CreatedAt)
+	return err
+}
+
+// GetRecipientList возвращает список получателей или ErrRecipientListNotFound
+func (st *Storage) GetRecipientList(id string) (*RecipientList, error) {
+	var data string
+	err := st.db.QueryRow(`SELECT data FROM recipient_lists WHERE id = ?`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: '%s'", ErrRecipientListNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return st.decodeRecipientList(data)
+}
+
+// ListRecipientLists возвращает списки получателей, новые первыми
+func (st *Storage) ListRecipientLists() ([]*RecipientList, error) {
+	rows, err := st.db.Query(`SELECT data FROM recipient_lists ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*RecipientList{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		list, err := st.decodeRecipientList(data)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	return lists, rows.Err()
+}
+
+// decodeRecipientList расшифровывает и разбирает сохраненный список получателей
+func (st *Storage) decodeRecipientList(data string) (*RecipientList, error) {
+	if err := st.decryptFields(&data); err != nil {
+		return nil, err
+	}
+	list := &RecipientList{}
+	if err := json.Unmarshal([]byte(data), list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// DeleteRecipientList удаляет список получателей, false - списка не было
+func (st *Storage) DeleteRecipientList(id string) (bool, error) {
+	res, err := st.db.Exec(`DELETE FROM recipient_lists WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := res.RowsAffected()
+	return deleted > 0, err
+}
+
+// DeleteRecipientFromLists убирает получателя из всех списков по JID и названиям names
+func (st *Storage) DeleteRecipientFromLists(jid string, names []string) error {
+	lists, err := st.ListRecipientLists()
+	if err != nil {
+		return err
+	}
+	names = append(names, jid)
+	for _, list := range lists {
+		entries := []string{}
+		for _, entry := range list.Entries {
+			if !containsFold(names, entry) {
+				entries = append(entries, entry)
+			}
+		}
+		if len(entries) == len(list.Entries) {
+			continue
+		}
+		list.Entries = entries
+		list.pruneColumns()
+		if err := st.SaveRecipientList(list); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeRecipients объединяет основной чат, получателей задачи и ее списки получателей, убирая
+// повторы по JID чата, чтобы человек из нескольких списков получил рассылку один раз.
+// Получатели с найденным JID отправляются по JID. Возвращает получателей, часовые пояса и языки
+// получателей из списков и количество убранных повторов
+func (s *Scheduler) mergeRecipients(task *ScheduledTask) ([]string, map[string]recipientInfo, int, error) {
+	entries := task.recipients()
+	entryInfo := make([]recipientInfo, len(entries))
+	for _, id := range task.RecipientLists {
+		list, err := s.storage.GetRecipientList(id)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		for _, entry := range list.Entries {
+			entries = append(entries, entry)
+			entryInfo = append(entryInfo, recipientInfo{Timezone: list.Timezones[entry], Language: list.Languages[entry]})
+		}
+	}
+
+	keys := s.recipientKeys(task, entries)
+
+	recipients := []string{}
+	info := map[string]recipientInfo{}
+	// first - получатель, под которым чат попал в рассылку, часовой пояс и язык берутся из первой
+	// записи, где они указаны
+	first := map[string]string{}
+	for i, entry := range entries {
+		recipient, seen := first[keys[i]]
+		if !seen {
+			recipient = entry
+			if i > 0 && strings.Contains(keys[i], "@") {
+				recipient = keys[i]
+			}
+			first[keys[i]] = recipient
+			recipients = append(recipients, recipient)
+		}
+		merged := info[recipient]
+		if merged.Timezone == "" {
+			merged.Timezone = entryInfo[i].Timezone
+		}
+		if merged.Language == "" {
+			merged.Language = entryInfo[i].Language
+		}
+		if merged != (recipientInfo{}) {
+			info[recipient] = merged
+		}
+	}
+	return recipients, info, len(entries) - len(recipients), nil
+}
+
+// recipientKeys возвращает канонический JID каждого получателя entries рассылки задачи: для основного
+// чата (entries[0]) - сохраненный ChatJID, для номеров - JID зарегистрированного номера, для названий -
+// найденный транспортом. Неверные записи и не найденные названия возвращаются в нижнем регистре
+func (s *Scheduler) recipientKeys(task *ScheduledTask, entries []string) []string {
+	keys := make([]string, len(entries))
+	phones := map[string][]int{}
+	for i, entry := range entries {
+		key, phone, reason := recipientKey(entry)
+		switch {
+		case i == 0 && task.ChatJID != "":
+			keys[i] = task.ChatJID
+		case reason != "":
+			keys[i] = strings.ToLower(entry)
+		case phone != "":
+			keys[i] = phone + "@s.whatsapp.net"
+			phones[phone] = append(phones[phone], i)
+		default:
+			// Неоднозначное или не найденное название сравнивается как есть, отправка сообщит об ошибке
+			if jid, err := s.resolveRecipient(entry); err == nil && jid != "" {
+				keys[i] = jid
+			} else {
+				keys[i] = key
+			}
+		}
+	}
+	// Канонический JID номера может отличаться от номера (например, без лишней цифры)
+	if checker, ok := s.sender.(NumberChecker); ok && len(phones) > 0 {
+		numbers := make([]string, 0, len(phones))
+		for phone := range phones {
+			numbers = append(numbers, phone)
+		}
+		if registered, err := checker.CheckNumbers(numbers); err != nil {
+			Logger.Warnf("Номера рассылки по задаче %s не проверены: %v", task.ID, err)
+		} else {
+			for phone, jid := range registered {
+				for _, i := range phones[phone] {
+					keys[i] = jid
+				}
+			}
+		}
+	}
+	return keys
+}
+
+// recipientInfo - часовой пояс и язык получателя рассылки из списков получателей
+type recipientInfo struct {
+	Timezone string
+	Language string
+}
+
+// pruneColumns убирает часовые пояса и языки записей, удаленных из списка
+func (l *RecipientList) pruneColumns() {
+	for _, column := range []map[string]string{l.Timezones, l.Languages} {
+		for entry := range column {
+			if !slices.Contains(l.Entries, entry) {
+				delete(column, entry)
+			}
+		}
+	}
+}
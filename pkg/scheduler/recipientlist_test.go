@@ -0,0 +1,135 @@
+package scheduler
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// checkingSender - resolvingSender, который возвращает канонический JID номеров из numbers
+// (остальные номера считаются зарегистрированными как есть)
+type checkingSender struct {
+	*resolvingSender
+	numbers map[string]string
+}
+
+// CheckNumbers возвращает JID зарегистрированных номеров
+func (c *checkingSender) CheckNumbers(phones []string) (map[string]string, error) {
+	registered := map[string]string{}
+	for _, phone := range phones {
+		if jid, ok := c.numbers[phone]; ok {
+			registered[phone] = jid
+		} else {
+			registered[phone] = phone + "@s.whatsapp.net"
+		}
+	}
+	return registered, nil
+}
+
+func TestMergeRecipientsDeduplicates(t *testing.T) {
+	const (
+		anna   = "491511111111@s.whatsapp.net"
+		boris  = "491512222222@s.whatsapp.net"
+		carlos = "551112345678@s.whatsapp.net"
+	)
+	lists := []*RecipientList{
+		{
+			ID:        "list_a",
+			Entries:   []string{"+49 151 1111111", "Boris", "+55 11 91234-5678"},
+			Timezones: map[string]string{"Boris": "Europe/Berlin"},
+		},
+		{
+			ID:        "list_b",
+			Entries:   []string{anna, "boris", "551112345678@s.whatsapp.net"},
+			Languages: map[string]string{"boris": "de"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		task       ScheduledTask
+		want       []string
+		wantInfo   map[string]recipientInfo
+		duplicates int
+	}{
+		{
+			name: "no duplicates",
+			task: ScheduledTask{ChatName: "Team", Recipients: []string{"Anna"}},
+			want: []string{"Team", anna},
+		},
+		{
+			name:       "name, phone and JID of one contact",
+			task:       ScheduledTask{ChatName: "Team", Recipients: []string{"Anna", "+49 151 1111111", anna}},
+			want:       []string{"Team", anna},
+			duplicates: 2,
+		},
+		{
+			name:       "recipient repeats the main chat",
+			task:       ScheduledTask{ChatName: "Anna", Recipients: []string{"+49 (151) 111-1111"}},
+			want:       []string{"Anna"},
+			duplicates: 1,
+		},
+		{
+			name:       "main chat by its pinned JID",
+			task:       ScheduledTask{ChatName: "Anna K.", ChatJID: anna, Recipients: []string{"Anna", "Boris"}},
+			want:       []string{"Anna K.", boris},
+			duplicates: 1,
+		},
+		{
+			name:       "unresolved names ignore case",
+			task:       ScheduledTask{ChatName: "Team", Recipients: []string{"Carol", "carol"}},
+			want:       []string{"Team", "Carol"},
+			duplicates: 1,
+		},
+		{
+			// Номер с лишней цифрой совпадает со своим каноническим JID, часовой пояс и язык
+			// берутся из первых записей, где они указаны
+			name:       "across recipient lists",
+			task:       ScheduledTask{ChatName: "Team", RecipientLists: []string{"list_a", "list_b"}},
+			want:       []string{"Team", anna, boris, carlos},
+			wantInfo:   map[string]recipientInfo{boris: {Timezone: "Europe/Berlin", Language: "de"}},
+			duplicates: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, err := OpenStorage(filepath.Join(t.TempDir(), "scheduler.db"))
+			if err != nil {
+				t.Fatalf("OpenStorage: %v", err)
+			}
+			t.Cleanup(func() { storage.db.Close() })
+			for _, list := range lists {
+				if err := storage.SaveRecipientList(list); err != nil {
+					t.Fatalf("SaveRecipientList: %v", err)
+				}
+			}
+			sender := &checkingSender{
+				resolvingSender: &resolvingSender{MemorySender: NewMemorySender(), chats: map[string]string{
+					"anna":  anna,
+					"boris": boris,
+				}},
+				numbers: map[string]string{"5511912345678": carlos, "551112345678": carlos},
+			}
+			s, err := NewScheduler(storage, sender, Config{})
+			if err != nil {
+				t.Fatalf("NewScheduler: %v", err)
+			}
+
+			got, info, duplicates, err := s.mergeRecipients(&tt.task)
+			if err != nil {
+				t.Fatalf("mergeRecipients: %v", err)
+			}
+			if !slices.Equal(got, tt.want) || duplicates != tt.duplicates {
+				t.Errorf("recipients %v with %d duplicates, want %v with %d", got, duplicates, tt.want, tt.duplicates)
+			}
+			if tt.wantInfo == nil {
+				tt.wantInfo = map[string]recipientInfo{}
+			}
+			if !maps.Equal(info, tt.wantInfo) {
+				t.Errorf("recipient info %v, want %v", info, tt.wantInfo)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"time"
+)
+
+// reconcileInterval - период сверки списка задач с горутинами и хранилищем
+const reconcileInterval = time.Minute
+
+// reconcileTasks периодически сверяет задачи: убирает из списка задачи, горутина которых
+// завершилась, и удаляет из хранилища записи задач, которых нет в списке
+func (s *Scheduler) reconcileTasks() {
+	for range time.Tick(reconcileInterval) {
+		s.reconcileOnce()
+	}
+}
+
+// reconcileOnce выполняет одну сверку задач
+func (s *Scheduler) reconcileOnce() {
+	ids, err := s.storage.taskIDs()
+	if err != nil {
+		Logger.Errorf("Ошибка сверки задач: %v", err)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for id, task := range s.tasks {
+		select {
+		case <-task.done:
+			Logger.Warnf("Задача %s осталась в списке после завершения и удалена", id)
+			s.removeTask(task)
+		default:
+		}
+	}
+	for _, id := range ids {
+		if _, exists := s.tasks[id]; !exists {
+			Logger.Warnf("Запись остановленной задачи %s удалена из хранилища", id)
+			if err := s.storage.DeleteTask(id); err != nil {
+				Logger.Errorf("Ошибка удаления задачи %s из хранилища: %v", id, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// missedOccurrence - последняя плановая отправка, не выполненная из-за отсутствия подключения
+type missedOccurrence struct {
+	task    *ScheduledTask
+	planned time.Time
+}
+
+// reconnectResend - отправка, которую нужно повторить после восстановления подключения
+type reconnectResend struct {
+	mutex  sync.Mutex
+	missed *missedOccurrence
+}
+
+// markMissed запоминает отправку задачи, не выполненную из-за отсутствия подключения, если включена
+// настройка resend_on_reconnect. Хранится только последняя пропущенная отправка
+func (s *Scheduler) markMissed(task *ScheduledTask, planned time.Time, err error) {
+	if !errors.Is(err, ErrDisconnected) || !s.Settings().ResendOnReconnect || planned.IsZero() {
+		return
+	}
+	s.reconnect.mutex.Lock()
+	defer s.reconnect.mutex.Unlock()
+	if s.reconnect.missed == nil || s.reconnect.missed.task != task || s.reconnect.missed.planned.Before(planned) {
+		s.reconnect.missed = &missedOccurrence{task: task, planned: planned}
+	}
+}
+
+// supersedeMissed забывает пропущенную отправку задачи, если наступила более поздняя отправка:
+// повторять устаревшее сообщение после нового не нужно
+func (s *Scheduler) supersedeMissed(task *ScheduledTask, planned time.Time) {
+	s.reconnect.mutex.Lock()
+	defer s.reconnect.mutex.Unlock()
+	if missed := s.reconnect.missed; missed != nil && missed.task == task && missed.planned.Before(planned) {
+		s.reconnect.missed = nil
+	}
+}
+
+// ReportConnected сразу повторяет последнюю отправку, пропущенную из-за отсутствия подключения,
+// не дожидаясь следующей отправки по интервалу (настройка resend_on_reconnect)
+func (s *Scheduler) ReportConnected() {
+	s.reconnect.mutex.Lock()
+	missed := s.reconnect.missed
+	s.reconnect.missed = nil
+	s.reconnect.mutex.Unlock()
+
+	if missed == nil || !s.Settings().ResendOnReconnect || !s.isCurrent(missed.task) {
+		return
+	}
+	Logger.Infof("🔁 Подключение восстановлено, повторяем пропущенную отправку по задаче %s (%s)",
+		missed.task.ID, missed.planned.Format(time.RFC3339))
+	go s.sendOccurrence(missed.task, missed.planned)
+}
@@ -0,0 +1,217 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+)
+
+// Поведение задач с календарным интервалом в сутки перехода на летнее время, когда время отправки
+// не существует (например, 02:30 при переводе часов с 02:00 на 03:00)
+const (
+	// DSTPolicyShift - отправить позже на величину перевода часов (03:30), по умолчанию
+	DSTPolicyShift = "shift"
+	// DSTPolicySkip - пропустить отправку в эти сутки
+	DSTPolicySkip = "skip"
+)
+
+// Единицы календарного интервала
+const (
+	UnitDay   = "day"
+	UnitWeek  = "week"
+	UnitMonth = "month"
+)
+
+// minutesPerDay - интервалы, кратные суткам, повторяются по часам, а не по длительности
+const minutesPerDay = 24 * 60
+
+// CalendarInterval - интервал в календарных единицах: каждые Count дней, недель или месяцев
+// в одно и то же время по часам часового пояса задачи
+type CalendarInterval struct {
+	Unit  string `json:"unit"`
+	Count int    `json:"count,omitempty"`
+	// At - время отправки ЧЧ:ММ, по умолчанию время начала задачи
+	At string `json:"at,omitempty"`
+}
+
+// validate проверяет интервал и подставляет значения по умолчанию
+func (c *CalendarInterval) validate() error {
+	if c.Count == 0 {
+		c.Count = 1
+	}
+	if c.Count < 0 {
+		return fmt.Errorf("every.count должен быть положительным")
+	}
+	switch c.Unit {
+	case UnitDay, UnitWeek, UnitMonth:
+	default:
+		return fmt.Errorf("неверная единица интервала '%s', допустимо: %s, %s, %s", c.Unit, UnitDay, UnitWeek, UnitMonth)
+	}
+	if c.At != "" {
+		if _, err := time.Parse("15:04", c.At); err != nil {
+			return fmt.Errorf("неверное время every.at '%s', пример: 09:30", c.At)
+		}
+	}
+	return nil
+}
+
+// minutes возвращает наименьшую длительность интервала в минутах (месяц - 28 дней).
+// Используется там, где нужна длительность: ограничения случайной задержки и отображение
+func (c *CalendarInterval) minutes() int {
+	switch c.Unit {
+	case UnitWeek:
+		return c.Count * 7 * minutesPerDay
+	case UnitMonth:
+		return c.Count * 28 * minutesPerDay
+	default:
+		return c.Count * minutesPerDay
+	}
+}
+
+// validateRecurrence проверяет интервал, часовой пояс и политику перевода часов.
+// Календарный интервал Every и правило RRule заменяют Interval, now - текущее время планировщика
+func (t *ScheduledTask) validateRecurrence(now time.Time) error {
+	if _, err := loadTimezone(t.Timezone); err != nil {
+		return err
+	}
+	if t.DSTPolicy != "" && t.DSTPolicy != DSTPolicyShift && t.DSTPolicy != DSTPolicySkip {
+		return fmt.Errorf("неверная политика перевода часов '%s', допустимо: %s, %s", t.DSTPolicy, DSTPolicyShift, DSTPolicySkip)
+	}
+	if t.RRule != "" {
+		if t.Interval != 0 || t.Every != nil {
+			return fmt.Errorf("%w: нельзя одновременно указывать rrule и interval или every", ErrInvalidInterval)
+		}
+		// Interval вычисляется по правилу после определения времени начала (resolveRRule)
+		if _, err := parseRRule(t.RRule, now); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidInterval, err)
+		}
+		return nil
+	}
+	if t.Every != nil {
+		if t.Interval != 0 {
+			return fmt.Errorf("%w: нельзя одновременно указывать interval и every", ErrInvalidInterval)
+		}
+		if err := t.Every.validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidInterval, err)
+		}
+		t.Interval = t.Every.minutes()
+	}
+	if t.Interval <= 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidInterval, t.Interval)
+	}
+	return nil
+}
+
+// alignStart переносит время начала на ближайшее время Every.At не раньше него.
+// Без времени начала первая отправка - ближайшее Every.At после now
+func (t *ScheduledTask) alignStart(now time.Time) {
+	if t.Every == nil || t.Every.At == "" {
+		return
+	}
+	if t.StartTime.IsZero() {
+		t.StartTime = now
+	}
+	at, _ := time.Parse("15:04", t.Every.At)
+	start := t.StartTime.In(t.location())
+	year, month, day := start.Date()
+	aligned := time.Date(year, month, day, at.Hour(), at.Minute(), 0, 0, start.Location())
+	if aligned.Before(start) {
+		aligned = time.Date(year, month, day+1, at.Hour(), at.Minute(), 0, 0, start.Location())
+	}
+	t.StartTime = aligned
+}
+
+// location возвращает часовой пояс задачи
+func (t *ScheduledTask) location() *time.Location {
+	location, err := loadTimezone(t.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+// calendar возвращает календарный интервал задачи: Every или интервал, кратный суткам.
+// nil - отправки идут через фиксированную длительность
+func (t *ScheduledTask) calendar() *CalendarInterval {
+	if t.Every != nil {
+		return t.Every
+	}
+	if t.Interval > 0 && t.Interval%minutesPerDay == 0 {
+		return &CalendarInterval{Unit: UnitDay, Count: t.Interval / minutesPerDay}
+	}
+	return nil
+}
+
+// calendarOccurrence возвращает первое время отправки строго после after по календарному интервалу.
+// Отправки идут в то же время по часам часового пояса задачи, что и первая, поэтому сутки перехода
+// на летнее время (23 или 25 часов) не сдвигают их на час, а ежемесячные приходятся на то же число
+func (t *ScheduledTask) calendarOccurrence(calendar *CalendarInterval, after time.Time) time.Time {
+	start := t.StartTime.In(t.location())
+	after = after.In(start.Location())
+
+	// Номер периода считается по календарю, начинаем с предыдущего на случай,
+	// если время отправки в этот день еще не наступило
+	var passed int
+	switch calendar.Unit {
+	case UnitWeek:
+		passed = calendarDays(start, after) / 7
+	case UnitMonth:
+		passed = (after.Year()-start.Year())*12 + int(after.Month()-start.Month())
+	default:
+		passed = calendarDays(start, after)
+	}
+	for period := max(0, passed/calendar.Count-1); ; period++ {
+		occurrence, ok := t.calendarAt(calendar, start, period*calendar.Count)
+		if ok && occurrence.After(after) {
+			return occurrence
+		}
+	}
+}
+
+// calendarAt возвращает время отправки через units единиц интервала после start по часам start.
+// Если в месяце нет такого числа, отправка приходится на последний день месяца.
+// Несуществующее из-за перевода часов время обрабатывается по DSTPolicy, false - отправка пропускается
+func (t *ScheduledTask) calendarAt(calendar *CalendarInterval, start time.Time, units int) (time.Time, bool) {
+	year, month, day := start.Date()
+	switch calendar.Unit {
+	case UnitWeek:
+		day += 7 * units
+	case UnitMonth:
+		month += time.Month(units)
+		day = min(day, daysIn(year, month))
+	default:
+		day += units
+	}
+	hour, minute, second := start.Clock()
+	occurrence := time.Date(year, month, day, hour, minute, second, start.Nanosecond(), start.Location())
+
+	// Сравниваем показания часов с запрошенными: при несуществующем времени time.Date
+	// возвращает время по одному из смещений, до или после перевода
+	wanted := time.Date(year, month, day, hour, minute, second, 0, time.UTC)
+	got := time.Date(occurrence.Year(), occurrence.Month(), occurrence.Day(),
+		occurrence.Hour(), occurrence.Minute(), occurrence.Second(), 0, time.UTC)
+	shift := got.Sub(wanted)
+	switch {
+	case shift == 0:
+		return occurrence, true
+	case t.DSTPolicy == DSTPolicySkip:
+		return time.Time{}, false
+	case shift < 0:
+		// Время по смещению до перевода (01:30 вместо 02:30) переносим на то же время после (03:30)
+		return occurrence.Add(-shift), true
+	default:
+		return occurrence, true
+	}
+}
+
+// daysIn возвращает количество дней в месяце (month может выходить за 1..12)
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// calendarDays возвращает количество календарных дней от даты from до даты to
+func calendarDays(from, to time.Time) int {
+	fromYear, fromMonth, fromDay := from.Date()
+	toYear, toMonth, toDay := to.Date()
+	return int(time.Date(toYear, toMonth, toDay, 0, 0, 0, 0, time.UTC).
+		Sub(time.Date(fromYear, fromMonth, fromDay, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour))
+}
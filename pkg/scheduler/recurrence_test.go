@@ -0,0 +1,277 @@
+package scheduler
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCalendarOccurrencesAcrossDST(t *testing.T) {
+	tests := []struct {
+		name     string
+		zone     string
+		start    string
+		policy   string
+		from, to string
+		// want - время отправок по часам пояса задачи с сокращением пояса
+		want []string
+	}{
+		{
+			name:  "Berlin spring forward keeps 09:00",
+			zone:  "Europe/Berlin",
+			start: "2025-03-29 09:00",
+			from:  "2025-03-29 00:00", to: "2025-03-31 23:59",
+			want: []string{"2025-03-29 09:00 CET", "2025-03-30 09:00 CEST", "2025-03-31 09:00 CEST"},
+		},
+		{
+			name:  "Berlin spring forward shifts skipped 02:30",
+			zone:  "Europe/Berlin",
+			start: "2025-03-29 02:30",
+			from:  "2025-03-29 00:00", to: "2025-03-31 23:59",
+			want: []string{"2025-03-29 02:30 CET", "2025-03-30 03:30 CEST", "2025-03-31 02:30 CEST"},
+		},
+		{
+			name:   "Berlin spring forward skips skipped 02:30",
+			zone:   "Europe/Berlin",
+			start:  "2025-03-29 02:30",
+			policy: DSTPolicySkip,
+			from:   "2025-03-29 00:00", to: "2025-03-31 23:59",
+			want: []string{"2025-03-29 02:30 CET", "2025-03-31 02:30 CEST"},
+		},
+		{
+			name:  "Berlin fall back keeps 09:00",
+			zone:  "Europe/Berlin",
+			start: "2025-10-25 09:00",
+			from:  "2025-10-25 00:00", to: "2025-10-27 23:59",
+			want: []string{"2025-10-25 09:00 CEST", "2025-10-26 09:00 CET", "2025-10-27 09:00 CET"},
+		},
+		{
+			name:  "New York spring forward keeps 09:00",
+			zone:  "America/New_York",
+			start: "2025-03-08 09:00",
+			from:  "2025-03-08 00:00", to: "2025-03-10 23:59",
+			want: []string{"2025-03-08 09:00 EST", "2025-03-09 09:00 EDT", "2025-03-10 09:00 EDT"},
+		},
+		{
+			name:  "New York spring forward shifts skipped 02:30",
+			zone:  "America/New_York",
+			start: "2025-03-08 02:30",
+			from:  "2025-03-08 00:00", to: "2025-03-10 23:59",
+			want: []string{"2025-03-08 02:30 EST", "2025-03-09 03:30 EDT", "2025-03-10 02:30 EDT"},
+		},
+		{
+			name:   "New York spring forward skips skipped 02:30",
+			zone:   "America/New_York",
+			start:  "2025-03-08 02:30",
+			policy: DSTPolicySkip,
+			from:   "2025-03-08 00:00", to: "2025-03-10 23:59",
+			want: []string{"2025-03-08 02:30 EST", "2025-03-10 02:30 EDT"},
+		},
+		{
+			name:  "New York fall back keeps 09:00",
+			zone:  "America/New_York",
+			start: "2025-11-01 09:00",
+			from:  "2025-11-01 00:00", to: "2025-11-03 23:59",
+			want: []string{"2025-11-01 09:00 EDT", "2025-11-02 09:00 EST", "2025-11-03 09:00 EST"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location := mustLocation(t, tt.zone)
+			task := dailyTask(t, location, tt.start, tt.policy)
+
+			got := task.occurrencesBetween(parseWall(t, location, tt.from), parseWall(t, location, tt.to), 10)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d occurrences %v, want %v", len(got), formatWall(got, location), tt.want)
+			}
+			for i, occurrence := range formatWall(got, location) {
+				if occurrence != tt.want[i] {
+					t.Errorf("occurrence %d = %s, want %s", i, occurrence, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// В сутки перевода часов назад время отправки повторяется, но отправка должна быть одна
+func TestCalendarOccurrencesRepeatedTime(t *testing.T) {
+	tests := []struct {
+		name   string
+		zone   string
+		start  string
+		policy string
+		// day - сутки перевода часов назад, repeated - повторяющееся время отправки
+		day, repeated string
+	}{
+		{name: "Berlin 02:30", zone: "Europe/Berlin", start: "2025-10-25 02:30", day: "2025-10-26", repeated: "02:30"},
+		{name: "Berlin 02:30 skip policy", zone: "Europe/Berlin", start: "2025-10-25 02:30", policy: DSTPolicySkip, day: "2025-10-26", repeated: "02:30"},
+		{name: "New York 01:30", zone: "America/New_York", start: "2025-11-01 01:30", day: "2025-11-02", repeated: "01:30"},
+		{name: "New York 01:30 skip policy", zone: "America/New_York", start: "2025-11-01 01:30", policy: DSTPolicySkip, day: "2025-11-02", repeated: "01:30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location := mustLocation(t, tt.zone)
+			task := dailyTask(t, location, tt.start, tt.policy)
+			day := parseWall(t, location, tt.day+" 00:00")
+			nextDay := day.AddDate(0, 0, 1)
+
+			occurrences := task.occurrencesBetween(day.AddDate(0, 0, -1), nextDay.AddDate(0, 0, 1), 10)
+			if len(occurrences) != 3 {
+				t.Fatalf("got %v, want one occurrence per day for 3 days", formatWall(occurrences, location))
+			}
+			onDay := 0
+			for i, occurrence := range occurrences {
+				if wall := occurrence.In(location).Format("15:04"); wall != tt.repeated {
+					t.Errorf("occurrence %d at %s, want %s", i, wall, tt.repeated)
+				}
+				if !occurrence.Before(day) && occurrence.Before(nextDay) {
+					onDay++
+				}
+				if i > 0 && occurrence.Sub(occurrences[i-1]) < 23*time.Hour {
+					t.Errorf("occurrences %v and %v are less than a day apart", occurrences[i-1], occurrence)
+				}
+			}
+			if onDay != 1 {
+				t.Errorf("%d occurrences on %s, want 1", onDay, tt.day)
+			}
+
+			// Следующая отправка после любого из повторяющихся времен - на следующие сутки, а не через час
+			first := parseWall(t, location, tt.day+" "+tt.repeated)
+			want := nextDay.Format("2006-01-02") + " " + tt.repeated
+			for _, after := range []time.Time{first, first.Add(time.Hour)} {
+				if next := task.plannedOccurrence(after); next.In(location).Format("2006-01-02 15:04") != want {
+					t.Errorf("plannedOccurrence(%v) = %v, want %s", after, next.In(location), want)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveRRule(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	tests := []struct {
+		name     string
+		rrule    string
+		location *time.Location
+		start    string
+		// interval - ожидаемый наименьший промежуток между отправками в минутах
+		interval int
+		// occurrences - первые отправки по часам location
+		occurrences []string
+		wantErr     bool
+	}{
+		{
+			name: "daily", rrule: "FREQ=DAILY", location: time.UTC, start: "2025-06-02 09:00",
+			interval:    24 * 60,
+			occurrences: []string{"2025-06-02 09:00 UTC", "2025-06-03 09:00 UTC", "2025-06-04 09:00 UTC"},
+		},
+		{
+			name: "weekly days with prefix", rrule: "RRULE:FREQ=WEEKLY;BYDAY=MO,WE;BYHOUR=10", location: time.UTC,
+			start:       "2025-06-02 09:00",
+			interval:    2 * 24 * 60,
+			occurrences: []string{"2025-06-02 10:00 UTC", "2025-06-04 10:00 UTC", "2025-06-09 10:00 UTC"},
+		},
+		{
+			name: "hourly interval", rrule: "FREQ=HOURLY;INTERVAL=3", location: time.UTC, start: "2025-06-02 09:00",
+			interval:    3 * 60,
+			occurrences: []string{"2025-06-02 09:00 UTC", "2025-06-02 12:00 UTC", "2025-06-02 15:00 UTC"},
+		},
+		{
+			name: "month end skips short months", rrule: "FREQ=MONTHLY;BYMONTHDAY=31", location: time.UTC,
+			start:       "2025-06-02 09:00",
+			interval:    31 * 24 * 60,
+			occurrences: []string{"2025-07-31 09:00 UTC", "2025-08-31 09:00 UTC", "2025-10-31 09:00 UTC"},
+		},
+		{
+			name: "local time across DST", rrule: "FREQ=WEEKLY;BYDAY=SU;BYHOUR=9", location: berlin,
+			start:       "2025-10-19 08:00",
+			interval:    7*24*60 - 60,
+			occurrences: []string{"2025-10-19 09:00 CEST", "2025-10-26 09:00 CET", "2025-11-02 09:00 CET"},
+		},
+		{
+			name: "count ends the task", rrule: "FREQ=DAILY;COUNT=2", location: time.UTC, start: "2025-06-02 09:00",
+			interval:    24 * 60,
+			occurrences: []string{"2025-06-02 09:00 UTC", "2025-06-03 09:00 UTC"},
+		},
+		{
+			// Единственная отправка: интервалом считается вся длительность задачи
+			name: "single occurrence", rrule: "FREQ=DAILY;COUNT=1", location: time.UTC, start: "2025-06-02 09:00",
+			interval:    365 * 24 * 60,
+			occurrences: []string{"2025-06-02 09:00 UTC"},
+		},
+		{name: "unknown frequency", rrule: "FREQ=SOMETIMES", location: time.UTC, start: "2025-06-02 09:00", wantErr: true},
+		{name: "not a rule", rrule: "every monday", location: time.UTC, start: "2025-06-02 09:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := parseWall(t, tt.location, tt.start)
+			task := &ScheduledTask{
+				RRule:     tt.rrule,
+				Timezone:  tt.location.String(),
+				StartTime: start,
+				EndTime:   start.AddDate(1, 0, 0),
+			}
+			err := task.resolveRRule()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveRRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if task.Interval != tt.interval {
+				t.Errorf("Interval = %d, want %d", task.Interval, tt.interval)
+			}
+
+			var occurrences []time.Time
+			after := start.Add(-time.Second)
+			for range 3 {
+				next := task.rruleOccurrence(after)
+				if next.After(task.EndTime) {
+					break
+				}
+				occurrences = append(occurrences, next)
+				after = next
+			}
+			if got := formatWall(occurrences, tt.location); !slices.Equal(got, tt.occurrences) {
+				t.Errorf("occurrences = %v, want %v", got, tt.occurrences)
+			}
+		})
+	}
+}
+
+// dailyTask создает ежедневную задачу с первой отправкой start (ГГГГ-ММ-ДД ЧЧ:ММ по часам location)
+func dailyTask(t *testing.T, location *time.Location, start, policy string) *ScheduledTask {
+	t.Helper()
+	task := &ScheduledTask{
+		Every:     &CalendarInterval{Unit: UnitDay},
+		StartTime: parseWall(t, location, start),
+		Timezone:  location.String(),
+		DSTPolicy: policy,
+	}
+	if err := task.validateRecurrence(task.StartTime); err != nil {
+		t.Fatalf("validateRecurrence: %v", err)
+	}
+	task.EndTime = task.StartTime.AddDate(1, 0, 0)
+	return task
+}
+
+// parseWall разбирает время по часам location
+func parseWall(t *testing.T, location *time.Location, value string) time.Time {
+	t.Helper()
+	at, err := time.ParseInLocation("2006-01-02 15:04", value, location)
+	if err != nil {
+		t.Fatalf("ParseInLocation(%s): %v", value, err)
+	}
+	return at
+}
+
+// formatWall возвращает времена по часам location с сокращением пояса
+func formatWall(times []time.Time, location *time.Location) []string {
+	formatted := make([]string, len(times))
+	for i, at := range times {
+		formatted[i] = at.In(location).Format("2006-01-02 15:04 MST")
+	}
+	return formatted
+}
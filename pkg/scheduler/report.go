@@ -0,0 +1,257 @@
+package scheduler
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Статусы получателя в отчете о рассылке
+const (
+	ReportStatusQueued    = "queued"
+	ReportStatusSent      = "sent"
+	ReportStatusDelivered = "delivered"
+	ReportStatusRead      = "read"
+	ReportStatusFailed    = "failed"
+	// ReportStatusSkipped - получатель уже получил сообщение в одной из прошлых рассылок (SendOncePerRecipient)
+	ReportStatusSkipped = "skipped"
+	// ReportStatusSuppressed - получатель в списке исключений рассылок
+	ReportStatusSuppressed = "suppressed"
+)
+
+// ErrReportNotFound - у задачи нет рассылки с таким номером
+var ErrReportNotFound = errors.New("отчет о рассылке не найден")
+
+// RecipientReport - результат отправки одному получателю рассылки
+type RecipientReport struct {
+	ChatName  string    `json:"chat_name"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// SendAt - время отправки по местному времени получателя (ScheduledTask.LocalTime)
+	SendAt *time.Time `json:"send_at,omitempty"`
+	// Language - язык получателя из списка получателей (ScheduledTask.Translations)
+	Language string `json:"language,omitempty"`
+}
+
+// OccurrenceReport - отчет об одной рассылке задачи
+type OccurrenceReport struct {
+	TaskID string `json:"task_id"`
+	// Occurrence - номер рассылки задачи, начиная с 1
+	Occurrence int               `json:"occurrence"`
+	PlannedAt  time.Time         `json:"planned_at"`
+	Recipients []RecipientReport `json:"recipients"`
+	// DuplicatesCollapsed - сколько повторов убрано при объединении списков получателей
+	DuplicatesCollapsed int `json:"duplicates_collapsed"`
+}
+
+// startReport создает отчет о рассылке planned со статусом queued для всех получателей
+// и возвращает ее номер. sendAt и languages - время отправки и язык каждого получателя, могут быть nil.
+// Продолженная после перезапуска рассылка сохраняет номер
+func (st *Storage) startReport(taskID string, planned time.Time, recipients []string, sendAt []time.Time,
+	languages []string) (int, error) {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var occurrence int
+	err = tx.QueryRow(`SELECT occurrence FROM broadcast_reports WHERE task_id = ? AND planned_at = ? LIMIT 1`,
+		taskID, planned.UTC()).Scan(&occurrence)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = tx.QueryRow(`SELECT COALESCE(MAX(occurrence), 0) + 1 FROM broadcast_reports WHERE task_id = ?`,
+			taskID).Scan(&occurrence)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for index, chatName := range recipients {
+		sealed, err := st.EncryptField(chatName)
+		if err != nil {
+			return 0, err
+		}
+		var recipientSendAt any
+		if sendAt != nil {
+			recipientSendAt = sendAt[index].UTC()
+		}
+		var language string
+		if languages != nil {
+			language = languages[index]
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO broadcast_reports
+			(task_id, occurrence, planned_at, recipient, chat_name, status, updated_at, send_at, language)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			taskID, occurrence, planned.UTC(), index, sealed, ReportStatusQueued, now, recipientSendAt, language); err != nil {
+			return 0, err
+		}
+	}
+	return occurrence, tx.Commit()
+}
+
+// updateReport записывает результат отправки получателю recipient
+func (st *Storage) updateReport(taskID string, occurrence, recipient int, messageID string, sendErr error) error {
+	status, reason := ReportStatusSent, ""
+	if sendErr != nil {
+		status, reason = ReportStatusFailed, sendErr.Error()
+	}
+	sealed, err := st.EncryptField(reason)
+	if err != nil {
+		return err
+	}
+	_, err = st.db.Exec(`UPDATE broadcast_reports SET status = ?, error = ?, message_id = ?, updated_at = ?
+		WHERE task_id = ? AND occurrence = ? AND recipient = ?`,
+		status, sealed, messageID, time.Now(), taskID, occurrence, recipient)
+	return err
+}
+
+// receivedRecipients возвращает чаты, успешно получившие одну из рассылок задачи до occurrence
+func (st *Storage) receivedRecipients(taskID string, occurrence int) (map[string]bool, error) {
+	rows, err := st.db.Query(`SELECT chat_name FROM broadcast_reports
+		WHERE task_id = ? AND occurrence < ? AND status IN (?, ?, ?)`,
+		taskID, occurrence, ReportStatusSent, ReportStatusDelivered, ReportStatusRead)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	received := map[string]bool{}
+	for rows.Next() {
+		var chatName string
+		if err := rows.Scan(&chatName); err != nil {
+			return nil, err
+		}
+		if err := st.decryptFields(&chatName); err != nil {
+			return nil, err
+		}
+		received[chatName] = true
+	}
+	return received, rows.Err()
+}
+
+// reportRecipients возвращает получателей рассылки задачи, запланированной на planned, в порядке номеров,
+// время отправки каждому по местному времени (nil - рассылка без local_time) и их языки.
+// Пустой список - рассылка еще не начиналась
+func (st *Storage) reportRecipients(taskID string, planned time.Time) ([]string, []time.Time, []string, error) {
+	rows, err := st.db.Query(`SELECT chat_name, send_at, language FROM broadcast_reports
+		WHERE task_id = ? AND planned_at = ? ORDER BY recipient`, taskID, planned.UTC())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer rows.Close()
+
+	var recipients, languages []string
+	var sendTimes []time.Time
+	for rows.Next() {
+		var chatName, language string
+		var sendAt sql.NullTime
+		if err := rows.Scan(&chatName, &sendAt, &language); err != nil {
+			return nil, nil, nil, err
+		}
+		if err := st.decryptFields(&chatName); err != nil {
+			return nil, nil, nil, err
+		}
+		recipients = append(recipients, chatName)
+		languages = append(languages, language)
+		if sendAt.Valid {
+			sendTimes = append(sendTimes, sendAt.Time)
+		}
+	}
+	if len(sendTimes) != len(recipients) {
+		sendTimes = nil
+	}
+	return recipients, sendTimes, languages, rows.Err()
+}
+
+// setReportStatus устанавливает статус получателя recipient в рассылке occurrence
+func (st *Storage) setReportStatus(taskID string, occurrence, recipient int, status string) error {
+	_, err := st.db.Exec(`UPDATE broadcast_reports SET status = ?, updated_at = ?
+		WHERE task_id = ? AND occurrence = ? AND recipient = ?`,
+		status, time.Now(), taskID, occurrence, recipient)
+	return err
+}
+
+// updateReceipts повышает статус получателей по подтверждениям доставки и прочтения.
+// Подтверждения приходят не по порядку, поэтому статус только повышается
+func (st *Storage) updateReceipts(messageIDs []string, status string) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	previous := []any{ReportStatusSent}
+	if status == ReportStatusRead {
+		previous = append(previous, ReportStatusDelivered)
+	}
+	args := append([]any{status, time.Now()}, previous...)
+	for _, id := range messageIDs {
+		args = append(args, id)
+	}
+	_, err := st.db.Exec(`UPDATE broadcast_reports SET status = ?, updated_at = ?
+		WHERE status IN (?`+strings.Repeat(", ?", len(previous)-1)+`)
+		AND message_id IN (?`+strings.Repeat(", ?", len(messageIDs)-1)+`)`, args...)
+	return err
+}
+
+// OccurrenceReport возвращает отчет о рассылке occurrence задачи
+func (st *Storage) OccurrenceReport(taskID string, occurrence int) (*OccurrenceReport, error) {
+	rows, err := st.db.Query(`SELECT planned_at, chat_name, status, error, updated_at, send_at, language
+		FROM broadcast_reports WHERE task_id = ? AND occurrence = ? ORDER BY recipient`, taskID, occurrence)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &OccurrenceReport{TaskID: taskID, Occurrence: occurrence, Recipients: []RecipientReport{}}
+	for rows.Next() {
+		var recipient RecipientReport
+		var sendAt sql.NullTime
+		if err := rows.Scan(&report.PlannedAt, &recipient.ChatName, &recipient.Status, &recipient.Error,
+			&recipient.UpdatedAt, &sendAt, &recipient.Language); err != nil {
+			return nil, err
+		}
+		if sendAt.Valid {
+			recipient.SendAt = &sendAt.Time
+		}
+		if err := st.decryptFields(&recipient.ChatName, &recipient.Error); err != nil {
+			return nil, err
+		}
+		report.Recipients = append(report.Recipients, recipient)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(report.Recipients) == 0 {
+		return nil, ErrReportNotFound
+	}
+	err = st.db.QueryRow(`SELECT duplicates FROM broadcast_duplicates WHERE task_id = ? AND planned_at = ?`,
+		taskID, report.PlannedAt.UTC()).Scan(&report.DuplicatesCollapsed)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return report, nil
+}
+
+// setReportDuplicates запоминает количество повторов, убранных из получателей рассылки planned
+func (st *Storage) setReportDuplicates(taskID string, planned time.Time, duplicates int) error {
+	_, err := st.db.Exec(`INSERT OR REPLACE INTO broadcast_duplicates (task_id, planned_at, duplicates) VALUES (?, ?, ?)`,
+		taskID, planned.UTC(), duplicates)
+	return err
+}
+
+// ReportReceipt обновляет отчеты о рассылках по подтверждению доставки (read = false) или прочтения
+func (s *Scheduler) ReportReceipt(messageIDs []string, read bool) {
+	status := ReportStatusDelivered
+	if read {
+		status = ReportStatusRead
+	}
+	if err := s.storage.updateReceipts(messageIDs, status); err != nil {
+		Logger.Errorf("Ошибка обновления отчета о рассылке: %v", err)
+	}
+	if read {
+		if err := s.storage.markVariantsRead(messageIDs); err != nil {
+			Logger.Errorf("Ошибка учета прочтения вариантов сообщения: %v", err)
+		}
+	}
+}
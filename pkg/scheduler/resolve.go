@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrAmbiguousChat - названию чата соответствует несколько контактов или групп
+var ErrAmbiguousChat = errors.New("названию соответствует несколько чатов")
+
+// ChatCandidate - контакт или группа, подходящие под название чата
+type ChatCandidate struct {
+	JID string `json:"jid"`
+	// Kind - contact или group
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Phone - номер телефона контакта
+	Phone        string `json:"phone,omitempty"`
+	PushName     string `json:"push_name,omitempty"`
+	BusinessName string `json:"business_name,omitempty"`
+	// Participants - количество участников группы
+	Participants int `json:"participants,omitempty"`
+}
+
+// ChatResolver - необязательная возможность транспорта: поиск всех чатов с указанным названием
+type ChatResolver interface {
+	ResolveChat(chatName string) ([]ChatCandidate, error)
+}
+
+// GroupMemberLister - необязательная возможность транспорта: участники группы для рассылки
+// в личные чаты (задачи с GroupMembers)
+type GroupMemberLister interface {
+	// GroupMembers возвращает JID участников группы, кроме собственного
+	GroupMembers(chatName, chatJID string) ([]string, error)
+}
+
+// AmbiguousChatError возвращает ошибку неоднозначного названия чата со списком подходящих JID
+func AmbiguousChatError(chatName string, candidates []ChatCandidate) error {
+	jids := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		jids[i] = candidate.JID
+	}
+	return fmt.Errorf("%w '%s': %s, укажите JID (GET /resolve?name=...)", ErrAmbiguousChat, chatName, strings.Join(jids, ", "))
+}
+
+// resolveTaskChats проверяет, что названия чатов задачи однозначны, и сохраняет JID чата ChatName.
+// Для неоднозначного ChatName задача должна хранить выбранный JID (ChatJID), получателей рассылки нужно указать JID.
+// Если транспорт не умеет искать чаты или недоступен, проверка пропускается
+func (s *Scheduler) resolveTaskChats(task *ScheduledTask) error {
+	resolver, ok := s.sender.(ChatResolver)
+	if !ok {
+		return nil
+	}
+
+	chats := task.Recipients
+	if task.ChatJID == "" {
+		chats = append([]string{task.ChatName}, chats...)
+	}
+	for _, chatName := range chats {
+		candidates, err := resolver.ResolveChat(chatName)
+		if err != nil {
+			Logger.Warnf("Проверка чата '%s' пропущена: %v", chatName, err)
+			return nil
+		}
+		if chatName == task.ChatName {
+			task.pinTaskChat(candidates)
+			if task.GroupMembers && len(candidates) == 1 && candidates[0].Kind != "group" {
+				return fmt.Errorf("group_members: чат '%s' не является группой", chatName)
+			}
+		}
+		if len(candidates) > 1 {
+			if chatName == task.ChatName {
+				return fmt.Errorf("%v; для chat_name можно указать chat_jid", AmbiguousChatError(chatName, candidates))
+			}
+			return AmbiguousChatError(chatName, candidates)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// janitorInterval - период удаления устаревших данных по настройкам хранения
+const janitorInterval = time.Hour
+
+// RetentionResult - количество удаленных записей и файлов при очистке по срокам хранения
+type RetentionResult struct {
+	History       int64 `json:"history"`
+	Messages      int64 `json:"messages"`
+	Media         int64 `json:"media"`
+	IncomingMedia int64 `json:"incoming_media"`
+}
+
+// runJanitor периодически удаляет данные старше сроков хранения из настроек
+func (s *Scheduler) runJanitor() {
+	for range time.Tick(janitorInterval) {
+		if _, err := s.PurgeExpired(); err != nil {
+			Logger.Errorf("Ошибка очистки по срокам хранения: %v", err)
+		}
+	}
+}
+
+// PurgeExpired удаляет историю, сохраненные сообщения и файлы медиатеки старше
+// history_retention_days, messages_retention_days и media_retention_days, а также вложения
+// входящих сообщений по их собственным ограничениям. Файлы, используемые задачами, не удаляются
+func (s *Scheduler) PurgeExpired() (RetentionResult, error) {
+	result, err := s.purgeExpired()
+	s.recordJanitorRun(result, err)
+	return result, err
+}
+
+// purgeExpired выполняет очистку по срокам хранения
+func (s *Scheduler) purgeExpired() (RetentionResult, error) {
+	settings := s.Settings()
+	var result RetentionResult
+	var err error
+
+	if settings.HistoryRetentionDays > 0 {
+		if result.History, err = s.storage.DeleteHistoryBefore(retentionCutoff(settings.HistoryRetentionDays)); err != nil {
+			return result, fmt.Errorf("ошибка удаления истории: %v", err)
+		}
+	}
+	if settings.MessagesRetentionDays > 0 {
+		if result.Messages, err = s.storage.DeleteStoredMessagesBefore(retentionCutoff(settings.MessagesRetentionDays)); err != nil {
+			return result, fmt.Errorf("ошибка удаления сохраненных сообщений: %v", err)
+		}
+	}
+	if settings.MediaRetentionDays > 0 {
+		if result.Media, err = s.DeleteMediaBefore(retentionCutoff(settings.MediaRetentionDays)); err != nil {
+			return result, fmt.Errorf("ошибка удаления файлов медиатеки: %v", err)
+		}
+	}
+	var before time.Time
+	if settings.IncomingMediaRetentionDays > 0 {
+		before = retentionCutoff(settings.IncomingMediaRetentionDays)
+	}
+	incoming, err := s.storage.purgeIncomingMedia(before, int64(settings.IncomingMediaMaxMB)<<20)
+	if err != nil {
+		return result, fmt.Errorf("ошибка удаления вложений входящих сообщений: %v", err)
+	}
+	result.IncomingMedia = int64(incoming)
+
+	if result != (RetentionResult{}) {
+		Logger.Infof("🧹 Очистка по срокам хранения: история %d, сообщения %d, медиатека %d, вложения входящих %d",
+			result.History, result.Messages, result.Media, result.IncomingMedia)
+	}
+	return result, nil
+}
+
+// retentionCutoff возвращает момент, раньше которого данные устарели при сроке хранения days дней
+func retentionCutoff(days int) time.Time {
+	return time.Now().AddDate(0, 0, -days)
+}
+
+// validateRetention проверяет сроки хранения данных
+func (st *Settings) validateRetention() error {
+	if st.HistoryRetentionDays < 0 || st.MessagesRetentionDays < 0 || st.MediaRetentionDays < 0 {
+		return fmt.Errorf("history_retention_days, messages_retention_days и media_retention_days не могут быть отрицательными")
+	}
+	return nil
+}
+
+// DeleteMediaBefore удаляет загруженные вручную файлы медиатеки старше before, кроме используемых
+// активными и сохраненными задачами. Вложения входящих сообщений хранятся по своим настройкам
+func (s *Scheduler) DeleteMediaBefore(before time.Time) (int64, error) {
+	inUse := map[string]bool{}
+	for _, task := range s.ListTasks() {
+		inUse[task.MediaID] = true
+	}
+	saved, _, err := s.storage.loadTasks()
+	if err != nil {
+		return 0, err
+	}
+	for _, task := range saved {
+		inUse[task.MediaID] = true
+	}
+
+	rows, err := s.storage.db.Query(`SELECT id, sha256 FROM media WHERE incoming = 0 AND created_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	var expired []MediaAsset
+	for rows.Next() {
+		var asset MediaAsset
+		if err := rows.Scan(&asset.ID, &asset.SHA256); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !inUse[asset.ID] {
+			expired = append(expired, asset)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, asset := range expired {
+		if err := s.storage.deleteMedia(asset, false); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(expired)), nil
+}
+
+// deleteMedia удаляет файл медиатеки вместе с миниатюрой. Связи с сообщениями и кэш загрузок
+// удаляются каскадно. Запись удаляется, только если признак incoming не изменился
+func (st *Storage) deleteMedia(asset MediaAsset, incoming bool) error {
+	res, err := st.db.Exec(`DELETE FROM media WHERE id = ? AND incoming = ?`, asset.ID, incoming)
+	if err != nil {
+		return err
+	}
+	if deleted, err := res.RowsAffected(); err != nil || deleted == 0 {
+		return err
+	}
+	for _, path := range []string{asset.Path(), asset.thumbnailPath()} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			Logger.Warnf("Ошибка удаления файла медиатеки %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// DeleteStoredMessagesBefore удаляет сохраненные сообщения старше before и возвращает их количество
+func (st *Storage) DeleteStoredMessagesBefore(before time.Time) (int64, error) {
+	res, err := st.db.Exec(`DELETE FROM stored_messages WHERE created_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,131 @@
+package scheduler
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// RetryResult - результат повторной отправки в чат
+type RetryResult struct {
+	ChatName string `json:"chat_name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// RetryFailed сразу повторяет отправку сообщения задачи в чаты, последняя отправка в которые
+// по истории завершилась ошибкой. Возвращает false, если задача не найдена
+func (s *Scheduler) RetryFailed(id string) ([]RetryResult, bool, error) {
+	s.mutex.RLock()
+	task, exists := s.tasks[id]
+	s.mutex.RUnlock()
+	if !exists {
+		return nil, false, nil
+	}
+
+	attempts, err := s.storage.lastTaskAttempts(task.ID)
+	if err != nil {
+		return nil, true, err
+	}
+	occurrence, err := s.storage.latestReport(task.ID)
+	if err != nil {
+		Logger.Errorf("Ошибка чтения отчета о рассылке задачи %s: %v", task.ID, err)
+	}
+	recipients := task.recipients()
+	var languages []string
+	if task.GroupMembers || len(task.RecipientLists) > 0 {
+		// Участники группы и списки получателей раскрываются при рассылке - берем получателей последней
+		recipients = nil
+		if report, err := s.storage.OccurrenceReport(task.ID, occurrence); err == nil {
+			for _, recipient := range report.Recipients {
+				recipients = append(recipients, recipient.ChatName)
+				languages = append(languages, recipient.Language)
+			}
+		}
+	}
+
+	results := []RetryResult{}
+	var failed []int
+	for index, chatName := range recipients {
+		if status, ok := attempts[chatName]; ok && status != HistoryStatusSent {
+			failed = append(failed, index)
+		}
+	}
+	if len(failed) == 0 {
+		return results, true, nil
+	}
+
+	message, err := task.ResolveMessage(task.ctx)
+	if err != nil {
+		return nil, true, err
+	}
+	generator, _ := s.sender.(MessageIDGenerator)
+
+	Logger.Infof("🔁 Повторная отправка по задаче %s в %d чатов с ошибками", task.ID, len(failed))
+	msg := OutgoingMessage{
+		TaskID:      task.ID,
+		Owner:       task.Owner,
+		Message:     message,
+		Media:       task.media(),
+		ForwardID:   task.ForwardMessageID,
+		Interactive: task.Interactive,
+		Poll:        task.Poll,
+		LinkPreview: !task.DisableLinkPreview,
+		Expiration:  task.expiration(),
+		Timeout:     time.Duration(task.SendTimeoutSeconds) * time.Second,
+	}
+	for _, index := range failed {
+		msg.ChatName, msg.ChatJID = recipients[index], task.recipientJID(recipients, index)
+		if languages != nil {
+			msg.Message, msg.Language = task.localize(languages[index], message), languages[index]
+		}
+		if generator != nil {
+			msg.ID = generator.GenerateMessageID()
+		}
+		sendErr := s.deliverToRecipient(task, msg)
+		result := RetryResult{ChatName: msg.ChatName, Status: SendStatus(sendErr)}
+		if sendErr != nil {
+			result.Error = sendErr.Error()
+		}
+		results = append(results, result)
+
+		if occurrence > 0 && task.broadcast() {
+			if err := s.storage.updateReport(task.ID, occurrence, index, msg.ID, sendErr); err != nil {
+				Logger.Errorf("Ошибка обновления отчета о рассылке задачи %s: %v", task.ID, err)
+			}
+		}
+	}
+	return results, true, nil
+}
+
+// lastTaskAttempts возвращает статус последней отправки задачи в каждый чат по истории
+func (st *Storage) lastTaskAttempts(taskID string) (map[string]string, error) {
+	rows, err := st.db.Query(`SELECT chat_name, status FROM history WHERE task_id = ? ORDER BY id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := map[string]string{}
+	for rows.Next() {
+		var chatName, status string
+		if err := rows.Scan(&chatName, &status); err != nil {
+			return nil, err
+		}
+		if err := st.decryptFields(&chatName); err != nil {
+			return nil, err
+		}
+		attempts[chatName] = status
+	}
+	return attempts, rows.Err()
+}
+
+// latestReport возвращает номер последней рассылки задачи, 0 - рассылок не было
+func (st *Storage) latestReport(taskID string) (int, error) {
+	var occurrence sql.NullInt64
+	err := st.db.QueryRow(`SELECT MAX(occurrence) FROM broadcast_reports WHERE task_id = ?`, taskID).Scan(&occurrence)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return int(occurrence.Int64), err
+}
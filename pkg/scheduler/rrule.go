@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// rruleSampleSize - количество первых отправок, по которым оценивается наименьший интервал RRULE
+const rruleSampleSize = 100
+
+// parseRRule разбирает правило повторения iCalendar (FREQ=WEEKLY;BYDAY=MO,WE;BYHOUR=10)
+// с началом отсчета start
+func parseRRule(value string, start time.Time) (*rrule.RRule, error) {
+	option, err := rrule.StrToROption(strings.TrimPrefix(strings.TrimSpace(value), "RRULE:"))
+	if err != nil {
+		return nil, fmt.Errorf("неверное правило повторения rrule: %v", err)
+	}
+	option.Dtstart = start
+	rule, err := rrule.NewRRule(*option)
+	if err != nil {
+		return nil, fmt.Errorf("неверное правило повторения rrule: %v", err)
+	}
+	return rule, nil
+}
+
+// rule возвращает правило повторения задачи в ее часовом поясе
+func (t *ScheduledTask) rule() (*rrule.RRule, error) {
+	return parseRRule(t.RRule, t.StartTime.In(t.location()))
+}
+
+// resolveRRule проверяет правило повторения и вычисляет Interval - наименьший промежуток
+// между первыми отправками, от которого зависят ограничения случайной задержки
+func (t *ScheduledTask) resolveRRule() error {
+	if t.RRule == "" {
+		return nil
+	}
+	rule, err := t.rule()
+	if err != nil {
+		return err
+	}
+	iterator := rule.Iterator()
+	previous, ok := iterator()
+	if !ok {
+		return fmt.Errorf("правило повторения rrule не дает ни одной отправки")
+	}
+
+	shortest := time.Duration(0)
+	for i := 1; i < rruleSampleSize; i++ {
+		next, ok := iterator()
+		if !ok {
+			break
+		}
+		if gap := next.Sub(previous); shortest == 0 || gap < shortest {
+			shortest = gap
+		}
+		previous = next
+	}
+	if shortest == 0 {
+		// Единственная отправка
+		shortest = t.EndTime.Sub(t.StartTime)
+	}
+	t.Interval = max(1, int(shortest/time.Minute))
+	return nil
+}
+
+// rruleOccurrence возвращает первую отправку по правилу повторения строго после after.
+// Когда отправки по правилу закончились (COUNT, UNTIL), возвращает время после окончания задачи
+func (t *ScheduledTask) rruleOccurrence(after time.Time) time.Time {
+	rule, err := t.rule()
+	if err == nil {
+		if next := rule.After(after, false); !next.IsZero() {
+			return next
+		}
+	}
+	return maxTime(after, t.EndTime).Add(time.Second)
+}
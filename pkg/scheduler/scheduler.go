@@ -0,0 +1,722 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logger - логгер пакета, по умолчанию стандартный логгер logrus
+var Logger = logrus.StandardLogger()
+
+// Scheduler - планировщик отправки сообщений
+type Scheduler struct {
+	tasks      map[string]*ScheduledTask
+	mutex      sync.RWMutex
+	sender     MessageSender
+	storage    *Storage
+	limiter    *RateLimiter
+	quotas     *SendQuotas
+	breakers   *CircuitBreakers
+	presence   *PresenceTracker
+	chatQueues *chatQueues
+	sendSlots  sendSlots
+	blockRules BlockRules
+	alerts     AlertLog
+	events     EventBus
+	// generation - счетчик запусков задач, отличает текущий запуск от устаревших горутин
+	generation uint64
+	// standby - экземпляр резервный и не отправляет сообщения, см. RunLeaderElection
+	standby atomic.Bool
+	// loggedOut - сессия WhatsApp завершена, отправки приостановлены до повторной авторизации
+	loggedOut atomic.Bool
+	// readOnly - режим только для чтения, см. SetReadOnly
+	readOnly atomic.Bool
+	// closing и sendingMutex - остановка процесса, см. Shutdown. Каждая отправка удерживает
+	// sendingMutex на чтение
+	closing      atomic.Bool
+	sendingMutex sync.RWMutex
+	// settings - настройки, изменяемые во время работы
+	settings      Settings
+	settingsMutex sync.RWMutex
+	// clock - источник времени цикла задач, см. SetClock
+	clock Clock
+	// reconnect - отправка, пропущенная из-за отсутствия подключения, см. ReportConnected
+	reconnect reconnectResend
+	// transcodes - перекодирование видео медиатеки, см. StartTranscode
+	transcodes transcodeJobs
+	// janitor - последняя очистка по срокам хранения, см. PurgeExpired
+	janitor janitorState
+	// banDetector - обнаружение ограничения аккаунта по ошибкам отправки, см. detectBan
+	banDetector banDetector
+	// oneOffs - отложенные разовые отправки, см. ScheduleOneOff
+	oneOffs oneOffSends
+}
+
+// Config - настройки планировщика
+type Config struct {
+	// RateLimit - максимум отправок в минуту по всем способам отправки, 0 - без ограничений
+	RateLimit int
+	// BreakerThreshold - количество ошибок подряд, после которого отправки задач в чат
+	// приостанавливаются, 0 - без приостановки
+	BreakerThreshold int
+	// SendTimeout - максимальное время отправки одного сообщения, 0 - DefaultSendTimeout
+	SendTimeout time.Duration
+	// ChatSendGap - минимальная пауза между сообщениями в один чат, 0 - без паузы
+	// (сообщения в чат все равно отправляются по одному)
+	ChatSendGap time.Duration
+	// MaxConcurrentSends - максимум одновременных отправок по всем задачам и API, 0 - без ограничения
+	MaxConcurrentSends int
+}
+
+// DefaultConfig возвращает настройки по умолчанию
+func DefaultConfig() Config {
+	return Config{
+		RateLimit:          DefaultRateLimit,
+		BreakerThreshold:   DefaultBreakerThreshold,
+		SendTimeout:        DefaultSendTimeout,
+		ChatSendGap:        DefaultChatSendGap,
+		MaxConcurrentSends: DefaultMaxConcurrentSends,
+	}
+}
+
+// NewScheduler создает планировщик, отправляющий сообщения через sender,
+// и загружает его настройки из хранилища
+func NewScheduler(storage *Storage, sender MessageSender, config Config) (*Scheduler, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("не задано хранилище планировщика")
+	}
+	if sender == nil {
+		return nil, fmt.Errorf("не задан способ отправки сообщений")
+	}
+
+	s := &Scheduler{
+		tasks:    make(map[string]*ScheduledTask),
+		sender:   sender,
+		storage:  storage,
+		limiter:  newRateLimiter(config.RateLimit, time.Minute),
+		quotas:   newSendQuotas(),
+		breakers: newCircuitBreakers(config.BreakerThreshold),
+		presence: newPresenceTracker(),
+		clock:    realClock{},
+
+		chatQueues: newChatQueues(config.ChatSendGap),
+		sendSlots:  newSendSlots(config.MaxConcurrentSends),
+		oneOffs:    newOneOffSends(),
+	}
+
+	settings := settingsFromConfig(config)
+	if settings.SendTimeoutSeconds <= 0 {
+		settings.SendTimeoutSeconds = int(DefaultSendTimeout.Seconds())
+	}
+	stored := settings
+	if err := storage.loadSettings(&stored); err != nil {
+		return nil, fmt.Errorf("ошибка загрузки настроек: %v", err)
+	}
+	if err := stored.Validate(); err != nil {
+		Logger.Warnf("Сохраненные настройки не применены: %v", err)
+	} else {
+		settings = stored
+	}
+	s.applySettings(settings)
+
+	if err := storage.loadGlobalExclusions(); err != nil {
+		return nil, fmt.Errorf("ошибка загрузки исключений: %v", err)
+	}
+	if err := storage.loadBlockRules(&s.blockRules); err != nil {
+		return nil, fmt.Errorf("ошибка загрузки правил блокировки: %v", err)
+	}
+	return s, nil
+}
+
+// Storage возвращает хранилище планировщика
+func (s *Scheduler) Storage() *Storage {
+	return s.storage
+}
+
+// Breakers возвращает состояние приостановленных чатов
+func (s *Scheduler) Breakers() *CircuitBreakers {
+	return s.breakers
+}
+
+// BlockRules возвращает правила автоматической блокировки
+func (s *Scheduler) BlockRules() *BlockRules {
+	return &s.blockRules
+}
+
+// Alerts возвращает последние оповещения, новые первыми
+func (s *Scheduler) Alerts() []Alert {
+	return s.alerts.List()
+}
+
+// GetCurrentTask возвращает копию текущей активной задачи (если есть)
+func (s *Scheduler) GetCurrentTask() *ScheduledTask {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	// Возвращаем первую найденную задачу (у нас только одна)
+	for _, task := range s.tasks {
+		return task.snapshot()
+	}
+	return nil
+}
+
+// ListTasks возвращает копии активных задач: поля задач (NextSendAt, Paused) меняются под
+// блокировкой планировщика, поэтому вызывающий код не должен читать их у самих задач
+func (s *Scheduler) ListTasks() []*ScheduledTask {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	tasks := make([]*ScheduledTask, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		tasks = append(tasks, task.snapshot())
+	}
+	return tasks
+}
+
+// AddTask добавляет новую задачу, заменяя существующую если нужно
+func (s *Scheduler) AddTask(task *ScheduledTask) (string, error) {
+	// Поиск чатов обращается к WhatsApp, поэтому выполняется до блокировки
+	if err := s.resolveTaskChats(task); err != nil {
+		return "", err
+	}
+	confirmRecipients := s.Settings().ConfirmRecipients
+	var recipients []string
+	var duplicates int
+	if confirmRecipients > 0 && task.broadcast() {
+		recipients, duplicates = s.countRecipients(task)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Проверяем, есть ли уже активная задача
+	var existingTask *ScheduledTask
+	for _, t := range s.tasks {
+		existingTask = t
+		break
+	}
+
+	if existingTask != nil {
+		// Останавливаем существующую задачу
+		s.removeTask(existingTask)
+		Logger.Infof("🔄 Остановлена существующая задача %s для замены новой", existingTask.ID)
+	}
+
+	// Проверяем валидность данных
+	if err := s.prepareTask(task, recipients, duplicates, confirmRecipients); err != nil {
+		return "", err
+	}
+
+	// Добавляем новую задачу
+	task.ID = s.newTaskID()
+	if err := s.storage.SaveTask(task); err != nil {
+		Logger.Errorf("Ошибка сохранения задачи %s, после перезапуска она не будет восстановлена: %v", task.ID, err)
+	}
+
+	Logger.Infof("🚀 Добавлена задача %s для чата '%s' (интервал: %d мин, задержка: %d мин)",
+		task.ID, task.ChatName, task.Interval, task.RandomDelay)
+	if task.PendingConfirmation {
+		Logger.Warnf("✋ Рассылка по задаче %s на %d получателей (%d сообщений) ждет подтверждения",
+			task.ID, task.Preview.Recipients, task.Preview.Messages)
+	}
+	s.startTask(task, taskProgress{})
+
+	return task.ID, nil
+}
+
+// prepareTask проверяет новую задачу и заполняет вычисляемые поля: часовой пояс, время начала
+// и окончания, ожидание подтверждения крупной рассылки. recipients и duplicates - получатели
+// рассылки из countRecipients для подтверждения
+func (s *Scheduler) prepareTask(task *ScheduledTask, recipients []string, duplicates, confirmRecipients int) error {
+	if task.ChatName == "" {
+		return fmt.Errorf("пустое название чата")
+	}
+	if task.Message == "" && task.MessageCommand == "" && task.MediaID == "" && task.MediaURL == "" &&
+		task.ForwardMessageID == "" && len(task.Variants) == 0 && !task.groupAction() {
+		return fmt.Errorf("пустое сообщение")
+	}
+	if task.MessageCommand != "" && !messageCommandsAllowed() {
+		return fmt.Errorf("выполнение команд отключено, установите %s=1 для включения", allowCommandsEnv)
+	}
+	if task.Timezone == "" {
+		task.Timezone = s.Settings().DefaultTimezone
+	}
+	now := s.clock.Now()
+	if err := task.validateRecurrence(now); err != nil {
+		return err
+	}
+	if err := task.resolveAnchors(now); err != nil {
+		return err
+	}
+	if task.StartTime.IsZero() {
+		return fmt.Errorf("неверное время начала")
+	}
+	if task.EndTime.IsZero() {
+		return fmt.Errorf("неверное время окончания")
+	}
+	if err := task.resolveRRule(); err != nil {
+		return err
+	}
+	if err := (Exclusions{Dates: task.ExcludedDates, HolidayCalendars: task.HolidayCalendars}).Validate(); err != nil {
+		return err
+	}
+	if err := task.validateDelay(); err != nil {
+		return err
+	}
+	if err := task.validateRecipients(); err != nil {
+		return err
+	}
+	if err := task.validateLocalTime(); err != nil {
+		return err
+	}
+	if err := task.validateVariants(); err != nil {
+		return err
+	}
+	if err := task.validateTranslations(); err != nil {
+		return err
+	}
+	if err := task.validateTemplate(); err != nil {
+		return err
+	}
+	if err := task.validateTags(); err != nil {
+		return err
+	}
+	if err := validateFooter(task.Footer); err != nil {
+		return err
+	}
+	if err := s.checkCompliance(task); err != nil {
+		return err
+	}
+	if task.SendTimeoutSeconds < 0 || time.Duration(task.SendTimeoutSeconds)*time.Second > maxSendTimeout {
+		return fmt.Errorf("send_timeout_seconds должен быть в диапазоне 0..%d", int(maxSendTimeout.Seconds()))
+	}
+	if task.Presence != nil {
+		if err := task.Presence.validate(); err != nil {
+			return err
+		}
+	}
+	if task.HealthCheck != nil {
+		if err := task.HealthCheck.validate(); err != nil {
+			return err
+		}
+	}
+	if !task.SplitLongMessages {
+		if err := validateLengthWithFooter(task.Message, s.footer(task)); err != nil {
+			return err
+		}
+	}
+	if task.SplitDelaySeconds < 0 {
+		return fmt.Errorf("split_delay_seconds не может быть отрицательным")
+	}
+	if err := ValidateFormat(task.Format); err != nil {
+		return err
+	}
+	if task.MediaID != "" {
+		if _, err := s.storage.GetMedia(task.MediaID); err != nil {
+			return err
+		}
+	}
+	for _, id := range task.RecipientLists {
+		if _, err := s.storage.GetRecipientList(id); err != nil {
+			return err
+		}
+	}
+	if task.Pin != "" {
+		if _, err := ParsePinDuration(task.Pin); err != nil {
+			return err
+		}
+	}
+	if task.Escalation != nil {
+		if err := task.Escalation.validate(); err != nil {
+			return err
+		}
+	}
+	if task.DisappearingTimer != "" {
+		if _, err := ParseDisappearingTimer(task.DisappearingTimer); err != nil {
+			return err
+		}
+	}
+	if task.MediaURL != "" {
+		if task.MediaID != "" {
+			return fmt.Errorf("нельзя одновременно указывать media_id и media_url")
+		}
+		if err := validateMediaURL(task.MediaURL); err != nil {
+			return err
+		}
+	}
+	if task.VoiceNote && task.media() == nil {
+		return fmt.Errorf("voice_note требует media_id или media_url со звуком")
+	}
+	if task.ForwardMessageID != "" {
+		if task.Message != "" || task.MessageCommand != "" || task.media() != nil {
+			return fmt.Errorf("forward_message_id нельзя сочетать с message, message_command и вложениями")
+		}
+		if _, err := s.storage.GetStoredMessage(task.ForwardMessageID); err != nil {
+			return err
+		}
+	}
+	if task.Interactive != nil {
+		if task.Message == "" && task.MessageCommand == "" && len(task.Variants) == 0 {
+			return fmt.Errorf("для интерактивного сообщения нужен текст message или message_command")
+		}
+		if task.media() != nil || task.ForwardMessageID != "" || task.SplitLongMessages {
+			return fmt.Errorf("interactive нельзя сочетать с вложениями, forward_message_id и split_long_messages")
+		}
+		if err := task.Interactive.validate(); err != nil {
+			return err
+		}
+	}
+	if task.Poll != nil {
+		if task.Message == "" && task.MessageCommand == "" && len(task.Variants) == 0 {
+			return fmt.Errorf("для опроса нужен вопрос в message или message_command")
+		}
+		if task.media() != nil || task.ForwardMessageID != "" || task.Interactive != nil || task.SplitLongMessages {
+			return fmt.Errorf("poll нельзя сочетать с вложениями, forward_message_id, interactive и split_long_messages")
+		}
+		if err := task.Poll.validate(); err != nil {
+			return err
+		}
+	}
+
+	if task.groupAction() {
+		if err := task.validateGroupAction(); err != nil {
+			return err
+		}
+	}
+
+	if task.Trigger != nil {
+		if err := task.Trigger.validate(); err != nil {
+			return err
+		}
+		if task.ChatJID == "" {
+			return fmt.Errorf("для trigger нужен JID чата: укажите chat_jid или дождитесь подключения WhatsApp")
+		}
+		// Задача ждет фразы включения
+		task.Paused = true
+	}
+	task.requireConfirmation(recipients, duplicates, confirmRecipients)
+	return nil
+}
+
+// newTaskID возвращает ID, не занятый активными задачами. Вызывается под s.mutex
+func (s *Scheduler) newTaskID() string {
+	for {
+		id := fmt.Sprintf("task_%d", time.Now().UnixNano())
+		if _, exists := s.tasks[id]; !exists {
+			return id
+		}
+	}
+}
+
+// startTask регистрирует задачу с новым поколением и запускает ее горутину. Вызывается под s.mutex
+func (s *Scheduler) startTask(task *ScheduledTask, progress taskProgress) {
+	s.generation++
+	task.generation = s.generation
+	task.ctx, task.cancel = context.WithCancel(context.Background())
+	task.done = make(chan struct{})
+	task.confirmed = make(chan struct{})
+	s.tasks[task.ID] = task
+
+	s.events.Publish(Event{Kind: EventKindTaskAdded, TaskID: task.ID, ChatName: task.ChatName})
+	go s.runTask(task, progress)
+}
+
+// removeTask останавливает задачу и удаляет ее из списка и хранилища. Вызывается под s.mutex
+func (s *Scheduler) removeTask(task *ScheduledTask) {
+	task.cancel()
+	delete(s.tasks, task.ID)
+	if err := s.storage.DeleteTask(task.ID); err != nil {
+		Logger.Errorf("Ошибка удаления задачи %s из хранилища: %v", task.ID, err)
+	}
+}
+
+// isCurrent проверяет, что горутина выполняет текущий запуск задачи, а не замененный или остановленный
+func (s *Scheduler) isCurrent(task *ScheduledTask) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	current, exists := s.tasks[task.ID]
+	return exists && current.generation == task.generation && task.ctx.Err() == nil
+}
+
+// RestoreTasks запускает задачи, сохраненные до перезапуска процесса.
+// Вызывается после подключения транспорта, чтобы пропущенная отправка не ушла в неготовый клиент
+func (s *Scheduler) RestoreTasks() error {
+	tasks, progress, err := s.storage.loadTasks()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки задач: %v", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, task := range tasks {
+		// Планировщик выполняет одну задачу: лишние записи остаются после сбоя во время замены,
+		// восстанавливаем только последнюю добавленную
+		if _, exists := s.tasks[task.ID]; exists || i < len(tasks)-1 {
+			Logger.Warnf("Сохраненная задача %s устарела и удалена", task.ID)
+			if err := s.storage.DeleteTask(task.ID); err != nil {
+				Logger.Errorf("Ошибка удаления задачи %s из хранилища: %v", task.ID, err)
+			}
+			continue
+		}
+		Logger.Infof("♻️ Восстановлена задача %s для чата '%s'", task.ID, task.ChatName)
+		s.startTask(task, progress[i])
+	}
+	if err := s.storage.failInterruptedBatches(); err != nil {
+		Logger.Errorf("Ошибка обновления прерванных пакетов сообщений: %v", err)
+	}
+	if err := s.restoreScheduledSends(); err != nil {
+		Logger.Errorf("Ошибка восстановления отложенных отправок: %v", err)
+	}
+
+	go s.reconcileTasks()
+	go s.runEscalations()
+	go s.runCampaigns()
+	go s.verifyTaskChats()
+	go s.runJanitor()
+	return nil
+}
+
+func (s *Scheduler) StopTask(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	task, exists := s.tasks[id]
+	if !exists {
+		Logger.Warnf("Попытка остановить несуществующую задачу: %s", id)
+		return false
+	}
+
+	s.removeTask(task)
+	Logger.Infof("⏹️ Задача %s остановлена (чат: %s)", id, task.ChatName)
+	return true
+}
+
+// PauseTask приостанавливает (paused=true) или возобновляет отправки задачи
+func (s *Scheduler) PauseTask(id string, paused bool) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	task, exists := s.tasks[id]
+	if !exists {
+		return false
+	}
+
+	task.Paused = paused
+	if err := s.storage.SaveTask(task); err != nil {
+		Logger.Errorf("Ошибка сохранения задачи %s: %v", id, err)
+	}
+	if paused {
+		Logger.Infof("⏸️ Задача %s приостановлена (чат: %s)", id, task.ChatName)
+	} else {
+		Logger.Infof("▶️ Задача %s возобновлена (чат: %s)", id, task.ChatName)
+	}
+	return true
+}
+
+// isPaused проверяет, что задача приостановлена
+func (s *Scheduler) isPaused(task *ScheduledTask) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return task.Paused
+}
+
+func (s *Scheduler) runTask(task *ScheduledTask, progress taskProgress) {
+	Logger.Infof("🔄 Запуск планировщика для задачи %s (чат: %s)", task.ID, task.ChatName)
+
+	defer func() {
+		// Удаляем только свой запуск: остановленную или замененную задачу уже убрали из списка,
+		// а под тем же ID может работать новый запуск
+		s.mutex.Lock()
+		// При остановке процесса задача остается в хранилище, чтобы продолжиться после перезапуска
+		if current, exists := s.tasks[task.ID]; exists && current.generation == task.generation && !s.IsShuttingDown() {
+			s.removeTask(task)
+		}
+		close(task.done)
+		s.mutex.Unlock()
+		s.events.Publish(Event{Kind: EventKindTaskStopped, TaskID: task.ID, ChatName: task.ChatName})
+	}()
+
+	if s.clock.Now().After(task.EndTime) {
+		Logger.Infof("⏰ Задача %s уже завершена по времени до первой отправки (чат: %s)", task.ID, task.ChatName)
+		return
+	}
+
+	if task.Interval <= 0 {
+		Logger.Errorf("❌ Неверный интервал для задачи %s(не может быть меньше 1 минуты) (чат: %s)", task.ID, task.ChatName)
+		return
+	}
+
+	if err := task.validateDelay(); err != nil {
+		Logger.Errorf("❌ %v для задачи %s (чат: %s)", err, task.ID, task.ChatName)
+		return
+	}
+
+	if !s.awaitConfirmation(task) {
+		Logger.Infof("🛑 Планировщик остановлен для задачи %s", task.ID)
+		return
+	}
+
+	// Если время начала в прошлом, вычисляем следующее время отправки.
+	// Уже обработанные до перезапуска отправки не повторяются
+	now := s.clock.Now()
+	nextSendTime := task.nextOccurrence(maxTime(now, progress.lastOccurrence))
+	if task.StartTime.Before(nextSendTime) {
+		Logger.Infof("⏰ Время начала в прошлом. Следующая отправка запланирована на: %s",
+			nextSendTime.Format("15:04:05 02.01.2006"))
+	}
+	resumeAt := time.Time{}
+	if planned, sendAt, ok := progress.resumePoint(task, now); ok {
+		nextSendTime, resumeAt = planned, sendAt
+		Logger.Infof("♻️ Задача %s продолжает прерванное перезапуском ожидание отправки", task.ID)
+	}
+
+	// Основной цикл для повторных отправок
+	for {
+		// Добавляем случайную задержку, если не продолжаем ожидание после перезапуска
+		nextMessageTime := resumeAt
+		if nextMessageTime.IsZero() {
+			nextMessageTime = nextSendTime.Add(task.randomOffset(nextSendTime))
+		}
+		resumeAt = time.Time{}
+
+		if nextMessageTime.After(task.EndTime) {
+			Logger.Infof("⏰ Задача %s завершена по времени (Чат: %s)", task.ID, task.ChatName)
+			return
+		}
+
+		// Логируем время до следующей отправки
+		timeUntilSend := nextMessageTime.Sub(s.clock.Now())
+		Logger.Infof("⏳ До отправки сообщения: %.2f минут (%s)",
+			timeUntilSend.Minutes(), nextMessageTime.Local().Format("15:04:05 02.01.2006"))
+		s.mutex.Lock()
+		task.NextSendAt = nextMessageTime
+		s.mutex.Unlock()
+		if err := s.storage.setTaskPending(task.ID, nextSendTime, nextMessageTime); err != nil {
+			Logger.Errorf("Ошибка сохранения состояния задачи %s: %v", task.ID, err)
+		}
+
+		if !s.clock.Sleep(task.ctx, timeUntilSend) || !s.isCurrent(task) {
+			Logger.Infof("🛑 Планировщик остановлен для задачи %s", task.ID)
+			return
+		}
+		s.sendOccurrence(task, nextSendTime)
+		if !s.isCurrent(task) {
+			Logger.Infof("🛑 Планировщик остановлен для задачи %s", task.ID)
+			return
+		}
+		if s.IsShuttingDown() {
+			// Отправка не отмечается обработанной и повторится после перезапуска
+			return
+		}
+
+		if err := s.storage.completeTaskOccurrence(task.ID, nextSendTime); err != nil {
+			Logger.Errorf("Ошибка сохранения состояния задачи %s: %v", task.ID, err)
+		}
+		nextSendTime = task.nextOccurrence(nextSendTime)
+	}
+}
+
+// sendOccurrence выполняет плановую отправку planned задачи, если ее не нужно пропустить
+func (s *Scheduler) sendOccurrence(task *ScheduledTask, planned time.Time) {
+	s.supersedeMissed(task, planned)
+	if s.isPaused(task) {
+		Logger.Infof("⏸️ Задача %s приостановлена, отправка пропущена", task.ID)
+		return
+	}
+	if !s.IsLeader() {
+		Logger.Infof("👥 Резервный экземпляр, отправка по задаче %s пропущена", task.ID)
+		return
+	}
+	if s.IsReadOnly() {
+		Logger.Infof("🔏 Режим только для чтения, отправка по задаче %s пропущена", task.ID)
+		return
+	}
+	if problem := s.needsAttention(task); problem != "" {
+		Logger.Warnf("⚠️ Задача %s требует внимания (%s), отправка пропущена", task.ID, problem)
+		return
+	}
+	if s.IsLoggedOut() {
+		Logger.Warnf("🔒 Сессия WhatsApp завершена, отправка по задаче %s пропущена до повторной авторизации", task.ID)
+		return
+	}
+	// Группа меняется и в тихие часы: закрытие группы на ночь - основной сценарий
+	if task.GroupSetting != nil {
+		s.applyGroupSetting(task)
+		return
+	}
+	if task.GroupInfo != nil {
+		s.applyGroupInfo(task, planned)
+		return
+	}
+	if s.inQuietHours(s.clock.Now()) {
+		Logger.Infof("🌙 Тихие часы, отправка по задаче %s пропущена", task.ID)
+		return
+	}
+	if !s.waitForPresence(task) {
+		return
+	}
+	if task.HealthCheck != nil {
+		if err := task.HealthCheck.Check(task.ctx); err != nil {
+			Logger.Infof("⏭️ Проверка %s для задачи %s не пройдена (%v), отправка пропущена",
+				task.HealthCheck.URL, task.ID, err)
+			return
+		}
+	}
+
+	message, err := task.ResolveMessage(task.ctx)
+	if err != nil {
+		Logger.Errorf("❌ Ошибка подготовки сообщения по задаче %s: %v", task.ID, err)
+		return
+	}
+	// Задачу могли остановить или заменить, пока шли проверки и подготовка сообщения
+	if !s.isCurrent(task) {
+		return
+	}
+	s.deliverOccurrence(task, planned, OutgoingMessage{
+		TaskID:      task.ID,
+		Owner:       task.Owner,
+		ChatName:    task.ChatName,
+		ChatJID:     task.ChatJID,
+		Message:     message,
+		Media:       task.media(),
+		ForwardID:   task.ForwardMessageID,
+		Interactive: task.Interactive,
+		Poll:        task.Poll,
+		LinkPreview: !task.DisableLinkPreview,
+		Expiration:  task.expiration(),
+		Timeout:     time.Duration(task.SendTimeoutSeconds) * time.Second,
+		Planned:     planned,
+		Escalation:  task.Escalation,
+	})
+}
+
+// sleepContext ждет d и возвращает false, если ожидание прервано отменой ctx
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return ctx.Err() == nil
+	}
+}
+
+// maxTime возвращает более позднее из двух времен
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+func (s *Scheduler) SendTestMessage(ctx context.Context, chatName, message string) error {
+	Logger.Infof("🧪 Отправка тестового сообщения в чат '%s'", chatName)
+	return s.Deliver(ctx, OutgoingMessage{ChatName: chatName, Message: message, LinkPreview: true})
+}
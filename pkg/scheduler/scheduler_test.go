@@ -0,0 +1,53 @@
+package scheduler
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// Журнал задач в тестах только мешает читать результат
+	Logger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestScheduler создает планировщик с хранилищем во временном каталоге, отправляющий в память
+func newTestScheduler(t *testing.T) (*Scheduler, *MemorySender) {
+	t.Helper()
+	storage, err := OpenStorage(filepath.Join(t.TempDir(), "scheduler.db"))
+	if err != nil {
+		t.Fatalf("OpenStorage: %v", err)
+	}
+	t.Cleanup(func() { storage.db.Close() })
+	sender := NewMemorySender()
+	s, err := NewScheduler(storage, sender, Config{})
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	return s, sender
+}
+
+// mustLocation загружает часовой пояс IANA
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%s): %v", name, err)
+	}
+	return location
+}
+
+// waitFor ждет, пока done вернет true, не дольше 5 секунд
+func waitFor(t *testing.T, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 5s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Результаты отдельной проверки самопроверки
+const (
+	SelfTestPassed  = "pass"
+	SelfTestFailed  = "fail"
+	SelfTestSkipped = "skip"
+)
+
+// selfTestMessage - сообщение, которое самопроверка отправляет в собственный чат
+const selfTestMessage = "✅ WhatsApp Scheduler: самопроверка пройдена"
+
+// errSelfTestSkipped - проверка пропущена, результат самопроверки она не портит
+var errSelfTestSkipped = errors.New("проверка пропущена")
+
+// SessionChecker - необязательная возможность транспорта: проверка сессии и подключения для самопроверки
+type SessionChecker interface {
+	// CheckSession проверяет, что сессия авторизована
+	CheckSession() error
+	// CheckConnection проверяет подключение запросом к серверу
+	CheckConnection(ctx context.Context) error
+	// OwnJID возвращает JID собственного аккаунта, пусто - аккаунт не привязан
+	OwnJID() string
+}
+
+// SelfTestCheck - результат одной проверки
+type SelfTestCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// DurationMs - время выполнения проверки
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// SelfTestResult - результат самопроверки. Passed - ни одна проверка не завершилась ошибкой
+type SelfTestResult struct {
+	Passed    bool            `json:"passed"`
+	StartedAt time.Time       `json:"started_at"`
+	Checks    []SelfTestCheck `json:"checks"`
+}
+
+// SelfTest проверяет хранилище, сессию и подключение к WhatsApp и шаблоны сообщений сохраненных задач.
+// С sendToSelf отправляет сообщение в собственный чат. Удобно запускать после обновления,
+// прежде чем оставлять приложение работать без присмотра
+func (s *Scheduler) SelfTest(ctx context.Context, sendToSelf bool) SelfTestResult {
+	result := SelfTestResult{Passed: true, StartedAt: time.Now()}
+	run := func(name string, check func() (string, error)) {
+		started := time.Now()
+		detail, err := check()
+		entry := SelfTestCheck{Name: name, Status: SelfTestPassed, Detail: detail}
+		switch {
+		case errors.Is(err, errSelfTestSkipped):
+			entry.Status = SelfTestSkipped
+		case err != nil:
+			entry.Status, entry.Detail = SelfTestFailed, err.Error()
+			result.Passed = false
+		}
+		entry.DurationMs = time.Since(started).Milliseconds()
+		result.Checks = append(result.Checks, entry)
+	}
+
+	checker, _ := s.sender.(SessionChecker)
+	run("database", func() (string, error) {
+		return "", s.storage.selfTest(ctx)
+	})
+	run("session", func() (string, error) {
+		if checker == nil {
+			return "транспорт не использует сессию WhatsApp", errSelfTestSkipped
+		}
+		if s.IsLoggedOut() {
+			return "", ErrLoggedOut
+		}
+		return checker.OwnJID(), checker.CheckSession()
+	})
+	run("connectivity", func() (string, error) {
+		if checker == nil {
+			return "транспорт не использует подключение к WhatsApp", errSelfTestSkipped
+		}
+		return "", checker.CheckConnection(ctx)
+	})
+	run("templates", func() (string, error) {
+		return s.checkTaskTemplates(ctx)
+	})
+	run("send_to_self", func() (string, error) {
+		if !sendToSelf {
+			return "не запрошена", errSelfTestSkipped
+		}
+		if checker == nil || checker.OwnJID() == "" {
+			return "", fmt.Errorf("собственный аккаунт неизвестен")
+		}
+		own := checker.OwnJID()
+		sent, err := s.DeliverResult(ctx, OutgoingMessage{ChatName: own, ChatJID: own, Message: selfTestMessage})
+		return sent.MessageID, err
+	})
+
+	if result.Passed {
+		Logger.Infof("🩺 Самопроверка пройдена")
+	} else {
+		Logger.Warnf("🩺 Самопроверка не пройдена")
+	}
+	return result
+}
+
+// checkTaskTemplates загружает сохраненные задачи и проверяет, что их тексты собираются
+// без незаполненных переменных. Загрузка из хранилища проверяет и чтение задач после обновления
+func (s *Scheduler) checkTaskTemplates(ctx context.Context) (string, error) {
+	tasks, _, err := s.storage.loadTasks()
+	if err != nil {
+		return "", fmt.Errorf("ошибка загрузки задач: %v", err)
+	}
+	for _, task := range tasks {
+		switch {
+		case task.GroupSetting != nil:
+			continue
+		case task.GroupInfo != nil:
+			if task.GroupInfo.Field == GroupInfoSubject {
+				if err := checkRenderedText(task.GroupInfo.render(time.Now().In(task.location())), false); err != nil {
+					return "", fmt.Errorf("задача %s: %v", task.ID, err)
+				}
+			}
+			continue
+		}
+		message, err := task.ResolveMessage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("задача %s: %v", task.ID, err)
+		}
+		texts := []string{message}
+		for _, variant := range task.Variants {
+			texts = append(texts, ApplyFormat(variant, task.Format))
+		}
+		for _, text := range texts {
+			if err := checkRenderedText(text, task.media() != nil || task.ForwardMessageID != ""); err != nil {
+				return "", fmt.Errorf("задача %s: %v", task.ID, err)
+			}
+		}
+	}
+	return fmt.Sprintf("задач проверено: %d", len(tasks)), nil
+}
+
+// selfTest проверяет чтение и запись базы данных: изменения выполняются в транзакции и откатываются
+func (st *Storage) selfTest(ctx context.Context) error {
+	if err := st.db.PingContext(ctx); err != nil {
+		return err
+	}
+	tx, err := st.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE self_test_probe (value TEXT)`); err != nil {
+		return fmt.Errorf("запись недоступна: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO self_test_probe (value) VALUES (?)`, time.Now().String()); err != nil {
+		return fmt.Errorf("запись недоступна: %v", err)
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM history`).Scan(&count); err != nil {
+		return fmt.Errorf("чтение недоступно: %v", err)
+	}
+	return nil
+}
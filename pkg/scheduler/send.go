@@ -0,0 +1,285 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSendTimeout - максимальное время отправки одного сообщения по умолчанию
+	DefaultSendTimeout = 30 * time.Second
+	// maxSendTimeout - максимальный таймаут отправки, задаваемый в задаче
+	maxSendTimeout = 10 * time.Minute
+	// maxSendDelay - максимальная задержка разовой отправки в delay_seconds
+	maxSendDelay = 7 * 24 * time.Hour
+)
+
+// MediaAttachment - вложение, переданное в base64 или ссылкой на файл медиатеки
+type MediaAttachment struct {
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	FileName string `json:"file_name,omitempty"`
+	// MediaID - идентификатор файла медиатеки (POST /media) вместо Data
+	MediaID string `json:"media_id,omitempty"`
+	// URL - адрес, с которого вложение скачивается в момент отправки, вместо Data
+	URL string `json:"url,omitempty"`
+	// VoiceNote - отправить звук как голосовое сообщение (PTT) с волной и нормализованной громкостью
+	VoiceNote bool `json:"voice_note,omitempty"`
+}
+
+// SendRequest - тело запроса POST /send
+type SendRequest struct {
+	ChatName    string           `json:"chat_name"`
+	Message     string           `json:"message"`
+	Media       *MediaAttachment `json:"media,omitempty"`
+	ScheduledAt *time.Time       `json:"scheduled_at,omitempty"`
+	// When - время отправки на естественном языке ("in 2 hours", "tomorrow 9am")
+	When string `json:"when,omitempty"`
+	// Timezone - часовой пояс IANA для разбора When, по умолчанию локальный
+	Timezone string `json:"timezone,omitempty"`
+	// DisableLinkPreview - не формировать превью ссылок
+	DisableLinkPreview bool `json:"disable_link_preview,omitempty"`
+	// Format - формат текста: plain или markdown
+	Format string `json:"format,omitempty"`
+	// QuotedMessageID - ID сохраненного сообщения того же чата (GET /messages), на которое отвечает отправляемое
+	QuotedMessageID string `json:"quoted_message_id,omitempty"`
+	// DelaySeconds - отправить через N секунд вместо немедленной отправки
+	DelaySeconds int `json:"delay_seconds,omitempty"`
+}
+
+// Deliver отправляет сообщение с учетом лимита отправки и записывает результат в историю.
+// Отправки задач в чат, приостановленный после серии ошибок, пропускаются.
+// Сообщения в один чат отправляются по очереди с паузой не меньше ChatSendGap,
+// одновременно отправляется не больше MaxConcurrentSends сообщений.
+// Сообщения, не прошедшие модерацию (ErrModerationRejected) или, в строгом режиме, с незаполненными
+// переменными шаблона (ErrTemplateRender), не отправляются.
+// Резервный экземпляр не отправляет сообщения (ErrStandby), как и останавливающийся (ErrShuttingDown),
+// потерявший сессию WhatsApp (ErrLoggedOut) или переведенный в режим только для чтения (ErrReadOnly).
+// Отмена ctx (остановка задачи, закрытие запроса) прерывает отправку
+func (s *Scheduler) Deliver(ctx context.Context, msg OutgoingMessage) error {
+	_, err := s.DeliverResult(ctx, msg)
+	return err
+}
+
+// DeliverResult отправляет сообщение как Deliver и возвращает ID отправленного сообщения и время отправки
+func (s *Scheduler) DeliverResult(ctx context.Context, msg OutgoingMessage) (SendResult, error) {
+	if !s.sendingMutex.TryRLock() {
+		return SendResult{}, ErrShuttingDown
+	}
+	defer s.sendingMutex.RUnlock()
+	if !s.IsLeader() {
+		return SendResult{}, ErrStandby
+	}
+	if s.IsReadOnly() {
+		return SendResult{}, ErrReadOnly
+	}
+	if s.IsLoggedOut() {
+		return SendResult{}, ErrLoggedOut
+	}
+	if msg.TaskID != "" && s.breakers.IsOpen(msg.ChatName) {
+		Logger.Warnf("⏸️ Отправка по задаче %s в чат '%s' пропущена: чат приостановлен после серии ошибок",
+			msg.TaskID, msg.ChatName)
+		return SendResult{}, ErrCircuitOpen
+	}
+	if err := s.checkRendered(msg); err != nil {
+		return SendResult{}, s.refuseSend(msg, alertKindTemplate, err)
+	}
+	if err := s.moderate(ctx, msg); err != nil {
+		return SendResult{}, s.refuseSend(msg, alertKindModeration, err)
+	}
+
+	release, err := s.chatQueues.acquire(ctx, msg.ChatName)
+	if err != nil {
+		return SendResult{}, err
+	}
+	defer release()
+
+	if err := s.sendSlots.acquire(ctx); err != nil {
+		return SendResult{}, err
+	}
+	defer s.sendSlots.release()
+
+	releaseQuota, err := s.reserveQuota(msg.Owner)
+	if err != nil {
+		Logger.Warnf("🚦 Квота пользователя '%s' исчерпана, сообщение в чат '%s' не отправлено", msg.Owner, msg.ChatName)
+		s.recordHistory(msg, SendResult{}, err)
+		s.publishSendResult(msg, err)
+		return SendResult{}, err
+	}
+	if !s.limiter.Allow() {
+		releaseQuota()
+		Logger.Warnf("🚦 Лимит отправки превышен, сообщение в чат '%s' не отправлено", msg.ChatName)
+		s.recordHistory(msg, SendResult{}, ErrRateLimited)
+		s.publishSendResult(msg, ErrRateLimited)
+		return SendResult{}, ErrRateLimited
+	}
+
+	timeout := msg.Timeout
+	if timeout <= 0 {
+		timeout = time.Duration(s.Settings().SendTimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	result, err := s.sender.SendMessage(ctx, msg)
+	if err != nil && ctx.Err() == context.DeadlineExceeded && !errors.Is(err, ErrSendTimeout) {
+		err = fmt.Errorf("%w (%s): %v", ErrSendTimeout, timeout, err)
+	}
+	if msg.ID == "" {
+		msg.ID = result.MessageID
+	}
+	jid := result.ChatJID
+	if err != nil {
+		// Квота учитывает только отправленные сообщения
+		releaseQuota()
+	}
+	s.recordHistory(msg, result, err)
+	s.recordTaskStat(msg, err, time.Since(started))
+	s.publishSendResult(msg, err)
+	s.detectBan(msg, err)
+
+	// Любая успешная отправка (в том числе тестовая) возобновляет отправки в чат
+	if err == nil {
+		s.trackEngagement(msg, jid)
+		s.trackVariant(msg)
+		s.trackEscalation(msg, jid)
+		s.trackPostedGroup(jid)
+		s.breakers.RecordSuccess(msg.ChatName)
+	} else if msg.TaskID != "" && s.breakers.RecordFailure(msg.ChatName, err) {
+		s.alert(alertKindCircuitOpen, fmt.Sprintf("Отправки в чат '%s' приостановлены после %d ошибок подряд, последняя: %v",
+			msg.ChatName, s.breakers.Threshold(), err))
+	}
+	return result, err
+}
+
+// refuseSend записывает в историю отказ отправлять сообщение по содержимому и оповещает о нем
+func (s *Scheduler) refuseSend(msg OutgoingMessage, alertKind string, err error) error {
+	s.recordHistory(msg, SendResult{}, err)
+	s.publishSendResult(msg, err)
+	s.alert(alertKind, fmt.Sprintf("Сообщение в чат '%s' не отправлено: %v", msg.ChatName, err))
+	return err
+}
+
+// recordHistory сохраняет результат отправки, ошибки сохранения только логируются
+func (s *Scheduler) recordHistory(msg OutgoingMessage, result SendResult, sendErr error) {
+	if s.storage == nil {
+		return
+	}
+
+	entry := &HistoryEntry{
+		TaskID:    msg.TaskID,
+		ChatName:  msg.ChatName,
+		Message:   msg.Message,
+		ChatJID:   result.ChatJID,
+		Status:    SendStatus(sendErr),
+		CreatedAt: time.Now(),
+	}
+	if sendErr == nil {
+		entry.MessageID = result.MessageID
+		if !result.Timestamp.IsZero() {
+			entry.SentAt = &result.Timestamp
+		}
+	}
+	if sendErr != nil {
+		entry.Error = sendErr.Error()
+	}
+
+	if err := s.storage.AddHistory(entry); err != nil {
+		Logger.Errorf("Ошибка записи истории отправки: %v", err)
+	}
+}
+
+// publishSendResult уведомляет подписчиков событий о результате отправки
+func (s *Scheduler) publishSendResult(msg OutgoingMessage, sendErr error) {
+	evt := Event{
+		Kind:     EventKindSendResult,
+		TaskID:   msg.TaskID,
+		ChatName: msg.ChatName,
+		Message:  msg.Message,
+		Status:   SendStatus(sendErr),
+	}
+	if sendErr != nil {
+		evt.Error = sendErr.Error()
+	}
+	s.events.Publish(evt)
+}
+
+// SendStatus возвращает статус истории для результата отправки
+func SendStatus(err error) string {
+	switch {
+	case err == nil:
+		return HistoryStatusSent
+	case errors.Is(err, ErrRateLimited):
+		return HistoryStatusRateLimited
+	default:
+		return HistoryStatusFailed
+	}
+}
+
+// PrepareSend проверяет запрос разовой отправки и возвращает сообщение
+// и время отложенной отправки (nil - отправить сразу). delay_seconds и when отсчитываются от now
+func PrepareSend(req SendRequest, now time.Time) (OutgoingMessage, *time.Time, error) {
+	if err := ValidateFormat(req.Format); err != nil {
+		return OutgoingMessage{}, nil, err
+	}
+
+	msg := OutgoingMessage{
+		ChatName:    strings.TrimSpace(req.ChatName),
+		Message:     ApplyFormat(strings.TrimSpace(req.Message), req.Format),
+		Media:       req.Media,
+		QuotedID:    strings.TrimSpace(req.QuotedMessageID),
+		LinkPreview: !req.DisableLinkPreview,
+	}
+	if msg.ChatName == "" {
+		return OutgoingMessage{}, nil, fmt.Errorf("пустое название чата")
+	}
+	if msg.Message == "" && msg.Media == nil {
+		return OutgoingMessage{}, nil, fmt.Errorf("пустое сообщение")
+	}
+	if err := ValidateMessageLength(msg.Message); err != nil {
+		return OutgoingMessage{}, nil, err
+	}
+
+	if req.DelaySeconds < 0 || req.DelaySeconds > int(maxSendDelay/time.Second) {
+		return OutgoingMessage{}, nil, fmt.Errorf("delay_seconds должен быть в диапазоне 0..%d", int(maxSendDelay.Seconds()))
+	}
+	if req.DelaySeconds > 0 {
+		if req.When != "" || req.ScheduledAt != nil {
+			return OutgoingMessage{}, nil, fmt.Errorf("delay_seconds нельзя сочетать с when и scheduled_at")
+		}
+		at := now.Add(time.Duration(req.DelaySeconds) * time.Second)
+		req.ScheduledAt = &at
+	}
+	if req.When != "" {
+		if req.ScheduledAt != nil {
+			return OutgoingMessage{}, nil, fmt.Errorf("нельзя одновременно указывать when и scheduled_at")
+		}
+		location, err := loadTimezone(req.Timezone)
+		if err != nil {
+			return OutgoingMessage{}, nil, err
+		}
+		at, err := parseNaturalTimeFrom(req.When, now.In(location))
+		if err != nil {
+			return OutgoingMessage{}, nil, err
+		}
+		req.ScheduledAt = &at
+	}
+
+	if req.ScheduledAt != nil && req.ScheduledAt.After(now) {
+		return msg, req.ScheduledAt, nil
+	}
+	return msg, nil, nil
+}
+
+// WithMessageID назначает сообщению идентификатор до отправки, если транспорт это поддерживает,
+// чтобы вернуть его вызывающему сразу, в том числе для отложенной отправки
+func (s *Scheduler) WithMessageID(msg OutgoingMessage) OutgoingMessage {
+	if generator, ok := s.sender.(MessageIDGenerator); ok && msg.ID == "" {
+		msg.ID = generator.GenerateMessageID()
+	}
+	return msg
+}
@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPrepareSend(t *testing.T) {
+	now := time.Date(2025, 6, 2, 10, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+	at := func(d time.Duration) *time.Time {
+		scheduled := now.Add(d)
+		return &scheduled
+	}
+
+	tests := []struct {
+		name        string
+		req         SendRequest
+		wantMessage string
+		wantAt      *time.Time
+		wantErr     bool
+	}{
+		{name: "send now", req: SendRequest{ChatName: " Team ", Message: " Hello "}, wantMessage: "Hello"},
+		{name: "markdown", req: SendRequest{ChatName: "Team", Message: "**Hello**", Format: "markdown"}, wantMessage: "*Hello*"},
+		{name: "empty chat", req: SendRequest{ChatName: " ", Message: "Hello"}, wantErr: true},
+		{name: "empty message", req: SendRequest{ChatName: "Team", Message: " "}, wantErr: true},
+		{name: "delay", req: SendRequest{ChatName: "Team", Message: "Hello", DelaySeconds: 90}, wantMessage: "Hello", wantAt: at(90 * time.Second)},
+		{
+			name:        "maximum delay",
+			req:         SendRequest{ChatName: "Team", Message: "Hello", DelaySeconds: int(maxSendDelay / time.Second)},
+			wantMessage: "Hello",
+			wantAt:      at(maxSendDelay),
+		},
+		{name: "delay above maximum", req: SendRequest{ChatName: "Team", Message: "Hello", DelaySeconds: int(maxSendDelay/time.Second) + 1}, wantErr: true},
+		{name: "overflowing delay", req: SendRequest{ChatName: "Team", Message: "Hello", DelaySeconds: math.MaxInt}, wantErr: true},
+		{name: "negative delay", req: SendRequest{ChatName: "Team", Message: "Hello", DelaySeconds: -1}, wantErr: true},
+		{name: "delay and scheduled_at", req: SendRequest{ChatName: "Team", Message: "Hello", DelaySeconds: 60, ScheduledAt: &future}, wantErr: true},
+		{name: "scheduled_at in the future", req: SendRequest{ChatName: "Team", Message: "Hello", ScheduledAt: &future}, wantMessage: "Hello", wantAt: &future},
+		{name: "scheduled_at in the past sends now", req: SendRequest{ChatName: "Team", Message: "Hello", ScheduledAt: &past}, wantMessage: "Hello"},
+		{
+			name:        "when relative to now",
+			req:         SendRequest{ChatName: "Team", Message: "Hello", When: "in 2 hours", Timezone: "Europe/Berlin"},
+			wantMessage: "Hello",
+			wantAt:      at(2 * time.Hour),
+		},
+		{
+			name:        "when in the task time zone",
+			req:         SendRequest{ChatName: "Team", Message: "Hello", When: "tomorrow 9am", Timezone: "Europe/Berlin"},
+			wantMessage: "Hello",
+			wantAt:      at(21 * time.Hour),
+		},
+		{name: "when in the past", req: SendRequest{ChatName: "Team", Message: "Hello", When: "yesterday"}, wantErr: true},
+		{name: "when and scheduled_at", req: SendRequest{ChatName: "Team", Message: "Hello", When: "in 1 hour", ScheduledAt: &future}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, scheduledAt, err := PrepareSend(tt.req, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PrepareSend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if msg.Message != tt.wantMessage || msg.ChatName != "Team" {
+				t.Errorf("message %q to %q, want %q to %q", msg.Message, msg.ChatName, tt.wantMessage, "Team")
+			}
+			switch {
+			case tt.wantAt == nil && scheduledAt != nil:
+				t.Errorf("scheduled at %v, want immediate send", scheduledAt)
+			case tt.wantAt != nil && (scheduledAt == nil || !scheduledAt.Equal(*tt.wantAt)):
+				t.Errorf("scheduled at %v, want %v", scheduledAt, tt.wantAt)
+			}
+		})
+	}
+}
+
+func TestPrepareSendDelivery(t *testing.T) {
+	now := time.Date(2025, 6, 2, 10, 0, 0, 0, time.UTC)
+	s, sender := newTestScheduler(t)
+	clock := NewSimulatedClock(now, time.Time{})
+	s.SetClock(clock)
+
+	msg, scheduledAt, err := PrepareSend(SendRequest{ChatName: "Team", Message: "Hello", DelaySeconds: 3600}, s.Now())
+	if err != nil {
+		t.Fatalf("PrepareSend: %v", err)
+	}
+	if scheduledAt == nil || !scheduledAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("scheduled at %v, want %v", scheduledAt, now.Add(time.Hour))
+	}
+	if _, err := s.ScheduleOneOff(msg, *scheduledAt); err != nil {
+		t.Fatalf("ScheduleOneOff: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sends, err := s.ScheduledSends()
+		if err != nil {
+			t.Fatalf("ScheduledSends: %v", err)
+		}
+		if len(sender.Messages()) > 0 && len(sends) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("message not delivered: %d sent, %d pending", len(sender.Messages()), len(sends))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sent := sender.Messages()
+	if len(sent) != 1 || sent[0].ChatName != "Team" || sent[0].Message != "Hello" {
+		t.Fatalf("sent %+v, want one message to Team", sent)
+	}
+	if got := clock.Now(); !got.Equal(*scheduledAt) {
+		t.Errorf("delivered at %v by the scheduler clock, want %v", got, *scheduledAt)
+	}
+}
+
+func TestOneOffKeptWhileSendingDisabled(t *testing.T) {
+	now := time.Date(2025, 6, 2, 10, 0, 0, 0, time.UTC)
+	s, sender := newTestScheduler(t)
+	until := now.Add(10 * time.Minute)
+	clock := NewSimulatedClock(now, until)
+	s.SetClock(clock)
+	s.SetReadOnly(true)
+
+	msg := OutgoingMessage{ChatName: "Team", Message: "Hello"}
+	id, err := s.ScheduleOneOff(msg, now.Add(30*time.Second))
+	if err != nil {
+		t.Fatalf("ScheduleOneOff: %v", err)
+	}
+
+	// Отправка повторяется каждые oneOffRetryDelay, пока часы не дойдут до until
+	waitFor(t, func() bool {
+		s.oneOffs.mutex.Lock()
+		defer s.oneOffs.mutex.Unlock()
+		return clock.Now().Equal(until) && len(s.oneOffs.cancels) == 0
+	})
+	if sent := sender.Messages(); len(sent) != 0 {
+		t.Fatalf("sent %+v in read-only mode", sent)
+	}
+	sends, err := s.ScheduledSends()
+	if err != nil {
+		t.Fatalf("ScheduledSends: %v", err)
+	}
+	if len(sends) != 1 || sends[0].ID != id {
+		t.Fatalf("pending sends %+v, want %s kept for retry", sends, id)
+	}
+
+	// После выключения режима только для чтения отправка доставляется и удаляется
+	s.SetReadOnly(false)
+	s.SetClock(NewSimulatedClock(until, time.Time{}))
+	if err := s.restoreScheduledSends(); err != nil {
+		t.Fatalf("restoreScheduledSends: %v", err)
+	}
+	waitFor(t, func() bool {
+		sends, err := s.ScheduledSends()
+		return err == nil && len(sends) == 0 && len(sender.Messages()) == 1
+	})
+}
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+// MessageSender - транспорт, через который планировщик отправляет сообщения
+type MessageSender interface {
+	// SendMessage отправляет сообщение и возвращает JID чата получателя, ID сообщения и время отправки.
+	// JID чата заполняется и при ошибке, если чат был найден
+	SendMessage(ctx context.Context, msg OutgoingMessage) (SendResult, error)
+}
+
+// SendResult - результат отправки сообщения транспортом
+type SendResult struct {
+	ChatJID string
+	// MessageID - ID отправленного сообщения в WhatsApp, по нему ссылаются ответы, реакции и подтверждения
+	MessageID string
+	// Timestamp - время отправки по серверу WhatsApp
+	Timestamp time.Time
+}
+
+// PresenceSubscriber - необязательная возможность транспорта: подписка на присутствие
+// получателя в сети для задач с условием Presence
+type PresenceSubscriber interface {
+	// SubscribePresence подписывается на присутствие личного чата и возвращает его JID
+	SubscribePresence(chatName string) (string, error)
+}
+
+// ContactBlocker - необязательная возможность транспорта: блокировка отправителей
+// по ключевым словам правил блокировки
+type ContactBlocker interface {
+	BlockContact(jid string) error
+}
+
+// MessageIDGenerator - необязательная возможность транспорта: идентификатор сообщения
+// до отправки, по которому сопоставляются подтверждения доставки и прочтения
+type MessageIDGenerator interface {
+	GenerateMessageID() string
+}
+
+// OutgoingMessage - сообщение, проходящее через общий конвейер отправки
+type OutgoingMessage struct {
+	// ID - идентификатор сообщения из MessageIDGenerator, пустой - транспорт генерирует его сам
+	ID       string
+	TaskID   string
+	ChatName string
+	// ChatJID - выбранный JID чата, если название неоднозначно (пустой - поиск по ChatName)
+	ChatJID string
+	Message string
+	Media   *MediaAttachment
+	// ForwardID - ID сохраненного сообщения (Storage.SaveMessage), пересылаемого вместо Message
+	ForwardID string
+	// QuotedID - ID сохраненного сообщения того же чата, на которое отвечает отправляемое (цитата)
+	QuotedID string
+	// Interactive - список или кнопки, Message становится телом интерактивного сообщения
+	Interactive *InteractiveContent
+	// Poll - варианты ответа опроса, Message становится вопросом
+	Poll *PollContent
+	// LinkPreview - формировать превью первой ссылки в тексте
+	LinkPreview bool
+	// Expiration - время жизни исчезающего сообщения (0 - обычное сообщение)
+	Expiration time.Duration
+	// Timeout - максимальное время отправки (0 - таймаут планировщика)
+	Timeout time.Duration
+	// Planned - плановое время рассылки задачи, к которой относится сообщение, для учета отклика
+	Planned time.Time
+	// Variant - буква варианта текста задачи (ScheduledTask.Variants), пустая - вариантов нет
+	Variant string
+	// Language - язык получателя из списков получателей для переводов и шаблона задачи
+	Language string
+	// Mentions - JID пользователей, упомянутых в тексте (@номер)
+	Mentions []string
+	// Escalation - сообщение, отправляемое, если на это сообщение не ответили, nil - без эскалации
+	Escalation *Escalation
+	// Owner - пользователь API, чья суточная квота расходуется на сообщение, пустой - без квоты
+	Owner string
+}
+
+// IncomingMessage - входящее сообщение, полученное транспортом
+type IncomingMessage struct {
+	ID         string
+	ChatJID    string
+	SenderJID  string
+	SenderName string
+	Text       string
+	// Direct - сообщение из личного чата
+	Direct bool
+	FromMe bool
+	// ReplyToID - ID сообщения, на которое отвечает входящее (цитата)
+	ReplyToID string
+	// ReactionToID - ID сообщения, на которое поставлена реакция Reaction (пустая - реакция снята)
+	ReactionToID string
+	Reaction     string
+	Timestamp    time.Time
+	// Media - вложение сообщения, которое можно сохранить в медиатеку, nil - вложения нет
+	Media *IncomingMedia
+}
@@ -0,0 +1,315 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	LocaleRU = "ru"
+	LocaleEN = "en"
+)
+
+// Settings - настройки, изменяемые во время работы (GET/PUT /admin/settings).
+// Сохраненные значения переопределяют Config, заданный при запуске
+type Settings struct {
+	// RateLimit - максимум отправок в минуту, 0 - без ограничений
+	RateLimit int `json:"rate_limit"`
+	// BreakerThreshold - количество ошибок подряд до приостановки чата, 0 - без приостановки
+	BreakerThreshold int `json:"breaker_threshold"`
+	// SendTimeoutSeconds - таймаут отправки сообщения, если он не задан в задаче
+	SendTimeoutSeconds int `json:"send_timeout_seconds"`
+	// ChatSendGapSeconds - минимальная пауза между сообщениями в один чат
+	ChatSendGapSeconds int `json:"chat_send_gap_seconds"`
+	// QuietHours - тихие часы ЧЧ:ММ-ЧЧ:ММ, в которые отправки задач пропускаются, пусто - без тихих часов
+	QuietHours string `json:"quiet_hours"`
+	// DefaultTimezone - часовой пояс IANA для тихих часов и времени на естественном языке, пусто - локальный
+	DefaultTimezone string `json:"default_timezone"`
+	// Locale - язык форматирования дат и чисел в сообщениях: ru или en
+	Locale string `json:"locale"`
+	// EngagementWindowHours - сколько часов после отправки реакции и ответы учитываются в статистике задачи
+	EngagementWindowHours int `json:"engagement_window_hours"`
+	// ModerationBlocklist - фразы (без учета регистра), сообщения с которыми не отправляются
+	ModerationBlocklist []string `json:"moderation_blocklist"`
+	// ModerationURL - сервис модерации, проверяющий каждое сообщение перед отправкой, пусто - без проверки
+	ModerationURL string `json:"moderation_url"`
+	// ModerationFailOpen - отправлять сообщения без проверки, если сервис модерации недоступен
+	ModerationFailOpen bool `json:"moderation_fail_open"`
+	// StrictTemplates - не отправлять сообщения с незаполненными переменными шаблона и пустым текстом
+	StrictTemplates bool `json:"strict_templates"`
+	// BusinessHours - рабочие часы ЧЧ:ММ-ЧЧ:ММ, пусто - весь день
+	BusinessHours string `json:"business_hours"`
+	// BusinessDays - рабочие дни: mon, tue, wed, thu, fri, sat, sun; пусто - все дни
+	BusinessDays []string `json:"business_days"`
+	// AutoReplyMessage - ответ на личные сообщения вне рабочего времени, не чаще раза в день
+	// каждому отправителю; пусто - без автоответа
+	AutoReplyMessage string `json:"auto_reply_message"`
+	// ResendOnReconnect - сразу после восстановления подключения повторять последнюю отправку задачи,
+	// не выполненную из-за отсутствия подключения, а не ждать следующей по интервалу
+	ResendOnReconnect bool `json:"resend_on_reconnect"`
+	// MessageFooter - подпись, добавляемая к каждому сообщению задач, пусто - без подписи.
+	// Поддерживает {{chat_name}}, {{date}}, {{time}} и {{weekday}}
+	MessageFooter string `json:"message_footer"`
+	// OptOutKeywords - личное сообщение из одного такого слова (без учета регистра) добавляет
+	// отправителя в список исключений рассылок
+	OptOutKeywords []string `json:"opt_out_keywords"`
+	// ComplianceMode - режим согласия: рассылки выполняются, только если их подпись содержит
+	// слово отписки из OptOutKeywords
+	ComplianceMode bool `json:"compliance_mode"`
+	// ProcessImages - перед отправкой уменьшать изображения JPEG и PNG, перекодировать их в JPEG
+	// и удалять метаданные EXIF с координатами GPS
+	ProcessImages bool `json:"process_images"`
+	// ImageMaxDimension - максимальная большая сторона изображения в пикселях, 0 - без уменьшения
+	ImageMaxDimension int `json:"image_max_dimension"`
+	// ImageQuality - качество JPEG при перекодировании изображения, 1..100
+	ImageQuality int `json:"image_quality"`
+	// DownloadIncomingMedia - сохранять изображения и документы входящих сообщений в медиатеку
+	DownloadIncomingMedia bool `json:"download_incoming_media"`
+	// IncomingMediaRetentionDays - срок хранения вложений входящих сообщений, 0 - без срока
+	IncomingMediaRetentionDays int `json:"incoming_media_retention_days"`
+	// IncomingMediaMaxMB - общий объем вложений входящих сообщений, старые удаляются первыми; 0 - без ограничения
+	IncomingMediaMaxMB int `json:"incoming_media_max_mb"`
+	// HistoryRetentionDays - срок хранения истории отправок, 0 - без срока
+	HistoryRetentionDays int `json:"history_retention_days"`
+	// MessagesRetentionDays - срок хранения сообщений для пересылки (GET /messages), 0 - без срока
+	MessagesRetentionDays int `json:"messages_retention_days"`
+	// MediaRetentionDays - срок хранения загруженных файлов медиатеки, не используемых задачами, 0 - без срока
+	MediaRetentionDays int `json:"media_retention_days"`
+	// BanDetection - приостанавливать все задачи, если ошибки отправки похожи на ограничение аккаунта
+	BanDetection bool `json:"ban_detection"`
+	// BanServerErrors - количество ошибок сервера (5xx) подряд до срабатывания, 0 - не учитывать
+	BanServerErrors int `json:"ban_server_errors"`
+	// BanRejections - количество отказов сервера (например, 479) до срабатывания, 0 - не учитывать
+	BanRejections int `json:"ban_rejections"`
+	// BanFailedChats - количество разных чатов с ошибкой отправки подряд до срабатывания, 0 - не учитывать
+	BanFailedChats int `json:"ban_failed_chats"`
+	// BanWindowMinutes - за сколько минут учитываются ошибки серии
+	BanWindowMinutes int `json:"ban_window_minutes"`
+	// ConfirmRecipients - рассылка на столько получателей и больше запускается только после
+	// подтверждения (POST /tasks/:id/confirm), 0 - без подтверждения
+	ConfirmRecipients int `json:"confirm_recipients"`
+	// IsolateTasks - пользователи API без ключа администратора видят и изменяют только свои задачи
+	IsolateTasks bool `json:"isolate_tasks"`
+	// UserDailyQuota - максимум отправок пользователя API за сутки (в DefaultTimezone), 0 - без ограничений
+	UserDailyQuota int `json:"user_daily_quota"`
+	// UserQuotas - суточные квоты отдельных пользователей вместо UserDailyQuota, 0 - без ограничений
+	UserQuotas map[string]int `json:"user_quotas,omitempty"`
+}
+
+// settingsFromConfig возвращает настройки, заданные при запуске
+func settingsFromConfig(config Config) Settings {
+	return Settings{
+		RateLimit:          config.RateLimit,
+		BreakerThreshold:   config.BreakerThreshold,
+		SendTimeoutSeconds: int(config.SendTimeout / time.Second),
+		ChatSendGapSeconds: int(config.ChatSendGap / time.Second),
+		Locale:             LocaleRU,
+
+		EngagementWindowHours: DefaultEngagementWindowHours,
+		ModerationBlocklist:   []string{},
+		BusinessDays:          []string{},
+		OptOutKeywords:        []string{},
+		ProcessImages:         true,
+		ImageMaxDimension:     DefaultImageMaxDimension,
+		ImageQuality:          DefaultImageQuality,
+
+		IncomingMediaRetentionDays: DefaultIncomingMediaRetentionDays,
+		IncomingMediaMaxMB:         DefaultIncomingMediaMaxMB,
+
+		BanDetection:     true,
+		BanServerErrors:  DefaultBanServerErrors,
+		BanRejections:    DefaultBanRejections,
+		BanFailedChats:   DefaultBanFailedChats,
+		BanWindowMinutes: DefaultBanWindowMinutes,
+
+		ConfirmRecipients: DefaultConfirmRecipients,
+	}
+}
+
+// Validate проверяет настройки
+func (st *Settings) Validate() error {
+	st.QuietHours = strings.TrimSpace(st.QuietHours)
+	st.DefaultTimezone = strings.TrimSpace(st.DefaultTimezone)
+	st.ModerationURL = strings.TrimSpace(st.ModerationURL)
+	st.ModerationBlocklist = normalizeKeywords(st.ModerationBlocklist)
+	st.BusinessHours = strings.TrimSpace(st.BusinessHours)
+	st.BusinessDays = normalizeKeywords(st.BusinessDays)
+	st.AutoReplyMessage = strings.TrimSpace(st.AutoReplyMessage)
+	st.MessageFooter = strings.TrimSpace(st.MessageFooter)
+
+	if st.RateLimit < 0 || st.BreakerThreshold < 0 || st.ChatSendGapSeconds < 0 {
+		return fmt.Errorf("rate_limit, breaker_threshold и chat_send_gap_seconds не могут быть отрицательными")
+	}
+	if st.SendTimeoutSeconds <= 0 || time.Duration(st.SendTimeoutSeconds)*time.Second > maxSendTimeout {
+		return fmt.Errorf("send_timeout_seconds должен быть в диапазоне 1..%d", int(maxSendTimeout.Seconds()))
+	}
+	if _, _, err := parseQuietHours(st.QuietHours); err != nil {
+		return err
+	}
+	if _, err := loadTimezone(st.DefaultTimezone); err != nil {
+		return err
+	}
+	if st.EngagementWindowHours <= 0 {
+		return fmt.Errorf("engagement_window_hours должен быть положительным")
+	}
+	if err := validateModerationURL(st.ModerationURL); err != nil {
+		return err
+	}
+	if err := st.validateBusinessHours(); err != nil {
+		return err
+	}
+	if err := validateFooter(st.MessageFooter); err != nil {
+		return err
+	}
+	if err := st.validateCompliance(); err != nil {
+		return err
+	}
+	if err := st.validateImageProcessing(); err != nil {
+		return err
+	}
+	if err := st.validateIncomingMedia(); err != nil {
+		return err
+	}
+	if err := st.validateRetention(); err != nil {
+		return err
+	}
+	if err := st.validateBanDetection(); err != nil {
+		return err
+	}
+	if err := st.validateConfirmation(); err != nil {
+		return err
+	}
+	if err := st.validateQuotas(); err != nil {
+		return err
+	}
+	if st.Locale != LocaleRU && st.Locale != LocaleEN {
+		return fmt.Errorf("неверный язык '%s', допустимо: %s, %s", st.Locale, LocaleRU, LocaleEN)
+	}
+	return nil
+}
+
+// parseQuietHours разбирает тихие часы "22:00-08:00" в смещения от начала суток
+func parseQuietHours(value string) (time.Duration, time.Duration, error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+	fromValue, toValue, ok := strings.Cut(value, "-")
+	from, fromErr := time.Parse("15:04", strings.TrimSpace(fromValue))
+	to, toErr := time.Parse("15:04", strings.TrimSpace(toValue))
+	if !ok || fromErr != nil || toErr != nil || from.Equal(to) {
+		return 0, 0, fmt.Errorf("неверные тихие часы '%s', пример: 22:00-08:00", value)
+	}
+	sinceMidnight := func(t time.Time) time.Duration {
+		return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	return sinceMidnight(from), sinceMidnight(to), nil
+}
+
+// Settings возвращает текущие настройки
+func (s *Scheduler) Settings() Settings {
+	s.settingsMutex.RLock()
+	defer s.settingsMutex.RUnlock()
+	return s.settings
+}
+
+// SetSettings проверяет, сохраняет и применяет настройки
+func (s *Scheduler) SetSettings(settings Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	if err := s.storage.saveSettings(settings); err != nil {
+		return fmt.Errorf("ошибка сохранения настроек: %v", err)
+	}
+	s.applySettings(settings)
+	Logger.Infof("⚙️ Настройки обновлены")
+	return nil
+}
+
+// applySettings применяет настройки к компонентам планировщика
+func (s *Scheduler) applySettings(settings Settings) {
+	s.settingsMutex.Lock()
+	s.settings = settings
+	s.settingsMutex.Unlock()
+
+	s.limiter.SetLimit(settings.RateLimit)
+	s.breakers.SetThreshold(settings.BreakerThreshold)
+	s.chatQueues.setGap(time.Duration(settings.ChatSendGapSeconds) * time.Second)
+}
+
+// Location возвращает часовой пояс по умолчанию
+func (s *Scheduler) Location() *time.Location {
+	location, err := loadTimezone(s.Settings().DefaultTimezone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+// inQuietHours проверяет, что момент t попадает в тихие часы
+func (s *Scheduler) inQuietHours(t time.Time) bool {
+	from, to, err := parseQuietHours(s.Settings().QuietHours)
+	if err != nil || from == to {
+		return false
+	}
+	t = t.In(s.Location())
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if from < to {
+		return clock >= from && clock < to
+	}
+	// Тихие часы переходят через полночь
+	return clock >= from || clock < to
+}
+
+// saveSettings сохраняет настройки: ключ - имя поля JSON, значение - его JSON
+func (st *Storage) saveSettings(settings Settings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for key, value := range values {
+		if _, err := tx.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)
+			ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, string(value)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// loadSettings переопределяет settings сохраненными значениями
+func (st *Storage) loadSettings(settings *Settings) error {
+	rows, err := st.db.Query(`SELECT key, value FROM settings`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	values := map[string]json.RawMessage{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		values[key] = json.RawMessage(value)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, settings)
+}
@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrShuttingDown возвращается при отправке во время остановки процесса
+var ErrShuttingDown = errors.New("приложение останавливается, отправка отклонена")
+
+// Shutdown готовит планировщик к остановке процесса: новые отправки отклоняются,
+// начатые отправки завершаются, но ожидание длится не дольше ctx.
+// Задачи остаются в хранилище, прерванные ожидания отправок возобновятся после перезапуска
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.closing.Store(true)
+	// Ожидающие отложенные отправки прерываются и остаются в хранилище
+	if s.oneOffs.stop != nil {
+		s.oneOffs.stop()
+	}
+
+	// Блокировка на запись дожидается всех отправок, удерживающих ее на чтение, и не отпускается
+	done := make(chan struct{})
+	go func() {
+		s.sendingMutex.Lock()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("не дождались завершения отправок: %w", ctx.Err())
+	}
+}
+
+// IsShuttingDown проверяет, что начата остановка процесса
+func (s *Scheduler) IsShuttingDown() bool {
+	return s.closing.Load()
+}
@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// SimulationDefaultDays - горизонт симуляции по умолчанию
+	SimulationDefaultDays = 7
+	// SimulationMaxDays - максимальный горизонт симуляции
+	SimulationMaxDays = 31
+	// simulationMaxSends ограничивает результат для задач с маленьким интервалом и большими рассылками
+	simulationMaxSends = 5000
+)
+
+// SimulatedSend - отправка, которую выполнила бы задача
+type SimulatedSend struct {
+	// At - время отправки с учетом случайной задержки и пауз рассылки
+	At       time.Time `json:"at"`
+	Planned  time.Time `json:"planned"`
+	ChatName string    `json:"chat_name"`
+	Message  string    `json:"message,omitempty"`
+	Variant  string    `json:"variant,omitempty"`
+}
+
+// Simulation - результат симуляции задачи
+type Simulation struct {
+	From  time.Time       `json:"from"`
+	To    time.Time       `json:"to"`
+	Sends []SimulatedSend `json:"sends"`
+	// Truncated - симуляция остановлена после simulationMaxSends отправок
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// simulationSender записывает отправки по симулированным часам
+type simulationSender struct {
+	clock     Clock
+	mutex     sync.Mutex
+	sends     []SimulatedSend
+	truncated bool
+	// stop останавливает задачу, когда записано simulationMaxSends отправок
+	stop func(taskID string)
+}
+
+func (m *simulationSender) SendMessage(ctx context.Context, msg OutgoingMessage) (SendResult, error) {
+	at := m.clock.Now()
+	m.mutex.Lock()
+	m.sends = append(m.sends, SimulatedSend{
+		At:       at,
+		Planned:  msg.Planned,
+		ChatName: msg.ChatName,
+		Message:  msg.Message,
+		Variant:  msg.Variant,
+	})
+	full := len(m.sends) >= simulationMaxSends
+	if full {
+		m.truncated = true
+	}
+	m.mutex.Unlock()
+
+	if full {
+		m.stop(msg.TaskID)
+	}
+	return SendResult{ChatJID: msg.ChatName, MessageID: msg.ID, Timestamp: at}, nil
+}
+
+// Simulate прогоняет задачу по симулированным часам на days дней вперед и возвращает отправки,
+// которые она выполнила бы с текущими настройками: с учетом исключений, случайной задержки,
+// тихих часов и пауз рассылки. Задача выполняется в отдельном планировщике с хранилищем в памяти,
+// ничего не отправляется и не сохраняется. Команды и проверки доступности не выполняются
+func (s *Scheduler) Simulate(ctx context.Context, task *ScheduledTask, days int) (*Simulation, error) {
+	if days <= 0 || days > SimulationMaxDays {
+		return nil, fmt.Errorf("горизонт симуляции должен быть от 1 до %d дней", SimulationMaxDays)
+	}
+
+	storage, err := OpenStorage("file::memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer storage.db.Close()
+
+	// Получатели из списка исключений пропускаются и в симуляции
+	suppressions, err := s.storage.ListSuppressions()
+	if err != nil {
+		return nil, err
+	}
+	for _, suppression := range suppressions {
+		if err := storage.AddSuppression(suppression.JID, suppression.Reason); err != nil {
+			return nil, err
+		}
+	}
+
+	from := s.clock.Now()
+	to := from.AddDate(0, 0, days)
+	clock := NewSimulatedClock(from, to)
+	sender := &simulationSender{clock: clock}
+	// Планировщик собирается без NewScheduler: тот загрузил бы из пустого хранилища общие исключения дат.
+	// Ограничения частоты и пауз между сообщениями работают по реальному времени и в симуляции
+	// только замедлили бы ее, а сервис модерации получил бы все сообщения за горизонт
+	simulation := &Scheduler{
+		tasks:      make(map[string]*ScheduledTask),
+		sender:     sender,
+		storage:    storage,
+		limiter:    newRateLimiter(0, time.Minute),
+		quotas:     newSendQuotas(),
+		breakers:   newCircuitBreakers(0),
+		presence:   newPresenceTracker(),
+		clock:      clock,
+		chatQueues: newChatQueues(0),
+		sendSlots:  newSendSlots(0),
+	}
+	sender.stop = func(taskID string) { simulation.StopTask(taskID) }
+
+	settings := s.Settings()
+	settings.RateLimit, settings.BreakerThreshold, settings.ChatSendGapSeconds = 0, 0, 0
+	settings.UserDailyQuota, settings.UserQuotas = 0, nil
+	settings.ModerationURL = ""
+	settings.ConfirmRecipients = 0
+	simulation.applySettings(settings)
+
+	if task.MessageCommand != "" {
+		task.Message = "$ " + task.MessageCommand
+		task.MessageCommand = ""
+	}
+	if task.GroupSetting != nil {
+		task.Message = task.GroupSetting.String()
+		task.GroupSetting = nil
+	}
+	if task.GroupInfo != nil {
+		task.Message = fmt.Sprintf("✏️ %s: %s", task.GroupInfo.Field, task.GroupInfo.Template)
+		task.GroupInfo = nil
+	}
+	task.HealthCheck = nil
+	if _, err := simulation.AddTask(task); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-task.done:
+	case <-ctx.Done():
+		simulation.StopTask(task.ID)
+		<-task.done
+		return nil, ctx.Err()
+	}
+
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+	return &Simulation{From: from, To: to, Sends: append([]SimulatedSend{}, sender.sends...), Truncated: sender.truncated}, nil
+}
@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSimulateOccurrences(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, berlin)
+
+	tests := []struct {
+		name string
+		task ScheduledTask
+		days int
+		want []time.Time
+	}{
+		{
+			name: "every day at 09:00 from start_at",
+			task: ScheduledTask{
+				Every:   &CalendarInterval{Unit: UnitDay, At: "09:00"},
+				StartAt: "tomorrow 8am",
+				EndAt:   "in 30 days",
+			},
+			days: 3,
+			want: []time.Time{
+				time.Date(2025, 3, 11, 9, 0, 0, 0, berlin),
+				time.Date(2025, 3, 12, 9, 0, 0, 0, berlin),
+				time.Date(2025, 3, 13, 9, 0, 0, 0, berlin),
+			},
+		},
+		{
+			name: "every 8 hours until end_time",
+			task: ScheduledTask{
+				Interval:  8 * 60,
+				StartTime: now.Add(time.Hour),
+				EndTime:   now.Add(20 * time.Hour),
+			},
+			days: 2,
+			want: []time.Time{
+				time.Date(2025, 3, 10, 13, 0, 0, 0, berlin),
+				time.Date(2025, 3, 10, 21, 0, 0, 0, berlin),
+				time.Date(2025, 3, 11, 5, 0, 0, 0, berlin),
+			},
+		},
+		{
+			name: "every day at 09:00 across spring forward",
+			task: ScheduledTask{
+				Every:     &CalendarInterval{Unit: UnitDay, At: "09:00"},
+				StartTime: time.Date(2025, 3, 29, 0, 0, 0, 0, berlin),
+				EndTime:   time.Date(2025, 4, 1, 0, 0, 0, 0, berlin),
+			},
+			days: 31,
+			want: []time.Time{
+				time.Date(2025, 3, 29, 9, 0, 0, 0, berlin),
+				time.Date(2025, 3, 30, 9, 0, 0, 0, berlin),
+				time.Date(2025, 3, 31, 9, 0, 0, 0, berlin),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestScheduler(t)
+			s.SetClock(NewSimulatedClock(now, time.Time{}))
+
+			request := tt.task
+			request.ChatName = "Team"
+			request.Message = "Daily report"
+			request.Timezone = "Europe/Berlin"
+			simulation, err := s.Simulate(context.Background(), NewTaskFromRequest(&request), tt.days)
+			if err != nil {
+				t.Fatalf("Simulate: %v", err)
+			}
+
+			if !simulation.From.Equal(now) {
+				t.Errorf("From = %v, want scheduler clock %v", simulation.From, now)
+			}
+			if len(simulation.Sends) != len(tt.want) {
+				t.Fatalf("got %d sends %v, want %d", len(simulation.Sends), simulation.Sends, len(tt.want))
+			}
+			for i, send := range simulation.Sends {
+				if !send.At.Equal(tt.want[i]) || !send.Planned.Equal(tt.want[i]) {
+					t.Errorf("send %d at %v (planned %v), want %v", i, send.At.In(berlin), send.Planned.In(berlin), tt.want[i])
+				}
+				if send.ChatName != "Team" || send.Message != "Daily report" {
+					t.Errorf("send %d = %q to %q", i, send.Message, send.ChatName)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"time"
+)
+
+// TaskStat - почасовая статистика отправок задачи
+type TaskStat struct {
+	Hour   time.Time `json:"hour"`
+	Sent   int       `json:"sent"`
+	Failed int       `json:"failed"`
+	// AvgLatencyMs - среднее время отправки одного сообщения
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+// recordTaskStat добавляет результат отправки задачи в почасовую статистику.
+// Разовые отправки и отклоненные лимитом сообщения не учитываются
+func (s *Scheduler) recordTaskStat(msg OutgoingMessage, sendErr error, latency time.Duration) {
+	if s.storage == nil || msg.TaskID == "" {
+		return
+	}
+	if err := s.storage.AddTaskStat(msg.TaskID, time.Now(), sendErr == nil, latency); err != nil {
+		Logger.Errorf("Ошибка записи статистики задачи %s: %v", msg.TaskID, err)
+	}
+}
+
+// AddTaskStat учитывает одну отправку задачи в агрегате ее часа
+func (st *Storage) AddTaskStat(taskID string, at time.Time, sent bool, latency time.Duration) error {
+	sentCount, failedCount := 1, 0
+	if !sent {
+		sentCount, failedCount = 0, 1
+	}
+	_, err := st.db.Exec(`INSERT INTO task_stats (task_id, hour, sent, failed, latency_ms) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (task_id, hour) DO UPDATE SET
+			sent = sent + excluded.sent,
+			failed = failed + excluded.failed,
+			latency_ms = latency_ms + excluded.latency_ms`,
+		taskID, at.UTC().Truncate(time.Hour), sentCount, failedCount, latency.Milliseconds())
+	return err
+}
+
+// TaskStats возвращает почасовую статистику задачи начиная с since (старые первыми)
+func (st *Storage) TaskStats(taskID string, since time.Time) ([]TaskStat, error) {
+	rows, err := st.db.Query(`SELECT hour, sent, failed, latency_ms FROM task_stats
+		WHERE task_id = ? AND hour >= ? ORDER BY hour`, taskID, since.UTC().Truncate(time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []TaskStat{}
+	for rows.Next() {
+		var stat TaskStat
+		var latencyMs int64
+		if err := rows.Scan(&stat.Hour, &stat.Sent, &stat.Failed, &latencyMs); err != nil {
+			return nil, err
+		}
+		if attempts := stat.Sent + stat.Failed; attempts > 0 {
+			stat.AvgLatencyMs = float64(latencyMs) / float64(attempts)
+		}
+		stats = append(stats, stat)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,316 @@
+package scheduler
+
+import (
+	"crypto/cipher"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3" // драйвер базы данных планировщика
+)
+
+// Storage - собственная база данных планировщика (история, настройки и т.д.),
+// отдельная от базы сессии whatsmeow
+type Storage struct {
+	db *sql.DB
+	// aead шифрует тексты сообщений и данные получателей, nil - шифрование выключено
+	aead cipher.AEAD
+}
+
+// storageMigrations выполняются при каждом запуске, поэтому должны быть идемпотентными
+var storageMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS history (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id    TEXT NOT NULL DEFAULT '',
+		chat_name  TEXT NOT NULL,
+		chat_jid   TEXT NOT NULL DEFAULT '',
+		message    TEXT NOT NULL,
+		status     TEXT NOT NULL,
+		error      TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS history_created_at ON history (created_at)`,
+	`CREATE TABLE IF NOT EXISTS exclusions (
+		kind  TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (kind, value)
+	)`,
+	`CREATE TABLE IF NOT EXISTS media (
+		id         TEXT PRIMARY KEY,
+		sha256     TEXT NOT NULL UNIQUE,
+		file_name  TEXT NOT NULL,
+		mime_type  TEXT NOT NULL,
+		size       INTEGER NOT NULL,
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS media_uploads (
+		media_id        TEXT NOT NULL REFERENCES media (id) ON DELETE CASCADE,
+		media_type      TEXT NOT NULL,
+		url             TEXT NOT NULL,
+		direct_path     TEXT NOT NULL,
+		media_key       BLOB NOT NULL,
+		file_enc_sha256 BLOB NOT NULL,
+		file_sha256     BLOB NOT NULL,
+		file_length     INTEGER NOT NULL,
+		uploaded_at     DATETIME NOT NULL,
+		PRIMARY KEY (media_id, media_type)
+	)`,
+	`CREATE TABLE IF NOT EXISTS block_keywords (
+		keyword TEXT PRIMARY KEY
+	)`,
+	`CREATE TABLE IF NOT EXISTS mqtt_routes (
+		topic     TEXT PRIMARY KEY,
+		chat_name TEXT NOT NULL,
+		template  TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS task_stats (
+		task_id    TEXT NOT NULL,
+		hour       DATETIME NOT NULL,
+		sent       INTEGER NOT NULL DEFAULT 0,
+		failed     INTEGER NOT NULL DEFAULT 0,
+		latency_ms INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (task_id, hour)
+	)`,
+	`CREATE TABLE IF NOT EXISTS notification_sinks (
+		url    TEXT PRIMARY KEY,
+		kind   TEXT NOT NULL,
+		events TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS tasks (
+		id                 TEXT PRIMARY KEY,
+		data               TEXT NOT NULL,
+		pending_occurrence DATETIME,
+		pending_send_at    DATETIME,
+		last_occurrence    DATETIME
+	)`,
+	`CREATE TABLE IF NOT EXISTS broadcast_reports (
+		task_id    TEXT NOT NULL,
+		occurrence INTEGER NOT NULL,
+		planned_at DATETIME NOT NULL,
+		recipient  INTEGER NOT NULL,
+		chat_name  TEXT NOT NULL,
+		status     TEXT NOT NULL,
+		error      TEXT NOT NULL DEFAULT '',
+		message_id TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME NOT NULL,
+		PRIMARY KEY (task_id, occurrence, recipient)
+	)`,
+	`CREATE INDEX IF NOT EXISTS broadcast_reports_message_id ON broadcast_reports (message_id)`,
+	`CREATE TABLE IF NOT EXISTS broadcast_duplicates (
+		task_id    TEXT NOT NULL,
+		planned_at DATETIME NOT NULL,
+		duplicates INTEGER NOT NULL,
+		PRIMARY KEY (task_id, planned_at)
+	)`,
+	`CREATE TABLE IF NOT EXISTS suppressions (
+		jid        TEXT PRIMARY KEY,
+		reason     TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS task_deliveries (
+		task_id   TEXT NOT NULL,
+		recipient INTEGER NOT NULL,
+		PRIMARY KEY (task_id, recipient)
+	)`,
+	`CREATE TABLE IF NOT EXISTS task_pins (
+		task_id    TEXT NOT NULL,
+		chat_name  TEXT NOT NULL,
+		message_id TEXT NOT NULL,
+		PRIMARY KEY (task_id, chat_name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS stored_messages (
+		id         TEXT PRIMARY KEY,
+		chat_jid   TEXT NOT NULL,
+		sender_jid TEXT NOT NULL DEFAULT '',
+		text       TEXT NOT NULL DEFAULT '',
+		data       TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS stored_messages_created_at ON stored_messages (created_at)`,
+	`CREATE TABLE IF NOT EXISTS incoming_media (
+		message_id TEXT PRIMARY KEY,
+		media_id   TEXT NOT NULL REFERENCES media (id) ON DELETE CASCADE,
+		chat_jid   TEXT NOT NULL,
+		sender_jid TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS polls (
+		message_id TEXT PRIMARY KEY,
+		task_id    TEXT NOT NULL DEFAULT '',
+		chat_jid   TEXT NOT NULL,
+		question   TEXT NOT NULL,
+		options    TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS poll_votes (
+		message_id TEXT NOT NULL,
+		voter_jid  TEXT NOT NULL,
+		options    TEXT NOT NULL,
+		voted_at   DATETIME NOT NULL,
+		PRIMARY KEY (message_id, voter_jid)
+	)`,
+	`CREATE TABLE IF NOT EXISTS engagement_messages (
+		message_id TEXT PRIMARY KEY,
+		task_id    TEXT NOT NULL,
+		planned_at DATETIME NOT NULL,
+		chat_jid   TEXT NOT NULL DEFAULT '',
+		sent_at    DATETIME NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS engagement_messages_task ON engagement_messages (task_id, planned_at)`,
+	`CREATE INDEX IF NOT EXISTS engagement_messages_chat ON engagement_messages (chat_jid, sent_at)`,
+	`CREATE TABLE IF NOT EXISTS engagement_events (
+		message_id TEXT NOT NULL,
+		sender_jid TEXT NOT NULL,
+		kind       TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (message_id, sender_jid, kind)
+	)`,
+	`CREATE TABLE IF NOT EXISTS experiment_messages (
+		message_id TEXT PRIMARY KEY,
+		task_id    TEXT NOT NULL,
+		variant    TEXT NOT NULL,
+		read_at    DATETIME
+	)`,
+	`CREATE INDEX IF NOT EXISTS experiment_messages_task ON experiment_messages (task_id)`,
+	`CREATE TABLE IF NOT EXISTS escalations (
+		message_id   TEXT PRIMARY KEY,
+		task_id      TEXT NOT NULL,
+		chat_name    TEXT NOT NULL,
+		chat_jid     TEXT NOT NULL DEFAULT '',
+		escalate_to  TEXT NOT NULL DEFAULT '',
+		message      TEXT NOT NULL,
+		sent_at      DATETIME NOT NULL,
+		due_at       DATETIME NOT NULL,
+		replied_at   DATETIME,
+		escalated_at DATETIME
+	)`,
+	`CREATE INDEX IF NOT EXISTS escalations_due ON escalations (due_at)`,
+	`CREATE INDEX IF NOT EXISTS escalations_chat ON escalations (chat_jid, sent_at)`,
+	`CREATE TABLE IF NOT EXISTS campaigns (
+		id         TEXT PRIMARY KEY,
+		data       TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS campaign_enrollments (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		campaign_id TEXT NOT NULL,
+		chat_name   TEXT NOT NULL,
+		chat_jid    TEXT NOT NULL DEFAULT '',
+		step        INTEGER NOT NULL DEFAULT 0,
+		next_at     DATETIME NOT NULL,
+		status      TEXT NOT NULL,
+		reason      TEXT NOT NULL DEFAULT '',
+		enrolled_at DATETIME NOT NULL,
+		updated_at  DATETIME NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS campaign_enrollments_campaign ON campaign_enrollments (campaign_id)`,
+	`CREATE TABLE IF NOT EXISTS recipient_lists (
+		id         TEXT PRIMARY KEY,
+		data       TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS scheduled_sends (
+		id         TEXT PRIMARY KEY,
+		data       TEXT NOT NULL,
+		send_at    DATETIME NOT NULL,
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS campaign_enrollments_due ON campaign_enrollments (status, next_at)`,
+	`CREATE INDEX IF NOT EXISTS campaign_enrollments_chat ON campaign_enrollments (chat_jid, status)`,
+	`CREATE TABLE IF NOT EXISTS auto_replies (
+		sender_jid TEXT PRIMARY KEY,
+		replied_on TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS group_welcomes (
+		group_jid  TEXT PRIMARY KEY,
+		message    TEXT NOT NULL,
+		direct     BOOLEAN NOT NULL DEFAULT 0,
+		updated_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS posted_groups (
+		group_jid      TEXT PRIMARY KEY,
+		last_posted_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS group_membership_log (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_jid  TEXT NOT NULL,
+		action     TEXT NOT NULL,
+		member_jid TEXT NOT NULL,
+		actor_jid  TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS group_membership_log_group ON group_membership_log (group_jid, created_at)`,
+	`CREATE TABLE IF NOT EXISTS batch_items (
+		batch_id     TEXT NOT NULL,
+		idx          INTEGER NOT NULL,
+		chat_name    TEXT NOT NULL,
+		status       TEXT NOT NULL,
+		error        TEXT NOT NULL DEFAULT '',
+		message_id   TEXT NOT NULL DEFAULT '',
+		scheduled_at DATETIME,
+		created_at   DATETIME NOT NULL,
+		updated_at   DATETIME NOT NULL,
+		PRIMARY KEY (batch_id, idx)
+	)`,
+	`CREATE INDEX IF NOT EXISTS batch_items_status ON batch_items (status)`,
+	`CREATE TABLE IF NOT EXISTS settings (
+		key   TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`,
+}
+
+// storageColumns - столбцы, добавленные в существующие таблицы после их создания
+var storageColumns = []struct {
+	table, column, definition string
+}{
+	{"history", "message_id", "TEXT NOT NULL DEFAULT ''"},
+	{"history", "sent_at", "DATETIME"},
+	{"media", "incoming", "INTEGER NOT NULL DEFAULT 0"},
+	{"broadcast_reports", "send_at", "DATETIME"},
+	{"broadcast_reports", "language", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// OpenStorage открывает (или создает) базу данных планировщика
+func OpenStorage(path string) (*Storage, error) {
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия БД планировщика: %v", err)
+	}
+	// SQLite не поддерживает параллельную запись
+	db.SetMaxOpenConns(1)
+
+	for _, migration := range storageMigrations {
+		if _, err := db.Exec(migration); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("ошибка миграции БД планировщика: %v", err)
+		}
+	}
+	if err := addStorageColumns(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ошибка миграции БД планировщика: %v", err)
+	}
+
+	return &Storage{db: db}, nil
+}
+
+// addStorageColumns добавляет отсутствующие столбцы storageColumns
+func addStorageColumns(db *sql.DB) error {
+	for _, c := range storageColumns {
+		var exists int
+		err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists > 0 {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DB возвращает соединение с базой данных для собственных таблиц транспортов и интеграций
+func (st *Storage) DB() *sql.DB {
+	return st.db
+}
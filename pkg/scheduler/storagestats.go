@@ -0,0 +1,140 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StorageFile - файл базы данных и его размер
+type StorageFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// JanitorRun - последняя очистка по срокам хранения
+type JanitorRun struct {
+	At     time.Time       `json:"at"`
+	Result RetentionResult `json:"result"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// StorageStats - размер хранилища планировщика (GET /admin/storage)
+type StorageStats struct {
+	// Files - файл базы данных и файлы журнала WAL, если они есть
+	Files     []StorageFile `json:"files"`
+	TotalSize int64         `json:"total_size"`
+	// FreeBytes - место внутри файла базы данных, освобожденное удалением и доступное для новых записей
+	FreeBytes int64 `json:"free_bytes"`
+	// Tables - количество строк по таблицам
+	Tables     map[string]int64 `json:"tables"`
+	MediaFiles int64            `json:"media_files"`
+	MediaSize  int64            `json:"media_size"`
+	// LastJanitorRun - последняя очистка по срокам хранения, nil - еще не выполнялась
+	LastJanitorRun *JanitorRun `json:"last_janitor_run,omitempty"`
+}
+
+// janitorState - результат последней очистки по срокам хранения
+type janitorState struct {
+	mutex   sync.Mutex
+	lastRun *JanitorRun
+}
+
+// recordJanitorRun запоминает результат очистки по срокам хранения
+func (s *Scheduler) recordJanitorRun(result RetentionResult, err error) {
+	run := &JanitorRun{At: time.Now(), Result: result}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	s.janitor.mutex.Lock()
+	s.janitor.lastRun = run
+	s.janitor.mutex.Unlock()
+}
+
+// StorageStats возвращает размеры файлов базы данных, количество строк в таблицах, объем
+// медиатеки и результат последней очистки, чтобы рост хранилища был заметен до заполнения диска
+func (s *Scheduler) StorageStats() (StorageStats, error) {
+	stats, err := s.storage.stats()
+	if err != nil {
+		return stats, err
+	}
+	s.janitor.mutex.Lock()
+	if s.janitor.lastRun != nil {
+		run := *s.janitor.lastRun
+		stats.LastJanitorRun = &run
+	}
+	s.janitor.mutex.Unlock()
+	return stats, nil
+}
+
+// stats собирает размер базы данных, количество строк и объем медиатеки
+func (st *Storage) stats() (StorageStats, error) {
+	stats := StorageStats{Files: []StorageFile{}, Tables: map[string]int64{}}
+
+	var path string
+	if err := st.db.QueryRow(`SELECT file FROM pragma_database_list WHERE name = 'main'`).Scan(&path); err != nil {
+		return stats, fmt.Errorf("ошибка чтения пути базы данных: %v", err)
+	}
+	// У базы в памяти нет файла
+	if path != "" {
+		for _, file := range []string{path, path + "-wal", path + "-shm"} {
+			if info, err := os.Stat(file); err == nil {
+				stats.Files = append(stats.Files, StorageFile{Path: file, Size: info.Size()})
+				stats.TotalSize += info.Size()
+			}
+		}
+	}
+	var freePages, pageSize int64
+	if err := st.db.QueryRow(`SELECT freelist_count FROM pragma_freelist_count`).Scan(&freePages); err != nil {
+		return stats, err
+	}
+	if err := st.db.QueryRow(`SELECT page_size FROM pragma_page_size`).Scan(&pageSize); err != nil {
+		return stats, err
+	}
+	stats.FreeBytes = freePages * pageSize
+
+	rows, err := st.db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return stats, err
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+	for _, table := range tables {
+		var count int64
+		if err := st.db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, table)).Scan(&count); err != nil {
+			return stats, err
+		}
+		stats.Tables[table] = count
+	}
+
+	// Учитываются и файлы, оставшиеся без записи в базе, и миниатюры видео
+	err = filepath.WalkDir(mediaDir, func(_ string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		stats.MediaFiles++
+		stats.MediaSize += info.Size()
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return stats, fmt.Errorf("ошибка чтения каталога медиатеки: %v", err)
+	}
+	return stats, nil
+}
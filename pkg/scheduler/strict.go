@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// alertKindTemplate - шаблон сообщения заполнен не полностью или пустой
+const alertKindTemplate = "template"
+
+// ErrTemplateRender - в строгом режиме сообщение с незаполненной переменной или пустым текстом не отправляется
+var ErrTemplateRender = errors.New("шаблон сообщения заполнен не полностью")
+
+var (
+	// unrenderedPlaceholder - переменная шаблона, оставшаяся в тексте: {{name}}, {{ .Data.name }}
+	unrenderedPlaceholder = regexp.MustCompile(`\{\{\s*\.?[\w.]*\s*\}\}`)
+	// missingValue - так text/template выводит отсутствующее значение
+	missingValue = "<no value>"
+)
+
+// checkRendered в строгом режиме (Settings.StrictTemplates) проверяет, что в тексте не осталось
+// переменных шаблона и он не пустой
+func (s *Scheduler) checkRendered(msg OutgoingMessage) error {
+	if !s.Settings().StrictTemplates || msg.ForwardID != "" {
+		return nil
+	}
+	return checkRenderedText(moderationText(msg), msg.Media != nil)
+}
+
+// checkRenderedText проверяет, что в тексте не осталось переменных шаблона и он не пустой (без медиа)
+func checkRenderedText(text string, hasMedia bool) error {
+	if strings.TrimSpace(text) == "" && !hasMedia {
+		return fmt.Errorf("%w: пустой текст", ErrTemplateRender)
+	}
+	if placeholder := unrenderedPlaceholder.FindString(text); placeholder != "" {
+		return fmt.Errorf("%w: незаполненная переменная %s", ErrTemplateRender, placeholder)
+	}
+	if strings.Contains(text, missingValue) {
+		return fmt.Errorf("%w: отсутствующее значение %s", ErrTemplateRender, missingValue)
+	}
+	return nil
+}
+
+// ReportRenderError оповещает об ошибке заполнения шаблона сообщения из внешнего источника
+// (например, маршрута MQTT), из-за которой сообщение не отправлено
+func (s *Scheduler) ReportRenderError(source string, err error) {
+	s.alert(alertKindTemplate, fmt.Sprintf("Сообщение из %s не отправлено: %v", source, err))
+}
@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"time"
+)
+
+// Suppression - получатель, которому рассылки не отправляются
+type Suppression struct {
+	JID       string    `json:"jid"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddSuppression добавляет получателя в список исключений рассылок
+func (st *Storage) AddSuppression(jid, reason string) error {
+	_, err := st.db.Exec(`INSERT INTO suppressions (jid, reason, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET reason = excluded.reason`, jid, reason, time.Now())
+	return err
+}
+
+// RemoveSuppression удаляет получателя из списка исключений, false - его там не было
+func (st *Storage) RemoveSuppression(jid string) (bool, error) {
+	res, err := st.db.Exec(`DELETE FROM suppressions WHERE jid = ?`, jid)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := res.RowsAffected()
+	return deleted > 0, err
+}
+
+// ListSuppressions возвращает список исключений рассылок
+func (st *Storage) ListSuppressions() ([]Suppression, error) {
+	rows, err := st.db.Query(`SELECT jid, reason, created_at FROM suppressions ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suppressions := []Suppression{}
+	for rows.Next() {
+		var suppression Suppression
+		if err := rows.Scan(&suppression.JID, &suppression.Reason, &suppression.CreatedAt); err != nil {
+			return nil, err
+		}
+		suppressions = append(suppressions, suppression)
+	}
+	return suppressions, rows.Err()
+}
+
+// skipSuppressed исключает из pending получателей из списка исключений и отмечает их в отчете.
+// Список исключений ведется по JID, поэтому названия и номера получателей сравниваются по их JID
+func (s *Scheduler) skipSuppressed(task *ScheduledTask, occurrence int, recipients []string, pending []int) []int {
+	suppressions, err := s.storage.ListSuppressions()
+	if err != nil {
+		Logger.Errorf("Ошибка чтения списка исключений рассылок: %v", err)
+		return pending
+	}
+	if len(suppressions) == 0 {
+		return pending
+	}
+	suppressed := make(map[string]bool, len(suppressions))
+	for _, suppression := range suppressions {
+		suppressed[suppression.JID] = true
+	}
+
+	// Участники группы уже указаны по JID
+	keys := recipients
+	if !task.GroupMembers {
+		keys = s.recipientKeys(task, recipients)
+	}
+
+	remaining := pending[:0]
+	for _, index := range pending {
+		if !suppressed[keys[index]] {
+			remaining = append(remaining, index)
+			continue
+		}
+		if occurrence > 0 {
+			if err := s.storage.setReportStatus(task.ID, occurrence, index, ReportStatusSuppressed); err != nil {
+				Logger.Errorf("Ошибка обновления отчета о рассылке задачи %s: %v", task.ID, err)
+			}
+		}
+	}
+	if skipped := len(pending) - len(remaining); skipped > 0 {
+		Logger.Infof("🚫 Рассылка по задаче %s: %d получателей в списке исключений пропущены", task.ID, skipped)
+	}
+	return remaining
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// resolvingSender - MemorySender, который находит чаты по названию в chats (название - JID)
+type resolvingSender struct {
+	*MemorySender
+	chats map[string]string
+}
+
+// ResolveChat возвращает JID чата с указанным названием
+func (r *resolvingSender) ResolveChat(chatName string) ([]ChatCandidate, error) {
+	jid, ok := r.chats[strings.ToLower(chatName)]
+	if !ok {
+		return nil, nil
+	}
+	return []ChatCandidate{{JID: jid, Kind: "contact", Name: chatName}}, nil
+}
+
+func TestSkipSuppressed(t *testing.T) {
+	tests := []struct {
+		name       string
+		task       ScheduledTask
+		recipients []string
+		want       []string
+	}{
+		{
+			name:       "contact name",
+			task:       ScheduledTask{ChatName: "Team", Recipients: []string{"Anna", "Boris"}},
+			recipients: []string{"Team", "Anna", "Boris"},
+			want:       []string{"Team", "Boris"},
+		},
+		{
+			name:       "phone number",
+			task:       ScheduledTask{ChatName: "Team", Recipients: []string{"+49 151 1111111", "+49 151 2222222"}},
+			recipients: []string{"Team", "+49 151 1111111", "+49 151 2222222"},
+			want:       []string{"Team", "+49 151 2222222"},
+		},
+		{
+			name:       "JID",
+			task:       ScheduledTask{ChatName: "Team", Recipients: []string{"491511111111@s.whatsapp.net", "Boris"}},
+			recipients: []string{"Team", "491511111111@s.whatsapp.net", "Boris"},
+			want:       []string{"Team", "Boris"},
+		},
+		{
+			name:       "main chat by its pinned JID",
+			task:       ScheduledTask{ChatName: "Anna K.", ChatJID: "491513333333@s.whatsapp.net", Recipients: []string{"Boris"}},
+			recipients: []string{"Anna K.", "Boris"},
+			want:       []string{"Boris"},
+		},
+		{
+			name:       "group members",
+			task:       ScheduledTask{ChatName: "Team", GroupMembers: true},
+			recipients: []string{"491513333333@s.whatsapp.net", "491514444444@s.whatsapp.net"},
+			want:       []string{"491514444444@s.whatsapp.net"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, err := OpenStorage(filepath.Join(t.TempDir(), "scheduler.db"))
+			if err != nil {
+				t.Fatalf("OpenStorage: %v", err)
+			}
+			t.Cleanup(func() { storage.db.Close() })
+			sender := &resolvingSender{MemorySender: NewMemorySender(), chats: map[string]string{
+				"anna":  "491511111111@s.whatsapp.net",
+				"boris": "491512222222@s.whatsapp.net",
+			}}
+			s, err := NewScheduler(storage, sender, Config{})
+			if err != nil {
+				t.Fatalf("NewScheduler: %v", err)
+			}
+			for _, jid := range []string{"491511111111@s.whatsapp.net", "491513333333@s.whatsapp.net"} {
+				if err := storage.AddSuppression(jid, optOutReason); err != nil {
+					t.Fatalf("AddSuppression: %v", err)
+				}
+			}
+
+			pending := make([]int, len(tt.recipients))
+			for i := range pending {
+				pending[i] = i
+			}
+			var got []string
+			for _, index := range s.skipSuppressed(&tt.task, 0, tt.recipients, pending) {
+				got = append(got, tt.recipients[index])
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("remaining recipients %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Ограничения на метки задачи
+const (
+	maxTaskTags      = 20
+	maxTaskTagLength = 50
+)
+
+// validateTags приводит метки к нижнему регистру, убирает повторы и проверяет их количество и длину
+func (t *ScheduledTask) validateTags() error {
+	tags := []string{}
+	for _, tag := range normalizeKeywords(t.Tags) {
+		if utf8.RuneCountInString(tag) > maxTaskTagLength {
+			return fmt.Errorf("метка '%s' длиннее %d символов", tag, maxTaskTagLength)
+		}
+		if !containsTag(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > maxTaskTags {
+		return fmt.Errorf("у задачи больше %d меток", maxTaskTags)
+	}
+	t.Tags = tags
+	return nil
+}
+
+// HasTag проверяет, что у задачи есть метка tag (без учета регистра)
+func (t *ScheduledTask) HasTag(tag string) bool {
+	return containsTag(t.Tags, strings.ToLower(strings.TrimSpace(tag)))
+}
+
+// containsTag проверяет, что tag есть в списке нормализованных меток
+func containsTag(tags []string, tag string) bool {
+	for _, existing := range tags {
+		if existing == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// TasksWithTag возвращает активные задачи владельца owner (пусто - любого) с меткой tag,
+// пустая метка не совпадает ни с одной задачей
+func (s *Scheduler) TasksWithTag(tag, owner string) []*ScheduledTask {
+	if strings.TrimSpace(tag) == "" {
+		return nil
+	}
+	tasks := s.TasksOwnedBy(owner)
+	tagged := make([]*ScheduledTask, 0, len(tasks))
+	for _, task := range tasks {
+		if task.HasTag(tag) {
+			tagged = append(tagged, task)
+		}
+	}
+	return tagged
+}
+
+// PauseTagged приостанавливает (paused=true) или возобновляет все задачи владельца owner
+// (пусто - любого) с меткой tag и возвращает их ID
+func (s *Scheduler) PauseTagged(tag, owner string, paused bool) []string {
+	ids := []string{}
+	for _, task := range s.TasksWithTag(tag, owner) {
+		if s.PauseTask(task.ID, paused) {
+			ids = append(ids, task.ID)
+		}
+	}
+	return ids
+}
+
+// StopTagged останавливает все задачи владельца owner (пусто - любого) с меткой tag и возвращает их ID
+func (s *Scheduler) StopTagged(tag, owner string) []string {
+	ids := []string{}
+	for _, task := range s.TasksWithTag(tag, owner) {
+		if s.StopTask(task.ID) {
+			ids = append(ids, task.ID)
+		}
+	}
+	return ids
+}
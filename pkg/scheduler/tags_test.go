@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTaggedOperations(t *testing.T) {
+	s, _ := newTestScheduler(t)
+	start := time.Now().Add(24 * time.Hour)
+	task := NewTaskFromRequest(&ScheduledTask{
+		ChatName:  "Team",
+		Message:   "Weekly report",
+		Interval:  60,
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+		Tags:      []string{"Marketing", "weekly"},
+	})
+	task.Owner = "alice"
+	id, err := s.AddTask(task)
+	if err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+	paused := func() bool {
+		tasks := s.ListTasks()
+		return len(tasks) == 1 && tasks[0].Paused
+	}
+
+	if got := s.PauseTagged("sales", "", true); len(got) != 0 {
+		t.Errorf("PauseTagged(sales) = %v, want no tasks", got)
+	}
+	// Задачи другого пользователя не затрагиваются
+	if got := s.PauseTagged("marketing", "bob", true); len(got) != 0 || paused() {
+		t.Errorf("PauseTagged(marketing, bob) = %v, want no tasks of another owner", got)
+	}
+	if got := s.PauseTagged(" MARKETING ", "alice", true); !slices.Equal(got, []string{id}) || !paused() {
+		t.Errorf("PauseTagged(marketing) = %v, paused %v, want [%s] paused", got, paused(), id)
+	}
+	if got := s.PauseTagged("weekly", "", false); !slices.Equal(got, []string{id}) || paused() {
+		t.Errorf("PauseTagged(weekly, false) = %v, paused %v, want [%s] resumed", got, paused(), id)
+	}
+	if got := s.StopTagged(" ", ""); len(got) != 0 {
+		t.Errorf("StopTagged(blank) = %v, want no tasks", got)
+	}
+	if got := s.StopTagged("weekly", "bob"); len(got) != 0 {
+		t.Errorf("StopTagged(weekly, bob) = %v, want no tasks of another owner", got)
+	}
+	if got := s.StopTagged("weekly", "alice"); !slices.Equal(got, []string{id}) || len(s.ListTasks()) != 0 {
+		t.Errorf("StopTagged(weekly) = %v, %d tasks left, want [%s] stopped", got, len(s.ListTasks()), id)
+	}
+}
@@ -0,0 +1,476 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// allowCommandsEnv - переменная окружения, разрешающая выполнение message_command
+	allowCommandsEnv = "WHATSAPP_SCHEDULER_ALLOW_COMMANDS"
+	// messageCommandTimeout - максимальное время выполнения message_command
+	messageCommandTimeout = time.Minute
+	// maxDelayMinutes - предел интервала и случайной задержки: большие значения переполняют time.Duration
+	maxDelayMinutes = 100 * 365 * minutesPerDay
+)
+
+type ScheduledTask struct {
+	ID          string    `json:"id"`
+	ChatName    string    `json:"chat_name"`
+	Message     string    `json:"message"`
+	Interval    int       `json:"interval"`
+	RandomDelay int       `json:"random_delay"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	// ChatJID - JID чата, выбранный среди одноименных (GET /resolve), вместо поиска по ChatName
+	ChatJID string `json:"chat_jid,omitempty"`
+	// StartAt - время первой отправки на естественном языке ("next monday 09:00") вместо StartTime,
+	// вычисляется при добавлении задачи в часовом поясе Timezone
+	StartAt string `json:"start_at,omitempty"`
+	// EndAt - время окончания на естественном языке относительно начала ("in 4 weeks") вместо EndTime
+	EndAt string `json:"end_at,omitempty"`
+	// Timezone - часовой пояс IANA для StartAt и отправок суточных задач, по умолчанию из настроек
+	Timezone string `json:"timezone,omitempty"`
+	// Every - интервал в календарных единицах (дни, недели, месяцы) вместо Interval
+	Every *CalendarInterval `json:"every,omitempty"`
+	// RRule - правило повторения iCalendar (FREQ=WEEKLY;BYDAY=MO,WE;BYHOUR=10) вместо Interval и Every
+	RRule string `json:"rrule,omitempty"`
+	// DSTPolicy - отправка суточной задачи, время которой не существует из-за перевода часов:
+	// shift (по умолчанию) или skip
+	DSTPolicy string `json:"dst_policy,omitempty"`
+	// Recipients - дополнительные чаты рассылки, сообщение отправляется в ChatName и в каждый из них
+	Recipients []string `json:"recipients,omitempty"`
+	// GroupMembers - ChatName - группа, сообщение отправляется каждому участнику в личный чат
+	GroupMembers bool `json:"group_members,omitempty"`
+	// RecipientLists - ID списков получателей (POST /recipients/lists), добавляемых к рассылке при
+	// каждой отправке. Повторы в списках и в Recipients убираются по JID чата
+	RecipientLists []string `json:"recipient_lists,omitempty"`
+	// Stagger - распределение отправок рассылки по времени
+	Stagger *StaggerOptions `json:"stagger,omitempty"`
+	// LocalTime - время ЧЧ:ММ по часам получателя: с планового времени каждый получатель рассылки
+	// получает сообщение в ближайшее LocalTime своего часового пояса (из списков получателей,
+	// остальные - по часовому поясу задачи)
+	LocalTime string `json:"local_time,omitempty"`
+	// SendOncePerRecipient - не отправлять получателям, уже получившим сообщение в прошлых рассылках
+	SendOncePerRecipient bool `json:"send_once_per_recipient,omitempty"`
+	// Variants - варианты текста вместо Message для сравнения отклика (GET /tasks/:id/variants)
+	Variants []string `json:"variants,omitempty"`
+	// VariantAssignment - распределение вариантов: occurrence (по умолчанию) или recipient
+	VariantAssignment string `json:"variant_assignment,omitempty"`
+	// Translations - переводы Message по кодам языков (en, pt-BR) для рассылки по спискам получателей:
+	// получатель с языком из списка получает перевод, остальные - Message
+	Translations map[string]string `json:"translations,omitempty"`
+	// Template - Message, варианты и переводы - шаблоны text/template, заполняемые при каждой отправке
+	// (см. taskTemplateData), с функциями formatDate и formatNumber на языке получателя
+	Template bool `json:"template,omitempty"`
+	// MessageCommand - команда, вывод которой используется как текст сообщения
+	MessageCommand string `json:"message_command,omitempty"`
+	// ExcludedDates - даты (ГГГГ-ММ-ДД), в которые отправки задачи пропускаются
+	ExcludedDates []string `json:"excluded_dates,omitempty"`
+	// HolidayCalendars - коды стран встроенных календарей праздников (RU, US, GB)
+	HolidayCalendars []string `json:"holiday_calendars,omitempty"`
+	// JitterSeconds - симметричное окно ±N секунд вокруг планового времени (вместо RandomDelay)
+	JitterSeconds int `json:"jitter_seconds,omitempty"`
+	// Humanize - случайное смещение по распределению, заменяет RandomDelay и JitterSeconds
+	Humanize *HumanizeOptions `json:"humanize,omitempty"`
+	// Presence - отправка только когда получатель в сети (или не в сети)
+	Presence *PresenceCondition `json:"presence,omitempty"`
+	// HealthCheck - HTTP проверка, без успешного прохождения которой отправка пропускается
+	HealthCheck *HealthCheck `json:"health_check,omitempty"`
+	// DisableLinkPreview - не формировать превью ссылок в сообщениях
+	DisableLinkPreview bool `json:"disable_link_preview,omitempty"`
+	// SplitLongMessages - разбивать слишком длинные сообщения на части вместо ошибки
+	SplitLongMessages bool `json:"split_long_messages,omitempty"`
+	// SplitDelaySeconds - пауза между частями, по умолчанию 2 секунды
+	SplitDelaySeconds int `json:"split_delay_seconds,omitempty"`
+	// Format - формат текста: plain (по умолчанию) или markdown с преобразованием в разметку WhatsApp
+	Format string `json:"format,omitempty"`
+	// MediaID - файл медиатеки, отправляемый с сообщением в качестве подписи
+	MediaID string `json:"media_id,omitempty"`
+	// MediaURL - вложение, скачиваемое заново при каждой отправке (например, обновляемый график)
+	MediaURL string `json:"media_url,omitempty"`
+	// VoiceNote - отправлять звуковое вложение как голосовое сообщение
+	VoiceNote bool `json:"voice_note,omitempty"`
+	// ForwardMessageID - пересылать сохраненное сообщение (GET /messages) вместо Message
+	ForwardMessageID string `json:"forward_message_id,omitempty"`
+	// Interactive - отправлять сообщение со списком или кнопками быстрого ответа
+	Interactive *InteractiveContent `json:"interactive,omitempty"`
+	// Poll - отправлять опрос с вопросом Message, голоса доступны в GET /polls/:message_id/results
+	Poll *PollContent `json:"poll,omitempty"`
+	// Pin - закреплять каждое отправленное сообщение на 24h, 7d или 30d, открепляя предыдущее
+	Pin string `json:"pin,omitempty"`
+	// Escalation - сообщение, отправляемое, если получатель не ответил на сообщение задачи
+	Escalation *Escalation `json:"escalation,omitempty"`
+	// Trigger - задача выключена, пока в ее чат не придет фраза включения
+	Trigger *TaskTrigger `json:"trigger,omitempty"`
+	// GroupSetting - менять настройку группы ChatName вместо отправки сообщения
+	GroupSetting *GroupSettingChange `json:"group_setting,omitempty"`
+	// GroupInfo - обновлять название или описание группы ChatName по шаблону вместо отправки сообщения
+	GroupInfo *GroupInfoUpdate `json:"group_info,omitempty"`
+	// DisappearingTimer - отправлять исчезающие сообщения: 24h, 7d или 90d
+	DisappearingTimer string `json:"disappearing_timer,omitempty"`
+	// Footer - подпись, добавляемая к каждому сообщению задачи вместо подписи из настроек
+	// (см. Settings.MessageFooter)
+	Footer string `json:"footer,omitempty"`
+	// DisableFooter - не добавлять подпись из настроек к сообщениям задачи
+	DisableFooter bool `json:"disable_footer,omitempty"`
+	// Tags - метки для поиска и групповых операций (GET /tasks?tag=, POST /tags/:tag/pause)
+	Tags []string `json:"tags,omitempty"`
+	// Owner - пользователь API, добавивший задачу (ключи WHATSAPP_SCHEDULER_API_KEYS); задается
+	// сервером, а не запросом. Пусто - задача добавлена без ключей пользователей
+	Owner string `json:"owner,omitempty"`
+	// Paused - задача приостановлена: расписание идет, но отправки пропускаются
+	Paused bool `json:"paused,omitempty"`
+	// NeedsAttention - причина, по которой отправки пропускаются до вмешательства:
+	// название чата больше не соответствует сохраненному ChatJID
+	NeedsAttention string `json:"needs_attention,omitempty"`
+	// PendingConfirmation - рассылка на много получателей ждет подтверждения (POST /tasks/:id/confirm)
+	// и до него не отправляется, Preview - ее объем
+	PendingConfirmation bool              `json:"pending_confirmation,omitempty"`
+	Preview             *BroadcastPreview `json:"preview,omitempty"`
+	// SendTimeoutSeconds - максимальное время отправки сообщения задачи (0 - таймаут планировщика)
+	SendTimeoutSeconds int `json:"send_timeout_seconds,omitempty"`
+	// NextSendAt - время следующей запланированной отправки
+	NextSendAt time.Time `json:"next_send_at"`
+	// ctx отменяется при остановке или замене задачи и прерывает ожидания и текущую отправку
+	ctx    context.Context
+	cancel context.CancelFunc
+	// generation - номер запуска задачи, см. Scheduler.isCurrent
+	generation uint64
+	// done закрывается при завершении горутины задачи
+	done chan struct{}
+	// confirmed закрывается при подтверждении рассылки, см. ConfirmTask
+	confirmed chan struct{}
+}
+
+// UnmarshalJSON для правильного парсинга времени
+func (t *ScheduledTask) UnmarshalJSON(data []byte) error {
+	type Alias ScheduledTask
+	aux := &struct {
+		StartTime string `json:"start_time"`
+		EndTime   string `json:"end_time"`
+		*Alias
+	}{
+		Alias: (*Alias)(t),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	// Парсим время в формате "2006-01-02T15:04:05.000Z"
+	if aux.StartTime != "" {
+		startTime, err := time.Parse("2006-01-02T15:04:05.000Z", aux.StartTime)
+		if err != nil {
+			return fmt.Errorf("неверный формат времени начала: %v", err)
+		}
+		t.StartTime = startTime
+	}
+
+	if aux.EndTime != "" {
+		endTime, err := time.Parse("2006-01-02T15:04:05.000Z", aux.EndTime)
+		if err != nil {
+			return fmt.Errorf("неверный формат времени окончания: %v", err)
+		}
+		t.EndTime = endTime
+	}
+
+	return nil
+}
+
+// snapshot возвращает копию задачи для чтения вне блокировки планировщика. Вызывается под
+// s.mutex; срезы и карты задачи после запуска не меняются и остаются общими
+func (t *ScheduledTask) snapshot() *ScheduledTask {
+	copied := *t
+	return &copied
+}
+
+// NewTaskFromRequest создает задачу из данных запроса, очищая входные данные
+func NewTaskFromRequest(task *ScheduledTask) *ScheduledTask {
+	return &ScheduledTask{
+		ChatName:    strings.TrimSpace(task.ChatName),
+		ChatJID:     strings.TrimSpace(task.ChatJID),
+		Message:     strings.TrimSpace(task.Message),
+		Interval:    task.Interval,
+		RandomDelay: task.RandomDelay,
+		StartTime:   task.StartTime,
+		EndTime:     task.EndTime,
+		StartAt:     strings.TrimSpace(task.StartAt),
+		EndAt:       strings.TrimSpace(task.EndAt),
+		Timezone:    strings.TrimSpace(task.Timezone),
+		DSTPolicy:   strings.TrimSpace(task.DSTPolicy),
+		Every:       task.Every,
+		RRule:       strings.TrimSpace(task.RRule),
+
+		Recipients:           task.Recipients,
+		GroupMembers:         task.GroupMembers,
+		RecipientLists:       task.RecipientLists,
+		Stagger:              task.Stagger,
+		LocalTime:            strings.TrimSpace(task.LocalTime),
+		SendOncePerRecipient: task.SendOncePerRecipient,
+
+		Variants:          task.Variants,
+		VariantAssignment: strings.TrimSpace(task.VariantAssignment),
+		Translations:      task.Translations,
+		Template:          task.Template,
+
+		MessageCommand:   strings.TrimSpace(task.MessageCommand),
+		ExcludedDates:    task.ExcludedDates,
+		HolidayCalendars: task.HolidayCalendars,
+		JitterSeconds:    task.JitterSeconds,
+		Humanize:         task.Humanize,
+		Presence:         task.Presence,
+		HealthCheck:      task.HealthCheck,
+
+		DisableLinkPreview: task.DisableLinkPreview,
+		SplitLongMessages:  task.SplitLongMessages,
+		SplitDelaySeconds:  task.SplitDelaySeconds,
+		Format:             task.Format,
+		MediaID:            strings.TrimSpace(task.MediaID),
+		MediaURL:           strings.TrimSpace(task.MediaURL),
+		VoiceNote:          task.VoiceNote,
+		ForwardMessageID:   strings.TrimSpace(task.ForwardMessageID),
+		Interactive:        task.Interactive,
+		Poll:               task.Poll,
+		Pin:                strings.TrimSpace(task.Pin),
+		Escalation:         task.Escalation,
+		Trigger:            task.Trigger,
+		GroupSetting:       task.GroupSetting,
+		GroupInfo:          task.GroupInfo,
+		DisappearingTimer:  strings.TrimSpace(task.DisappearingTimer),
+		SendTimeoutSeconds: task.SendTimeoutSeconds,
+		Tags:               task.Tags,
+		Footer:             strings.TrimSpace(task.Footer),
+		DisableFooter:      task.DisableFooter,
+	}
+}
+
+// resolveAnchors вычисляет StartTime и EndTime из StartAt и EndAt в часовом поясе задачи
+// относительно now и переносит начало на время every.at
+func (t *ScheduledTask) resolveAnchors(now time.Time) error {
+	if t.StartAt != "" && !t.StartTime.IsZero() {
+		return fmt.Errorf("нельзя одновременно указывать start_time и start_at")
+	}
+	if t.EndAt != "" && !t.EndTime.IsZero() {
+		return fmt.Errorf("нельзя одновременно указывать end_time и end_at")
+	}
+
+	location := t.location()
+	if t.StartAt != "" {
+		startTime, err := parseNaturalTimeFrom(t.StartAt, now.In(location))
+		if err != nil {
+			return fmt.Errorf("start_at: %v", err)
+		}
+		t.StartTime = startTime
+	}
+	t.alignStart(now)
+	if t.EndAt != "" && !t.StartTime.IsZero() {
+		endTime, err := parseNaturalTimeFrom(t.EndAt, t.StartTime.In(location))
+		if err != nil {
+			return fmt.Errorf("end_at: %v", err)
+		}
+		t.EndTime = endTime
+	}
+	return nil
+}
+
+// ParseDisappearingTimer разбирает таймер исчезающих сообщений: off, 24h, 7d, 90d.
+// WhatsApp поддерживает только эти значения, поэтому допускаются лишь их синонимы (1d, 1week, 3m)
+func ParseDisappearingTimer(value string) (time.Duration, error) {
+	const day = 24 * time.Hour
+	switch strings.ReplaceAll(strings.ToLower(value), " ", "") {
+	case "0d", "0h", "0s", "0", "off":
+		return 0, nil
+	case "1day", "day", "1d", "1", "24h", "24", "86400s", "86400":
+		return day, nil
+	case "1week", "week", "7d", "7", "168h", "168", "604800s", "604800":
+		return 7 * day, nil
+	case "3months", "3m", "3mo", "90d", "90", "2160h", "2160", "7776000s", "7776000":
+		return 90 * day, nil
+	default:
+		return 0, fmt.Errorf("неверный таймер исчезающих сообщений '%s', допустимо: off, 24h, 7d, 90d", value)
+	}
+}
+
+// media возвращает вложение задачи из медиатеки или по адресу, если оно задано
+func (t *ScheduledTask) media() *MediaAttachment {
+	switch {
+	case t.MediaID != "":
+		return &MediaAttachment{MediaID: t.MediaID, VoiceNote: t.VoiceNote}
+	case t.MediaURL != "":
+		return &MediaAttachment{URL: t.MediaURL, VoiceNote: t.VoiceNote}
+	default:
+		return nil
+	}
+}
+
+// expiration возвращает время жизни исчезающих сообщений задачи
+func (t *ScheduledTask) expiration() time.Duration {
+	timer, _ := ParseDisappearingTimer(t.DisappearingTimer)
+	return timer
+}
+
+// validateDelay проверяет настройки случайной задержки относительно интервала
+func (t *ScheduledTask) validateDelay() error {
+	if t.RandomDelay < 0 || t.JitterSeconds < 0 {
+		return fmt.Errorf("случайная задержка не может быть отрицательной")
+	}
+	if t.Interval > maxDelayMinutes || t.RandomDelay > maxDelayMinutes || t.JitterSeconds > maxDelayMinutes*60 {
+		return fmt.Errorf("интервал и случайная задержка не могут превышать %d мин", maxDelayMinutes)
+	}
+	if t.RandomDelay > 0 && t.JitterSeconds > 0 {
+		return fmt.Errorf("нельзя одновременно указывать random_delay и jitter_seconds")
+	}
+	if t.Humanize != nil {
+		if t.RandomDelay > 0 || t.JitterSeconds > 0 {
+			return fmt.Errorf("режим humanize нельзя совмещать с random_delay и jitter_seconds")
+		}
+		if err := t.Humanize.validate(); err != nil {
+			return err
+		}
+	}
+	if t.RandomDelay > t.Interval {
+		return fmt.Errorf("случайная задержка %d мин не может превышать интервал %d мин", t.RandomDelay, t.Interval)
+	}
+	// Окна соседних отправок не должны пересекаться
+	if 2*t.JitterSeconds >= t.Interval*60 {
+		return fmt.Errorf("окно разброса ±%d сек должно быть меньше половины интервала", t.JitterSeconds)
+	}
+	return nil
+}
+
+// randomOffset возвращает случайное смещение от планового времени отправки:
+// от 0 до RandomDelay минут, от -JitterSeconds до +JitterSeconds секунд или по настройкам Humanize
+func (t *ScheduledTask) randomOffset(occurrence time.Time) time.Duration {
+	switch {
+	case t.Humanize != nil:
+		return t.Humanize.offset(occurrence, time.Duration(t.Interval)*time.Minute)
+	case t.JitterSeconds > 0:
+		return time.Duration(rand.IntN(2*t.JitterSeconds+1)-t.JitterSeconds) * time.Second
+	case t.RandomDelay > 0:
+		return time.Duration(rand.IntN(int(t.maxDelay()/time.Second)+1)) * time.Second
+	default:
+		return 0
+	}
+}
+
+// maxDelay возвращает наибольшую случайную задержку RandomDelay. При задержке, равной интервалу,
+// она на секунду короче, чтобы отправка не совпала со следующим плановым временем
+func (t *ScheduledTask) maxDelay() time.Duration {
+	return min(time.Duration(t.RandomDelay)*time.Minute, time.Duration(t.Interval)*time.Minute-time.Second)
+}
+
+// sendWindow возвращает промежуток, в который попадет фактическая отправка планового времени
+func (t *ScheduledTask) sendWindow(occurrence time.Time) (time.Time, time.Time) {
+	if t.Humanize != nil {
+		limit := time.Duration(t.Interval)*time.Minute/2 - time.Second
+		return occurrence.Add(-limit), occurrence.Add(limit)
+	}
+	if t.JitterSeconds > 0 {
+		jitter := time.Duration(t.JitterSeconds) * time.Second
+		return occurrence.Add(-jitter), occurrence.Add(jitter)
+	}
+	if t.RandomDelay > 0 {
+		return occurrence, occurrence.Add(t.maxDelay())
+	}
+	return occurrence, occurrence
+}
+
+// nextOccurrence возвращает первое плановое время отправки (без случайной задержки) строго после after,
+// пропуская исключенные даты
+func (t *ScheduledTask) nextOccurrence(after time.Time) time.Time {
+	next := t.plannedOccurrence(after)
+	// Не ищем дальше времени окончания, чтобы не зациклиться на длинных исключениях
+	for !next.After(t.EndTime) && t.isExcluded(next) {
+		next = t.plannedOccurrence(next)
+	}
+	return next
+}
+
+// plannedOccurrence возвращает первое время по сетке интервала строго после after.
+// Следующее время отправки = startTime + (intervalsPassed + 1) * interval,
+// для календарных интервалов - по часам (calendarOccurrence), для RRule - по правилу повторения
+func (t *ScheduledTask) plannedOccurrence(after time.Time) time.Time {
+	if t.RRule != "" {
+		// Время начала - отсчет правила, но не обязательно одна из отправок
+		return t.rruleOccurrence(after)
+	}
+	if after.Before(t.StartTime) {
+		return t.StartTime
+	}
+	if calendar := t.calendar(); calendar != nil {
+		return t.calendarOccurrence(calendar, after)
+	}
+
+	intervalDuration := time.Duration(t.Interval) * time.Minute
+	intervalsPassed := int(after.Sub(t.StartTime) / intervalDuration)
+	return t.StartTime.Add(time.Duration(intervalsPassed+1) * intervalDuration)
+}
+
+// occurrencesBetween возвращает плановые времена отправки задачи в промежутке [from, to], не более limit штук
+func (t *ScheduledTask) occurrencesBetween(from, to time.Time, limit int) []time.Time {
+	occurrences := []time.Time{}
+	if t.Interval <= 0 {
+		return occurrences
+	}
+	if t.EndTime.Before(to) {
+		to = t.EndTime
+	}
+
+	for next := t.nextOccurrence(from.Add(-time.Nanosecond)); !next.After(to) && len(occurrences) < limit; next = t.nextOccurrence(next) {
+		occurrences = append(occurrences, next)
+	}
+	return occurrences
+}
+
+// ResolveMessage возвращает текст для отправки: вывод MessageCommand, если она задана, иначе Message,
+// преобразованный согласно формату задачи
+func (t *ScheduledTask) ResolveMessage(ctx context.Context) (string, error) {
+	if t.MessageCommand == "" {
+		return ApplyFormat(t.Message, t.Format), nil
+	}
+	if !messageCommandsAllowed() {
+		return "", fmt.Errorf("выполнение команд отключено, установите %s=1 для включения", allowCommandsEnv)
+	}
+	message, err := runMessageCommand(ctx, t.MessageCommand)
+	if err != nil {
+		return "", err
+	}
+	return ApplyFormat(message, t.Format), nil
+}
+
+// messageCommandsAllowed проверяет, разрешено ли выполнение команд для генерации сообщений
+func messageCommandsAllowed() bool {
+	return os.Getenv(allowCommandsEnv) == "1"
+}
+
+// runMessageCommand выполняет команду (без shell) и возвращает её stdout
+func runMessageCommand(ctx context.Context, command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", fmt.Errorf("пустая команда")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, messageCommandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("ошибка выполнения команды '%s': %v %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	message := strings.TrimSpace(stdout.String())
+	if message == "" {
+		return "", fmt.Errorf("команда '%s' вернула пустой вывод", command)
+	}
+	return message, nil
+}
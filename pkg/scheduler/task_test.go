@@ -0,0 +1,373 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewTaskFromRequest(t *testing.T) {
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		request ScheduledTask
+		want    ScheduledTask
+	}{
+		{
+			name: "trims text fields",
+			request: ScheduledTask{
+				ChatName: "  Team  ",
+				Message:  "\n Hello \t",
+				StartAt:  " tomorrow 9am ",
+				Timezone: " Europe/Berlin ",
+				MediaID:  " media_1 ",
+				Footer:   " — bot ",
+			},
+			want: ScheduledTask{
+				ChatName: "Team",
+				Message:  "Hello",
+				StartAt:  "tomorrow 9am",
+				Timezone: "Europe/Berlin",
+				MediaID:  "media_1",
+				Footer:   "— bot",
+			},
+		},
+		{
+			name: "keeps schedule and delay",
+			request: ScheduledTask{
+				ChatName:    "Team",
+				Message:     "Hello",
+				Interval:    60,
+				RandomDelay: 5,
+				StartTime:   start,
+				EndTime:     start.Add(24 * time.Hour),
+			},
+			want: ScheduledTask{
+				ChatName:    "Team",
+				Message:     "Hello",
+				Interval:    60,
+				RandomDelay: 5,
+				StartTime:   start,
+				EndTime:     start.Add(24 * time.Hour),
+			},
+		},
+		{
+			name: "drops server-side state",
+			request: ScheduledTask{
+				ID:             "task_1",
+				ChatName:       "Team",
+				Message:        "Hello",
+				Paused:         true,
+				NeedsAttention: "chat_not_found",
+			},
+			want: ScheduledTask{ChatName: "Team", Message: "Hello"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewTaskFromRequest(&tt.request)
+			if got.ID != tt.want.ID || got.Paused != tt.want.Paused || got.NeedsAttention != tt.want.NeedsAttention {
+				t.Errorf("state copied: ID %q, Paused %v, NeedsAttention %q", got.ID, got.Paused, got.NeedsAttention)
+			}
+			if got.ChatName != tt.want.ChatName || got.Message != tt.want.Message || got.StartAt != tt.want.StartAt ||
+				got.Timezone != tt.want.Timezone || got.MediaID != tt.want.MediaID || got.Footer != tt.want.Footer {
+				t.Errorf("got %q %q %q %q %q %q", got.ChatName, got.Message, got.StartAt, got.Timezone, got.MediaID, got.Footer)
+			}
+			if got.Interval != tt.want.Interval || got.RandomDelay != tt.want.RandomDelay ||
+				!got.StartTime.Equal(tt.want.StartTime) || !got.EndTime.Equal(tt.want.EndTime) {
+				t.Errorf("schedule: interval %d, delay %d, %v - %v", got.Interval, got.RandomDelay, got.StartTime, got.EndTime)
+			}
+		})
+	}
+}
+
+func TestValidateDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		task    ScheduledTask
+		wantErr bool
+	}{
+		{name: "no delay", task: ScheduledTask{Interval: 60}},
+		{name: "random delay below interval", task: ScheduledTask{Interval: 60, RandomDelay: 30}},
+		{name: "random delay equal to interval", task: ScheduledTask{Interval: 60, RandomDelay: 60}},
+		{name: "random delay above interval", task: ScheduledTask{Interval: 60, RandomDelay: 61}, wantErr: true},
+		{name: "negative random delay", task: ScheduledTask{Interval: 60, RandomDelay: -1}, wantErr: true},
+		{name: "jitter below half interval", task: ScheduledTask{Interval: 60, JitterSeconds: 1799}},
+		{name: "jitter equal to half interval", task: ScheduledTask{Interval: 60, JitterSeconds: 1800}, wantErr: true},
+		{name: "negative jitter", task: ScheduledTask{Interval: 60, JitterSeconds: -1}, wantErr: true},
+		{name: "random delay and jitter", task: ScheduledTask{Interval: 60, RandomDelay: 5, JitterSeconds: 10}, wantErr: true},
+		{
+			name: "humanize",
+			task: ScheduledTask{Interval: 60, Humanize: &HumanizeOptions{Distribution: humanizeGaussian, SpreadSeconds: 60}},
+		},
+		{
+			name:    "humanize and jitter",
+			task:    ScheduledTask{Interval: 60, JitterSeconds: 10, Humanize: &HumanizeOptions{Distribution: humanizeGaussian, SpreadSeconds: 60}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.task.validateDelay()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateDelay() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRandomOffsetBounds(t *testing.T) {
+	occurrence := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		task     ScheduledTask
+		min, max time.Duration
+	}{
+		{name: "no delay", task: ScheduledTask{Interval: 60}},
+		{name: "random delay", task: ScheduledTask{Interval: 60, RandomDelay: 5}, max: 5 * time.Minute},
+		{
+			name: "random delay equal to interval",
+			task: ScheduledTask{Interval: 60, RandomDelay: 60},
+			max:  time.Hour - time.Second,
+		},
+		{name: "jitter", task: ScheduledTask{Interval: 60, JitterSeconds: 30}, min: -30 * time.Second, max: 30 * time.Second},
+		{
+			name: "humanize",
+			task: ScheduledTask{Interval: 10, Humanize: &HumanizeOptions{Distribution: humanizeGaussian, SpreadSeconds: 3600}},
+			min:  -5*time.Minute + time.Second,
+			max:  5*time.Minute - time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.task.validateDelay(); err != nil {
+				t.Fatalf("validateDelay: %v", err)
+			}
+			for i := 0; i < 1000; i++ {
+				offset := tt.task.randomOffset(occurrence)
+				if offset < tt.min || offset > tt.max {
+					t.Fatalf("randomOffset() = %v, want within [%v, %v]", offset, tt.min, tt.max)
+				}
+				from, to := tt.task.sendWindow(occurrence)
+				if at := occurrence.Add(offset); at.Before(from) || at.After(to) {
+					t.Fatalf("send at %v outside sendWindow [%v, %v]", at, from, to)
+				}
+			}
+		})
+	}
+}
+
+func TestPlannedOccurrence(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	start := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		task  ScheduledTask
+		after time.Time
+		want  time.Time
+	}{
+		{
+			name:  "before start",
+			task:  ScheduledTask{Interval: 60, StartTime: start},
+			after: start.Add(-time.Hour),
+			want:  start,
+		},
+		{
+			name:  "at start",
+			task:  ScheduledTask{Interval: 60, StartTime: start},
+			after: start,
+			want:  start.Add(time.Hour),
+		},
+		{
+			name:  "between occurrences",
+			task:  ScheduledTask{Interval: 90, StartTime: start},
+			after: start.Add(100 * time.Minute),
+			want:  start.Add(180 * time.Minute),
+		},
+		{
+			name:  "exactly on an occurrence",
+			task:  ScheduledTask{Interval: 90, StartTime: start},
+			after: start.Add(180 * time.Minute),
+			want:  start.Add(270 * time.Minute),
+		},
+		{
+			name:  "whole days keep the wall clock",
+			task:  ScheduledTask{Interval: minutesPerDay, StartTime: time.Date(2025, 3, 29, 9, 0, 0, 0, berlin), Timezone: "Europe/Berlin"},
+			after: time.Date(2025, 3, 29, 9, 0, 0, 0, berlin),
+			want:  time.Date(2025, 3, 30, 9, 0, 0, 0, berlin),
+		},
+		{
+			name: "every week",
+			task: ScheduledTask{
+				Every:     &CalendarInterval{Unit: UnitWeek, Count: 1},
+				StartTime: time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC),
+				Timezone:  "UTC",
+			},
+			after: time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2025, 6, 9, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "monthly on the 31st falls on the last day",
+			task: ScheduledTask{
+				Every:     &CalendarInterval{Unit: UnitMonth, Count: 1},
+				StartTime: time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC),
+				Timezone:  "UTC",
+			},
+			after: time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC),
+			want:  time.Date(2025, 2, 28, 9, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.task.plannedOccurrence(tt.after); !got.Equal(tt.want) {
+				t.Errorf("plannedOccurrence(%v) = %v, want %v", tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRandomOffsetProperty(t *testing.T) {
+	occurrence := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	intervals := []int{1, 2, 5, 60, 61, minutesPerDay, 7 * minutesPerDay}
+
+	for _, interval := range intervals {
+		// Случайная задержка: от 0 до интервала включительно, отправка в [0, delay]
+		for _, delay := range []int{0, 1, interval / 2, interval - 1, interval} {
+			task := ScheduledTask{Interval: interval, RandomDelay: delay}
+			if err := task.validateDelay(); err != nil {
+				t.Fatalf("interval %d, random_delay %d: %v", interval, delay, err)
+			}
+			limit := min(time.Duration(delay)*time.Minute, time.Duration(interval)*time.Minute-time.Second)
+			for i := 0; i < 200; i++ {
+				if offset := task.randomOffset(occurrence); offset < 0 || offset > limit {
+					t.Fatalf("interval %d, random_delay %d: offset %v outside [0, %v]", interval, delay, offset, limit)
+				}
+			}
+		}
+
+		// Разброс: от 0 до половины интервала без нее, отправка в [-jitter, +jitter]
+		for _, jitter := range []int{0, 1, interval*30 - 1} {
+			task := ScheduledTask{Interval: interval, JitterSeconds: jitter}
+			if err := task.validateDelay(); err != nil {
+				t.Fatalf("interval %d, jitter_seconds %d: %v", interval, jitter, err)
+			}
+			limit := time.Duration(jitter) * time.Second
+			for i := 0; i < 200; i++ {
+				if offset := task.randomOffset(occurrence); offset < -limit || offset > limit {
+					t.Fatalf("interval %d, jitter_seconds %d: offset %v outside [%v, %v]", interval, jitter, offset, -limit, limit)
+				}
+			}
+		}
+	}
+}
+
+func TestRandomOffsetBoundaries(t *testing.T) {
+	occurrence := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+
+	t.Run("zero delay", func(t *testing.T) {
+		task := ScheduledTask{Interval: 60}
+		for i := 0; i < 100; i++ {
+			if offset := task.randomOffset(occurrence); offset != 0 {
+				t.Fatalf("randomOffset() = %v, want 0", offset)
+			}
+		}
+	})
+
+	t.Run("maximum delay never reaches the next occurrence", func(t *testing.T) {
+		task := ScheduledTask{Interval: 1, RandomDelay: 1}
+		seen := map[time.Duration]bool{}
+		for i := 0; i < 5000; i++ {
+			offset := task.randomOffset(occurrence)
+			if offset < 0 || offset >= time.Minute {
+				t.Fatalf("randomOffset() = %v, want within [0, 1m)", offset)
+			}
+			seen[offset] = true
+		}
+		if !seen[0] || !seen[time.Minute-time.Second] {
+			t.Errorf("offsets 0 and 59s not both drawn in 5000 tries: %d distinct", len(seen))
+		}
+	})
+
+	t.Run("maximum jitter", func(t *testing.T) {
+		task := ScheduledTask{Interval: 1, JitterSeconds: 29}
+		seen := map[time.Duration]bool{}
+		for i := 0; i < 5000; i++ {
+			offset := task.randomOffset(occurrence)
+			if offset < -29*time.Second || offset > 29*time.Second {
+				t.Fatalf("randomOffset() = %v, want within [-29s, 29s]", offset)
+			}
+			seen[offset] = true
+		}
+		if !seen[-29*time.Second] || !seen[29*time.Second] {
+			t.Errorf("offsets -29s and 29s not both drawn in 5000 tries: %d distinct", len(seen))
+		}
+	})
+}
+
+func TestValidateDelayRejectsOverflow(t *testing.T) {
+	tests := []struct {
+		name string
+		task ScheduledTask
+	}{
+		{name: "interval", task: ScheduledTask{Interval: math.MaxInt}},
+		{name: "interval and random delay", task: ScheduledTask{Interval: math.MaxInt, RandomDelay: math.MaxInt}},
+		{name: "interval above limit", task: ScheduledTask{Interval: maxDelayMinutes + 1, RandomDelay: 1}},
+		{name: "jitter doubling overflows", task: ScheduledTask{Interval: 60, JitterSeconds: math.MaxInt/2 + 1}},
+		{name: "jitter", task: ScheduledTask{Interval: maxDelayMinutes, JitterSeconds: math.MaxInt}},
+		{
+			name: "humanize pause",
+			task: ScheduledTask{Interval: 60, Humanize: &HumanizeOptions{Distribution: humanizeGaussian, SpreadSeconds: 60, PauseMinutes: math.MaxInt}},
+		},
+		{
+			name: "humanize spread",
+			task: ScheduledTask{Interval: 60, Humanize: &HumanizeOptions{Distribution: humanizeGaussian, SpreadSeconds: math.MaxInt}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.task.validateDelay(); err == nil {
+				t.Errorf("validateDelay() accepted %+v", tt.task)
+			}
+		})
+	}
+
+	// Предел принимается, и смещение по нему считается без переполнения
+	task := ScheduledTask{Interval: maxDelayMinutes, RandomDelay: maxDelayMinutes}
+	if err := task.validateDelay(); err != nil {
+		t.Fatalf("validateDelay() at the limit: %v", err)
+	}
+	if offset := task.randomOffset(time.Now()); offset < 0 || offset >= time.Duration(maxDelayMinutes)*time.Minute {
+		t.Errorf("randomOffset() at the limit = %v", offset)
+	}
+}
+
+func TestParseDisappearingTimer(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"off", 0, false},
+		{"0", 0, false},
+		{"24h", 24 * time.Hour, false},
+		{"1 Day", 24 * time.Hour, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"1week", 7 * 24 * time.Hour, false},
+		{"90d", 90 * 24 * time.Hour, false},
+		{"3m", 90 * 24 * time.Hour, false},
+		{"", 0, true},
+		{"48h", 0, true},
+		{"30d", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDisappearingTimer(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseDisappearingTimer(%q) = %v, %v; want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
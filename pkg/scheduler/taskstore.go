@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// storedTask - задача в хранилище. Отдельный тип нужен, чтобы при загрузке не применялся
+// UnmarshalJSON запроса API с фиксированным форматом времени
+type storedTask ScheduledTask
+
+// taskProgress - сохраненное состояние цикла отправок задачи
+type taskProgress struct {
+	// pendingOccurrence и pendingSendAt - плановое и фактическое (со случайной задержкой) время
+	// отправки, ожидание которой шло в момент остановки процесса
+	pendingOccurrence time.Time
+	pendingSendAt     time.Time
+	// lastOccurrence - плановое время последней обработанной отправки
+	lastOccurrence time.Time
+}
+
+// SaveTask сохраняет активную задачу, чтобы возобновить ее после перезапуска
+func (st *Storage) SaveTask(task *ScheduledTask) error {
+	data, err := json.Marshal((*storedTask)(task))
+	if err != nil {
+		return err
+	}
+	sealed, err := st.EncryptField(string(data))
+	if err != nil {
+		return err
+	}
+	_, err = st.db.Exec(`INSERT INTO tasks (id, data) VALUES (?, ?)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data`, task.ID, sealed)
+	return err
+}
+
+// DeleteTask удаляет задачу вместе с состоянием ожидания отправки и прогрессом рассылки
+func (st *Storage) DeleteTask(id string) error {
+	if _, err := st.db.Exec(`DELETE FROM task_deliveries WHERE task_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := st.db.Exec(`DELETE FROM task_pins WHERE task_id = ?`, id); err != nil {
+		return err
+	}
+	_, err := st.db.Exec(`DELETE FROM tasks WHERE id = ?`, id)
+	return err
+}
+
+// taskIDs возвращает ID сохраненных задач
+func (st *Storage) taskIDs() ([]string, error) {
+	rows, err := st.db.Query(`SELECT id FROM tasks`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// setTaskPending запоминает, что задача ждет отправку planned со случайной задержкой до sendAt
+func (st *Storage) setTaskPending(id string, planned, sendAt time.Time) error {
+	_, err := st.db.Exec(`UPDATE tasks SET pending_occurrence = ?, pending_send_at = ? WHERE id = ?`,
+		planned.UTC(), sendAt.UTC(), id)
+	return err
+}
+
+// completeTaskOccurrence отмечает отправку planned обработанной (отправленной или пропущенной)
+func (st *Storage) completeTaskOccurrence(id string, planned time.Time) error {
+	if _, err := st.db.Exec(`DELETE FROM task_deliveries WHERE task_id = ?`, id); err != nil {
+		return err
+	}
+	_, err := st.db.Exec(`UPDATE tasks SET pending_occurrence = NULL, pending_send_at = NULL, last_occurrence = ?
+		WHERE id = ?`, planned.UTC(), id)
+	return err
+}
+
+// loadTasks загружает сохраненные задачи и состояние их отправок
+func (st *Storage) loadTasks() ([]*ScheduledTask, []taskProgress, error) {
+	rows, err := st.db.Query(`SELECT data, pending_occurrence, pending_send_at, last_occurrence FROM tasks ORDER BY id`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var tasks []*ScheduledTask
+	var progress []taskProgress
+	for rows.Next() {
+		var data string
+		var pendingOccurrence, pendingSendAt, lastOccurrence sql.NullTime
+		if err := rows.Scan(&data, &pendingOccurrence, &pendingSendAt, &lastOccurrence); err != nil {
+			return nil, nil, err
+		}
+		if err := st.decryptFields(&data); err != nil {
+			return nil, nil, err
+		}
+		task := &ScheduledTask{}
+		if err := json.Unmarshal([]byte(data), (*storedTask)(task)); err != nil {
+			return nil, nil, err
+		}
+		tasks = append(tasks, task)
+		progress = append(progress, taskProgress{
+			pendingOccurrence: pendingOccurrence.Time,
+			pendingSendAt:     pendingSendAt.Time,
+			lastOccurrence:    lastOccurrence.Time,
+		})
+	}
+	return tasks, progress, rows.Err()
+}
+
+// resumePoint возвращает плановое и фактическое время первой отправки задачи после перезапуска.
+// Если процесс остановился во время случайной задержки, ожидание продолжается до того же момента,
+// а не разыгрывается заново; пропущенная за время простоя отправка выполняется сразу,
+// если еще не наступило следующее плановое время
+func (p taskProgress) resumePoint(task *ScheduledTask, now time.Time) (time.Time, time.Time, bool) {
+	if p.pendingOccurrence.IsZero() {
+		return time.Time{}, time.Time{}, false
+	}
+	if p.pendingSendAt.After(now) {
+		return p.pendingOccurrence, p.pendingSendAt, true
+	}
+	if task.nextOccurrence(p.pendingOccurrence).After(now) {
+		return p.pendingOccurrence, now, true
+	}
+	return time.Time{}, time.Time{}, false
+}
@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // декодеры форматов миниатюр
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"strings"
+)
+
+// Размер большей стороны миниатюры файла медиатеки
+const (
+	DefaultThumbnailSize = 320
+	MaxThumbnailSize     = 640
+)
+
+// ErrNoThumbnail - у файла медиатеки нет миниатюры: это не изображение и не перекодированное видео
+// или его не удалось прочитать
+var ErrNoThumbnail = errors.New("миниатюра доступна только для изображений и перекодированных видео")
+
+// MediaThumbnail возвращает JPEG миниатюру изображения медиатеки, большая сторона которой
+// не превышает size. Для перекодированного видео миниатюра строится по его кадру.
+// Полноразмерный файл наружу не отдается
+func (st *Storage) MediaThumbnail(id string, size int) ([]byte, error) {
+	asset, err := st.GetMedia(id)
+	if err != nil {
+		return nil, err
+	}
+	path := asset.Path()
+	switch {
+	case strings.HasPrefix(asset.MimeType, "image/"):
+	case strings.HasPrefix(asset.MimeType, "video/"):
+		path = asset.thumbnailPath()
+	default:
+		return nil, ErrNoThumbnail
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && path != asset.Path() {
+		return nil, ErrNoThumbnail
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла медиатеки: %v", err)
+	}
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoThumbnail, err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, DownscaleImage(source, size), &jpeg.Options{Quality: 75}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DownscaleImage уменьшает изображение (ближайший сосед) так, чтобы большая сторона не превышала maxSize
+func DownscaleImage(source image.Image, maxSize int) image.Image {
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return source
+	}
+
+	scale := float64(maxSize) / float64(max(width, height))
+	newWidth, newHeight := max(1, int(float64(width)*scale)), max(1, int(float64(height)*scale))
+	result := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		for x := 0; x < newWidth; x++ {
+			result.Set(x, y, source.At(bounds.Min.X+int(float64(x)/scale), bounds.Min.Y+int(float64(y)/scale)))
+		}
+	}
+	return result
+}
@@ -0,0 +1,259 @@
+package scheduler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ffmpegPathEnv - переменная окружения с путем к ffmpeg, пусто - видео не перекодируются
+	ffmpegPathEnv = "WHATSAPP_SCHEDULER_FFMPEG_PATH"
+	// MaxVideoSize - максимальный размер видео, которое WhatsApp принимает как видеосообщение
+	MaxVideoSize = 16 << 20
+	// maxVideoDimension - большая сторона перекодированного видео
+	maxVideoDimension = 1280
+	// transcodeAudioBitrate - битрейт звука перекодированного видео, кбит/с
+	transcodeAudioBitrate = 128
+	// maxVideoBitrate - битрейт видео коротких роликов, которым лимит размера не мешает, кбит/с
+	maxVideoBitrate = 2500
+	// transcodeTimeout - максимальное время перекодирования одного видео
+	transcodeTimeout = 30 * time.Minute
+	// videoThumbnailSize - ширина кадра-миниатюры видео
+	videoThumbnailSize = 480
+)
+
+// Статусы перекодирования видео
+const (
+	TranscodeStatusQueued  = "queued"
+	TranscodeStatusRunning = "running"
+	TranscodeStatusDone    = "done"
+	TranscodeStatusFailed  = "failed"
+)
+
+// ErrTranscodeNotFound - видео медиатеки не перекодировалось после запуска приложения
+var ErrTranscodeNotFound = errors.New("перекодирование не найдено")
+
+// TranscodeJob - перекодирование видео медиатеки в MP4 (H.264/AAC)
+type TranscodeJob struct {
+	// SourceID - исходное видео медиатеки
+	SourceID string `json:"source_id"`
+	Status   string `json:"status"`
+	// Progress - выполненная часть, 0..100
+	Progress int `json:"progress"`
+	// MediaID - перекодированное видео медиатеки, заполняется по завершении
+	MediaID   string    `json:"media_id,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// transcodeJobs - перекодирования, запущенные после старта приложения. Видео перекодируются по одному
+type transcodeJobs struct {
+	mutex   sync.Mutex
+	jobs    map[string]*TranscodeJob
+	running sync.Mutex
+}
+
+// ffmpegDurationPattern - длительность исходного файла в выводе ffmpeg
+var ffmpegDurationPattern = regexp.MustCompile(`Duration: (\d+):(\d+):(\d+(?:\.\d+)?)`)
+
+// ffmpegPath возвращает путь к ffmpeg или пустую строку, если перекодирование отключено
+func ffmpegPath() string {
+	return strings.TrimSpace(os.Getenv(ffmpegPathEnv))
+}
+
+// TranscodingEnabled проверяет, что задан путь к ffmpeg и видео перекодируются при загрузке
+func TranscodingEnabled() bool {
+	return ffmpegPath() != ""
+}
+
+// thumbnailPath возвращает путь к кадру-миниатюре видео, созданному при перекодировании
+func (a *MediaAsset) thumbnailPath() string {
+	return a.Path() + ".jpg"
+}
+
+// StartTranscode ставит видео медиатеки в очередь перекодирования в MP4 (H.264/AAC) размером
+// до MaxVideoSize. Результат - новый файл медиатеки с кадром-миниатюрой, см. TranscodeStatus
+func (s *Scheduler) StartTranscode(asset *MediaAsset) (TranscodeJob, error) {
+	if !TranscodingEnabled() {
+		return TranscodeJob{}, fmt.Errorf("перекодирование видео отключено, укажите путь к ffmpeg в %s", ffmpegPathEnv)
+	}
+	if !strings.HasPrefix(asset.MimeType, "video/") {
+		return TranscodeJob{}, fmt.Errorf("перекодировать можно только видео, а не %s", asset.MimeType)
+	}
+
+	s.transcodes.mutex.Lock()
+	if s.transcodes.jobs == nil {
+		s.transcodes.jobs = map[string]*TranscodeJob{}
+	}
+	if job, ok := s.transcodes.jobs[asset.ID]; ok && job.Status != TranscodeStatusFailed {
+		s.transcodes.mutex.Unlock()
+		return *job, nil
+	}
+	now := time.Now()
+	job := &TranscodeJob{SourceID: asset.ID, Status: TranscodeStatusQueued, CreatedAt: now, UpdatedAt: now}
+	s.transcodes.jobs[asset.ID] = job
+	result := *job
+	s.transcodes.mutex.Unlock()
+
+	go s.runTranscode(asset)
+	return result, nil
+}
+
+// TranscodeStatus возвращает состояние перекодирования видео медиатеки
+func (s *Scheduler) TranscodeStatus(mediaID string) (TranscodeJob, error) {
+	s.transcodes.mutex.Lock()
+	defer s.transcodes.mutex.Unlock()
+	job, ok := s.transcodes.jobs[mediaID]
+	if !ok {
+		return TranscodeJob{}, fmt.Errorf("%w: '%s'", ErrTranscodeNotFound, mediaID)
+	}
+	return *job, nil
+}
+
+// updateTranscode изменяет состояние перекодирования
+func (s *Scheduler) updateTranscode(sourceID string, update func(job *TranscodeJob)) {
+	s.transcodes.mutex.Lock()
+	defer s.transcodes.mutex.Unlock()
+	if job, ok := s.transcodes.jobs[sourceID]; ok {
+		update(job)
+		job.UpdatedAt = time.Now()
+	}
+}
+
+// runTranscode перекодирует видео и добавляет результат в медиатеку
+func (s *Scheduler) runTranscode(asset *MediaAsset) {
+	s.transcodes.running.Lock()
+	defer s.transcodes.running.Unlock()
+
+	s.updateTranscode(asset.ID, func(job *TranscodeJob) { job.Status = TranscodeStatusRunning })
+	Logger.Infof("🎬 Перекодирование видео %s (%s)", asset.ID, asset.FileName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), transcodeTimeout)
+	defer cancel()
+	result, err := s.transcode(ctx, asset, func(progress int) {
+		s.updateTranscode(asset.ID, func(job *TranscodeJob) { job.Progress = progress })
+	})
+	if err != nil {
+		Logger.Warnf("⚠️ Ошибка перекодирования видео %s: %v", asset.ID, err)
+		s.updateTranscode(asset.ID, func(job *TranscodeJob) {
+			job.Status, job.Error = TranscodeStatusFailed, err.Error()
+		})
+		return
+	}
+	Logger.Infof("🎬 Видео %s перекодировано: %s, %d байт", asset.ID, result.ID, result.Size)
+	s.updateTranscode(asset.ID, func(job *TranscodeJob) {
+		job.Status, job.Progress, job.MediaID = TranscodeStatusDone, 100, result.ID
+	})
+}
+
+// transcode запускает ffmpeg: видео H.264 (baseline, yuv420p) и звук AAC в MP4 с битрейтом,
+// рассчитанным по длительности так, чтобы файл уместился в MaxVideoSize
+func (s *Scheduler) transcode(ctx context.Context, asset *MediaAsset, onProgress func(int)) (*MediaAsset, error) {
+	dir, err := os.MkdirTemp("", "whatsapp-scheduler-transcode-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "video.mp4")
+	thumbnail := filepath.Join(dir, "thumbnail.jpg")
+
+	duration, err := probeDuration(ctx, asset.Path())
+	if err != nil {
+		return nil, err
+	}
+	// 10% запаса на контейнер и неточность кодировщика
+	videoBitrate := int(float64(MaxVideoSize*8)*0.9/duration.Seconds()/1000) - transcodeAudioBitrate
+	if videoBitrate < 100 {
+		return nil, fmt.Errorf("видео длительностью %s не уместится в %d МБ", duration.Round(time.Second), MaxVideoSize>>20)
+	}
+	videoBitrate = min(videoBitrate, maxVideoBitrate)
+
+	scale := fmt.Sprintf("scale='if(gt(iw,ih),min(%[1]d,iw),-2)':'if(gt(iw,ih),-2,min(%[1]d,ih))'", maxVideoDimension)
+	cmd := exec.CommandContext(ctx, ffmpegPath(), "-y", "-nostdin", "-i", asset.Path(),
+		"-vf", scale, "-c:v", "libx264", "-preset", "veryfast", "-profile:v", "baseline", "-pix_fmt", "yuv420p",
+		"-b:v", fmt.Sprintf("%dk", videoBitrate), "-maxrate", fmt.Sprintf("%dk", videoBitrate),
+		"-bufsize", fmt.Sprintf("%dk", videoBitrate*2),
+		"-c:a", "aac", "-b:a", fmt.Sprintf("%dk", transcodeAudioBitrate),
+		"-movflags", "+faststart", "-progress", "pipe:1", "-nostats", "-loglevel", "error", output)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ошибка запуска ffmpeg: %v", err)
+	}
+	// Строки out_time_us=... сообщают, сколько видео уже обработано
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "out_time_us=")
+		if !ok {
+			continue
+		}
+		if processed, err := strconv.ParseInt(value, 10, 64); err == nil && processed > 0 {
+			onProgress(min(99, int(time.Duration(processed)*time.Microsecond*100/duration)))
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ошибка ffmpeg: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxVideoSize {
+		return nil, fmt.Errorf("перекодированное видео больше %d МБ", MaxVideoSize>>20)
+	}
+
+	thumbnailCmd := exec.CommandContext(ctx, ffmpegPath(), "-y", "-nostdin", "-i", output,
+		"-vf", fmt.Sprintf("thumbnail,scale=%d:-2", videoThumbnailSize), "-frames:v", "1", "-loglevel", "error", thumbnail)
+	if out, err := thumbnailCmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("ошибка создания миниатюры: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	thumbnailData, err := os.ReadFile(thumbnail)
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := strings.TrimSuffix(asset.FileName, filepath.Ext(asset.FileName)) + ".mp4"
+	result, _, err := s.storage.AddMedia(data, fileName, "video/mp4")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(result.thumbnailPath(), thumbnailData, 0o644); err != nil {
+		return nil, fmt.Errorf("ошибка сохранения миниатюры: %v", err)
+	}
+	return result, nil
+}
+
+// probeDuration определяет длительность видео по выводу ffmpeg
+func probeDuration(ctx context.Context, path string) (time.Duration, error) {
+	// Без выходного файла ffmpeg завершается с ошибкой, но успевает вывести сведения о файле
+	out, _ := exec.CommandContext(ctx, ffmpegPath(), "-nostdin", "-hide_banner", "-i", path).CombinedOutput()
+	match := ffmpegDurationPattern.FindSubmatch(out)
+	if match == nil {
+		return 0, fmt.Errorf("не удалось определить длительность видео: %s", strings.TrimSpace(string(out)))
+	}
+	hours, _ := strconv.Atoi(string(match[1]))
+	minutes, _ := strconv.Atoi(string(match[2]))
+	seconds, _ := strconv.ParseFloat(string(match[3]), 64)
+	duration := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second))
+	if duration <= 0 {
+		return 0, fmt.Errorf("видео нулевой длительности")
+	}
+	return duration, nil
+}
@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TaskTrigger - фразы, включающие и выключающие отправки задачи из ее чата. Задача с триггером
+// добавляется выключенной (приостановленной) и начинает отправки, когда в чат приходит фраза Start
+type TaskTrigger struct {
+	Start string `json:"start"`
+	// Stop - фраза, снова выключающая задачу, пусто - задачу выключают только через API
+	Stop string `json:"stop,omitempty"`
+}
+
+// validate проверяет фразы триггера и приводит их к нижнему регистру
+func (t *TaskTrigger) validate() error {
+	t.Start = strings.ToLower(strings.TrimSpace(t.Start))
+	t.Stop = strings.ToLower(strings.TrimSpace(t.Stop))
+	if t.Start == "" {
+		return fmt.Errorf("пустая фраза trigger.start")
+	}
+	if t.Start == t.Stop {
+		return fmt.Errorf("фразы trigger.start и trigger.stop должны различаться")
+	}
+	return nil
+}
+
+// handleTriggers включает или выключает задачи, чат которых получил фразу триггера.
+// Фраза должна совпадать с сообщением целиком, без учета регистра
+func (s *Scheduler) handleTriggers(msg IncomingMessage) {
+	text := strings.ToLower(strings.TrimSpace(msg.Text))
+	if text == "" || msg.ChatJID == "" {
+		return
+	}
+
+	changes := map[string]bool{}
+	s.mutex.RLock()
+	for id, task := range s.tasks {
+		if task.Trigger == nil || task.ChatJID != msg.ChatJID {
+			continue
+		}
+		switch {
+		case text == task.Trigger.Start && task.Paused:
+			changes[id] = false
+		case text == task.Trigger.Stop && !task.Paused:
+			changes[id] = true
+		}
+	}
+	s.mutex.RUnlock()
+
+	for id, paused := range changes {
+		if !s.PauseTask(id, paused) {
+			continue
+		}
+		if paused {
+			Logger.Infof("🔕 Задача %s выключена фразой '%s' из чата %s", id, text, msg.ChatJID)
+		} else {
+			Logger.Infof("🔔 Задача %s включена фразой '%s' из чата %s", id, text, msg.ChatJID)
+		}
+	}
+}
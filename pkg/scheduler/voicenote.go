@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// voiceNoteWaveformLength - количество столбцов волны голосового сообщения в клиентах WhatsApp
+	voiceNoteWaveformLength = 64
+	// voiceNoteSampleRate - частота дискретизации при построении волны
+	voiceNoteSampleRate = 8000
+	// voiceNoteLoudness - фильтр нормализации громкости (EBU R128), как у записей с телефона
+	voiceNoteLoudness = "loudnorm=I=-16:TP=-1.5:LRA=11"
+	// voiceNoteTimeout - максимальное время обработки голосового сообщения
+	voiceNoteTimeout = 5 * time.Minute
+	// VoiceNoteMimeType - формат голосовых сообщений WhatsApp
+	VoiceNoteMimeType = "audio/ogg; codecs=opus"
+)
+
+// VoiceNote - голосовое сообщение, подготовленное к отправке
+type VoiceNote struct {
+	// Data - звук в OGG/Opus
+	Data []byte
+	// Seconds - длительность, которую клиенты показывают до загрузки звука
+	Seconds uint32
+	// Waveform - громкость по 64 отрезкам записи, 0..100
+	Waveform []byte
+}
+
+// PrepareVoiceNote перекодирует звук в моно OGG/Opus с нормализованной громкостью и вычисляет
+// длительность и волну, которые клиенты WhatsApp показывают у записанных голосовых сообщений.
+// Требует ffmpeg (WHATSAPP_SCHEDULER_FFMPEG_PATH)
+func PrepareVoiceNote(ctx context.Context, data []byte) (VoiceNote, error) {
+	if !TranscodingEnabled() {
+		return VoiceNote{}, fmt.Errorf("для обработки голосовых сообщений укажите путь к ffmpeg в %s", ffmpegPathEnv)
+	}
+	ctx, cancel := context.WithTimeout(ctx, voiceNoteTimeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "whatsapp-scheduler-voice-")
+	if err != nil {
+		return VoiceNote{}, err
+	}
+	defer os.RemoveAll(dir)
+	input, output := filepath.Join(dir, "input"), filepath.Join(dir, "voice.ogg")
+	if err := os.WriteFile(input, data, 0o600); err != nil {
+		return VoiceNote{}, err
+	}
+
+	cmd := exec.CommandContext(ctx, ffmpegPath(), "-y", "-nostdin", "-i", input, "-vn",
+		"-af", voiceNoteLoudness, "-ac", "1", "-ar", "48000",
+		"-c:a", "libopus", "-b:a", "32k", "-application", "voip", "-loglevel", "error", output)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return VoiceNote{}, fmt.Errorf("ошибка обработки голосового сообщения: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	voice, err := os.ReadFile(output)
+	if err != nil {
+		return VoiceNote{}, err
+	}
+
+	// Волна и длительность считаются по уже нормализованному звуку, как у записи в самом WhatsApp
+	pcmCmd := exec.CommandContext(ctx, ffmpegPath(), "-nostdin", "-i", output,
+		"-f", "s16le", "-ac", "1", "-ar", fmt.Sprint(voiceNoteSampleRate), "-loglevel", "error", "pipe:1")
+	var stderr strings.Builder
+	pcmCmd.Stderr = &stderr
+	pcm, err := pcmCmd.Output()
+	if err != nil {
+		return VoiceNote{}, fmt.Errorf("ошибка чтения голосового сообщения: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	samples := make([]int16, len(pcm)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+	}
+	if len(samples) == 0 {
+		return VoiceNote{}, fmt.Errorf("пустое голосовое сообщение")
+	}
+
+	return VoiceNote{
+		Data:     voice,
+		Seconds:  uint32(math.Ceil(float64(len(samples)) / voiceNoteSampleRate)),
+		Waveform: voiceWaveform(samples),
+	}, nil
+}
+
+// voiceWaveform вычисляет среднеквадратичную громкость отрезков записи, нормированную так,
+// что самый громкий отрезок равен 100
+func voiceWaveform(samples []int16) []byte {
+	levels := make([]float64, voiceNoteWaveformLength)
+	var loudest float64
+	for i := range levels {
+		from := i * len(samples) / voiceNoteWaveformLength
+		to := max((i+1)*len(samples)/voiceNoteWaveformLength, from+1)
+		var sum float64
+		for _, sample := range samples[from:min(to, len(samples))] {
+			sum += float64(sample) * float64(sample)
+		}
+		levels[i] = math.Sqrt(sum / float64(to-from))
+		loudest = max(loudest, levels[i])
+	}
+
+	waveform := make([]byte, voiceNoteWaveformLength)
+	if loudest == 0 {
+		return waveform
+	}
+	for i, level := range levels {
+		waveform[i] = byte(math.Round(level / loudest * 100))
+	}
+	return waveform
+}
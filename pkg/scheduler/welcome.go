@@ -0,0 +1,161 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// welcomeMentionPlaceholder - в тексте приветствия заменяется упоминанием новых участников
+const welcomeMentionPlaceholder = "{{mention}}"
+
+// GroupWelcome - приветствие новых участников группы
+type GroupWelcome struct {
+	GroupJID string `json:"group_jid"`
+	// Message - текст приветствия, {{mention}} заменяется упоминанием новых участников
+	Message string `json:"message"`
+	// Direct - отправлять приветствие каждому новому участнику в личный чат, а не в группу
+	Direct    bool      `json:"direct,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// validate проверяет приветствие
+func (w *GroupWelcome) validate() error {
+	w.Message = strings.TrimSpace(w.Message)
+	if w.Message == "" {
+		return fmt.Errorf("пустое сообщение приветствия")
+	}
+	return ValidateMessageLength(w.Message)
+}
+
+// SetGroupWelcome проверяет и сохраняет приветствие группы
+func (s *Scheduler) SetGroupWelcome(welcome GroupWelcome) error {
+	if err := welcome.validate(); err != nil {
+		return err
+	}
+	welcome.UpdatedAt = time.Now()
+	return s.storage.saveGroupWelcome(welcome)
+}
+
+// HandleGroupJoin приветствует участников members, вступивших в группу groupJID, если для нее
+// настроено приветствие. Вызывается транспортом
+func (s *Scheduler) HandleGroupJoin(groupJID string, members []string) {
+	if len(members) == 0 {
+		return
+	}
+	welcome, err := s.storage.GetGroupWelcome(groupJID)
+	if err != nil {
+		Logger.Errorf("Ошибка чтения приветствия группы %s: %v", groupJID, err)
+		return
+	}
+	if welcome == nil {
+		return
+	}
+
+	var messages []OutgoingMessage
+	if welcome.Direct {
+		for _, member := range members {
+			messages = append(messages, OutgoingMessage{
+				ChatName:    member,
+				ChatJID:     member,
+				Message:     strings.ReplaceAll(welcome.Message, welcomeMentionPlaceholder, mentionText(member)),
+				LinkPreview: true,
+			})
+		}
+	} else {
+		mentions := make([]string, len(members))
+		for i, member := range members {
+			mentions[i] = mentionText(member)
+		}
+		msg := OutgoingMessage{
+			ChatName:    groupJID,
+			ChatJID:     groupJID,
+			Message:     strings.ReplaceAll(welcome.Message, welcomeMentionPlaceholder, strings.Join(mentions, ", ")),
+			LinkPreview: true,
+		}
+		if strings.Contains(welcome.Message, welcomeMentionPlaceholder) {
+			msg.Mentions = members
+		}
+		messages = append(messages, msg)
+	}
+
+	// Отправка не должна задерживать обработку событий транспорта
+	go func() {
+		for _, msg := range messages {
+			if err := s.Deliver(context.Background(), msg); err != nil {
+				Logger.Errorf("❌ Ошибка отправки приветствия в чат %s: %v", msg.ChatJID, err)
+				continue
+			}
+			Logger.Infof("👋 Отправлено приветствие новых участников группы %s в чат %s", groupJID, msg.ChatJID)
+		}
+	}()
+}
+
+// mentionText возвращает упоминание пользователя в тексте: @ и номер (пользовательская часть JID)
+func mentionText(jid string) string {
+	user, _, _ := strings.Cut(jid, "@")
+	return "@" + user
+}
+
+// saveGroupWelcome сохраняет приветствие группы, заменяя предыдущее
+func (st *Storage) saveGroupWelcome(welcome GroupWelcome) error {
+	message, err := st.EncryptField(welcome.Message)
+	if err != nil {
+		return err
+	}
+	_, err = st.db.Exec(`INSERT INTO group_welcomes (group_jid, message, direct, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (group_jid) DO UPDATE SET message = excluded.message, direct = excluded.direct, updated_at = excluded.updated_at`,
+		welcome.GroupJID, message, welcome.Direct, welcome.UpdatedAt)
+	return err
+}
+
+// GetGroupWelcome возвращает приветствие группы или nil, если оно не настроено
+func (st *Storage) GetGroupWelcome(groupJID string) (*GroupWelcome, error) {
+	welcome := &GroupWelcome{}
+	err := st.db.QueryRow(`SELECT group_jid, message, direct, updated_at FROM group_welcomes WHERE group_jid = ?`, groupJID).
+		Scan(&welcome.GroupJID, &welcome.Message, &welcome.Direct, &welcome.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := st.decryptFields(&welcome.Message); err != nil {
+		return nil, err
+	}
+	return welcome, nil
+}
+
+// ListGroupWelcomes возвращает приветствия всех групп
+func (st *Storage) ListGroupWelcomes() ([]GroupWelcome, error) {
+	rows, err := st.db.Query(`SELECT group_jid, message, direct, updated_at FROM group_welcomes ORDER BY group_jid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	welcomes := []GroupWelcome{}
+	for rows.Next() {
+		var welcome GroupWelcome
+		if err := rows.Scan(&welcome.GroupJID, &welcome.Message, &welcome.Direct, &welcome.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := st.decryptFields(&welcome.Message); err != nil {
+			return nil, err
+		}
+		welcomes = append(welcomes, welcome)
+	}
+	return welcomes, rows.Err()
+}
+
+// DeleteGroupWelcome отключает приветствие группы, false - оно не было настроено
+func (st *Storage) DeleteGroupWelcome(groupJID string) (bool, error) {
+	res, err := st.db.Exec(`DELETE FROM group_welcomes WHERE group_jid = ?`, groupJID)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := res.RowsAffected()
+	return deleted > 0, err
+}
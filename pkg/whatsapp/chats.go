@@ -0,0 +1,97 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mau.fi/whatsmeow/appstate"
+	waTypes "go.mau.fi/whatsmeow/types"
+)
+
+// resolveChat находит JID чата по имени, номеру или JID
+func (c *Client) resolveChat(chatName string) (waTypes.JID, error) {
+	if c.client == nil {
+		return waTypes.JID{}, fmt.Errorf("клиент не инициализирован")
+	}
+	return c.findChat(chatName, "")
+}
+
+// SetDisappearingTimer включает или выключает исчезающие сообщения в чате
+func (c *Client) SetDisappearingTimer(chatName string, timer time.Duration) error {
+	jid, err := c.resolveChat(chatName)
+	if err != nil {
+		return err
+	}
+	if err := c.client.SetDisappearingTimer(jid, timer); err != nil {
+		return fmt.Errorf("ошибка установки таймера исчезающих сообщений: %v", err)
+	}
+	Logger.Infof("⏱️ Таймер исчезающих сообщений в чате '%s' установлен: %s", chatName, timer)
+	return nil
+}
+
+// SetMuted отключает или включает уведомления чата, нулевая длительность - навсегда
+func (c *Client) SetMuted(chatName string, muted bool, duration time.Duration) error {
+	jid, err := c.resolveChat(chatName)
+	if err != nil {
+		return err
+	}
+	if err := c.client.SendAppState(context.Background(), appstate.BuildMute(jid, muted, duration)); err != nil {
+		return fmt.Errorf("ошибка изменения уведомлений чата: %v", err)
+	}
+	Logger.Infof("🔕 Уведомления чата '%s' отключены: %t", chatName, muted)
+	return nil
+}
+
+// SetArchived архивирует или разархивирует чат
+func (c *Client) SetArchived(chatName string, archived bool) error {
+	jid, err := c.resolveChat(chatName)
+	if err != nil {
+		return err
+	}
+	if err := c.client.SendAppState(context.Background(), appstate.BuildArchive(jid, archived, time.Time{}, nil)); err != nil {
+		return fmt.Errorf("ошибка архивации чата: %v", err)
+	}
+	Logger.Infof("🗄️ Чат '%s' в архиве: %t", chatName, archived)
+	return nil
+}
+
+// GroupMembers возвращает JID участников группы, кроме собственного, реализует scheduler.GroupMemberLister.
+// Участникам, скрытым за LID, пишем по номеру телефона, если он известен
+func (c *Client) GroupMembers(chatName, chatJID string) ([]string, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("клиент не инициализирован")
+	}
+	jid, err := c.findChat(chatName, chatJID)
+	if err != nil {
+		return nil, err
+	}
+	if jid.Server != waTypes.GroupServer {
+		return nil, fmt.Errorf("чат '%s' не является группой", chatName)
+	}
+	info, err := c.client.GetGroupInfo(jid)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения участников группы: %v", err)
+	}
+
+	var own, ownLID waTypes.JID
+	if c.client.Store.ID != nil {
+		own = c.client.Store.ID.ToNonAD()
+	}
+	ownLID = c.client.Store.LID.ToNonAD()
+
+	members := []string{}
+	for _, participant := range info.Participants {
+		member := participant.JID.ToNonAD()
+		if member == own || member == ownLID || participant.PhoneNumber.ToNonAD() == own {
+			continue
+		}
+		if member.Server == waTypes.HiddenUserServer && !participant.PhoneNumber.IsEmpty() {
+			member = participant.PhoneNumber.ToNonAD()
+		}
+		members = append(members, member.String())
+	}
+	slices.Sort(members)
+	return members, nil
+}
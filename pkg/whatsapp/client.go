@@ -0,0 +1,373 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	waTypes "go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-scheduler/pkg/scheduler"
+
+	// not using this package directly, but it's required for
+	// the whatsmeow library to work with SQLite3
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ErrNotAuthorized - сессия не авторизована, а вывод QR кода отключен (RequireSession)
+var ErrNotAuthorized = errors.New("клиент не авторизован, запустите приложение и отсканируйте QR код")
+
+// Logger - логгер пакета, по умолчанию стандартный логгер logrus
+var Logger = logrus.StandardLogger()
+
+// Client - клиент WhatsApp на базе whatsmeow, реализует scheduler.MessageSender
+type Client struct {
+	client *whatsmeow.Client
+	// storage - хранилище планировщика с медиатекой и кэшем загрузок, может быть nil
+	storage *scheduler.Storage
+
+	// OnPresence вызывается при изменении присутствия пользователя, на которого оформлена подписка
+	OnPresence func(jid string, online bool, lastSeen time.Time)
+	// OnMessage вызывается для каждого входящего сообщения
+	OnMessage func(msg scheduler.IncomingMessage)
+	// OnReceipt вызывается при подтверждении доставки (read = false) или прочтения отправленных сообщений
+	OnReceipt func(messageIDs []string, read bool)
+	// OnGroupJoin вызывается, когда в группу вступили или были добавлены участники (кроме собственного аккаунта)
+	OnGroupJoin func(groupJID string, members []string)
+	// OnGroupMembership вызывается при вступлении в группу и выходе из нее участников, включая собственный аккаунт
+	OnGroupMembership func(change scheduler.GroupMembershipChange)
+	// OnLoggedOut вызывается, когда WhatsApp завершил сессию устройства. Клиент после этого
+	// сам переходит в режим привязки по QR коду
+	OnLoggedOut func(reason string)
+	// OnPaired вызывается после успешной привязки устройства по QR коду
+	OnPaired func()
+	// OnConnected вызывается при каждом установлении подключения к WhatsApp, в том числе после обрыва
+	OnConnected func()
+	// PrepareImage обрабатывает изображение вложения перед загрузкой (уменьшение, удаление EXIF),
+	// nil - изображения отправляются как есть
+	PrepareImage func(data []byte, mimeType string) ([]byte, string)
+	// RequireSession - не выводить QR код, а возвращать ErrNotAuthorized для неавторизованной сессии
+	RequireSession bool
+
+	presenceMutex sync.Mutex
+	subscribed    map[waTypes.JID]bool
+	// sessionPath - путь к базе сессии whatsmeow
+	sessionPath string
+	// pairing - привязка устройства по QR коду, см. StartPairing
+	pairing pairing
+	// keepalive - поддержание сессии, см. StartKeepalive
+	keepalive keepalive
+}
+
+// NewClient создает клиента, вложения из медиатеки берутся из storage
+func NewClient(storage *scheduler.Storage) *Client {
+	return &Client{
+		storage:    storage,
+		subscribed: make(map[waTypes.JID]bool),
+	}
+}
+
+// Connect открывает сессию из базы dbPath и подключается к WhatsApp.
+// Если клиент не авторизован, выводит QR код в терминал и ждет его сканирования
+func (c *Client) Connect(dbPath string) error {
+	c.sessionPath = dbPath
+	// Создаем базу данных с поддержкой foreign keys
+	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
+	if err != nil {
+		return fmt.Errorf("ошибка открытия БД: %v", err)
+	}
+	defer db.Close()
+
+	// Включаем foreign keys
+	_, err = db.Exec("PRAGMA foreign_keys = ON")
+	if err != nil {
+		return fmt.Errorf("ошибка включения foreign keys: %v", err)
+	}
+
+	container, err := sqlstore.New(context.Background(), "sqlite3", dbPath+"?_foreign_keys=on", nil)
+	if err != nil {
+		return fmt.Errorf("ошибка создания контейнера: %v", err)
+	}
+
+	deviceStore, err := container.GetFirstDevice(context.Background())
+	if err != nil {
+		return fmt.Errorf("ошибка получения устройства: %v", err)
+	}
+
+	client := whatsmeow.NewClient(deviceStore, nil)
+	c.client = client
+
+	client.AddEventHandler(c.handleEvent)
+
+	if client.Store.ID == nil && c.RequireSession {
+		return ErrNotAuthorized
+	}
+	if client.Store.ID == nil {
+		Logger.Info("Клиент не авторизован, требуется привязка по QR коду")
+		if err := c.waitForPairing(); err != nil {
+			return err
+		}
+	} else {
+		err = client.Connect()
+		if err != nil {
+			return fmt.Errorf("ошибка подключения: %v", err)
+		}
+		Logger.Info("WhatsApp клиент подключен")
+	}
+
+	// Проверяем статус подключения
+	if !client.IsConnected() {
+		return fmt.Errorf("не удалось установить подключение к WhatsApp")
+	}
+
+	return nil
+}
+
+// waitForPairing ждет сканирования QR кода при запуске. Если коды закончились,
+// привязка начинается заново, пока устройство не будет привязано
+func (c *Client) waitForPairing() error {
+	events, unsubscribe := c.SubscribeQR()
+	defer unsubscribe()
+	if err := c.StartPairing(); err != nil {
+		return err
+	}
+	for event := range events {
+		switch event.Event {
+		case QREventSuccess:
+			return nil
+		case QREventTimeout, QREventError:
+			if err := c.StartPairing(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IsConnected проверяет подключение к WhatsApp
+func (c *Client) IsConnected() bool {
+	return c.client != nil && c.client.IsConnected()
+}
+
+// IsAuthorized проверяет, что устройство авторизовано (QR код отсканирован)
+func (c *Client) IsAuthorized() bool {
+	return c.client != nil && c.client.Store.ID != nil
+}
+
+// Disconnect отключается от WhatsApp
+func (c *Client) Disconnect() {
+	if c.client != nil {
+		c.client.Disconnect()
+	}
+}
+
+// handleEvent обрабатывает события whatsmeow
+func (c *Client) handleEvent(evt interface{}) {
+	switch v := evt.(type) {
+	case *events.Receipt:
+		// Обрабатываем только подтверждения отправки
+		if v.Type == events.ReceiptTypeDelivered || v.Type == events.ReceiptTypeRead {
+			Logger.Debugf("Сообщение доставлено/прочитано: %s", v.MessageIDs)
+			if c.OnReceipt != nil {
+				ids := make([]string, len(v.MessageIDs))
+				for i, id := range v.MessageIDs {
+					ids[i] = string(id)
+				}
+				c.OnReceipt(ids, v.Type == events.ReceiptTypeRead)
+			}
+		}
+	case *events.Connected:
+		Logger.Info("✅ Подключение к WhatsApp установлено")
+		if c.OnConnected != nil {
+			c.OnConnected()
+		}
+	case *events.Disconnected:
+		Logger.Warn("⚠️ Отключение от WhatsApp")
+	case *events.LoggedOut:
+		Logger.Errorf("❌ Сессия WhatsApp завершена: %s", v.Reason.String())
+		if c.OnLoggedOut != nil {
+			c.OnLoggedOut(v.Reason.String())
+		}
+		go c.pairAfterLogout()
+	case *events.Presence:
+		if c.OnPresence != nil {
+			c.OnPresence(v.From.ToNonAD().String(), !v.Unavailable, v.LastSeen)
+		}
+	case *events.GroupInfo:
+		if len(v.Join) > 0 && c.OnGroupJoin != nil {
+			c.handleGroupJoin(v)
+		}
+		if (len(v.Join) > 0 || len(v.Leave) > 0) && c.OnGroupMembership != nil {
+			c.handleGroupMembership(v)
+		}
+	case *events.Message:
+		if v.Message.GetPollUpdateMessage() != nil {
+			c.handlePollVote(v)
+			return
+		}
+		c.storeMessage(v.Info.ID, v.Info.Chat, v.Info.Sender, v.Message, v.Info.Timestamp)
+		if c.OnMessage != nil {
+			msg := incomingMessage(v)
+			msg.Media = c.incomingMedia(v.Message)
+			c.OnMessage(msg)
+		}
+	}
+}
+
+// GenerateMessageID возвращает идентификатор для следующего сообщения, реализует scheduler.MessageIDGenerator
+func (c *Client) GenerateMessageID() string {
+	if c.client == nil {
+		return ""
+	}
+	return string(c.client.GenerateMessageID())
+}
+
+// SendMessage отправляет сообщение и возвращает JID чата получателя, реализует scheduler.MessageSender
+func (c *Client) SendMessage(ctx context.Context, out scheduler.OutgoingMessage) (scheduler.SendResult, error) {
+	if c.client == nil {
+		return scheduler.SendResult{}, fmt.Errorf("клиент не инициализирован")
+	}
+
+	// Проверяем подключение
+	if !c.client.IsConnected() {
+		Logger.Warnf("Клиент не подключен, пытаемся переподключиться...")
+		if err := c.client.Connect(); err != nil {
+			return scheduler.SendResult{}, fmt.Errorf("не удалось переподключиться к WhatsApp: %v", err)
+		}
+	}
+
+	// Очищаем входные данные
+	chatName := strings.TrimSpace(out.ChatName)
+	message := strings.TrimSpace(out.Message)
+	media := out.Media
+
+	if chatName == "" {
+		return scheduler.SendResult{}, fmt.Errorf("название чата не может быть пустым")
+	}
+
+	if message == "" && media == nil && out.ForwardID == "" {
+		return scheduler.SendResult{}, fmt.Errorf("сообщение не может быть пустым")
+	}
+
+	Logger.Infof("Попытка отправки сообщения в чат '%s': %s", chatName, message)
+	targetJID, err := c.findChat(chatName, out.ChatJID)
+	if err != nil {
+		return scheduler.SendResult{}, err
+	}
+
+	Logger.Infof("Отправляем сообщение в %s (%s)", chatName, targetJID)
+
+	// Создаем сообщение
+	msg := &waE2E.Message{
+		Conversation: proto.String(message),
+	}
+	if media == nil && out.LinkPreview {
+		msg = buildTextMessage(ctx, message)
+	}
+	if media != nil {
+		var err error
+		if msg, err = c.buildMediaMessage(ctx, media, message); err != nil {
+			return scheduler.SendResult{ChatJID: targetJID.String()}, err
+		}
+	}
+	if out.ForwardID != "" {
+		var err error
+		if msg, err = c.forwardedMessage(out.ForwardID); err != nil {
+			return scheduler.SendResult{ChatJID: targetJID.String()}, err
+		}
+	}
+	if out.Interactive != nil {
+		msg = interactiveMessage(message, out.Interactive)
+	}
+	if out.Poll != nil {
+		msg = c.client.BuildPollCreation(message, out.Poll.Options, out.Poll.SelectableCount)
+	}
+	if out.QuotedID != "" {
+		var err error
+		if msg, err = c.quoteMessage(msg, out.QuotedID, targetJID); err != nil {
+			return scheduler.SendResult{ChatJID: targetJID.String()}, err
+		}
+	}
+	msg = applyMentions(msg, out.Mentions)
+	msg = applyExpiration(msg, out.Expiration)
+
+	resp, err := c.client.SendMessage(ctx, targetJID, msg, whatsmeow.SendRequestExtra{ID: waTypes.MessageID(out.ID)})
+	if err != nil && out.Interactive != nil && ctx.Err() == nil {
+		// Интерактивные сообщения доступны не всем аккаунтам и типам чатов, вместо них
+		// отправляем варианты ответа обычным текстом
+		Logger.Warnf("Сервер не принял интерактивное сообщение в %s, отправляем обычным текстом: %v", targetJID, err)
+		msg = applyExpiration(&waE2E.Message{Conversation: proto.String(out.Interactive.FallbackText(message))}, out.Expiration)
+		resp, err = c.client.SendMessage(ctx, targetJID, msg, whatsmeow.SendRequestExtra{ID: waTypes.MessageID(out.ID)})
+	}
+	if err != nil {
+		Logger.Errorf("Ошибка отправки сообщения в %s: %v", targetJID, err)
+
+		if ctx.Err() == context.Canceled {
+			return scheduler.SendResult{ChatJID: targetJID.String()}, fmt.Errorf("отправка отменена")
+		}
+		return scheduler.SendResult{ChatJID: targetJID.String()}, classifySendError(err, chatName)
+	}
+
+	Logger.Infof("✅ Сообщение успешно отправлено в чат '%s' (%s): %s", chatName, targetJID, message)
+	c.storeMessage(resp.ID, targetJID, resp.Sender, msg, resp.Timestamp)
+	if out.Poll != nil && c.storage != nil {
+		err := c.storage.SavePoll(scheduler.Poll{
+			MessageID: string(resp.ID),
+			TaskID:    out.TaskID,
+			ChatJID:   targetJID.String(),
+			Question:  message,
+			Options:   out.Poll.Options,
+			CreatedAt: resp.Timestamp,
+		})
+		if err != nil {
+			Logger.Warnf("Ошибка сохранения опроса %s, голоса по нему не будут собраны: %v", resp.ID, err)
+		}
+	}
+	return scheduler.SendResult{ChatJID: targetJID.String(), MessageID: string(resp.ID), Timestamp: resp.Timestamp}, nil
+}
+
+// sendErrorKinds - ошибки whatsmeow и соответствующие им ошибки планировщика
+var sendErrorKinds = []struct {
+	causes []error
+	kind   error
+}{
+	{[]error{whatsmeow.ErrMessageTimedOut, whatsmeow.ErrIQTimedOut, context.DeadlineExceeded}, scheduler.ErrSendTimeout},
+	{[]error{whatsmeow.ErrNotConnected}, scheduler.ErrDisconnected},
+	{[]error{whatsmeow.ErrNotLoggedIn}, scheduler.ErrLoggedOut},
+	{[]error{whatsmeow.ErrIQNotFound, whatsmeow.ErrGroupNotFound, whatsmeow.ErrUnknownServer}, scheduler.ErrChatNotFound},
+	{[]error{whatsmeow.ErrIQNotAuthorized, whatsmeow.ErrIQForbidden, whatsmeow.ErrIQNotAllowed, whatsmeow.ErrNotInGroup}, scheduler.ErrNotAuthorized},
+	{[]error{whatsmeow.ErrIQRateOverLimit, whatsmeow.ErrIQResourceLimit}, scheduler.ErrRateLimited},
+	{[]error{whatsmeow.ErrIQInternalServerError, whatsmeow.ErrIQServiceUnavailable, whatsmeow.ErrIQPartialServerError}, scheduler.ErrServerUnavailable},
+	{[]error{whatsmeow.ErrServerReturnedError}, scheduler.ErrServerRejected},
+}
+
+// classifySendError переводит ошибку отправки whatsmeow в ошибку планировщика, сохраняя исходный текст,
+// чтобы API вернуло код ошибки, а планировщик понял, можно ли повторить отправку
+func classifySendError(err error, chatName string) error {
+	var disconnected *whatsmeow.DisconnectedError
+	if errors.As(err, &disconnected) {
+		return fmt.Errorf("%w: %v", scheduler.ErrDisconnected, err)
+	}
+	for _, kind := range sendErrorKinds {
+		for _, cause := range kind.causes {
+			if !errors.Is(err, cause) {
+				continue
+			}
+			if kind.kind == scheduler.ErrChatNotFound {
+				return fmt.Errorf("%w или недоступен: '%s'", scheduler.ErrChatNotFound, chatName)
+			}
+			return fmt.Errorf("%w: %v", kind.kind, err)
+		}
+	}
+	return fmt.Errorf("ошибка отправки сообщения: %v", err)
+}
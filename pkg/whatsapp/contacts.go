@@ -0,0 +1,92 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	waTypes "go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ParseUserJID разбирает номер телефона или JID пользователя
+func ParseUserJID(value string) (waTypes.JID, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "+")
+	if !strings.Contains(value, "@") {
+		value += "@" + waTypes.DefaultUserServer
+	}
+	jid, err := waTypes.ParseJID(value)
+	if err != nil || jid.User == "" || (jid.Server != waTypes.DefaultUserServer && jid.Server != waTypes.HiddenUserServer) {
+		return waTypes.JID{}, fmt.Errorf("неверный JID пользователя '%s'", value)
+	}
+	return jid, nil
+}
+
+// SetBlocked блокирует или разблокирует пользователя
+func (c *Client) SetBlocked(jid waTypes.JID, blocked bool) error {
+	if c.client == nil {
+		return fmt.Errorf("клиент не инициализирован")
+	}
+
+	action := events.BlocklistChangeActionUnblock
+	if blocked {
+		action = events.BlocklistChangeActionBlock
+	}
+	if _, err := c.client.UpdateBlocklist(jid, action); err != nil {
+		return fmt.Errorf("ошибка изменения списка блокировки: %v", err)
+	}
+	Logger.Infof("🚫 Пользователь %s: %s | UI: http://localhost:8080", jid, action)
+	return nil
+}
+
+// BlockContact блокирует пользователя, реализует scheduler.ContactBlocker
+func (c *Client) BlockContact(jid string) error {
+	parsed, err := ParseUserJID(jid)
+	if err != nil {
+		return err
+	}
+	return c.SetBlocked(parsed, true)
+}
+
+// ForgetContact удаляет из сессии сохраненные имена контакта и возвращает их,
+// чтобы найти записи, в которых получатель указан по имени
+func (c *Client) ForgetContact(ctx context.Context, jid waTypes.JID) ([]string, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("клиент не инициализирован")
+	}
+
+	contacts := c.client.Store.Contacts
+	info, err := contacts.GetContact(ctx, jid)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения контакта: %v", err)
+	}
+	names := []string{jid.User, info.FirstName, info.FullName, info.PushName, info.BusinessName}
+
+	// Пустые имена очищают и кэш whatsmeow в памяти, затем удаляем саму запись
+	if err := contacts.PutContactName(ctx, jid, "", ""); err != nil {
+		return nil, fmt.Errorf("ошибка очистки контакта: %v", err)
+	}
+	if _, _, err := contacts.PutPushName(ctx, jid, ""); err != nil {
+		return nil, fmt.Errorf("ошибка очистки контакта: %v", err)
+	}
+	if _, _, err := contacts.PutBusinessName(ctx, jid, ""); err != nil {
+		return nil, fmt.Errorf("ошибка очистки контакта: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", c.sessionPath+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия БД сессии: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, `DELETE FROM whatsmeow_contacts WHERE their_jid = ?`, jid.String()); err != nil {
+		return nil, fmt.Errorf("ошибка удаления контакта: %v", err)
+	}
+
+	c.presenceMutex.Lock()
+	delete(c.subscribed, jid)
+	c.presenceMutex.Unlock()
+
+	Logger.Infof("🧹 Данные контакта %s удалены из сессии", jid)
+	return names, nil
+}
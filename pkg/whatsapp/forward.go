@@ -0,0 +1,95 @@
+package whatsapp
+
+import (
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	waTypes "go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+// storeMessage сохраняет сообщение для последующей пересылки задачами с forward_message_id.
+// Служебные сообщения (реакции, удаления и т.п.) не сохраняются
+func (c *Client) storeMessage(id waTypes.MessageID, chat, sender waTypes.JID, msg *waE2E.Message, timestamp time.Time) {
+	if c.storage == nil || msg == nil {
+		return
+	}
+	if _, contextInfo := withContextInfo(proto.Clone(msg).(*waE2E.Message)); contextInfo == nil {
+		return
+	}
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		Logger.Warnf("Ошибка сериализации сообщения %s: %v", id, err)
+		return
+	}
+	err = c.storage.SaveMessage(scheduler.StoredMessage{
+		ID:        string(id),
+		ChatJID:   chat.ToNonAD().String(),
+		SenderJID: sender.ToNonAD().String(),
+		Text:      messageText(msg),
+		Data:      data,
+		CreatedAt: timestamp,
+	})
+	if err != nil {
+		Logger.Warnf("Ошибка сохранения сообщения %s для пересылки: %v", id, err)
+	}
+}
+
+// quoteMessage делает msg ответом на сохраненное сообщение quotedID из чата chat
+func (c *Client) quoteMessage(msg *waE2E.Message, quotedID string, chat waTypes.JID) (*waE2E.Message, error) {
+	if c.storage == nil {
+		return nil, fmt.Errorf("хранилище сообщений не подключено")
+	}
+	stored, err := c.storage.GetStoredMessage(quotedID)
+	if err != nil {
+		return nil, err
+	}
+	if stored.ChatJID != chat.ToNonAD().String() {
+		return nil, fmt.Errorf("сообщение %s из другого чата, цитировать можно только сообщения чата получателя", quotedID)
+	}
+	quoted := &waE2E.Message{}
+	if err := proto.Unmarshal(stored.Data, quoted); err != nil {
+		return nil, fmt.Errorf("ошибка чтения сообщения %s для цитаты: %v", quotedID, err)
+	}
+
+	msg, contextInfo := withContextInfo(msg)
+	if contextInfo == nil {
+		return nil, fmt.Errorf("к этому типу сообщения нельзя добавить цитату")
+	}
+	contextInfo.StanzaID = proto.String(quotedID)
+	contextInfo.QuotedMessage = quoted
+	if stored.SenderJID != "" {
+		contextInfo.Participant = proto.String(stored.SenderJID)
+	}
+	return msg, nil
+}
+
+// forwardedMessage восстанавливает сохраненное сообщение и помечает его как пересланное
+func (c *Client) forwardedMessage(id string) (*waE2E.Message, error) {
+	if c.storage == nil {
+		return nil, fmt.Errorf("хранилище сообщений для пересылки не подключено")
+	}
+	stored, err := c.storage.GetStoredMessage(id)
+	if err != nil {
+		return nil, err
+	}
+	msg := &waE2E.Message{}
+	if err := proto.Unmarshal(stored.Data, msg); err != nil {
+		return nil, fmt.Errorf("ошибка чтения сообщения %s для пересылки: %v", id, err)
+	}
+
+	msg, contextInfo := withContextInfo(msg)
+	if contextInfo == nil {
+		return nil, fmt.Errorf("сообщение %s нельзя переслать", id)
+	}
+	// Как и в приложении WhatsApp, пересылается только содержимое: без цитаты и упоминаний
+	*contextInfo = waE2E.ContextInfo{
+		IsForwarded:     proto.Bool(true),
+		ForwardingScore: proto.Uint32(contextInfo.GetForwardingScore() + 1),
+	}
+	return msg, nil
+}
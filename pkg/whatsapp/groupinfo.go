@@ -0,0 +1,36 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+
+	waTypes "go.mau.fi/whatsmeow/types"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+// SetGroupInfo меняет название или описание группы, реализует scheduler.GroupInfoUpdater.
+// Для закрытой группы (locked) аккаунт должен быть администратором
+func (c *Client) SetGroupInfo(ctx context.Context, chatName, chatJID, field, value string) error {
+	if c.client == nil {
+		return fmt.Errorf("клиент не инициализирован")
+	}
+	jid, err := c.findChat(chatName, chatJID)
+	if err != nil {
+		return err
+	}
+	if jid.Server != waTypes.GroupServer {
+		return fmt.Errorf("чат '%s' не является группой", chatName)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	switch field {
+	case scheduler.GroupInfoSubject:
+		return c.client.SetGroupName(jid, value)
+	case scheduler.GroupInfoDescription:
+		return c.client.SetGroupDescription(jid, value)
+	}
+	return fmt.Errorf("неизвестное поле группы '%s'", field)
+}
@@ -0,0 +1,44 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+
+	waTypes "go.mau.fi/whatsmeow/types"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+// SetGroupSetting включает или выключает настройку группы, реализует scheduler.GroupSettingChanger.
+// Аккаунт должен быть администратором группы
+func (c *Client) SetGroupSetting(ctx context.Context, chatName, chatJID, setting string, enabled bool) error {
+	if c.client == nil {
+		return fmt.Errorf("клиент не инициализирован")
+	}
+	jid, err := c.findChat(chatName, chatJID)
+	if err != nil {
+		return err
+	}
+	if jid.Server != waTypes.GroupServer {
+		return fmt.Errorf("чат '%s' не является группой", chatName)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	switch setting {
+	case scheduler.GroupSettingAnnounce:
+		return c.client.SetGroupAnnounce(jid, enabled)
+	case scheduler.GroupSettingLocked:
+		return c.client.SetGroupLocked(jid, enabled)
+	case scheduler.GroupSettingJoinApproval:
+		return c.client.SetGroupJoinApprovalMode(jid, enabled)
+	case scheduler.GroupSettingAdminAdd:
+		mode := waTypes.GroupMemberAddModeAllMember
+		if enabled {
+			mode = waTypes.GroupMemberAddModeAdmin
+		}
+		return c.client.SetGroupMemberAddMode(jid, mode)
+	}
+	return fmt.Errorf("неизвестная настройка группы '%s'", setting)
+}
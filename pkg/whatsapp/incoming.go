@@ -0,0 +1,86 @@
+package whatsapp
+
+import (
+	"context"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	waTypes "go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+// incomingMessage преобразует событие whatsmeow во входящее сообщение планировщика
+func incomingMessage(evt *events.Message) scheduler.IncomingMessage {
+	msg := scheduler.IncomingMessage{
+		ID:         string(evt.Info.ID),
+		ChatJID:    evt.Info.Chat.String(),
+		SenderJID:  evt.Info.Sender.ToNonAD().String(),
+		SenderName: evt.Info.PushName,
+		Text:       messageText(evt.Message),
+		Direct:     evt.Info.Chat.Server == waTypes.DefaultUserServer,
+		FromMe:     evt.Info.IsFromMe,
+		Timestamp:  evt.Info.Timestamp,
+	}
+	if reaction := evt.Message.GetReactionMessage(); reaction != nil {
+		msg.ReactionToID, msg.Reaction = reaction.GetKey().GetID(), reaction.GetText()
+	} else if evt.Message != nil {
+		if _, contextInfo := withContextInfo(proto.Clone(evt.Message).(*waE2E.Message)); contextInfo != nil {
+			msg.ReplyToID = contextInfo.GetStanzaID()
+		}
+	}
+	return msg
+}
+
+// incomingMedia возвращает изображение или документ входящего сообщения, которые можно сохранить в медиатеку
+func (c *Client) incomingMedia(msg *waE2E.Message) *scheduler.IncomingMedia {
+	var downloadable whatsmeow.DownloadableMessage
+	media := &scheduler.IncomingMedia{}
+	switch {
+	case msg.GetImageMessage() != nil:
+		image := msg.GetImageMessage()
+		downloadable, media.MimeType, media.Size = image, image.GetMimetype(), int64(image.GetFileLength())
+	case msg.GetDocumentMessage() != nil:
+		document := msg.GetDocumentMessage()
+		downloadable, media.MimeType, media.Size = document, document.GetMimetype(), int64(document.GetFileLength())
+		media.FileName = document.GetFileName()
+	default:
+		return nil
+	}
+	media.Download = func(ctx context.Context) ([]byte, error) {
+		return c.client.Download(ctx, downloadable)
+	}
+	return media
+}
+
+// messageText извлекает текст из сообщения (текст или подпись к медиа)
+func messageText(msg *waE2E.Message) string {
+	switch {
+	case msg == nil:
+		return ""
+	case msg.GetConversation() != "":
+		return msg.GetConversation()
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetText()
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetCaption()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetCaption()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetCaption()
+	case msg.GetButtonsMessage() != nil:
+		return msg.GetButtonsMessage().GetContentText()
+	case msg.GetListMessage() != nil:
+		return msg.GetListMessage().GetDescription()
+	case msg.GetButtonsResponseMessage() != nil:
+		return msg.GetButtonsResponseMessage().GetSelectedDisplayText()
+	case msg.GetListResponseMessage() != nil:
+		return msg.GetListResponseMessage().GetTitle()
+	case msg.GetPollCreationMessage() != nil:
+		return msg.GetPollCreationMessage().GetName()
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,58 @@
+package whatsapp
+
+import (
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+// interactiveMessage формирует сообщение со списком или кнопками быстрого ответа с текстом body
+func interactiveMessage(body string, content *scheduler.InteractiveContent) *waE2E.Message {
+	var footer *string
+	if content.Footer != "" {
+		footer = proto.String(content.Footer)
+	}
+
+	if content.Type == scheduler.InteractiveButtons {
+		buttons := make([]*waE2E.ButtonsMessage_Button, 0, len(content.Buttons))
+		for _, text := range content.Buttons {
+			buttons = append(buttons, &waE2E.ButtonsMessage_Button{
+				ButtonID:   proto.String(text),
+				ButtonText: &waE2E.ButtonsMessage_Button_ButtonText{DisplayText: proto.String(text)},
+				Type:       waE2E.ButtonsMessage_Button_RESPONSE.Enum(),
+			})
+		}
+		return &waE2E.Message{ButtonsMessage: &waE2E.ButtonsMessage{
+			ContentText: proto.String(body),
+			FooterText:  footer,
+			Buttons:     buttons,
+			HeaderType:  waE2E.ButtonsMessage_EMPTY.Enum(),
+		}}
+	}
+
+	sections := make([]*waE2E.ListMessage_Section, 0, len(content.Sections))
+	for _, section := range content.Sections {
+		rows := make([]*waE2E.ListMessage_Row, 0, len(section.Rows))
+		for _, row := range section.Rows {
+			listRow := &waE2E.ListMessage_Row{RowID: proto.String(row.ID), Title: proto.String(row.Title)}
+			if row.Description != "" {
+				listRow.Description = proto.String(row.Description)
+			}
+			rows = append(rows, listRow)
+		}
+		listSection := &waE2E.ListMessage_Section{Rows: rows}
+		if section.Title != "" {
+			listSection.Title = proto.String(section.Title)
+		}
+		sections = append(sections, listSection)
+	}
+	return &waE2E.Message{ListMessage: &waE2E.ListMessage{
+		Title:       proto.String(content.Title),
+		Description: proto.String(body),
+		ButtonText:  proto.String(content.ButtonText),
+		ListType:    waE2E.ListMessage_SINGLE_SELECT.Enum(),
+		Sections:    sections,
+		FooterText:  footer,
+	}}
+}
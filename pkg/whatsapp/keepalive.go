@@ -0,0 +1,67 @@
+package whatsapp
+
+import (
+	"sync"
+	"time"
+
+	waTypes "go.mau.fi/whatsmeow/types"
+)
+
+// DefaultKeepaliveInterval - период отправки присутствия для поддержания сессии по умолчанию
+const DefaultKeepaliveInterval = 10 * time.Minute
+
+// keepalive - время последней успешной отправки присутствия
+type keepalive struct {
+	mutex sync.Mutex
+	last  time.Time
+}
+
+// StartKeepalive периодически отправляет присутствие, чтобы привязанная сессия не становилась
+// неактивной в долгих паузах между отправками. interval <= 0 - не отправлять
+func (c *Client) StartKeepalive(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			c.sendKeepalive()
+		}
+	}()
+}
+
+// sendKeepalive отправляет присутствие "в сети" и, если нет подписок на присутствие, снова
+// "не в сети", чтобы телефон продолжал получать уведомления
+func (c *Client) sendKeepalive() {
+	if !c.IsAuthorized() || !c.IsConnected() {
+		Logger.Debug("Нет подключения к WhatsApp, присутствие не отправлено")
+		return
+	}
+
+	// Подписки на присутствие работают только со статусом "в сети", его нельзя снимать
+	c.presenceMutex.Lock()
+	defer c.presenceMutex.Unlock()
+	if err := c.client.SendPresence(waTypes.PresenceAvailable); err != nil {
+		Logger.Warnf("⚠️ Ошибка отправки присутствия для поддержания сессии: %v", err)
+		return
+	}
+	if len(c.subscribed) == 0 {
+		if err := c.client.SendPresence(waTypes.PresenceUnavailable); err != nil {
+			Logger.Warnf("⚠️ Ошибка отправки присутствия для поддержания сессии: %v", err)
+			return
+		}
+	}
+
+	c.keepalive.mutex.Lock()
+	c.keepalive.last = time.Now()
+	c.keepalive.mutex.Unlock()
+	Logger.Debug("Присутствие для поддержания сессии отправлено")
+}
+
+// LastKeepalive возвращает время последней успешной отправки присутствия, нулевое - еще не было
+func (c *Client) LastKeepalive() time.Time {
+	c.keepalive.mutex.Lock()
+	defer c.keepalive.mutex.Unlock()
+	return c.keepalive.last
+}
@@ -0,0 +1,178 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif" // декодеры форматов превью
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"golang.org/x/net/html"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+const (
+	// linkPreviewMaxPage - максимальный размер загружаемой страницы
+	linkPreviewMaxPage = 1 << 20
+	// linkPreviewMaxImage - максимальный размер загружаемой картинки превью
+	linkPreviewMaxImage = 5 << 20
+	// linkPreviewThumbnailSize - максимальная сторона миниатюры в пикселях
+	linkPreviewThumbnailSize = 200
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// linkPreview - данные превью страницы
+type linkPreview struct {
+	title       string
+	description string
+	imageURL    string
+}
+
+// buildTextMessage формирует текстовое сообщение, добавляя превью первой ссылки.
+// Если превью получить не удалось, отправляется обычный текст
+func buildTextMessage(ctx context.Context, message string) *waE2E.Message {
+	plain := &waE2E.Message{Conversation: proto.String(message)}
+
+	link := strings.TrimRight(urlPattern.FindString(message), ".,;:!?)")
+	if link == "" {
+		return plain
+	}
+
+	preview, err := fetchLinkPreview(ctx, link)
+	if err != nil {
+		Logger.Debugf("Не удалось получить превью ссылки %s: %v", link, err)
+		return plain
+	}
+
+	extended := &waE2E.ExtendedTextMessage{
+		Text:        proto.String(message),
+		MatchedText: proto.String(link),
+		Title:       proto.String(preview.title),
+		Description: proto.String(preview.description),
+		PreviewType: waE2E.ExtendedTextMessage_NONE.Enum(),
+	}
+	if preview.imageURL != "" {
+		if thumbnail, err := fetchThumbnail(ctx, preview.imageURL); err == nil {
+			extended.JPEGThumbnail = thumbnail
+		} else {
+			Logger.Debugf("Не удалось получить картинку превью %s: %v", preview.imageURL, err)
+		}
+	}
+	return &waE2E.Message{ExtendedTextMessage: extended}
+}
+
+// fetchLinkPreview загружает страницу и извлекает OpenGraph/HTML метаданные
+func fetchLinkPreview(ctx context.Context, link string) (*linkPreview, error) {
+	body, err := httpGet(ctx, link, linkPreviewMaxPage)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &linkPreview{}
+	var htmlTitle, htmlDescription string
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	inTitle := false
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+		switch {
+		case tokenType == html.StartTagToken && token.Data == "title":
+			inTitle = true
+		case tokenType == html.EndTagToken && token.Data == "title":
+			inTitle = false
+		case tokenType == html.TextToken && inTitle && htmlTitle == "":
+			htmlTitle = strings.TrimSpace(token.Data)
+		case (tokenType == html.StartTagToken || tokenType == html.SelfClosingTagToken) && token.Data == "meta":
+			var key, content string
+			for _, attr := range token.Attr {
+				switch attr.Key {
+				case "property", "name":
+					key = strings.ToLower(attr.Val)
+				case "content":
+					content = strings.TrimSpace(attr.Val)
+				}
+			}
+			switch key {
+			case "og:title":
+				preview.title = content
+			case "og:description":
+				preview.description = content
+			case "description":
+				htmlDescription = content
+			case "og:image":
+				preview.imageURL = content
+			}
+		}
+	}
+
+	if preview.title == "" {
+		preview.title = htmlTitle
+	}
+	if preview.description == "" {
+		preview.description = htmlDescription
+	}
+	if preview.title == "" {
+		return nil, fmt.Errorf("страница не содержит заголовка")
+	}
+	if preview.imageURL != "" {
+		// og:image может быть относительным
+		if base, err := url.Parse(link); err == nil {
+			if resolved, err := base.Parse(preview.imageURL); err == nil {
+				preview.imageURL = resolved.String()
+			}
+		}
+	}
+	return preview, nil
+}
+
+// fetchThumbnail загружает картинку и уменьшает её до JPEG миниатюры
+func fetchThumbnail(ctx context.Context, imageURL string) ([]byte, error) {
+	data, err := httpGet(ctx, imageURL, linkPreviewMaxImage)
+	if err != nil {
+		return nil, err
+	}
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scheduler.DownscaleImage(source, linkPreviewThumbnailSize), &jpeg.Options{Quality: 70}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// httpGet выполняет GET запрос и читает не более limit байт ответа
+func httpGet(ctx context.Context, link string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; WhatsAppScheduler/1.0)")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("статус ответа %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
@@ -0,0 +1,68 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+// mediaUploadTTL - сколько времени используется ранее загруженный в WhatsApp файл
+// (ссылки на серверах WhatsApp со временем перестают работать)
+const mediaUploadTTL = 7 * 24 * time.Hour
+
+// cachedUpload возвращает сохраненный ответ загрузки файла в WhatsApp, если он еще действителен
+func cachedUpload(db *sql.DB, mediaID string, mediaType whatsmeow.MediaType) (*whatsmeow.UploadResponse, error) {
+	uploaded := &whatsmeow.UploadResponse{}
+	var uploadedAt time.Time
+	err := db.QueryRow(`SELECT url, direct_path, media_key, file_enc_sha256, file_sha256, file_length, uploaded_at
+		FROM media_uploads WHERE media_id = ? AND media_type = ?`, mediaID, string(mediaType)).
+		Scan(&uploaded.URL, &uploaded.DirectPath, &uploaded.MediaKey, &uploaded.FileEncSHA256,
+			&uploaded.FileSHA256, &uploaded.FileLength, &uploadedAt)
+	if err == sql.ErrNoRows || (err == nil && time.Since(uploadedAt) > mediaUploadTTL) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return uploaded, nil
+}
+
+// saveUpload сохраняет ответ загрузки файла в WhatsApp для повторного использования
+func saveUpload(db *sql.DB, mediaID string, mediaType whatsmeow.MediaType, uploaded whatsmeow.UploadResponse) error {
+	_, err := db.Exec(`INSERT OR REPLACE INTO media_uploads
+		(media_id, media_type, url, direct_path, media_key, file_enc_sha256, file_sha256, file_length, uploaded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		mediaID, string(mediaType), uploaded.URL, uploaded.DirectPath, uploaded.MediaKey,
+		uploaded.FileEncSHA256, uploaded.FileSHA256, uploaded.FileLength, time.Now())
+	return err
+}
+
+// uploadAsset загружает файл медиатеки в WhatsApp или использует ранее загруженный
+func (c *Client) uploadAsset(ctx context.Context, asset *scheduler.MediaAsset, mediaType whatsmeow.MediaType) (whatsmeow.UploadResponse, error) {
+	cached, err := cachedUpload(c.storage.DB(), asset.ID, mediaType)
+	if err != nil {
+		Logger.Warnf("Ошибка чтения кэша загрузок медиатеки: %v", err)
+	} else if cached != nil {
+		Logger.Debugf("Используем ранее загруженный файл медиатеки %s", asset.ID)
+		return *cached, nil
+	}
+
+	data, err := os.ReadFile(asset.Path())
+	if err != nil {
+		return whatsmeow.UploadResponse{}, fmt.Errorf("ошибка чтения файла медиатеки '%s': %v", asset.ID, err)
+	}
+	uploaded, err := c.client.Upload(ctx, data, mediaType)
+	if err != nil {
+		return whatsmeow.UploadResponse{}, fmt.Errorf("ошибка загрузки вложения: %v", err)
+	}
+	if err := saveUpload(c.storage.DB(), asset.ID, mediaType, uploaded); err != nil {
+		Logger.Warnf("Ошибка сохранения кэша загрузок медиатеки: %v", err)
+	}
+	return uploaded, nil
+}
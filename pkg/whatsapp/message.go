@@ -0,0 +1,232 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+// videoPreviewSize - большая сторона миниатюры видеосообщения
+const videoPreviewSize = 200
+
+// buildMediaMessage загружает вложение на сервера WhatsApp и формирует сообщение с подписью.
+// Вложения из медиатеки повторно используют ранее загруженные файлы
+func (c *Client) buildMediaMessage(ctx context.Context, media *scheduler.MediaAttachment, caption string) (*waE2E.Message, error) {
+	if media.VoiceNote {
+		return c.buildVoiceNote(ctx, media)
+	}
+	mimeType, fileName := media.MimeType, media.FileName
+
+	var uploaded whatsmeow.UploadResponse
+	var mediaType whatsmeow.MediaType
+	if media.MediaID != "" {
+		if c.storage == nil {
+			return nil, fmt.Errorf("медиатека недоступна")
+		}
+		asset, err := c.storage.GetMedia(media.MediaID)
+		if err != nil {
+			return nil, err
+		}
+		mimeType, fileName = asset.MimeType, asset.FileName
+		mediaType = mediaTypeFor(mimeType)
+		if uploaded, err = c.uploadAsset(ctx, asset, mediaType); err != nil {
+			return nil, err
+		}
+	} else {
+		data, detectedMime, detectedName, err := attachmentData(ctx, media)
+		if err != nil {
+			return nil, err
+		}
+		mimeType, fileName = detectedMime, detectedName
+		if c.PrepareImage != nil {
+			data, mimeType = c.PrepareImage(data, mimeType)
+		}
+		mediaType = mediaTypeFor(mimeType)
+		if uploaded, err = c.client.Upload(ctx, data, mediaType); err != nil {
+			return nil, fmt.Errorf("ошибка загрузки вложения: %v", err)
+		}
+	}
+
+	message := mediaMessage(mediaType, mimeType, fileName, caption, uploaded)
+	// Кадр перекодированного видео медиатеки показывается в чате до загрузки самого видео
+	if message.VideoMessage != nil && media.MediaID != "" {
+		if thumbnail, err := c.storage.MediaThumbnail(media.MediaID, videoPreviewSize); err == nil {
+			message.VideoMessage.JPEGThumbnail = thumbnail
+		}
+	}
+	return message, nil
+}
+
+// attachmentData возвращает содержимое вложения, переданного в base64 или ссылкой, его MIME тип и имя файла
+func attachmentData(ctx context.Context, media *scheduler.MediaAttachment) ([]byte, string, string, error) {
+	mimeType, fileName := media.MimeType, media.FileName
+	var data []byte
+	var err error
+	if media.URL != "" {
+		var downloadedMime, downloadedName string
+		if data, downloadedMime, downloadedName, err = scheduler.DownloadMedia(ctx, media.URL); err != nil {
+			return nil, "", "", err
+		}
+		if mimeType == "" {
+			mimeType = downloadedMime
+		}
+		if fileName == "" {
+			fileName = downloadedName
+		}
+	} else if data, err = base64.StdEncoding.DecodeString(media.Data); err != nil {
+		return nil, "", "", fmt.Errorf("неверное содержимое вложения (ожидается base64): %v", err)
+	}
+	if len(data) == 0 {
+		return nil, "", "", fmt.Errorf("пустое вложение")
+	}
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return data, mimeType, fileName, nil
+}
+
+// mediaTypeFor определяет тип медиа WhatsApp по MIME типу
+func mediaTypeFor(mimeType string) whatsmeow.MediaType {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return whatsmeow.MediaImage
+	case strings.HasPrefix(mimeType, "video/"):
+		return whatsmeow.MediaVideo
+	case strings.HasPrefix(mimeType, "audio/"):
+		return whatsmeow.MediaAudio
+	default:
+		return whatsmeow.MediaDocument
+	}
+}
+
+// mediaMessage формирует сообщение из загруженного вложения
+func mediaMessage(mediaType whatsmeow.MediaType, mimeType, fileName, caption string, uploaded whatsmeow.UploadResponse) *waE2E.Message {
+	var captionPtr *string
+	if caption != "" {
+		captionPtr = proto.String(caption)
+	}
+
+	switch mediaType {
+	case whatsmeow.MediaImage:
+		return &waE2E.Message{ImageMessage: &waE2E.ImageMessage{
+			Caption:       captionPtr,
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}
+	case whatsmeow.MediaVideo:
+		return &waE2E.Message{VideoMessage: &waE2E.VideoMessage{
+			Caption:       captionPtr,
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}
+	case whatsmeow.MediaAudio:
+		// У аудиосообщений нет подписи
+		return &waE2E.Message{AudioMessage: &waE2E.AudioMessage{
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}
+	default:
+		if fileName == "" {
+			fileName = "file"
+		}
+		return &waE2E.Message{DocumentMessage: &waE2E.DocumentMessage{
+			Caption:       captionPtr,
+			FileName:      proto.String(fileName),
+			Title:         proto.String(fileName),
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}
+	}
+}
+
+// applyExpiration помечает сообщение как исчезающее через заданное время
+func applyExpiration(msg *waE2E.Message, expiration time.Duration) *waE2E.Message {
+	if expiration <= 0 {
+		return msg
+	}
+
+	msg, contextInfo := withContextInfo(msg)
+	if contextInfo != nil {
+		contextInfo.Expiration = proto.Uint32(uint32(expiration.Seconds()))
+	}
+	return msg
+}
+
+// applyMentions отмечает упомянутых в тексте пользователей, чтобы упоминания стали ссылками
+// и участники получили уведомление
+func applyMentions(msg *waE2E.Message, mentions []string) *waE2E.Message {
+	if len(mentions) == 0 {
+		return msg
+	}
+
+	msg, contextInfo := withContextInfo(msg)
+	if contextInfo != nil {
+		contextInfo.MentionedJID = mentions
+	}
+	return msg
+}
+
+// withContextInfo возвращает ContextInfo сообщения, создавая его при необходимости, или nil,
+// если у типа сообщения его нет. Простой текст заменяется расширенным, так как у него нет ContextInfo
+func withContextInfo(msg *waE2E.Message) (*waE2E.Message, *waE2E.ContextInfo) {
+	if msg.Conversation != nil {
+		msg = &waE2E.Message{ExtendedTextMessage: &waE2E.ExtendedTextMessage{Text: msg.Conversation}}
+	}
+
+	var contextInfo **waE2E.ContextInfo
+	switch {
+	case msg.ExtendedTextMessage != nil:
+		contextInfo = &msg.ExtendedTextMessage.ContextInfo
+	case msg.ImageMessage != nil:
+		contextInfo = &msg.ImageMessage.ContextInfo
+	case msg.VideoMessage != nil:
+		contextInfo = &msg.VideoMessage.ContextInfo
+	case msg.AudioMessage != nil:
+		contextInfo = &msg.AudioMessage.ContextInfo
+	case msg.DocumentMessage != nil:
+		contextInfo = &msg.DocumentMessage.ContextInfo
+	case msg.StickerMessage != nil:
+		contextInfo = &msg.StickerMessage.ContextInfo
+	case msg.ButtonsMessage != nil:
+		contextInfo = &msg.ButtonsMessage.ContextInfo
+	case msg.ListMessage != nil:
+		contextInfo = &msg.ListMessage.ContextInfo
+	case msg.PollCreationMessage != nil:
+		contextInfo = &msg.PollCreationMessage.ContextInfo
+	default:
+		return msg, nil
+	}
+	if *contextInfo == nil {
+		*contextInfo = &waE2E.ContextInfo{}
+	}
+	return msg, *contextInfo
+}
@@ -0,0 +1,174 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mdp/qrterminal/v3"
+	"go.mau.fi/whatsmeow"
+)
+
+// События привязки устройства по QR коду
+const (
+	// QREventCode - новый QR код, предыдущий больше не действует
+	QREventCode = "code"
+	// QREventSuccess - QR код отсканирован, устройство привязано
+	QREventSuccess = "success"
+	// QREventTimeout - коды закончились без сканирования, привязку нужно начать заново (StartPairing)
+	QREventTimeout = "timeout"
+	// QREventError - привязка прервана ошибкой
+	QREventError = "error"
+)
+
+// ErrAlreadyPaired - устройство уже привязано, QR код не нужен
+var ErrAlreadyPaired = errors.New("клиент уже авторизован")
+
+// QREvent - состояние привязки устройства по QR коду
+type QREvent struct {
+	Event string `json:"event"`
+	// Code - содержимое QR кода (для QREventCode)
+	Code string `json:"code,omitempty"`
+	// ExpiresAt - время, когда WhatsApp сменит код
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// pairing - текущая привязка устройства и подписчики на ее события
+type pairing struct {
+	mutex       sync.Mutex
+	active      bool
+	last        QREvent
+	subscribers map[chan QREvent]struct{}
+}
+
+// StartPairing переводит клиента в режим привязки: WhatsApp выдает QR коды, которые
+// сменяются примерно каждые 20 секунд (SubscribeQR). Если привязка уже идет, ничего не делает
+func (c *Client) StartPairing() error {
+	if c.client == nil {
+		return fmt.Errorf("клиент не инициализирован")
+	}
+	if c.IsAuthorized() {
+		return ErrAlreadyPaired
+	}
+
+	c.pairing.mutex.Lock()
+	defer c.pairing.mutex.Unlock()
+	if c.pairing.active {
+		return nil
+	}
+
+	// QR канал можно получить только до подключения
+	c.client.Disconnect()
+	qrChan, err := c.client.GetQRChannel(context.Background())
+	if err != nil {
+		return fmt.Errorf("ошибка получения QR кода: %v", err)
+	}
+	if err := c.client.Connect(); err != nil {
+		return fmt.Errorf("ошибка подключения: %v", err)
+	}
+	c.pairing.active = true
+	c.pairing.last = QREvent{}
+	go c.watchPairing(qrChan)
+	return nil
+}
+
+// pairAfterLogout переводит клиента в режим привязки после завершения сессии с телефона
+func (c *Client) pairAfterLogout() {
+	// whatsmeow удаляет сессию из базы параллельно с отправкой события LoggedOut
+	for i := 0; i < 50 && c.IsAuthorized(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := c.StartPairing(); err != nil {
+		Logger.Errorf("Ошибка перехода в режим привязки: %v", err)
+		return
+	}
+	Logger.Info("Ожидание повторной привязки устройства по QR коду")
+}
+
+// watchPairing пересылает события QR канала подписчикам и выводит коды в лог
+func (c *Client) watchPairing(qrChan <-chan whatsmeow.QRChannelItem) {
+	for item := range qrChan {
+		event := QREvent{Event: item.Event}
+		switch item.Event {
+		case whatsmeow.QRChannelEventCode:
+			event.Code = item.Code
+			event.ExpiresAt = time.Now().Add(item.Timeout)
+			Logger.Info("Сканируйте QR код:")
+			qrterminal.GenerateHalfBlock(item.Code, qrterminal.L, Logger.Out)
+		case QREventSuccess:
+			Logger.Info("QR код отсканирован! Авторизация завершена.")
+			if c.OnPaired != nil {
+				c.OnPaired()
+			}
+		case QREventTimeout:
+			Logger.Warn("⌛ QR код не отсканирован вовремя, начните привязку заново (POST /qr/restart)")
+		default:
+			event.Event = QREventError
+			event.Error = item.Event
+			if item.Error != nil {
+				event.Error = item.Error.Error()
+			}
+			Logger.Errorf("❌ Ошибка привязки устройства: %s", event.Error)
+		}
+		c.publishQR(event)
+	}
+}
+
+// publishQR запоминает событие привязки и рассылает его подписчикам
+func (c *Client) publishQR(event QREvent) {
+	c.pairing.mutex.Lock()
+	defer c.pairing.mutex.Unlock()
+	c.pairing.last = event
+	if event.Event != QREventCode {
+		// Привязка завершена, StartPairing может начать новую
+		c.pairing.active = false
+	}
+	for subscriber := range c.pairing.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			// Медленный подписчик пропускает событие, следующий код придет через несколько секунд
+		}
+	}
+}
+
+// SubscribeQR подписывает на события привязки. Первым событием приходит текущее состояние,
+// если код еще действует. Подписку нужно закрыть вызовом unsubscribe
+func (c *Client) SubscribeQR() (<-chan QREvent, func()) {
+	events := make(chan QREvent, 4)
+
+	c.pairing.mutex.Lock()
+	if c.pairing.subscribers == nil {
+		c.pairing.subscribers = make(map[chan QREvent]struct{})
+	}
+	c.pairing.subscribers[events] = struct{}{}
+	if current, ok := c.currentQRLocked(); ok {
+		events <- current
+	}
+	c.pairing.mutex.Unlock()
+
+	return events, func() {
+		c.pairing.mutex.Lock()
+		delete(c.pairing.subscribers, events)
+		c.pairing.mutex.Unlock()
+	}
+}
+
+// CurrentQR возвращает действующий QR код, если идет привязка
+func (c *Client) CurrentQR() (QREvent, bool) {
+	c.pairing.mutex.Lock()
+	defer c.pairing.mutex.Unlock()
+	return c.currentQRLocked()
+}
+
+// currentQRLocked возвращает действующий QR код. Вызывается под pairing.mutex
+func (c *Client) currentQRLocked() (QREvent, bool) {
+	last := c.pairing.last
+	if !c.pairing.active || last.Event != QREventCode || time.Now().After(last.ExpiresAt) {
+		return QREvent{}, false
+	}
+	return last, true
+}
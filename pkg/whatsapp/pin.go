@@ -0,0 +1,50 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	waTypes "go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// PinMessage закрепляет собственное сообщение в чате на duration, реализует scheduler.MessagePinner
+func (c *Client) PinMessage(ctx context.Context, chatName, chatJID, messageID string, duration time.Duration) error {
+	return c.sendPin(ctx, chatName, chatJID, messageID, waE2E.PinInChatMessage_PIN_FOR_ALL, duration)
+}
+
+// UnpinMessage открепляет собственное сообщение в чате, реализует scheduler.MessagePinner
+func (c *Client) UnpinMessage(ctx context.Context, chatName, chatJID, messageID string) error {
+	return c.sendPin(ctx, chatName, chatJID, messageID, waE2E.PinInChatMessage_UNPIN_FOR_ALL, 0)
+}
+
+// sendPin отправляет в чат служебное сообщение закрепления или открепления
+func (c *Client) sendPin(ctx context.Context, chatName, chatJID, messageID string,
+	pinType waE2E.PinInChatMessage_Type, duration time.Duration) error {
+	if c.client == nil {
+		return fmt.Errorf("клиент не инициализирован")
+	}
+	targetJID, err := c.findChat(chatName, chatJID)
+	if err != nil {
+		return err
+	}
+
+	msg := &waE2E.Message{
+		PinInChatMessage: &waE2E.PinInChatMessage{
+			Key:               c.client.BuildMessageKey(targetJID, waTypes.EmptyJID, waTypes.MessageID(messageID)),
+			Type:              pinType.Enum(),
+			SenderTimestampMS: proto.Int64(time.Now().UnixMilli()),
+		},
+	}
+	if duration > 0 {
+		msg.MessageContextInfo = &waE2E.MessageContextInfo{
+			MessageAddOnDurationInSecs: proto.Uint32(uint32(duration.Seconds())),
+		}
+	}
+	if _, err := c.client.SendMessage(ctx, targetJID, msg); err != nil {
+		return fmt.Errorf("ошибка закрепления сообщения: %v", err)
+	}
+	return nil
+}
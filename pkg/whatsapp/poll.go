@@ -0,0 +1,55 @@
+package whatsapp
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+// handlePollVote расшифровывает голос в опросе, отправленном планировщиком, и сохраняет его.
+// Голоса в чужих опросах игнорируются
+func (c *Client) handlePollVote(evt *events.Message) {
+	if c.storage == nil {
+		return
+	}
+	messageID := evt.Message.GetPollUpdateMessage().GetPollCreationMessageKey().GetID()
+	poll, err := c.storage.GetPoll(messageID)
+	if errors.Is(err, scheduler.ErrPollNotFound) {
+		return
+	}
+	if err != nil {
+		Logger.Warnf("Ошибка чтения опроса %s: %v", messageID, err)
+		return
+	}
+
+	vote, err := c.client.DecryptPollVote(context.Background(), evt)
+	if err != nil {
+		Logger.Warnf("Не удалось расшифровать голос в опросе %s: %v", messageID, err)
+		return
+	}
+	// Голос содержит SHA-256 выбранных вариантов, сопоставляем их с вариантами опроса
+	hashes := map[[sha256.Size]byte]string{}
+	for _, option := range poll.Options {
+		hashes[sha256.Sum256([]byte(option))] = option
+	}
+	selected := []string{}
+	for _, hash := range vote.GetSelectedOptions() {
+		if len(hash) != sha256.Size {
+			continue
+		}
+		if option, ok := hashes[[sha256.Size]byte(hash)]; ok {
+			selected = append(selected, option)
+		}
+	}
+
+	voter := evt.Info.Sender.ToNonAD().String()
+	if err := c.storage.RecordPollVote(messageID, voter, selected, evt.Info.Timestamp); err != nil {
+		Logger.Warnf("Ошибка сохранения голоса в опросе %s: %v", messageID, err)
+		return
+	}
+	Logger.Infof("🗳️ Голос %s в опросе %s: %v", voter, messageID, selected)
+}
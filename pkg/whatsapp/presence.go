@@ -0,0 +1,35 @@
+package whatsapp
+
+import (
+	"fmt"
+
+	waTypes "go.mau.fi/whatsmeow/types"
+)
+
+// SubscribePresence подписывается на присутствие личного чата (один раз за время работы)
+// и возвращает его JID, реализует scheduler.PresenceSubscriber
+func (c *Client) SubscribePresence(chatName string) (string, error) {
+	jid, err := c.resolveChat(chatName)
+	if err != nil {
+		return "", err
+	}
+	if jid.Server != waTypes.DefaultUserServer {
+		return "", fmt.Errorf("'%s' не является личным чатом", chatName)
+	}
+
+	c.presenceMutex.Lock()
+	defer c.presenceMutex.Unlock()
+
+	if c.subscribed[jid] {
+		return jid.String(), nil
+	}
+	// Без собственного статуса "в сети" WhatsApp не присылает присутствие других пользователей
+	if err := c.client.SendPresence(waTypes.PresenceAvailable); err != nil {
+		return "", fmt.Errorf("не удалось подписаться на присутствие '%s': %v", chatName, err)
+	}
+	if err := c.client.SubscribePresence(jid); err != nil {
+		return "", fmt.Errorf("не удалось подписаться на присутствие '%s': %v", chatName, err)
+	}
+	c.subscribed[jid] = true
+	return jid.String(), nil
+}
@@ -0,0 +1,146 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	waTypes "go.mau.fi/whatsmeow/types"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+// checkNumbersBatch - сколько номеров проверяется одним запросом к серверу WhatsApp
+const checkNumbersBatch = 50
+
+// ResolveChat возвращает все контакты и группы, подходящие под название чата: JID, имя контакта
+// или группы, либо номер телефона. Реализует scheduler.ChatResolver
+func (c *Client) ResolveChat(chatName string) ([]scheduler.ChatCandidate, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("клиент не инициализирован")
+	}
+	chatName = strings.TrimSpace(chatName)
+	Logger.Debugf("Ищем в контактах: %s", chatName)
+
+	candidates := []scheduler.ChatCandidate{}
+	contacts, err := c.client.Store.Contacts.GetAllContacts(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения контактов: %v", err)
+	}
+	for jid, contact := range contacts {
+		if jid.String() == chatName || contact.FullName == chatName {
+			candidates = append(candidates, contactCandidate(jid, contact))
+		}
+	}
+
+	groups, err := c.client.GetJoinedGroups()
+	if err != nil {
+		// Без групп ищем только среди контактов
+		Logger.Warnf("Ошибка получения групп: %v", err)
+	}
+	for _, group := range groups {
+		if group.JID.String() == chatName || group.Name == chatName {
+			candidates = append(candidates, scheduler.ChatCandidate{
+				JID:          group.JID.String(),
+				Kind:         "group",
+				Name:         group.Name,
+				Participants: len(group.Participants),
+			})
+		}
+	}
+
+	// Точное совпадение JID однозначно
+	for _, candidate := range candidates {
+		if candidate.JID == chatName {
+			return []scheduler.ChatCandidate{candidate}, nil
+		}
+	}
+	if len(candidates) == 0 {
+		if jid, ok := parseChatJID(chatName); ok {
+			return []scheduler.ChatCandidate{contactCandidate(jid, waTypes.ContactInfo{})}, nil
+		}
+	}
+	slices.SortFunc(candidates, func(a, b scheduler.ChatCandidate) int {
+		return strings.Compare(a.JID, b.JID)
+	})
+	return candidates, nil
+}
+
+// CheckNumbers проверяет, зарегистрированы ли номера в WhatsApp, по checkNumbersBatch номеров за запрос.
+// Реализует scheduler.NumberChecker
+func (c *Client) CheckNumbers(phones []string) (map[string]string, error) {
+	if c.client == nil || !c.client.IsConnected() {
+		return nil, fmt.Errorf("нет подключения к WhatsApp")
+	}
+	registered := map[string]string{}
+	for batch := range slices.Chunk(phones, checkNumbersBatch) {
+		query := make([]string, len(batch))
+		for i, phone := range batch {
+			query[i] = "+" + phone
+		}
+		results, err := c.client.IsOnWhatsApp(query)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка проверки номеров: %v", err)
+		}
+		for _, result := range results {
+			if result.IsIn {
+				registered[strings.TrimPrefix(result.Query, "+")] = result.JID.String()
+			}
+		}
+	}
+	return registered, nil
+}
+
+// findChat находит JID чата: сохраненный chatJID или единственный чат с названием chatName.
+// Несколько подходящих чатов - ошибка scheduler.ErrAmbiguousChat, а не первый найденный
+func (c *Client) findChat(chatName, chatJID string) (waTypes.JID, error) {
+	if chatJID != "" {
+		jid, err := waTypes.ParseJID(chatJID)
+		if err != nil {
+			return waTypes.JID{}, fmt.Errorf("неверный JID чата '%s'", chatJID)
+		}
+		return jid, nil
+	}
+
+	candidates, err := c.ResolveChat(chatName)
+	if err != nil {
+		return waTypes.JID{}, err
+	}
+	switch len(candidates) {
+	case 0:
+		Logger.Debugf("Контакты или группы не найдены по имени: %s", chatName)
+		return waTypes.JID{}, fmt.Errorf("%w: '%s'. Убедитесь, что указали правильное имя чата или номер телефона", scheduler.ErrChatNotFound, chatName)
+	case 1:
+		return waTypes.ParseJID(candidates[0].JID)
+	default:
+		return waTypes.JID{}, scheduler.AmbiguousChatError(chatName, candidates)
+	}
+}
+
+// parseChatJID разбирает JID (номер@s.whatsapp.net, группа@g.us) или номер телефона из 10-15 цифр
+func parseChatJID(value string) (waTypes.JID, bool) {
+	if strings.Contains(value, "@") {
+		jid, err := waTypes.ParseJID(value)
+		return jid, err == nil && jid.User != ""
+	}
+	if len(value) < 10 || len(value) > 15 || strings.ContainsFunc(value, func(r rune) bool { return r < '0' || r > '9' }) {
+		return waTypes.JID{}, false
+	}
+	return waTypes.NewJID(value, waTypes.DefaultUserServer), true
+}
+
+// contactCandidate описывает контакт для выбора среди одноименных
+func contactCandidate(jid waTypes.JID, contact waTypes.ContactInfo) scheduler.ChatCandidate {
+	candidate := scheduler.ChatCandidate{
+		JID:          jid.String(),
+		Kind:         "contact",
+		Name:         contact.FullName,
+		PushName:     contact.PushName,
+		BusinessName: contact.BusinessName,
+	}
+	if jid.Server == waTypes.DefaultUserServer {
+		candidate.Phone = "+" + jid.User
+	}
+	return candidate
+}
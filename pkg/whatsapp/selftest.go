@@ -0,0 +1,36 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+// CheckSession проверяет, что устройство привязано и сессия не завершена (scheduler.SessionChecker)
+func (c *Client) CheckSession() error {
+	if !c.IsAuthorized() {
+		return ErrNotAuthorized
+	}
+	return nil
+}
+
+// CheckConnection проверяет подключение запросом настроек приватности к серверу WhatsApp
+// (scheduler.SessionChecker)
+func (c *Client) CheckConnection(ctx context.Context) error {
+	if !c.IsConnected() {
+		return scheduler.ErrDisconnected
+	}
+	if _, err := c.client.TryFetchPrivacySettings(ctx, true); err != nil {
+		return fmt.Errorf("сервер WhatsApp не ответил: %v", err)
+	}
+	return nil
+}
+
+// OwnJID возвращает JID собственного аккаунта без номера устройства (scheduler.SessionChecker)
+func (c *Client) OwnJID() string {
+	if !c.IsAuthorized() {
+		return ""
+	}
+	return c.client.Store.ID.ToNonAD().String()
+}
@@ -0,0 +1,70 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+// buildVoiceNote загружает звук как голосовое сообщение (PTT). С ffmpeg громкость нормализуется,
+// а длительность и волна заполняются, как у записи с телефона; без него отправляется только OGG
+// как есть. Голосовые сообщения не кэшируются: звук обрабатывается при каждой отправке
+func (c *Client) buildVoiceNote(ctx context.Context, media *scheduler.MediaAttachment) (*waE2E.Message, error) {
+	var data []byte
+	var mimeType string
+	if media.MediaID != "" {
+		if c.storage == nil {
+			return nil, fmt.Errorf("медиатека недоступна")
+		}
+		asset, err := c.storage.GetMedia(media.MediaID)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = os.ReadFile(asset.Path()); err != nil {
+			return nil, fmt.Errorf("ошибка чтения файла медиатеки '%s': %v", asset.ID, err)
+		}
+		mimeType = asset.MimeType
+	} else {
+		var err error
+		if data, mimeType, _, err = attachmentData(ctx, media); err != nil {
+			return nil, err
+		}
+	}
+
+	voice := scheduler.VoiceNote{Data: data}
+	if scheduler.TranscodingEnabled() {
+		var err error
+		if voice, err = scheduler.PrepareVoiceNote(ctx, data); err != nil {
+			return nil, err
+		}
+	} else if !strings.HasSuffix(strings.SplitN(mimeType, ";", 2)[0], "/ogg") {
+		return nil, fmt.Errorf("голосовое сообщение в формате %s требует ffmpeg, без него принимается только OGG/Opus", mimeType)
+	}
+
+	uploaded, err := c.client.Upload(ctx, voice.Data, whatsmeow.MediaAudio)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки вложения: %v", err)
+	}
+	audio := &waE2E.AudioMessage{
+		PTT:           proto.Bool(true),
+		Mimetype:      proto.String(scheduler.VoiceNoteMimeType),
+		URL:           proto.String(uploaded.URL),
+		DirectPath:    proto.String(uploaded.DirectPath),
+		MediaKey:      uploaded.MediaKey,
+		FileEncSHA256: uploaded.FileEncSHA256,
+		FileSHA256:    uploaded.FileSHA256,
+		FileLength:    proto.Uint64(uploaded.FileLength),
+	}
+	if voice.Seconds > 0 {
+		audio.Seconds = proto.Uint32(voice.Seconds)
+		audio.Waveform = voice.Waveform
+	}
+	return &waE2E.Message{AudioMessage: audio}, nil
+}
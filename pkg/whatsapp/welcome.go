@@ -0,0 +1,65 @@
+package whatsapp
+
+import (
+	"fmt"
+	"strings"
+
+	waTypes "go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"whatsapp-scheduler/pkg/scheduler"
+)
+
+// handleGroupJoin передает в OnGroupJoin новых участников группы, пропуская собственный аккаунт
+func (c *Client) handleGroupJoin(evt *events.GroupInfo) {
+	var own, ownLID waTypes.JID
+	if c.client.Store.ID != nil {
+		own = c.client.Store.ID.ToNonAD()
+	}
+	ownLID = c.client.Store.LID.ToNonAD()
+
+	members := []string{}
+	for _, jid := range evt.Join {
+		member := jid.ToNonAD()
+		if member == own || member == ownLID {
+			continue
+		}
+		members = append(members, member.String())
+	}
+	if len(members) > 0 {
+		Logger.Infof("👥 В группу %s вступили участники: %s", evt.JID, strings.Join(members, ", "))
+		c.OnGroupJoin(evt.JID.String(), members)
+	}
+}
+
+// handleGroupMembership передает в OnGroupMembership изменение состава группы
+func (c *Client) handleGroupMembership(evt *events.GroupInfo) {
+	change := scheduler.GroupMembershipChange{
+		GroupJID:  evt.JID.String(),
+		Joined:    nonADStrings(evt.Join),
+		Left:      nonADStrings(evt.Leave),
+		Timestamp: evt.Timestamp,
+	}
+	if evt.Sender != nil {
+		change.ActorJID = evt.Sender.ToNonAD().String()
+	}
+	c.OnGroupMembership(change)
+}
+
+// nonADStrings возвращает JID без номера устройства в виде строк
+func nonADStrings(jids []waTypes.JID) []string {
+	values := make([]string, len(jids))
+	for i, jid := range jids {
+		values[i] = jid.ToNonAD().String()
+	}
+	return values
+}
+
+// ParseGroupJID разбирает JID группы (идентификатор@g.us)
+func ParseGroupJID(value string) (waTypes.JID, error) {
+	jid, err := waTypes.ParseJID(strings.TrimSpace(value))
+	if err != nil || jid.User == "" || jid.Server != waTypes.GroupServer {
+		return waTypes.JID{}, fmt.Errorf("неверный JID группы '%s', пример: 120363000000000000@g.us", value)
+	}
+	return jid, nil
+}